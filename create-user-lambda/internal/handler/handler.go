@@ -2,52 +2,108 @@ package handler
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/go-playground/validator/v10"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/entities"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type Handle interface {
+	// HandleRequest decodes the user of a proxy integration event, which
+	// carries the authorizer context, and hands it to HandleCreateUser.
+	HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 	HandleCreateUser(ctx context.Context, req entities.UserReq) (events.APIGatewayProxyResponse, error)
 }
 
+const operationCreateUser = "HandleCreateUser"
+
 type handleImpl struct {
-	srv service.Service
-	log logger.Logger
-	v   *validator.Validate
+	srv     service.Service
+	log     logging.Logger
+	metrics metrics.Metrics
+	v       *validator.Validate
 }
 
-func New(srv service.Service, log logger.Logger) Handle {
+func New(srv service.Service, log logging.Logger, m metrics.Metrics) Handle {
 	return &handleImpl{
-		srv: srv,
-		log: log,
-		v:   validator.New(),
+		srv:     srv,
+		log:     log,
+		metrics: m,
+		v:       validator.New(),
+	}
+}
+
+func (h *handleImpl) HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx = logging.WithAPIGatewayRequest(ctx, req)
+	ctx = tracing.WithAPIGatewayRequest(ctx, req)
+
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return h.badRequest(ctx, err), nil
+		}
+		body = decoded
+	}
+
+	var user entities.UserReq
+	if err := json.Unmarshal(body, &user); err != nil {
+		return h.badRequest(ctx, err), nil
+	}
+
+	return h.HandleCreateUser(ctx, user)
+}
+
+func (h *handleImpl) badRequest(ctx context.Context, err error) events.APIGatewayProxyResponse {
+	h.log.WithContext(ctx).Errorf("error decoding body: %v", err)
+	h.metrics.Increment(operationCreateUser, metrics.MetricValidationFailure)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusBadRequest,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: `{"code": "bad_request", "message": "body is not a valid user"}`,
 	}
 }
 
 func (h *handleImpl) HandleCreateUser(ctx context.Context, req entities.UserReq) (events.APIGatewayProxyResponse, error) {
+	defer h.metrics.HandlerLatency(operationCreateUser, time.Now())
+	h.metrics.ColdStart(operationCreateUser)
+
+	ctx, span := tracing.StartServer(ctx, operationCreateUser)
+	defer span.End()
+
+	log := h.log.WithContext(ctx)
 	var res events.APIGatewayProxyResponse
-	h.log.Debug("event received")
+	log.Debug("event received")
 
-	h.log.Debug("validating request")
+	log.Debug("validating request")
 	if err := h.v.StructCtx(ctx, req); err != nil {
-		h.log.Errorf("error occurs validating struct: %v", err)
+		log.Errorf("error occurs validating struct: %s", validationSummary(err))
+		tracing.Error(span, err)
 		return h.getErrorResponse(err), nil
 	}
 
-	h.log.Debug("creating user")
+	log.Debug("creating user")
 	err := h.srv.CreateUser(ctx, req)
 	if err != nil {
-		h.log.Errorf("error creating user: %v", err)
+		log.Errorf("error creating user: %v", err)
+		tracing.Error(span, err)
 		return h.getErrorResponse(err), nil
 	}
 
-	h.log.Info("event processed")
+	log.Info("event processed")
 	res = events.APIGatewayProxyResponse{StatusCode: http.StatusCreated}
 	return res, nil
 }
@@ -59,9 +115,14 @@ func (h *handleImpl) getErrorResponse(err error) events.APIGatewayProxyResponse
 	if errors.As(err, &ve) {
 		statusCode = http.StatusBadRequest
 		data = fmt.Sprintf(`{"code": "bad_request", "message": "%s"}`, ve)
+		h.metrics.Increment(operationCreateUser, metrics.MetricValidationFailure)
+	} else if errors.Is(err, clientopts.ErrUnavailable) {
+		statusCode = http.StatusServiceUnavailable
+		data = `{"code": "unavailable", "message": "service unavailable, retry later"}`
 	} else {
 		statusCode = http.StatusConflict
 		data = fmt.Sprintf(`{"code": "conflict", "message": "%s"}`, err)
+		h.metrics.Increment(operationCreateUser, metrics.MetricConflict)
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -72,3 +133,19 @@ func (h *handleImpl) getErrorResponse(err error) events.APIGatewayProxyResponse
 		Body: data,
 	}
 }
+
+// validationSummary lists the failing fields and rules without the rejected
+// values, which may contain personal data.
+func validationSummary(err error) string {
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		return err.Error()
+	}
+
+	fields := make([]string, 0, len(ve))
+	for _, fe := range ve {
+		fields = append(fields, fmt.Sprintf("%s(%s)", fe.Namespace(), fe.Tag()))
+	}
+
+	return strings.Join(fields, ", ")
+}
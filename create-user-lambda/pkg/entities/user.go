@@ -1,40 +1,32 @@
 package entities
 
 import (
-	"fmt"
-	"github.com/google/uuid"
-	"github.com/ricardojonathanromero/go-utilities/environment"
-	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
 	"time"
 )
 
+// UserReq is the body of a create user request.
 type UserReq struct {
-	ID        string    `json:"-"`
-	Name      string    `json:"name" validate:"required,min=3,max=50"`
-	Lastname  string    `json:"lastname" validate:"required,min=3,max=50"`
-	Age       int32     `json:"age" validate:"required,gt=0,lt=99"`
-	Email     string    `json:"email" validate:"required,email"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
+	Name     string `json:"name" validate:"required,min=3,max=50" pii:"mask"`
+	Lastname string `json:"lastname" validate:"required,min=3,max=50" pii:"mask"`
+	Age      int32  `json:"age" validate:"required,gt=0,lt=99"`
+	Email    string `json:"email" validate:"required,email" pii:"mask"`
 }
 
-const (
-	envLocation     = "TZ_LOCATION"
-	defaultLocation = "America/Los_Angeles"
-)
-
-func (u *UserReq) ToDB() (*models.UserDB, error) {
-	u.ID = uuid.NewString()
-
-	tz := environment.GetEnv(envLocation, defaultLocation)
-	lc, err := time.LoadLocation(tz)
-	if err != nil {
-		return nil, fmt.Errorf("location %s not found: %w", tz, err)
+// ToDomain returns the new user of the request, the id and time come from
+// the service. The timestamps are in UTC, responses render them in the
+// timezone of the request. New users wait for the verification of their
+// email.
+func (u UserReq) ToDomain(id string, now time.Time) domain.User {
+	now = now.UTC()
+	return domain.User{
+		ID:        id,
+		Name:      u.Name,
+		Lastname:  u.Lastname,
+		Age:       u.Age,
+		Email:     u.Email,
+		Status:    domain.StatusPendingVerification,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
-
-	now := time.Now().In(lc)
-	u.CreatedAt = now
-	u.UpdatedAt = now
-
-	return (*models.UserDB)(u), nil
 }
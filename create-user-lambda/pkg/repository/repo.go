@@ -3,50 +3,76 @@ package repository
 import (
 	"context"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/ricardojonathanromero/go-utilities/logger"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"time"
 )
 
 type Repository interface {
 	InsertUser(ctx context.Context, user any) error
 }
 
+const operationInsertUser = "InsertUser"
+
 type repoImpl struct {
 	tableName string
-	client    *dynamodb.Client
-	log       logger.Logger
+	client    dynamodbapi.ItemPutter
+	log       logging.Logger
+	metrics   metrics.Metrics
+	opts      clientopts.Options
+	enc       fieldcrypt.Encryptor
 }
 
-func New(tableName string, client *dynamodb.Client, log logger.Logger) Repository {
+func New(tableName string, client dynamodbapi.ItemPutter, log logging.Logger, m metrics.Metrics, opts clientopts.Options, enc fieldcrypt.Encryptor) Repository {
 	return &repoImpl{
 		tableName: tableName,
 		client:    client,
 		log:       log,
+		metrics:   m,
+		opts:      opts,
+		enc:       enc,
 	}
 }
 
 func (repo *repoImpl) InsertUser(ctx context.Context, user any) error {
-	repo.log.Debug("marshalling input")
-	av, err := attributevalue.MarshalMap(user)
+	log := repo.log.WithContext(ctx)
+	log.Debug("marshalling input")
+	av, err := fieldcrypt.MarshalMap(ctx, repo.enc, user)
 	if err != nil {
-		repo.log.Errorf("error marshalling input: %v", err)
+		log.Errorf("error marshalling input: %v", err)
 		return err
 	}
 
-	repo.log.Debug("sending input")
+	log.Debug("sending input")
 	req := &dynamodb.PutItemInput{
-		Item:                av,
-		TableName:           aws.String(repo.tableName),
-		ConditionExpression: aws.String("attribute_not_exists(Id)"),
+		Item:                   av,
+		TableName:              aws.String(repo.tableName),
+		ConditionExpression:    aws.String("attribute_not_exists(Id)"),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
 	}
 
-	_, err = repo.client.PutItem(ctx, req)
+	callCtx, cancel, err := repo.opts.Context(ctx, operationInsertUser)
 	if err != nil {
-		repo.log.Errorf("error put item: %v", err)
+		log.Errorf("error put item: %v", err)
 		return err
 	}
+	defer cancel()
+
+	start := time.Now()
+	out, err := repo.client.PutItem(callCtx, req, tracing.DynamoDB, repo.opts.DynamoDB)
+	repo.metrics.RepositoryLatency(operationInsertUser, start)
+	if err != nil {
+		log.Errorf("error put item: %v", err)
+		return repo.opts.Err(operationInsertUser, err)
+	}
 
-	repo.log.Debug("item inserted")
+	repo.metrics.ConsumedCapacity(operationInsertUser, out.ConsumedCapacity)
+	log.Debug("item inserted")
 	return nil
 }
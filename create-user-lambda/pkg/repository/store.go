@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+)
+
+type storeImpl struct {
+	store userstore.UserRepository
+}
+
+// NewFromStore inserts the users through a userstore backend, used when
+// USER_STORE selects another database than the DynamoDB table.
+func NewFromStore(store userstore.UserRepository) Repository {
+	return &storeImpl{store: store}
+}
+
+func (repo *storeImpl) InsertUser(ctx context.Context, user any) error {
+	u, ok := user.(*models.UserDB)
+	if !ok {
+		return fmt.Errorf("unsupported user type %T", user)
+	}
+	return repo.store.Insert(ctx, u)
+}
@@ -2,9 +2,15 @@ package service
 
 import (
 	"context"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/entities"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ids"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
 )
 
 type Service interface {
@@ -12,32 +18,50 @@ type Service interface {
 }
 
 type serviceImpl struct {
-	repo repository.Repository
-	log  logger.Logger
+	repo     repository.Repository
+	verifier verification.Verifier
+	log      logging.Logger
+	clock    clock.Clock
+	ids      ids.IDGenerator
 }
 
-func New(repo repository.Repository, log logger.Logger) Service {
+func New(repo repository.Repository, verifier verification.Verifier, log logging.Logger, clk clock.Clock, gen ids.IDGenerator) Service {
 	return &serviceImpl{
-		repo: repo,
-		log:  log,
+		repo:     repo,
+		verifier: verifier,
+		log:      log,
+		clock:    clk,
+		ids:      gen,
 	}
 }
 
 func (s *serviceImpl) CreateUser(ctx context.Context, req entities.UserReq) error {
-	s.log.Debug("converting req model into db model")
-	dbReq, err := req.ToDB()
-	if err != nil {
-		s.log.Errorf("error loading location: %v", err)
-		return err
+	ctx, span := tracing.Start(ctx, "CreateUser")
+	defer span.End()
+
+	log := s.log.WithContext(ctx)
+	log.Debug("converting req model into db model")
+	user := req.ToDomain(s.ids.NewID(), s.clock.Now())
+	// the creator owns the user, the other callers need the admin rights
+	if identity, ok := auth.FromContext(ctx); ok {
+		user.OwnerSubject = identity.Subject
 	}
 
-	s.log.Info("saving request")
-	err = s.repo.InsertUser(ctx, dbReq)
+	log.Info("saving request")
+	err := s.repo.InsertUser(ctx, models.UserFromDomain(user))
 	if err != nil {
-		s.log.Errorf("error inserting user: %v", err)
+		log.Errorf("error inserting user: %v", err)
+		tracing.Error(span, err)
 		return err
 	}
 
-	s.log.Info("record saved")
+	log.Info("record saved")
+
+	// the user exists once saved, an email that fails is sent again through
+	// the resend endpoint
+	if err = s.verifier.Issue(ctx, user); err != nil {
+		log.Errorf("error sending verification email: %v", err)
+		tracing.Error(span, err)
+	}
 	return nil
 }
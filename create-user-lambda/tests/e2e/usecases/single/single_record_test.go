@@ -8,13 +8,21 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/internal/handler"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/entities"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/repository"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ids"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/mailer"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/tests"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
 	"net/http"
 	"time"
 )
@@ -28,7 +36,7 @@ var (
 	port             int
 	dynamodbTestConn tests.DBSuite
 	conn             *dynamodb.Client
-	log              logger.Logger
+	log              logging.Logger
 )
 
 var _ = Describe("Single Record", func() {
@@ -37,9 +45,11 @@ var _ = Describe("Single Record", func() {
 	var ctx context.Context
 
 	BeforeEach(func() {
-		repo := repository.New(tableName, conn, log)
-		srv := service.New(repo, log)
-		hdl = handler.New(srv, log)
+		repo := repository.New(tableName, conn, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
+		verifier, err := verification.New(verification.NewMemoryStore(), mailer.NewNoop(), clock.New(), verification.Options{TTL: time.Hour, LinkURL: "http://localhost:3000/verify"})
+		Expect(err).To(BeNil())
+		srv := service.New(repo, verifier, log, clock.New(), ids.NewSequential("usr_"))
+		hdl = handler.New(srv, log, metrics.NewNoop())
 
 		lambdaCtx = &lambdacontext.LambdaContext{
 			AwsRequestID:       "awsRequestId1234",
@@ -90,6 +100,8 @@ var _ = Describe("Single Record", func() {
 				Expect(errUnmarshal).To(BeNil())
 				Expect(items).To(HaveLen(1))
 				Expect(items[0].Name).To(Equal("john"))
+				Expect(items[0].ID).To(Equal("usr_00000000-0000-7000-8000-000000000001"))
+				Expect(items[0].Status).To(Equal(string(domain.StatusPendingVerification)))
 				log.Debug("item exists as expected")
 			})
 		})
@@ -2,13 +2,19 @@ package handler_test
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambdacontext"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/internal/handler"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/entities"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
 	"github.com/stretchr/testify/mock"
 	"net/http"
 	"time"
@@ -27,14 +33,18 @@ var _ = Describe("Handler", func() {
 	var mockService *MockService
 	var lambdaCtx *lambdacontext.LambdaContext
 	var ctx context.Context
-	var log logger.Logger
+	var log logging.Logger
+	var sink *metrics.MemorySink
+	var m metrics.Metrics
 
 	appName := "create-user-lambda-handler-test"
 	logLevel := "debug"
 
 	BeforeEach(func() {
-		log = logger.NewLoggerWithOptions(logger.Opts{AppName: appName, Level: logLevel})
+		log = logging.New(logging.Opts{AppName: appName, Level: logLevel})
 		mockService = new(MockService)
+		sink = metrics.NewMemorySink()
+		m = metrics.New(appName, sink)
 		lambdaCtx = &lambdacontext.LambdaContext{
 			AwsRequestID:       "awsRequestId1234",
 			InvokedFunctionArn: "arn:aws:lambda:xxx",
@@ -65,7 +75,7 @@ var _ = Describe("Handler", func() {
 						Email:    "john.smith@test.com",
 					}
 
-					mockService.On("CreateUser", ctx, req).
+					mockService.On("CreateUser", mock.Anything, req).
 						Times(1).
 						Return(err)
 				})
@@ -73,10 +83,51 @@ var _ = Describe("Handler", func() {
 				It("can get 201 http code from response", func() {
 					defer cancel()
 
-					res, errRes := handler.New(mockService, log).HandleCreateUser(ctx, req)
+					res, errRes := handler.New(mockService, log, m).HandleCreateUser(ctx, req)
 					Expect(errRes).To(BeNil())
 					Expect(res).NotTo(BeNil())
 					Expect(res.StatusCode).To(Equal(http.StatusCreated))
+					Expect(sink.Values("HandleCreateUser", metrics.MetricHandlerLatency)).To(HaveLen(1))
+				})
+
+				It("records the handler span", func() {
+					defer cancel()
+
+					recorder := tracing.NewRecorder()
+
+					_, errRes := handler.New(mockService, log, m).HandleCreateUser(ctx, req)
+					Expect(errRes).To(BeNil())
+					Expect(tracing.SpanNames(recorder)).To(Equal([]string{"HandleCreateUser"}))
+				})
+
+				It("decodes the body of a proxy event", func() {
+					defer cancel()
+
+					body := `{"name": "John", "lastname": "Smith", "age": 30, "email": "john.smith@test.com"}`
+					res, errRes := handler.New(mockService, log, m).HandleRequest(ctx, events.APIGatewayProxyRequest{Body: body})
+					Expect(errRes).To(BeNil())
+					Expect(res.StatusCode).To(Equal(http.StatusCreated))
+				})
+
+				It("decodes a base64 encoded body", func() {
+					defer cancel()
+
+					body := base64.StdEncoding.EncodeToString([]byte(`{"name": "John", "lastname": "Smith", "age": 30, "email": "john.smith@test.com"}`))
+					res, errRes := handler.New(mockService, log, m).HandleRequest(ctx, events.APIGatewayProxyRequest{Body: body, IsBase64Encoded: true})
+					Expect(errRes).To(BeNil())
+					Expect(res.StatusCode).To(Equal(http.StatusCreated))
+				})
+			})
+
+			Context("receiving a body that is not a user", func() {
+				It("can get 400 http code from response", func() {
+					defer cancel()
+
+					res, errRes := handler.New(mockService, log, m).HandleRequest(ctx, events.APIGatewayProxyRequest{Body: `{"name": `})
+					Expect(errRes).To(BeNil())
+					Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+					Expect(sink.Sum("HandleCreateUser", metrics.MetricValidationFailure)).To(Equal(float64(1)))
+					mockService.AssertNotCalled(GinkgoT(), "CreateUser", mock.Anything, mock.Anything)
 				})
 			})
 
@@ -94,10 +145,11 @@ var _ = Describe("Handler", func() {
 				It("can get 201 http code from response", func() {
 					defer cancel()
 
-					res, errRes := handler.New(mockService, log).HandleCreateUser(ctx, req)
+					res, errRes := handler.New(mockService, log, m).HandleCreateUser(ctx, req)
 					Expect(errRes).To(BeNil())
 					Expect(res).NotTo(BeNil())
 					Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+					Expect(sink.Sum("HandleCreateUser", metrics.MetricValidationFailure)).To(Equal(float64(1)))
 				})
 			})
 
@@ -112,7 +164,7 @@ var _ = Describe("Handler", func() {
 						Email:    "john.smith@test.com",
 					}
 
-					mockService.On("CreateUser", ctx, req).
+					mockService.On("CreateUser", mock.Anything, req).
 						Times(1).
 						Return(errors.New("generic error"))
 				})
@@ -120,10 +172,37 @@ var _ = Describe("Handler", func() {
 				It("can get 409 http code from response", func() {
 					defer cancel()
 
-					res, errRes := handler.New(mockService, log).HandleCreateUser(ctx, req)
+					res, errRes := handler.New(mockService, log, m).HandleCreateUser(ctx, req)
 					Expect(errRes).To(BeNil())
 					Expect(res).NotTo(BeNil())
 					Expect(res.StatusCode).To(Equal(http.StatusConflict))
+					Expect(sink.Sum("HandleCreateUser", metrics.MetricConflict)).To(Equal(float64(1)))
+				})
+			})
+
+			When("dynamodb is not reachable within the invocation budget", func() {
+				var req entities.UserReq
+
+				BeforeEach(func() {
+					req = entities.UserReq{
+						Name:     "john",
+						Lastname: "Smith",
+						Age:      30,
+						Email:    "john.smith@test.com",
+					}
+
+					mockService.On("CreateUser", mock.Anything, req).
+						Times(1).
+						Return(fmt.Errorf("%w: InsertUser timed out", clientopts.ErrUnavailable))
+				})
+
+				It("can get 503 http code from response", func() {
+					defer cancel()
+
+					res, errRes := handler.New(mockService, log, m).HandleCreateUser(ctx, req)
+					Expect(errRes).To(BeNil())
+					Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+					Expect(sink.Sum("HandleCreateUser", metrics.MetricConflict)).To(BeZero())
 				})
 			})
 		})
@@ -1,111 +1,72 @@
 package entities_test
 
 import (
-	"errors"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/entities"
-	"os"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/testutil"
+	"time"
 )
 
 var _ = Describe("Entities", func() {
-	Context("marshal entity into db model", func() {
-		When("use default timezone location", func() {
-			When("declare user", func() {
-				user := &entities.UserReq{
-					Name:     "john",
-					Lastname: "smith",
-					Age:      30,
-					Email:    "john.smith@test.com",
-				}
+	Context("map the request into the domain user", func() {
+		var user *entities.UserReq
 
-				It("can marshal the entity", func() {
-					dbModel, err := user.ToDB()
-					Expect(err).To(BeNil())
-					Expect(dbModel).NotTo(BeNil())
-					Expect(dbModel).To(HaveExistingField("ID"))
-					Expect(dbModel).To(HaveExistingField("Name"))
-					Expect(dbModel).To(HaveExistingField("Lastname"))
-					Expect(dbModel).To(HaveExistingField("Age"))
-					Expect(dbModel).To(HaveExistingField("Email"))
-					Expect(dbModel).To(HaveExistingField("CreatedAt"))
-					Expect(dbModel).To(HaveExistingField("UpdatedAt"))
-					Expect(dbModel.ID).NotTo(BeEmpty())
-					Expect(dbModel.CreatedAt).NotTo(BeNil())
-					Expect(dbModel.UpdatedAt).NotTo(BeNil())
-				})
-			})
+		BeforeEach(func() {
+			user = &entities.UserReq{
+				Name:     "john",
+				Lastname: "smith",
+				Age:      30,
+				Email:    "john.smith@test.com",
+			}
 		})
 
-		When("set custom timezone location", func() {
-			When("declare user and valid timezone", func() {
-				var user *entities.UserReq
-				BeforeEach(func() {
-					user = &entities.UserReq{
-						Name:     "john",
-						Lastname: "smith",
-						Age:      30,
-						Email:    "john.smith@test.com",
-					}
-
-					err := os.Setenv("TZ_LOCATION", "America/Mexico_City")
-					Expect(err).To(BeNil())
-				})
-
-				AfterEach(func() {
-					val := os.Getenv("TZ_LOCATION")
-					if len(val) > 0 {
-						err := os.Unsetenv("TZ_LOCATION")
-						Expect(err).To(BeNil())
-					}
-				})
+		When("the time is in utc", func() {
+			It("can map the entity", func() {
+				now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+				domainUser := user.ToDomain("usr_00000000-0000-7000-8000-000000000001", now)
+				Expect(domainUser.ID).To(Equal("usr_00000000-0000-7000-8000-000000000001"))
+				Expect(domainUser.Name).To(Equal("john"))
+				Expect(domainUser.Lastname).To(Equal("smith"))
+				Expect(domainUser.Age).To(Equal(int32(30)))
+				Expect(domainUser.Email).To(Equal("john.smith@test.com"))
+				Expect(domainUser.CreatedAt).To(Equal(now))
+				Expect(domainUser.CreatedAt.Location()).To(Equal(time.UTC))
+				Expect(domainUser.UpdatedAt).To(Equal(domainUser.CreatedAt))
+				Expect(domainUser.Status).To(Equal(domain.StatusPendingVerification))
+			})
 
-				It("can marshal the entity", func() {
-					dbModel, err := user.ToDB()
-					Expect(err).To(BeNil())
-					Expect(dbModel).NotTo(BeNil())
-					Expect(dbModel).To(HaveExistingField("ID"))
-					Expect(dbModel).To(HaveExistingField("Name"))
-					Expect(dbModel).To(HaveExistingField("Lastname"))
-					Expect(dbModel).To(HaveExistingField("Age"))
-					Expect(dbModel).To(HaveExistingField("Email"))
-					Expect(dbModel).To(HaveExistingField("CreatedAt"))
-					Expect(dbModel).To(HaveExistingField("UpdatedAt"))
-					Expect(dbModel.ID).NotTo(BeEmpty())
-					Expect(dbModel.CreatedAt).NotTo(BeNil())
-					Expect(dbModel.UpdatedAt).NotTo(BeNil())
-				})
+			It("leaves the request untouched", func() {
+				before := *user
+				_ = user.ToDomain("usr_00000000-0000-7000-8000-000000000001", time.Now())
+				Expect(*user).To(Equal(before))
 			})
+		})
 
-			When("declare user and invalid timezone", func() {
-				var user *entities.UserReq
-				BeforeEach(func() {
-					user = &entities.UserReq{
-						Name:     "john",
-						Lastname: "smith",
-						Age:      30,
-						Email:    "john.smith@test.com",
-					}
+		When("the time is in another location", func() {
+			It("keeps the timestamps in utc", func() {
+				loc, err := time.LoadLocation("America/Mexico_City")
+				Expect(err).To(BeNil())
+				now := time.Date(2024, 5, 1, 4, 0, 0, 0, loc)
 
-					err := os.Setenv("TZ_LOCATION", "NotValid")
-					Expect(err).To(BeNil())
-				})
+				domainUser := user.ToDomain("usr_00000000-0000-7000-8000-000000000002", now)
+				Expect(domainUser.CreatedAt.Location()).To(Equal(time.UTC))
+				Expect(domainUser.CreatedAt).To(Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+				Expect(domainUser.UpdatedAt.Location()).To(Equal(time.UTC))
+			})
+		})
 
-				AfterEach(func() {
-					val := os.Getenv("TZ_LOCATION")
-					if len(val) > 0 {
-						err := os.Unsetenv("TZ_LOCATION")
-						Expect(err).To(BeNil())
-					}
-				})
+		When("every field of the request is set", func() {
+			It("maps all of them", func() {
+				var req entities.UserReq
+				testutil.Fill(&req)
 
-				It("can marshal the entity", func() {
-					dbModel, err := user.ToDB()
-					Expect(dbModel).To(BeNil())
-					Expect(err).NotTo(BeNil())
-					unwErr := errors.Unwrap(err)
-					Expect(unwErr.Error()).To(Equal("unknown time zone NotValid"))
-				})
+				domainUser := req.ToDomain("usr_00000000-0000-7000-8000-000000000001", time.Now())
+				Expect(testutil.UnmappedFields(req, domainUser)).To(BeEmpty())
+				// a new user has no status change to explain, its owner is the
+				// caller known to the service
+				Expect(testutil.ZeroFields(domainUser)).To(ConsistOf("StatusReason", "OwnerSubject"))
 			})
 		})
 	})
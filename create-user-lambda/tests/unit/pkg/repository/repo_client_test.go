@@ -0,0 +1,65 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi/mocks"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+var _ = Describe("Repository with a mocked client", func() {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var putter *mocks.ItemPutter
+	var repo repository.Repository
+
+	tableName := "my-table"
+	usr := models.UserDB{ID: "1", Name: "john", Lastname: "smith", Age: 30, Email: "john.smith@test.com"}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		putter = mocks.NewItemPutter(GinkgoT())
+		log := logging.New(logging.Opts{AppName: "create-user-lambda-repository-test", Level: "debug"})
+		repo = repository.New(tableName, putter, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("puts the item only when the id is new", func() {
+		putter.EXPECT().
+			PutItem(mock.Anything, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+				return aws.ToString(in.TableName) == tableName &&
+					aws.ToString(in.ConditionExpression) == "attribute_not_exists(Id)" &&
+					in.Item["Id"].(*types.AttributeValueMemberS).Value == "1"
+			}), mock.Anything).
+			Return(&dynamodb.PutItemOutput{}, nil).
+			Once()
+
+		Expect(repo.InsertUser(ctx, usr)).To(Succeed())
+	})
+
+	It("returns the conditional check failure of the client", func() {
+		putter.EXPECT().
+			PutItem(mock.Anything, mock.Anything, mock.Anything).
+			Return(nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}).
+			Once()
+
+		err := repo.InsertUser(ctx, usr)
+		var conditionErr *types.ConditionalCheckFailedException
+		Expect(errors.As(err, &conditionErr)).To(BeTrue())
+	})
+})
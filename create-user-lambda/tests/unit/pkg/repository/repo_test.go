@@ -14,8 +14,11 @@ import (
 	"github.com/jarcoal/httpmock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
 	"net/http"
 	"time"
@@ -50,7 +53,7 @@ func (u UnsupportedType) MarshalDynamoDBAttributeValue() (types.AttributeValue,
 
 var _ = Describe("Repository", func() {
 	var ctx context.Context
-	var log logger.Logger
+	var log logging.Logger
 	var conn *dynamodb.Client
 
 	appName := "create-user-lambda-repository-test"
@@ -62,7 +65,7 @@ var _ = Describe("Repository", func() {
 		var err error
 		// configure dynamodb local session
 		ctx = context.Background()
-		log = logger.NewLoggerWithOptions(logger.Opts{AppName: appName, Level: logLevel})
+		log = logging.New(logging.Opts{AppName: appName, Level: logLevel})
 		conn, err = getDBClientWithHttpHandler(dynamodbLocalURL)
 		Expect(err).To(BeNil())
 	})
@@ -83,7 +86,7 @@ var _ = Describe("Repository", func() {
 					result := `{}`
 					resp := httpmock.NewStringResponder(http.StatusOK, result)
 					httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
-					repo = repository.New(tableName, conn, log)
+					repo = repository.New(tableName, conn, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 				})
 
 				It("can be finish the process without any error", func() {
@@ -113,7 +116,7 @@ var _ = Describe("Repository", func() {
 						//result = `{"code":"ConditionalCheckFailedException","message":"The id set already exists"}`
 						//resp := httpmock.NewStringResponder(http.StatusBadRequest, result)
 						//httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
-						repo = repository.New(tableName, conn, log)
+						repo = repository.New(tableName, conn, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 					})
 
 					It("cannot be marshalled due to unsupported channel type", func() {
@@ -137,7 +140,7 @@ var _ = Describe("Repository", func() {
 						result := `{"code":"ConditionalCheckFailedException","message":"The id set already exists"}`
 						resp := httpmock.NewStringResponder(http.StatusBadRequest, result)
 						httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
-						repo = repository.New(tableName, conn, log)
+						repo = repository.New(tableName, conn, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 					})
 
 					It("cannot be marshalled due to unsupported channel type", func() {
@@ -182,10 +185,10 @@ var _ = Describe("Repository", func() {
 					result := `{}`
 					resp := httpmock.NewStringResponder(http.StatusOK, result)
 					httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
-					repo = repository.New(tableName, conn, log)
+					repo = repository.New(tableName, conn, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 				})
 
-				It("cannot send request by timeout", func() {
+				It("fails fast as unavailable without calling dynamodb", func() {
 					defer cancel()
 
 					time.Sleep(2 * time.Second) // sleep 2 secs
@@ -202,13 +205,8 @@ var _ = Describe("Repository", func() {
 
 					err := repo.InsertUser(ctx, usr)
 					Expect(err).NotTo(BeNil())
-
-					var oe *smithy.OperationError
-					ok := errors.As(err, &oe)
-					Expect(ok).To(BeTrue())
-
-					unerr := oe.Unwrap()
-					Expect(errors.Is(unerr, context.DeadlineExceeded)).To(BeTrue())
+					Expect(errors.Is(err, clientopts.ErrUnavailable)).To(BeTrue())
+					Expect(httpmock.GetTotalCallCount()).To(BeZero())
 				})
 			})
 		})
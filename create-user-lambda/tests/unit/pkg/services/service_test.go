@@ -5,11 +5,15 @@ import (
 	"errors"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/entities"
 	"github.com/ricardojonathanromero/lambda-golang-example/create-user-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ids"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
 	"github.com/stretchr/testify/mock"
-	"os"
 	"time"
 )
 
@@ -22,14 +26,41 @@ func (m *MockRepo) InsertUser(ctx context.Context, user any) error {
 	return args.Error(0)
 }
 
+type MockVerifier struct {
+	mock.Mock
+}
+
+func (m *MockVerifier) Issue(ctx context.Context, user domain.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockVerifier) Check(ctx context.Context, token string) (string, error) {
+	args := m.Called(ctx, token)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockVerifier) Consume(ctx context.Context, token string) (string, error) {
+	args := m.Called(ctx, token)
+	return args.String(0), args.Error(1)
+}
+
 var _ = Describe("Service", func() {
 	var mockRepo *MockRepo
-	var log logger.Logger
+	var mockVerifier *MockVerifier
+	var log logging.Logger
 	var ctx context.Context
+	var gen ids.IDGenerator
+	var clk *clock.Fake
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
 
 	BeforeEach(func() {
 		mockRepo = new(MockRepo)
-		log = logger.NewLoggerWithOptions(logger.Opts{
+		mockVerifier = new(MockVerifier)
+		mockVerifier.On("Issue", mock.Anything, mock.Anything).Return(nil).Maybe()
+		gen = ids.NewSequential("usr_")
+		clk = clock.NewFake(now)
+		log = logging.New(logging.Opts{
 			AppName: "create-user-lambda-service-test",
 			Level:   "debug",
 		})
@@ -56,43 +87,96 @@ var _ = Describe("Service", func() {
 
 			When("request is valid and mock valid response from db", func() {
 				BeforeEach(func() {
-					mockRepo.On("InsertUser", ctx, mock.Anything).
+					mockRepo.On("InsertUser", mock.Anything, mock.MatchedBy(func(user *models.UserDB) bool {
+						return user.ID == "usr_00000000-0000-7000-8000-000000000001"
+					})).
 						Times(1).
 						Return(nil)
 				})
 
-				It("can save the record", func() {
+				It("can save the record with the generated id", func() {
+					defer cancel()
+
+					err := service.New(mockRepo, mockVerifier, log, clk, gen).CreateUser(ctx, req)
+					Expect(err).To(BeNil())
+					mockRepo.AssertExpectations(GinkgoT())
+				})
+
+				It("saves the user pending verification and mails it a token", func() {
 					defer cancel()
 
-					err := service.New(mockRepo, log).CreateUser(ctx, req)
+					err := service.New(mockRepo, mockVerifier, log, clk, gen).CreateUser(ctx, req)
 					Expect(err).To(BeNil())
+					mockVerifier.AssertCalled(GinkgoT(), "Issue", mock.Anything, mock.MatchedBy(func(user domain.User) bool {
+						return user.ID == "usr_00000000-0000-7000-8000-000000000001" &&
+							user.Email == req.Email && user.Status == domain.StatusPendingVerification
+					}))
 				})
 			})
 
-			When("request cannot be marshalled as db model", func() {
+			When("the verification email cannot be sent", func() {
 				BeforeEach(func() {
-					err := os.Setenv("TZ_LOCATION", "NotValid")
+					mockRepo.On("InsertUser", mock.Anything, mock.Anything).
+						Times(1).
+						Return(nil)
+					mockVerifier = new(MockVerifier)
+					mockVerifier.On("Issue", mock.Anything, mock.Anything).
+						Times(1).
+						Return(errors.New("smtp unavailable"))
+				})
+
+				It("still creates the user, the email is resent on request", func() {
+					defer cancel()
+
+					err := service.New(mockRepo, mockVerifier, log, clk, gen).CreateUser(ctx, req)
 					Expect(err).To(BeNil())
+					mockRepo.AssertExpectations(GinkgoT())
+					mockVerifier.AssertExpectations(GinkgoT())
+				})
+			})
+
+			When("the caller is authenticated", func() {
+				BeforeEach(func() {
+					mockRepo.On("InsertUser", mock.Anything, mock.MatchedBy(func(user *models.UserDB) bool {
+						return user.OwnerSubject == "sub-1"
+					})).
+						Times(1).
+						Return(nil)
 				})
 
-				AfterEach(func() {
-					err := os.Unsetenv("TZ_LOCATION")
+				It("saves the subject of the caller as owner", func() {
+					defer cancel()
+
+					ctx = auth.WithIdentity(ctx, &auth.Identity{Subject: "sub-1"})
+					err := service.New(mockRepo, mockVerifier, log, clk, gen).CreateUser(ctx, req)
 					Expect(err).To(BeNil())
+					mockRepo.AssertExpectations(GinkgoT())
 				})
+			})
 
-				It("cannot save record in db due to timezone not exist", func() {
+			When("the clock is set", func() {
+				BeforeEach(func() {
+					mockRepo.On("InsertUser", mock.Anything, mock.MatchedBy(func(user *models.UserDB) bool {
+						return user.CreatedAt.Equal(now.Add(time.Hour)) && user.CreatedAt.Location() == time.UTC &&
+							user.UpdatedAt.Equal(user.CreatedAt)
+					})).
+						Times(1).
+						Return(nil)
+				})
+
+				It("saves the time of the clock in utc", func() {
 					defer cancel()
 
-					err := service.New(mockRepo, log).CreateUser(ctx, req)
-					Expect(err).NotTo(BeNil())
-					unwErr := errors.Unwrap(err)
-					Expect(unwErr.Error()).To(Equal("unknown time zone NotValid"))
+					clk.Advance(time.Hour)
+					err := service.New(mockRepo, mockVerifier, log, clk, gen).CreateUser(ctx, req)
+					Expect(err).To(BeNil())
+					mockRepo.AssertExpectations(GinkgoT())
 				})
 			})
 
 			When("db returns an error", func() {
 				BeforeEach(func() {
-					mockRepo.On("InsertUser", ctx, mock.Anything).
+					mockRepo.On("InsertUser", mock.Anything, mock.Anything).
 						Times(1).
 						Return(context.DeadlineExceeded)
 				})
@@ -100,9 +184,10 @@ var _ = Describe("Service", func() {
 				It("cannot save the record due to deadline exceeded", func() {
 					defer cancel()
 
-					err := service.New(mockRepo, log).CreateUser(ctx, req)
+					err := service.New(mockRepo, mockVerifier, log, clk, gen).CreateUser(ctx, req)
 					Expect(err).NotTo(BeNil())
 					Expect(err).To(Equal(context.DeadlineExceeded))
+					mockVerifier.AssertNotCalled(GinkgoT(), "Issue", mock.Anything, mock.Anything)
 				})
 			})
 		})
@@ -0,0 +1,106 @@
+// Command export dumps the users table into local files.
+//
+//	DYNAMODB_TABLE_NAME=users go run ./cmd/export -format parquet -gzip -rows-per-file 50000 -out ./dump
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/ricardojonathanromero/go-utilities/db/dynamodb"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/internal/handler"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/entities"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/export"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"path/filepath"
+)
+
+const appName = "export-users"
+
+func main() {
+	var req entities.ExportReq
+	var out string
+
+	flag.StringVar(&req.Format, "format", string(export.FormatNDJSON), "output format: ndjson, csv or parquet")
+	flag.BoolVar(&req.Gzip, "gzip", false, "compress every file with gzip")
+	flag.IntVar(&req.RowsPerFile, "rows-per-file", 0, "maximum rows per file, 0 uses the default")
+	flag.StringVar(&req.Prefix, "prefix", "", "file name prefix, defaults to users")
+	pageSize := flag.Int("page-size", 0, "scan page size, 0 lets dynamodb decide")
+	flag.StringVar(&out, "out", ".", "output directory")
+	flag.Parse()
+	req.PageSize = int32(*pageSize)
+
+	// configuration is loaded once, every problem is reported at once and
+	// ssm:// or secretsmanager:// references are resolved at cold start
+	var cfg config.Common
+	configCtx, cancelConfig := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	secrets, err := config.NewProvider(configCtx)
+	if err == nil {
+		err = config.Load(&cfg, config.WithContext(configCtx), config.WithProvider(secrets))
+	}
+	cancelConfig()
+	if err != nil {
+		logging.New(logging.Opts{AppName: appName}).Fatal(err.Error())
+	}
+
+	customLog := logging.New(logging.Opts{
+		AppName: appName,
+		Level:   cfg.LogLevel,
+	})
+
+	// spans are exported to the OTLP endpoint when OTEL_TRACES_EXPORTER=otlp
+	tracerProvider, err := tracing.Setup(context.Background(), tracing.Opts{
+		ServiceName: appName,
+		Exporter:    cfg.TracesExporter,
+	})
+	if err != nil {
+		customLog.Fatalf("error configuring tracing: %v", err)
+	}
+
+	defer func() {
+		if err = tracerProvider.Shutdown(context.Background()); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	// per-call timeouts are derived from the invocation deadline
+	clientOpts := clientopts.New(cfg.DynamoDB.Policy())
+
+	// the export holds the decrypted PII, FIELD_ENCRYPTION must match the
+	// writers of the table
+	enc, err := fieldcrypt.Open(context.Background(), cfg.Encryption)
+	if err != nil {
+		customLog.Fatalf("error configuring field encryption: %v", err)
+	}
+
+	// connect to db
+	db := dynamodb.New()
+	conn, err := db.Connect()
+	if err != nil {
+		customLog.Fatalf("error initializing db connection: %s", err.Error())
+	}
+
+	defer func() {
+		if err = db.Disconnect(); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	repo := repository.New(conn, cfg.DynamoDB.TableName, customLog, metrics.NewNoop(), clientOpts, enc)
+	srv := service.New(repo, export.NewDirSink(out), customLog)
+
+	manifest, err := handler.New(srv, customLog, metrics.NewNoop()).HandleExport(context.Background(), req)
+	if err != nil {
+		customLog.Fatalf("error exporting users: %v", err)
+	}
+
+	fmt.Printf("exported %d rows into %d files, manifest: %s\n",
+		manifest.TotalRows, len(manifest.Files), filepath.Join(out, manifest.Name))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ricardojonathanromero/go-utilities/db/dynamodb"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/internal/handler"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/export"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"os"
+)
+
+const appName = "export-users-lambda"
+
+func main() {
+	// configuration is loaded once, every problem is reported at once and
+	// ssm:// or secretsmanager:// references are resolved at cold start
+	var cfg config.ExportUsers
+	configCtx, cancelConfig := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	secrets, err := config.NewProvider(configCtx)
+	if err == nil {
+		err = config.Load(&cfg, config.WithContext(configCtx), config.WithProvider(secrets))
+	}
+	cancelConfig()
+	if err != nil {
+		logging.New(logging.Opts{AppName: appName}).Fatal(err.Error())
+	}
+
+	customLog := logging.New(logging.Opts{
+		AppName: appName,
+		Level:   cfg.LogLevel,
+	})
+
+	// metrics are written to stdout in embedded metric format
+	customMetrics := metrics.New(appName, metrics.NewEMFSink(os.Stdout, cfg.MetricsNamespace))
+
+	// spans are exported to the OTLP endpoint when OTEL_TRACES_EXPORTER=otlp
+	tracerProvider, err := tracing.Setup(context.Background(), tracing.Opts{
+		ServiceName: appName,
+		Exporter:    cfg.TracesExporter,
+	})
+	if err != nil {
+		customLog.Fatalf("error configuring tracing: %v", err)
+	}
+
+	defer func() {
+		if err = tracerProvider.Shutdown(context.Background()); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	// per-call timeouts are derived from the invocation deadline
+	clientOpts := clientopts.New(cfg.DynamoDB.Policy())
+
+	// the export holds the decrypted PII, FIELD_ENCRYPTION must match the
+	// writers of the table
+	enc, err := fieldcrypt.Open(context.Background(), cfg.Encryption)
+	if err != nil {
+		customLog.Fatalf("error configuring field encryption: %v", err)
+	}
+
+	// connect to db
+	db := dynamodb.New()
+	conn, err := db.Connect()
+	if err != nil {
+		customLog.Fatalf("error initializing db connection: %s", err.Error())
+	}
+
+	defer func() {
+		if err = db.Disconnect(); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	// configure s3 destination
+	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		customLog.Fatalf("error loading aws config: %v", err)
+	}
+	sink := export.NewS3Sink(s3.NewFromConfig(awsCfg), cfg.Bucket, cfg.KeyPrefix)
+
+	// init dependency injection
+	repo := repository.New(conn, cfg.DynamoDB.TableName, customLog, customMetrics, clientOpts, enc)
+	srv := service.New(repo, sink, customLog)
+
+	lambda.Start(tracing.WithFlush(tracerProvider, handler.New(srv, customLog, customMetrics).HandleExport))
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"github.com/go-playground/validator/v10"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/entities"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/export"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"time"
+)
+
+type Handler interface {
+	HandleExport(ctx context.Context, req entities.ExportReq) (*export.Manifest, error)
+}
+
+const operationHandleExport = "HandleExport"
+
+type handleImpl struct {
+	srv     service.Service
+	log     logging.Logger
+	metrics metrics.Metrics
+	v       *validator.Validate
+}
+
+func New(srv service.Service, log logging.Logger, m metrics.Metrics) Handler {
+	return &handleImpl{
+		srv:     srv,
+		log:     log,
+		metrics: m,
+		v:       validator.New(),
+	}
+}
+
+// HandleExport is invoked asynchronously (schedule or manual invoke), errors
+// are returned to the runtime so the invocation is reported as failed.
+func (h *handleImpl) HandleExport(ctx context.Context, req entities.ExportReq) (*export.Manifest, error) {
+	defer h.metrics.HandlerLatency(operationHandleExport, time.Now())
+	h.metrics.ColdStart(operationHandleExport)
+
+	ctx, span := tracing.StartServer(ctx, operationHandleExport)
+	defer span.End()
+
+	log := h.log.WithContext(ctx)
+	log.Debug("event received")
+
+	log.Debug("validating request")
+	if err := h.v.StructCtx(ctx, req); err != nil {
+		log.Errorf("error occurs validating struct: %v", err)
+		tracing.Error(span, err)
+		h.metrics.Increment(operationHandleExport, metrics.MetricValidationFailure)
+		return nil, err
+	}
+
+	manifest, err := h.srv.ExportUsers(ctx, req)
+	if err != nil {
+		log.Errorf("error exporting users: %v", err)
+		tracing.Error(span, err)
+		return nil, err
+	}
+
+	log.Info("event processed")
+	return manifest, nil
+}
@@ -0,0 +1,20 @@
+package entities
+
+import "github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/export"
+
+type ExportReq struct {
+	Format      string `json:"format" validate:"required,oneof=ndjson csv parquet"`
+	Gzip        bool   `json:"gzip"`
+	RowsPerFile int    `json:"rows_per_file" validate:"gte=0"`
+	PageSize    int32  `json:"page_size" validate:"gte=0,lte=1000"`
+	Prefix      string `json:"prefix" validate:"omitempty,max=200"`
+}
+
+func (e ExportReq) ToOptions() export.Options {
+	return export.Options{
+		Format:      export.Format(e.Format),
+		Gzip:        e.Gzip,
+		RowsPerFile: e.RowsPerFile,
+		Prefix:      e.Prefix,
+	}
+}
@@ -0,0 +1,227 @@
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"hash"
+	"io"
+	"time"
+)
+
+const (
+	defaultPrefix      = "users"
+	defaultRowsPerFile = 100000
+	compressionGzip    = "gzip"
+)
+
+var ErrClosed = errors.New("exporter already closed")
+
+type Options struct {
+	Format Format
+	Gzip   bool
+	// RowsPerFile is the maximum number of rows written to a single file, zero uses the default.
+	RowsPerFile int
+	// Prefix is used to name the data files and the manifest, zero uses "users".
+	Prefix string
+}
+
+type File struct {
+	Name   string `json:"name"`
+	Rows   int64  `json:"rows"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+type Manifest struct {
+	Name        string    `json:"-"`
+	Format      Format    `json:"format"`
+	Compression string    `json:"compression,omitempty"`
+	TotalRows   int64     `json:"total_rows"`
+	Files       []File    `json:"files"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Exporter receives pages of users and splits them into chunked files. Close
+// must be called once every page has been written, it flushes the last file
+// and writes the manifest.
+type Exporter interface {
+	Write(users []*models.UserDB) error
+	Close() (*Manifest, error)
+}
+
+type exporterImpl struct {
+	ctx      context.Context
+	sink     Sink
+	opts     Options
+	manifest *Manifest
+	current  *chunk
+	closed   bool
+}
+
+func New(ctx context.Context, sink Sink, opts Options) (Exporter, error) {
+	if opts.Format.Extension() == "" {
+		return nil, fmt.Errorf("unsupported export format: %q", opts.Format)
+	}
+
+	if opts.RowsPerFile <= 0 {
+		opts.RowsPerFile = defaultRowsPerFile
+	}
+
+	if len(opts.Prefix) == 0 {
+		opts.Prefix = defaultPrefix
+	}
+
+	manifest := &Manifest{
+		Name:      fmt.Sprintf("%s-manifest.json", opts.Prefix),
+		Format:    opts.Format,
+		Files:     []File{},
+		CreatedAt: time.Now().UTC(),
+	}
+	if opts.Gzip {
+		manifest.Compression = compressionGzip
+	}
+
+	return &exporterImpl{ctx: ctx, sink: sink, opts: opts, manifest: manifest}, nil
+}
+
+func (e *exporterImpl) Write(users []*models.UserDB) error {
+	if e.closed {
+		return ErrClosed
+	}
+
+	for _, user := range users {
+		if e.current == nil {
+			if err := e.openChunk(); err != nil {
+				return err
+			}
+		}
+
+		if err := e.current.records.Write(user); err != nil {
+			return err
+		}
+		e.current.rows++
+
+		if e.current.rows >= int64(e.opts.RowsPerFile) {
+			if err := e.closeChunk(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *exporterImpl) Close() (*Manifest, error) {
+	if e.closed {
+		return nil, ErrClosed
+	}
+	e.closed = true
+
+	if e.current != nil {
+		if err := e.closeChunk(); err != nil {
+			return nil, err
+		}
+	}
+
+	wc, err := e.sink.Create(e.ctx, e.manifest.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := json.NewEncoder(wc)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(e.manifest); err != nil {
+		_ = wc.Close()
+		return nil, err
+	}
+
+	if err = wc.Close(); err != nil {
+		return nil, err
+	}
+
+	return e.manifest, nil
+}
+
+// chunk is one data file being written. Bytes are counted and hashed after
+// compression so the manifest describes the file exactly as it is stored.
+type chunk struct {
+	name    string
+	dst     io.WriteCloser
+	hash    hash.Hash
+	size    int64
+	gz      *gzip.Writer
+	records RecordWriter
+	rows    int64
+}
+
+func (c *chunk) Write(p []byte) (int, error) {
+	n, err := c.dst.Write(p)
+	c.hash.Write(p[:n])
+	c.size += int64(n)
+	return n, err
+}
+
+func (e *exporterImpl) openChunk() error {
+	name := fmt.Sprintf("%s-%05d.%s", e.opts.Prefix, len(e.manifest.Files), e.opts.Format.Extension())
+	if e.opts.Gzip {
+		name += ".gz"
+	}
+
+	dst, err := e.sink.Create(e.ctx, name)
+	if err != nil {
+		return err
+	}
+
+	c := &chunk{name: name, dst: dst, hash: sha256.New()}
+
+	var out io.Writer = c
+	if e.opts.Gzip {
+		c.gz = gzip.NewWriter(c)
+		out = c.gz
+	}
+
+	c.records, err = NewRecordWriter(e.opts.Format, out)
+	if err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	e.current = c
+	return nil
+}
+
+func (e *exporterImpl) closeChunk() error {
+	c := e.current
+	e.current = nil
+
+	if err := c.records.Close(); err != nil {
+		_ = c.dst.Close()
+		return err
+	}
+
+	if c.gz != nil {
+		if err := c.gz.Close(); err != nil {
+			_ = c.dst.Close()
+			return err
+		}
+	}
+
+	if err := c.dst.Close(); err != nil {
+		return err
+	}
+
+	e.manifest.Files = append(e.manifest.Files, File{
+		Name:   c.name,
+		Rows:   c.rows,
+		Bytes:  c.size,
+		SHA256: hex.EncodeToString(c.hash.Sum(nil)),
+	})
+	e.manifest.TotalRows += c.rows
+	return nil
+}
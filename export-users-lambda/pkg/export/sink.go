@@ -0,0 +1,72 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Sink creates the destination objects of an export.
+type Sink interface {
+	Create(ctx context.Context, name string) (io.WriteCloser, error)
+}
+
+type dirSink struct {
+	dir string
+}
+
+// NewDirSink writes every export file inside dir, creating it when missing.
+func NewDirSink(dir string) Sink {
+	return &dirSink{dir: dir}
+}
+
+func (d *dirSink) Create(_ context.Context, name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return os.Create(filepath.Join(d.dir, name))
+}
+
+// PutObjectAPI is the subset of the s3 client used by the s3 sink.
+type PutObjectAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+type s3Sink struct {
+	client PutObjectAPI
+	bucket string
+	prefix string
+}
+
+// NewS3Sink uploads every export file as s3://bucket/prefix/name. Files are
+// buffered in memory until closed, so RowsPerFile bounds the memory used.
+func NewS3Sink(client PutObjectAPI, bucket, prefix string) Sink {
+	return &s3Sink{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (s *s3Sink) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	return &s3Object{ctx: ctx, sink: s, key: path.Join(s.prefix, name)}, nil
+}
+
+type s3Object struct {
+	bytes.Buffer
+	ctx  context.Context
+	sink *s3Sink
+	key  string
+}
+
+func (o *s3Object) Close() error {
+	_, err := o.sink.client.PutObject(o.ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(o.sink.bucket),
+		Key:           aws.String(o.key),
+		Body:          bytes.NewReader(o.Bytes()),
+		ContentLength: aws.Int64(int64(o.Len())),
+	})
+	return err
+}
@@ -0,0 +1,129 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/parquet-go/parquet-go"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"io"
+	"strconv"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatNDJSON  Format = "ndjson"
+	FormatCSV     Format = "csv"
+	FormatParquet Format = "parquet"
+)
+
+// Extension returns the file extension used for the format, without gzip suffix.
+func (f Format) Extension() string {
+	switch f {
+	case FormatNDJSON:
+		return "ndjson"
+	case FormatCSV:
+		return "csv"
+	case FormatParquet:
+		return "parquet"
+	default:
+		return ""
+	}
+}
+
+// RecordWriter serializes users into a single output file. Close flushes any
+// buffered data but never closes the underlying writer.
+type RecordWriter interface {
+	Write(user *models.UserDB) error
+	Close() error
+}
+
+func NewRecordWriter(format Format, w io.Writer) (RecordWriter, error) {
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatParquet:
+		return &parquetWriter{w: parquet.NewGenericWriter[userRow](w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %q", format)
+	}
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(user *models.UserDB) error {
+	return n.enc.Encode(user)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+var csvHeader = []string{"id", "name", "lastname", "age", "email", "created_at", "updated_at"}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(user *models.UserDB) error {
+	if !c.headerWritten {
+		if err := c.w.Write(csvHeader); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	return c.w.Write([]string{
+		user.ID,
+		user.Name,
+		user.Lastname,
+		strconv.FormatInt(int64(user.Age), 10),
+		user.Email,
+		user.CreatedAt.Format(time.RFC3339Nano),
+		user.UpdatedAt.Format(time.RFC3339Nano),
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// userRow is the parquet schema of an exported user.
+type userRow struct {
+	ID        string    `parquet:"id"`
+	Name      string    `parquet:"name"`
+	Lastname  string    `parquet:"lastname"`
+	Age       int32     `parquet:"age"`
+	Email     string    `parquet:"email"`
+	CreatedAt time.Time `parquet:"created_at,timestamp(microsecond)"`
+	UpdatedAt time.Time `parquet:"updated_at,timestamp(microsecond)"`
+}
+
+type parquetWriter struct {
+	w *parquet.GenericWriter[userRow]
+}
+
+func (p *parquetWriter) Write(user *models.UserDB) error {
+	_, err := p.w.Write([]userRow{{
+		ID:        user.ID,
+		Name:      user.Name,
+		Lastname:  user.Lastname,
+		Age:       user.Age,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}})
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	return p.w.Close()
+}
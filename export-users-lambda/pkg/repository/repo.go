@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"time"
+)
+
+// PageFunc receives every page returned by the scan, returning an error stops the scan.
+type PageFunc func(users []*models.UserDB) error
+
+type Repository interface {
+	ScanUsers(ctx context.Context, pageSize int32, fn PageFunc) error
+}
+
+const operationScanUsers = "ScanUsers"
+
+type repositoryImpl struct {
+	conn      dynamodbapi.Scanner
+	log       logging.Logger
+	metrics   metrics.Metrics
+	opts      clientopts.Options
+	enc       fieldcrypt.Encryptor
+	tableName string
+}
+
+func New(conn dynamodbapi.Scanner, tableName string, log logging.Logger, m metrics.Metrics, opts clientopts.Options, enc fieldcrypt.Encryptor) Repository {
+	return &repositoryImpl{
+		conn:      conn,
+		log:       log,
+		metrics:   m,
+		opts:      opts,
+		enc:       enc,
+		tableName: tableName,
+	}
+}
+
+func (repo *repositoryImpl) ScanUsers(ctx context.Context, pageSize int32, fn PageFunc) error {
+	log := repo.log.WithContext(ctx)
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(repo.tableName),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	if pageSize > 0 {
+		input.Limit = aws.Int32(pageSize)
+	}
+
+	log.Debugf("executing paginated scan in table: %s", repo.tableName)
+	paginator := dynamodb.NewScanPaginator(repo.conn, input)
+
+	var page int
+	for paginator.HasMorePages() {
+		output, err := repo.nextPage(ctx, paginator)
+		if err != nil {
+			log.Errorf("error scanning page %d: %s", page, err)
+			return err
+		}
+
+		repo.metrics.ConsumedCapacity(operationScanUsers, output.ConsumedCapacity)
+
+		var users []*models.UserDB
+		err = fieldcrypt.UnmarshalListOfMaps(ctx, repo.enc, output.Items, &users)
+		if err != nil {
+			log.Errorf("error serializing page %d into model: %s", page, err)
+			return err
+		}
+
+		log.Debugf("page %d serialized - total items: %d", page, len(users))
+		if err = fn(users); err != nil {
+			log.Errorf("error processing page %d: %s", page, err)
+			return err
+		}
+		page++
+	}
+
+	log.Debugf("scan finished - total pages: %d", page)
+	return nil
+}
+
+// nextPage bounds every page with its own timeout, the whole scan may take
+// longer than a single call.
+func (repo *repositoryImpl) nextPage(ctx context.Context, paginator *dynamodb.ScanPaginator) (*dynamodb.ScanOutput, error) {
+	callCtx, cancel, err := repo.opts.Context(ctx, operationScanUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	start := time.Now()
+	output, err := paginator.NextPage(callCtx, tracing.DynamoDB, repo.opts.DynamoDB)
+	repo.metrics.RepositoryLatency(operationScanUsers, start)
+	return output, repo.opts.Err(operationScanUsers, err)
+}
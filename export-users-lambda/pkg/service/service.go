@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/entities"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/export"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+)
+
+type Service interface {
+	ExportUsers(ctx context.Context, req entities.ExportReq) (*export.Manifest, error)
+}
+
+type serviceImpl struct {
+	repo repository.Repository
+	sink export.Sink
+	log  logging.Logger
+}
+
+func New(repo repository.Repository, sink export.Sink, log logging.Logger) Service {
+	return &serviceImpl{
+		repo: repo,
+		sink: sink,
+		log:  log,
+	}
+}
+
+func (srv *serviceImpl) ExportUsers(ctx context.Context, req entities.ExportReq) (*export.Manifest, error) {
+	ctx, span := tracing.Start(ctx, "ExportUsers")
+	defer span.End()
+
+	log := srv.log.WithContext(ctx)
+	log.Debugf("starting %s export", req.Format)
+	exp, err := export.New(ctx, srv.sink, req.ToOptions())
+	if err != nil {
+		log.Errorf("error configuring exporter: %s", err)
+		tracing.Error(span, err)
+		return nil, err
+	}
+
+	err = srv.repo.ScanUsers(ctx, req.PageSize, func(users []*models.UserDB) error {
+		return exp.Write(users)
+	})
+	if err != nil {
+		log.Errorf("error exporting users: %s", err)
+		tracing.Error(span, err)
+		return nil, err
+	}
+
+	manifest, err := exp.Close()
+	if err != nil {
+		log.Errorf("error writing manifest: %s", err)
+		tracing.Error(span, err)
+		return nil, err
+	}
+
+	log.Debugf("export finished - files: %d, rows: %d", len(manifest.Files), manifest.TotalRows)
+	return manifest, nil
+}
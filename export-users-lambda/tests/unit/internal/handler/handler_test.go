@@ -0,0 +1,111 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/go-playground/validator/v10"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/internal/handler"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/entities"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/export"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) ExportUsers(ctx context.Context, req entities.ExportReq) (*export.Manifest, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(*export.Manifest), args.Error(1)
+}
+
+var _ = Describe("Handler", func() {
+	var mockService *MockService
+	var lambdaCtx *lambdacontext.LambdaContext
+	var ctx context.Context
+	var log logging.Logger
+
+	appName := "export-users-lambda-handler-test"
+	logLevel := "debug"
+
+	BeforeEach(func() {
+		log = logging.New(logging.Opts{AppName: appName, Level: logLevel})
+		mockService = new(MockService)
+		lambdaCtx = &lambdacontext.LambdaContext{
+			AwsRequestID:       "awsRequestId1234",
+			InvokedFunctionArn: "arn:aws:lambda:xxx",
+			Identity:           lambdacontext.CognitoIdentity{},
+			ClientContext:      lambdacontext.ClientContext{},
+		}
+	})
+
+	Describe("process export event", func() {
+		Context("with context timeout of 10s", func() {
+			var c context.Context
+			var cancel context.CancelFunc
+
+			BeforeEach(func() {
+				c, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+				ctx = lambdacontext.NewContext(c, lambdaCtx)
+			})
+
+			When("service exports the users", func() {
+				var req entities.ExportReq
+
+				BeforeEach(func() {
+					req = entities.ExportReq{Format: "parquet", Gzip: true}
+					mockService.On("ExportUsers", mock.Anything, req).
+						Times(1).
+						Return(&export.Manifest{Format: export.FormatParquet, TotalRows: 10}, nil)
+				})
+
+				It("returns the manifest", func() {
+					defer cancel()
+
+					manifest, err := handler.New(mockService, log, metrics.NewNoop()).HandleExport(ctx, req)
+					Expect(err).To(BeNil())
+					Expect(manifest.TotalRows).To(Equal(int64(10)))
+				})
+			})
+
+			When("request not pass validations", func() {
+				It("returns the validation errors", func() {
+					defer cancel()
+
+					manifest, err := handler.New(mockService, log, metrics.NewNoop()).HandleExport(ctx, entities.ExportReq{Format: "xml"})
+					Expect(manifest).To(BeNil())
+
+					var ve validator.ValidationErrors
+					Expect(errors.As(err, &ve)).To(BeTrue())
+					mockService.AssertNotCalled(GinkgoT(), "ExportUsers", mock.Anything, mock.Anything)
+				})
+			})
+
+			When("service fails", func() {
+				var req entities.ExportReq
+
+				BeforeEach(func() {
+					req = entities.ExportReq{Format: "ndjson"}
+					var manifest *export.Manifest
+					mockService.On("ExportUsers", mock.Anything, req).
+						Times(1).
+						Return(manifest, errors.New("internal error"))
+				})
+
+				It("returns the error to the runtime", func() {
+					defer cancel()
+
+					manifest, err := handler.New(mockService, log, metrics.NewNoop()).HandleExport(ctx, req)
+					Expect(manifest).To(BeNil())
+					Expect(err).To(MatchError("internal error"))
+				})
+			})
+		})
+	})
+})
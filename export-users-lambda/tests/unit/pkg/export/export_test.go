@@ -0,0 +1,210 @@
+package export_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/parquet-go/parquet-go"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/export"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func buildUsers(total int) []*models.UserDB {
+	now := time.Date(2024, 4, 14, 13, 44, 37, 0, time.UTC)
+	users := make([]*models.UserDB, 0, total)
+	for i := 0; i < total; i++ {
+		users = append(users, &models.UserDB{
+			ID:        fmt.Sprintf("%d", i+1),
+			Name:      "john",
+			Lastname:  "smith",
+			Age:       int32(20 + i),
+			Email:     fmt.Sprintf("john.smith%d@test.com", i+1),
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+	return users
+}
+
+var _ = Describe("Export", func() {
+	var ctx context.Context
+	var dir string
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dir = GinkgoT().TempDir()
+	})
+
+	readManifest := func(name string) *export.Manifest {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		Expect(err).To(BeNil())
+
+		var manifest export.Manifest
+		Expect(json.Unmarshal(data, &manifest)).To(Succeed())
+		return &manifest
+	}
+
+	Describe("configure exporter", func() {
+		It("rejects unknown formats", func() {
+			exp, err := export.New(ctx, export.NewDirSink(dir), export.Options{Format: "xml"})
+			Expect(exp).To(BeNil())
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("export users as ndjson", func() {
+		When("rows per file is lower than the total rows", func() {
+			var manifest *export.Manifest
+
+			BeforeEach(func() {
+				exp, err := export.New(ctx, export.NewDirSink(dir), export.Options{Format: export.FormatNDJSON, RowsPerFile: 2})
+				Expect(err).To(BeNil())
+
+				users := buildUsers(5)
+				Expect(exp.Write(users[:3])).To(Succeed())
+				Expect(exp.Write(users[3:])).To(Succeed())
+
+				manifest, err = exp.Close()
+				Expect(err).To(BeNil())
+			})
+
+			It("splits the users in chunks", func() {
+				Expect(manifest.TotalRows).To(Equal(int64(5)))
+				Expect(manifest.Files).To(HaveLen(3))
+				Expect(manifest.Files[0].Name).To(Equal("users-00000.ndjson"))
+				Expect(manifest.Files[0].Rows).To(Equal(int64(2)))
+				Expect(manifest.Files[2].Rows).To(Equal(int64(1)))
+			})
+
+			It("writes a manifest with the checksum of every file", func() {
+				stored := readManifest("users-manifest.json")
+				Expect(stored.Files).To(Equal(manifest.Files))
+
+				for _, file := range stored.Files {
+					data, err := os.ReadFile(filepath.Join(dir, file.Name))
+					Expect(err).To(BeNil())
+
+					sum := sha256.Sum256(data)
+					Expect(file.SHA256).To(Equal(hex.EncodeToString(sum[:])))
+					Expect(file.Bytes).To(Equal(int64(len(data))))
+				}
+			})
+
+			It("writes one json document per line", func() {
+				f, err := os.Open(filepath.Join(dir, "users-00001.ndjson"))
+				Expect(err).To(BeNil())
+				defer f.Close()
+
+				var ids []string
+				scanner := bufio.NewScanner(f)
+				for scanner.Scan() {
+					var user models.UserDB
+					Expect(json.Unmarshal(scanner.Bytes(), &user)).To(Succeed())
+					ids = append(ids, user.ID)
+				}
+				Expect(ids).To(Equal([]string{"3", "4"}))
+			})
+		})
+
+		When("gzip is enabled", func() {
+			It("compresses every file", func() {
+				exp, err := export.New(ctx, export.NewDirSink(dir), export.Options{Format: export.FormatNDJSON, Gzip: true, Prefix: "dump"})
+				Expect(err).To(BeNil())
+				Expect(exp.Write(buildUsers(3))).To(Succeed())
+
+				manifest, err := exp.Close()
+				Expect(err).To(BeNil())
+				Expect(manifest.Compression).To(Equal("gzip"))
+				Expect(manifest.Files).To(HaveLen(1))
+				Expect(manifest.Files[0].Name).To(Equal("dump-00000.ndjson.gz"))
+
+				f, err := os.Open(filepath.Join(dir, manifest.Files[0].Name))
+				Expect(err).To(BeNil())
+				defer f.Close()
+
+				gz, err := gzip.NewReader(f)
+				Expect(err).To(BeNil())
+
+				var lines int
+				scanner := bufio.NewScanner(gz)
+				for scanner.Scan() {
+					lines++
+				}
+				Expect(lines).To(Equal(3))
+			})
+		})
+
+		When("table is empty", func() {
+			It("writes a manifest without files", func() {
+				exp, err := export.New(ctx, export.NewDirSink(dir), export.Options{Format: export.FormatNDJSON})
+				Expect(err).To(BeNil())
+
+				manifest, err := exp.Close()
+				Expect(err).To(BeNil())
+				Expect(manifest.Files).To(BeEmpty())
+				Expect(manifest.TotalRows).To(BeZero())
+
+				_, err = exp.Close()
+				Expect(err).To(MatchError(export.ErrClosed))
+			})
+		})
+	})
+
+	Describe("export users as csv", func() {
+		It("writes a header and one record per user", func() {
+			exp, err := export.New(ctx, export.NewDirSink(dir), export.Options{Format: export.FormatCSV})
+			Expect(err).To(BeNil())
+			Expect(exp.Write(buildUsers(2))).To(Succeed())
+
+			manifest, err := exp.Close()
+			Expect(err).To(BeNil())
+
+			data, err := os.ReadFile(filepath.Join(dir, manifest.Files[0].Name))
+			Expect(err).To(BeNil())
+
+			records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			Expect(err).To(BeNil())
+			Expect(records).To(HaveLen(3))
+			Expect(records[0]).To(Equal([]string{"id", "name", "lastname", "age", "email", "created_at", "updated_at"}))
+			Expect(records[2]).To(Equal([]string{"2", "john", "smith", "21", "john.smith2@test.com", "2024-04-14T13:44:37Z", "2024-04-14T13:44:37Z"}))
+		})
+	})
+
+	Describe("export users as parquet", func() {
+		It("can be read back", func() {
+			exp, err := export.New(ctx, export.NewDirSink(dir), export.Options{Format: export.FormatParquet})
+			Expect(err).To(BeNil())
+			Expect(exp.Write(buildUsers(4))).To(Succeed())
+
+			manifest, err := exp.Close()
+			Expect(err).To(BeNil())
+			Expect(manifest.Files[0].Name).To(Equal("users-00000.parquet"))
+
+			type row struct {
+				ID        string    `parquet:"id"`
+				Age       int32     `parquet:"age"`
+				Email     string    `parquet:"email"`
+				CreatedAt time.Time `parquet:"created_at,timestamp(microsecond)"`
+			}
+
+			rows, err := parquet.ReadFile[row](filepath.Join(dir, manifest.Files[0].Name))
+			Expect(err).To(BeNil())
+			Expect(rows).To(HaveLen(4))
+			Expect(rows[3].ID).To(Equal("4"))
+			Expect(rows[3].Age).To(Equal(int32(23)))
+			Expect(rows[3].Email).To(Equal("john.smith4@test.com"))
+			Expect(rows[3].CreatedAt.Equal(time.Date(2024, 4, 14, 13, 44, 37, 0, time.UTC))).To(BeTrue())
+		})
+	})
+})
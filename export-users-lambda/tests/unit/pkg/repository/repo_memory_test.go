@@ -0,0 +1,63 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"time"
+)
+
+var _ = Describe("Repository with the in-memory client", func() {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var repo repository.Repository
+
+	tableName := "users"
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		log := logging.New(logging.Opts{AppName: "export-users-lambda-repository-test", Level: "debug"})
+		opts := clientopts.New(clientopts.DefaultPolicy())
+
+		client := dynamodbapi.NewInMemory()
+		Expect(db.New(client, log, opts, db.NewNoopCache()).ConfigureTable(ctx, tableName)).To(Succeed())
+
+		for i := 0; i < 5; i++ {
+			item, err := attributevalue.MarshalMap(models.UserDB{ID: fmt.Sprint(i), Name: "john", CreatedAt: time.Now()})
+			Expect(err).To(BeNil())
+			_, err = client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: item})
+			Expect(err).To(BeNil())
+		}
+
+		repo = repository.New(client, tableName, log, metrics.NewNoop(), opts, fieldcrypt.NewNoop())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("walks every page", func() {
+		var pages, users int
+		err := repo.ScanUsers(ctx, 2, func(page []*models.UserDB) error {
+			pages++
+			users += len(page)
+			return nil
+		})
+
+		Expect(err).To(BeNil())
+		Expect(pages).To(Equal(3))
+		Expect(users).To(Equal(5))
+	})
+})
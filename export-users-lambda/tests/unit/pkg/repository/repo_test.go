@@ -0,0 +1,181 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go"
+	"github.com/jarcoal/httpmock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"net/http"
+	"time"
+)
+
+func getDBClientWithHttpHandler(url string) (*dynamodb.Client, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO(),
+		config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(
+			func(service, region string, options ...any) (aws.Endpoint, error) {
+				return aws.Endpoint{URL: url}, nil
+			})),
+		config.WithHTTPClient(http.DefaultClient),
+		config.WithRegion("us-east-1"),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("dummyKey", "dummySecret", "")),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamodb.NewFromConfig(cfg), nil
+}
+
+const firstPage = `{
+    "Count": 2,
+    "Items": [
+  {
+    "Age": {"N": "33"},
+    "CreatedAt": {"S": "2024-04-14T13:44:37.609166-06:00"},
+    "Email": {"S": "john.smith@test.com"},
+    "Id": {"S": "1"},
+    "Lastname": {"S": "smith"},
+    "Name": {"S": "john"},
+    "UpdatedAt": {"S": "2024-04-14T13:44:37.609169-06:00"}
+  },
+  {
+    "Age": {"N": "25"},
+    "CreatedAt": {"S": "2024-04-14T13:44:37.609169-06:00"},
+    "Email": {"S": "john.smith2@test.com"},
+    "Id": {"S": "2"},
+    "Lastname": {"S": "smith"},
+    "Name": {"S": "john"},
+    "UpdatedAt": {"S": "2024-04-14T13:44:37.609169-06:00"}
+  }
+],
+    "LastEvaluatedKey": {
+      "Id": {"S": "2"},
+      "CreatedAt": {"S": "2024-04-14T13:44:37.609169-06:00"}
+    },
+    "ScannedCount": 2
+  }`
+
+const lastPage = `{
+    "Count": 1,
+    "Items": [
+  {
+    "Age": {"N": "17"},
+    "CreatedAt": {"S": "2024-04-14T13:44:37.609169-06:00"},
+    "Email": {"S": "john.smith3@test.com"},
+    "Id": {"S": "3"},
+    "Lastname": {"S": "smith"},
+    "Name": {"S": "john"},
+    "UpdatedAt": {"S": "2024-04-14T13:44:37.609169-06:00"}
+  }
+],
+    "ScannedCount": 1
+  }`
+
+var _ = Describe("Repository", func() {
+	var ctx context.Context
+	var log logging.Logger
+	var conn *dynamodb.Client
+
+	appName := "export-users-lambda-repository-test"
+	dynamodbLocalURL := "http://localhost:8000/"
+	tableName := "my-table"
+	logLevel := "debug"
+
+	BeforeEach(func() {
+		var err error
+		// configure dynamodb local session
+		ctx = context.Background()
+		log = logging.New(logging.Opts{AppName: appName, Level: logLevel})
+		conn, err = getDBClientWithHttpHandler(dynamodbLocalURL)
+		Expect(err).To(BeNil())
+	})
+
+	Describe("scan every user page by page", func() {
+		When("connection db has been initialized and context deadline is set to 10 secs", func() {
+			var cancel context.CancelFunc
+			BeforeEach(func() {
+				// remove any mocks
+				httpmock.Reset()
+				ctx, cancel = context.WithTimeout(ctx, time.Second*10)
+			})
+
+			Context("the db returns two pages", func() {
+				var repo repository.Repository
+
+				BeforeEach(func() {
+					resp := httpmock.ResponderFromMultipleResponses([]*http.Response{
+						httpmock.NewStringResponse(http.StatusOK, firstPage),
+						httpmock.NewStringResponse(http.StatusOK, lastPage),
+					})
+					httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
+					repo = repository.New(conn, tableName, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
+				})
+
+				It("receives every page", func() {
+					defer cancel()
+
+					var pages [][]*models.UserDB
+					err := repo.ScanUsers(ctx, 2, func(users []*models.UserDB) error {
+						pages = append(pages, users)
+						return nil
+					})
+					Expect(err).To(BeNil())
+					Expect(pages).To(HaveLen(2))
+					Expect(pages[0]).To(HaveLen(2))
+					Expect(pages[1]).To(HaveLen(1))
+					Expect(pages[1][0].ID).To(Equal("3"))
+					Expect(httpmock.GetTotalCallCount()).To(Equal(2))
+				})
+
+				It("stops when the page func fails", func() {
+					defer cancel()
+
+					expectedErr := errors.New("disk full")
+					err := repo.ScanUsers(ctx, 2, func(users []*models.UserDB) error {
+						return expectedErr
+					})
+					Expect(err).To(MatchError(expectedErr))
+					Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("the db return not valid response", func() {
+				var repo repository.Repository
+
+				BeforeEach(func() {
+					result := `{"code":"ResourceNotFoundException","message":"Requested resource not found"}`
+					resp := httpmock.NewStringResponder(http.StatusBadRequest, result)
+					httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
+					repo = repository.New(conn, tableName, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
+				})
+
+				It("returns the api error", func() {
+					defer cancel()
+
+					err := repo.ScanUsers(ctx, 0, func(users []*models.UserDB) error {
+						return nil
+					})
+					Expect(err).NotTo(BeNil())
+
+					var ae smithy.APIError
+					ok := errors.As(err, &ae)
+					Expect(ok).To(BeTrue())
+					Expect(ae.ErrorCode()).To(Equal("ResourceNotFoundException"))
+				})
+			})
+		})
+	})
+})
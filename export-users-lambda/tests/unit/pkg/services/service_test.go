@@ -0,0 +1,104 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/entities"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/export"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/export-users-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/stretchr/testify/mock"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+	pages [][]*models.UserDB
+}
+
+func (m *MockRepo) ScanUsers(ctx context.Context, pageSize int32, fn repository.PageFunc) error {
+	args := m.Called(ctx, pageSize, fn)
+	for _, page := range m.pages {
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return args.Error(0)
+}
+
+var _ = Describe("Service", func() {
+	var mockRepo *MockRepo
+	var log logging.Logger
+	var ctx context.Context
+	var dir string
+
+	BeforeEach(func() {
+		mockRepo = new(MockRepo)
+		log = logging.New(logging.Opts{
+			AppName: "export-users-lambda-service-test",
+			Level:   "debug",
+		})
+		ctx = context.Background()
+		dir = GinkgoT().TempDir()
+	})
+
+	Describe("service return response", func() {
+		Context("deadline is up to 10 secs", func() {
+			var cancel context.CancelFunc
+			BeforeEach(func() {
+				ctx, cancel = context.WithTimeout(ctx, time.Second*10)
+			})
+
+			When("repository returns two pages", func() {
+				BeforeEach(func() {
+					mockRepo.pages = [][]*models.UserDB{
+						{{ID: "1", Name: "john", CreatedAt: time.Now()}, {ID: "2", Name: "jane", CreatedAt: time.Now()}},
+						{{ID: "3", Name: "jack", CreatedAt: time.Now()}},
+					}
+					mockRepo.On("ScanUsers", mock.Anything, int32(100), mock.Anything).
+						Times(1).
+						Return(nil)
+				})
+
+				It("exports every user and writes the manifest", func() {
+					defer cancel()
+
+					req := entities.ExportReq{Format: "csv", RowsPerFile: 2, PageSize: 100}
+					manifest, err := service.New(mockRepo, export.NewDirSink(dir), log).ExportUsers(ctx, req)
+					Expect(err).To(BeNil())
+					Expect(manifest.Format).To(Equal(export.FormatCSV))
+					Expect(manifest.TotalRows).To(Equal(int64(3)))
+					Expect(manifest.Files).To(HaveLen(2))
+					Expect(filepath.Join(dir, "users-manifest.json")).To(BeAnExistingFile())
+					mockRepo.AssertExpectations(GinkgoT())
+				})
+			})
+
+			When("db returns an error", func() {
+				BeforeEach(func() {
+					mockRepo.On("ScanUsers", mock.Anything, int32(0), mock.Anything).
+						Times(1).
+						Return(context.DeadlineExceeded)
+				})
+
+				It("does not write the manifest", func() {
+					defer cancel()
+
+					req := entities.ExportReq{Format: "ndjson"}
+					manifest, err := service.New(mockRepo, export.NewDirSink(dir), log).ExportUsers(ctx, req)
+					Expect(manifest).To(BeNil())
+					Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+
+					_, err = os.Stat(filepath.Join(dir, "users-manifest.json"))
+					Expect(os.IsNotExist(err)).To(BeTrue())
+				})
+			})
+		})
+	})
+})
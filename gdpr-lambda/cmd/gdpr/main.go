@@ -0,0 +1,106 @@
+// Command gdpr answers a data subject request from a terminal, with the same
+// configuration as the lambda.
+//
+//	DYNAMODB_TABLE_NAME=users GDPR_RECEIPT_KEY=... go run ./cmd/gdpr -action export -id usr-1 -out bundle.json
+//	DYNAMODB_TABLE_NAME=users GDPR_RECEIPT_KEY=... go run ./cmd/gdpr -action erase -email john@example.com -requested-by TICKET-42
+//
+// The bundle or the receipts are written as JSON to -out, stdout by default.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/ricardojonathanromero/lambda-golang-example/gdpr-lambda/internal/app"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/gdpr"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/wiring"
+	"os"
+)
+
+const appName = "gdpr"
+
+func main() {
+	var subject gdpr.Subject
+	action := flag.String("action", "export", "export gathers the data of the subject, erase removes it")
+	flag.StringVar(&subject.UserID, "id", "", "user id of the subject")
+	flag.StringVar(&subject.Email, "email", "", "email of the subject, used without -id")
+	requestedBy := flag.String("requested-by", "", "who asked for the erasure, written in the receipt")
+	out := flag.String("out", "", "output file, stdout when empty")
+	flag.Parse()
+
+	var cfg config.GDPR
+	configCtx, cancelConfig := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	secrets, err := config.NewProvider(configCtx)
+	if err == nil {
+		err = config.Load(&cfg, config.WithContext(configCtx), config.WithProvider(secrets))
+	}
+	cancelConfig()
+	if err != nil {
+		logging.New(logging.Opts{AppName: appName}).Fatal(err.Error())
+	}
+
+	customLog := logging.New(logging.Opts{
+		AppName: appName,
+		Level:   cfg.LogLevel,
+	})
+
+	if err = subject.Validate(); err != nil {
+		customLog.Fatal(err.Error())
+	}
+	if *action == "erase" && *requestedBy == "" {
+		customLog.Fatal("-requested-by is required to erase")
+	}
+
+	conn := wiring.NewConn()
+	defer func() {
+		if err = conn.Close(); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	initCtx, cancelInit := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	srv, release, err := app.NewService(initCtx, cfg, conn, customLog, metrics.NewNoop(), clientopts.New(cfg.DynamoDB.Policy()))
+	cancelInit()
+	if err != nil {
+		customLog.Fatal(err.Error())
+	}
+	defer release()
+
+	var result any
+	switch *action {
+	case "export":
+		result, err = srv.Export(context.Background(), subject)
+	case "erase":
+		var receipts []gdpr.Receipt
+		receipts, err = srv.Erase(context.Background(), subject, *requestedBy)
+		result = map[string]any{"receipts": receipts}
+	default:
+		customLog.Fatalf("unknown action %q, expected export or erase", *action)
+	}
+	if err != nil {
+		customLog.Fatalf("error running %s: %v", *action, err)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		// the bundle holds personal data, only the owner may read it
+		if w, err = os.OpenFile(*out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600); err != nil {
+			customLog.Fatalf("error creating %s: %v", *out, err)
+		}
+		defer func() {
+			if err = w.Close(); err != nil {
+				customLog.Error(err.Error())
+			}
+		}()
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(result); err != nil {
+		customLog.Fatalf("error writing result: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/ricardojonathanromero/lambda-golang-example/gdpr-lambda/internal/app"
+	"github.com/ricardojonathanromero/lambda-golang-example/gdpr-lambda/internal/handler"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/wiring"
+	"os"
+)
+
+const appName = "gdpr-lambda"
+
+func main() {
+	// configuration is loaded once, every problem is reported at once and
+	// ssm:// or secretsmanager:// references are resolved at cold start
+	var cfg config.GDPR
+	configCtx, cancelConfig := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	secrets, err := config.NewProvider(configCtx)
+	if err == nil {
+		err = config.Load(&cfg, config.WithContext(configCtx), config.WithProvider(secrets))
+	}
+	cancelConfig()
+	if err != nil {
+		logging.New(logging.Opts{AppName: appName}).Fatal(err.Error())
+	}
+
+	customLog := logging.New(logging.Opts{
+		AppName: appName,
+		Level:   cfg.LogLevel,
+	})
+
+	// metrics are written to stdout in embedded metric format
+	customMetrics := metrics.New(appName, metrics.NewEMFSink(os.Stdout, cfg.MetricsNamespace))
+
+	// spans are exported to the OTLP endpoint when OTEL_TRACES_EXPORTER=otlp
+	tracerProvider, err := tracing.Setup(context.Background(), tracing.Opts{
+		ServiceName: appName,
+		Exporter:    cfg.TracesExporter,
+	})
+	if err != nil {
+		customLog.Fatalf("error configuring tracing: %v", err)
+	}
+
+	defer func() {
+		if err = tracerProvider.Shutdown(context.Background()); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	// per-call timeouts are derived from the invocation deadline
+	clientOpts := clientopts.New(cfg.DynamoDB.Policy())
+
+	// the stores share one DynamoDB connection, opened on first use
+	conn := wiring.NewConn()
+	defer func() {
+		if err = conn.Close(); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	initCtx, cancelInit := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	srv, release, err := app.NewService(initCtx, cfg, conn, customLog, customMetrics, clientOpts)
+	cancelInit()
+	if err != nil {
+		customLog.Fatal(err.Error())
+	}
+	defer release()
+
+	// the data of any user is reachable, only admins may call
+	authMiddleware, err := auth.Open(cfg.Auth, customLog, customMetrics)
+	if err != nil {
+		customLog.Fatalf("error configuring authentication: %v", err)
+	}
+
+	// callers are throttled per route after authentication, so they are
+	// keyed by subject
+	limiterCtx, cancelLimiter := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	limiter, err := wiring.NewRateLimiter(limiterCtx, cfg.Common, cfg.RateLimit, conn, customLog, customMetrics, clientOpts)
+	cancelLimiter()
+	if err != nil {
+		customLog.Fatalf("error configuring rate limiting: %v", err)
+	}
+
+	h := handler.New(srv, customLog, customMetrics)
+	lambda.Start(tracing.WithFlush(tracerProvider, authMiddleware.Require(auth.ScopeAdmin, limiter.Wrap(h.HandleRequest))))
+}
@@ -0,0 +1,122 @@
+// Package app wires the service shared by the lambda and the command.
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/gdpr-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	dbInfra "github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/gdpr"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/wiring"
+	"os"
+)
+
+// NewService connects to the users of USER_STORE and to the tombstone table,
+// which is kept in DynamoDB through dbConn whatever the store. The returned
+// func releases the other connections.
+func NewService(ctx context.Context, cfg config.GDPR, dbConn *wiring.Conn, log logging.Logger, m metrics.Metrics, opts clientopts.Options) (service.Service, func(), error) {
+	key, err := cfg.ReceiptKey.Value(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error resolving GDPR_RECEIPT_KEY: %w", err)
+	}
+	signer, err := gdpr.NewSigner([]byte(key))
+	if err != nil {
+		return nil, nil, fmt.Errorf("GDPR_RECEIPT_KEY: %w", err)
+	}
+
+	// the export holds the decrypted PII, FIELD_ENCRYPTION must match the
+	// writers of the table
+	enc, err := fieldcrypt.Open(ctx, cfg.Encryption)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error configuring field encryption: %w", err)
+	}
+
+	conn, err := dbConn.Client()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error initializing db connection: %w", err)
+	}
+	var closers []func() error
+	release := func() {
+		for _, closer := range closers {
+			if err := closer(); err != nil {
+				log.Error(err.Error())
+			}
+		}
+	}
+
+	// the tombstone and verification token tables follow the provisioning
+	// of the users table
+	if cfg.DynamoDB.Provisioning == dbInfra.ModeCreate || cfg.DynamoDB.Provisioning == dbInfra.ModeReconcile {
+		if err = gdpr.CreateTable(ctx, conn, cfg.TombstoneTable); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("error provisioning tombstone table: %w", err)
+		}
+		if err = verification.CreateTable(ctx, conn, cfg.VerificationTable); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("error provisioning verification token table: %w", err)
+		}
+	}
+	tombstones := gdpr.NewDynamoDBTombstones(conn, cfg.TombstoneTable, log, m, opts)
+
+	// the receipts and the audit entries of the erasures are timestamped
+	// by the same clock
+	clk := clock.New()
+	var store userstore.UserRepository
+	history := audit.NewNoop()
+	if cfg.Storage.Backend == config.BackendSQL {
+		sqlStore, sqlDB, err := userstore.OpenSQL(ctx, cfg.Storage.SQL, log, m, opts, enc, cfg.Audit.TableName(), clk)
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("error opening sql store: %w", err)
+		}
+		closers = append(closers, sqlDB.Close)
+		store = sqlStore
+		if cfg.Audit.Enabled {
+			history = audit.NewSQL(sqlDB, cfg.Audit.Table, log, m, opts, enc)
+		}
+	} else {
+		tableName := cfg.DynamoDB.TableName
+		verifyCache := dbInfra.NewFileCache(os.TempDir(), dbInfra.DefaultVerifyTTL)
+		if err = dbInfra.New(conn, log, opts, verifyCache).Provision(ctx, tableName, cfg.DynamoDB.Provisioning); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("error provisioning table: %w", err)
+		}
+		if cfg.Audit.Enabled && (cfg.DynamoDB.Provisioning == dbInfra.ModeCreate || cfg.DynamoDB.Provisioning == dbInfra.ModeReconcile) {
+			if err = audit.CreateTable(ctx, conn, cfg.Audit.Table); err != nil {
+				release()
+				return nil, nil, fmt.Errorf("error provisioning audit table: %w", err)
+			}
+		}
+		store = userstore.NewDynamoDB(conn, tableName, log, m, opts, enc, cfg.Audit.TableName(), clk)
+		if cfg.Audit.Enabled {
+			history = audit.NewDynamoDB(conn, cfg.Audit.Table, log, m, opts, enc)
+		}
+	}
+
+	// the erased users are dropped from the cache of get-document when it
+	// is shared
+	if cfg.Cache.Backend == config.CacheRedis {
+		userCache, cacheCloser, err := cache.Open(ctx, cfg.Cache)
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("error opening cache: %w", err)
+		}
+		closers = append(closers, cacheCloser.Close)
+		store = userstore.NewInvalidating(store, userCache, cfg.Cache, log, m)
+	}
+
+	// the audit trail is exported with the user and redacted on erasure,
+	// the verification token is exported and deleted
+	tokens := verification.NewDynamoDBStore(conn, cfg.VerificationTable, log, m, opts)
+	return service.New(store, tombstones, signer, log, clk, gdpr.NewAuditSource(history), gdpr.NewVerificationSource(tokens)), release, nil
+}
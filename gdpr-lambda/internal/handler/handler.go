@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/gdpr-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/gdpr"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"net/http"
+	"time"
+)
+
+const (
+	// ResourceExports answers an access request with the bundle.
+	ResourceExports = "/gdpr/exports"
+	// ResourceErasures answers an erasure request with the receipts.
+	ResourceErasures = "/gdpr/erasures"
+
+	operationExport = "HandleExport"
+	operationErase  = "HandleErase"
+)
+
+type Handler interface {
+	// HandleRequest dispatches the proxy integration event on its resource.
+	HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	HandleExport(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	HandleErase(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}
+
+type handleImpl struct {
+	srv     service.Service
+	log     logging.Logger
+	metrics metrics.Metrics
+}
+
+func New(srv service.Service, log logging.Logger, m metrics.Metrics) Handler {
+	return &handleImpl{
+		srv:     srv,
+		log:     log,
+		metrics: m,
+	}
+}
+
+func (h *handleImpl) HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	resource := req.Resource
+	if resource == "" {
+		resource = req.Path
+	}
+
+	switch resource {
+	case ResourceExports:
+		return h.HandleExport(ctx, req)
+	case ResourceErasures:
+		return h.HandleErase(ctx, req)
+	default:
+		p := problem.New(http.StatusNotFound, "unknown resource "+resource)
+		p.Instance = req.Path
+		return p.Response(nil), nil
+	}
+}
+
+func (h *handleImpl) HandleExport(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx = logging.WithAPIGatewayRequest(ctx, req)
+	ctx, span := tracing.StartServer(tracing.WithAPIGatewayRequest(ctx, req), operationExport)
+	defer span.End()
+
+	defer h.metrics.HandlerLatency(operationExport, time.Now())
+	h.metrics.ColdStart(operationExport)
+
+	log := h.log.WithContext(ctx)
+	subject, err := decodeSubject(req)
+	if err != nil {
+		log.Errorf("error decoding subject: %v", err)
+		return h.errorResponse(req, operationExport, err), nil
+	}
+
+	bundle, err := h.srv.Export(ctx, subject)
+	if err != nil {
+		log.Errorf("error exporting subject: %v", err)
+		tracing.Error(span, err)
+		return h.errorResponse(req, operationExport, err), nil
+	}
+
+	body, err := json.Marshal(bundle)
+	if err != nil {
+		tracing.Error(span, err)
+		return h.errorResponse(req, operationExport, err), nil
+	}
+
+	log.Info("subject exported")
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":        "application/json",
+			"Content-Disposition": `attachment; filename="gdpr-export.json"`,
+			"Cache-Control":       "no-store",
+		},
+		Body: string(body),
+	}, nil
+}
+
+func (h *handleImpl) HandleErase(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx = logging.WithAPIGatewayRequest(ctx, req)
+	ctx, span := tracing.StartServer(tracing.WithAPIGatewayRequest(ctx, req), operationErase)
+	defer span.End()
+
+	defer h.metrics.HandlerLatency(operationErase, time.Now())
+	h.metrics.ColdStart(operationErase)
+
+	log := h.log.WithContext(ctx)
+	subject, err := decodeSubject(req)
+	if err != nil {
+		log.Errorf("error decoding subject: %v", err)
+		return h.errorResponse(req, operationErase, err), nil
+	}
+
+	// the receipt names who asked, the caller runs without identity only
+	// when authentication is off
+	requestedBy := "anonymous"
+	if identity, ok := auth.FromContext(ctx); ok {
+		requestedBy = identity.Subject
+	}
+
+	receipts, err := h.srv.Erase(ctx, subject, requestedBy)
+	if err != nil {
+		log.Errorf("error erasing subject: %v", err)
+		tracing.Error(span, err)
+		return h.errorResponse(req, operationErase, err), nil
+	}
+
+	body, err := json.Marshal(map[string]any{"receipts": receipts})
+	if err != nil {
+		tracing.Error(span, err)
+		return h.errorResponse(req, operationErase, err), nil
+	}
+
+	log.Infof("subject erased by %s", requestedBy)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+		},
+		Body: string(body),
+	}, nil
+}
+
+// errDecoding is answered with 400.
+var errDecoding = errors.New("body is not a valid subject")
+
+func decodeSubject(req events.APIGatewayProxyRequest) (gdpr.Subject, error) {
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return gdpr.Subject{}, errDecoding
+		}
+		body = decoded
+	}
+
+	var subject gdpr.Subject
+	if err := json.Unmarshal(body, &subject); err != nil {
+		return gdpr.Subject{}, errDecoding
+	}
+	return subject, subject.Validate()
+}
+
+func (h *handleImpl) errorResponse(req events.APIGatewayProxyRequest, operation string, err error) events.APIGatewayProxyResponse {
+	var p problem.Problem
+	switch {
+	case errors.Is(err, errDecoding), errors.Is(err, gdpr.ErrInvalidSubject):
+		p = problem.New(http.StatusBadRequest, err.Error())
+		h.metrics.Increment(operation, metrics.MetricValidationFailure)
+	case errors.Is(err, gdpr.ErrNotFound):
+		p = problem.New(http.StatusNotFound, "no user matches the subject")
+		h.metrics.Increment(operation, metrics.MetricNotFound)
+	case errors.Is(err, clientopts.ErrUnavailable):
+		p = problem.New(http.StatusServiceUnavailable, "service unavailable, retry later")
+	default:
+		p = problem.New(http.StatusInternalServerError, "the request could not be completed")
+	}
+	p.Instance = req.Path
+	return p.Response(nil)
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/gdpr"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+)
+
+type Service interface {
+	// Export gathers the records of every source about the users of the
+	// subject, gdpr.ErrNotFound when there is none.
+	Export(ctx context.Context, subject gdpr.Subject) (*gdpr.Bundle, error)
+	// Erase removes the records of every source about the users of the
+	// subject and returns one signed receipt per user. A user erased before
+	// is answered with the receipt of its tombstone.
+	Erase(ctx context.Context, subject gdpr.Subject, requestedBy string) ([]gdpr.Receipt, error)
+}
+
+type serviceImpl struct {
+	store      userstore.UserRepository
+	sources    []gdpr.Source
+	tombstones gdpr.Tombstones
+	signer     gdpr.Signer
+	log        logging.Logger
+	clock      clock.Clock
+}
+
+// New answers the requests from store and sources, the user record is
+// exported first and erased last so an interrupted erasure can be retried.
+// The bundles and the receipts are dated by clk.
+func New(store userstore.UserRepository, tombstones gdpr.Tombstones, signer gdpr.Signer, log logging.Logger, clk clock.Clock, sources ...gdpr.Source) Service {
+	return &serviceImpl{
+		store:      store,
+		sources:    append([]gdpr.Source{gdpr.NewUserSource(store)}, sources...),
+		tombstones: tombstones,
+		signer:     signer,
+		log:        log,
+		clock:      clk,
+	}
+}
+
+func (srv *serviceImpl) Export(ctx context.Context, subject gdpr.Subject) (*gdpr.Bundle, error) {
+	ctx, span := tracing.Start(ctx, "ExportSubject")
+	defer span.End()
+
+	log := srv.log.WithContext(ctx)
+	users, err := srv.resolve(ctx, subject)
+	if err != nil {
+		log.Errorf("error resolving subject: %v", err)
+		tracing.Error(span, err)
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, gdpr.ErrNotFound
+	}
+
+	bundle := &gdpr.Bundle{Subject: subject, GeneratedAt: srv.clock.Now().UTC()}
+	for _, user := range users {
+		data, err := gdpr.Collect(ctx, user, srv.sources)
+		if err != nil {
+			log.Errorf("error exporting user %s: %v", user.ID, err)
+			tracing.Error(span, err)
+			return nil, err
+		}
+		bundle.Users = append(bundle.Users, data)
+	}
+
+	log.Infof("subject exported - users: %d", len(bundle.Users))
+	return bundle, nil
+}
+
+func (srv *serviceImpl) Erase(ctx context.Context, subject gdpr.Subject, requestedBy string) ([]gdpr.Receipt, error) {
+	ctx, span := tracing.Start(ctx, "EraseSubject")
+	defer span.End()
+
+	// the audit entries of the erasure name who asked for it when the
+	// caller has no identity, such as the command line
+	ctx = audit.WithActor(ctx, requestedBy)
+
+	log := srv.log.WithContext(ctx)
+	users, err := srv.resolve(ctx, subject)
+	if err != nil {
+		log.Errorf("error resolving subject: %v", err)
+		tracing.Error(span, err)
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		if subject.UserID == "" {
+			return nil, gdpr.ErrNotFound
+		}
+		tombstone, err := srv.tombstones.Get(ctx, subject.UserID)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("user %s already erased", subject.UserID)
+		return []gdpr.Receipt{tombstone.Receipt}, nil
+	}
+
+	receipts := make([]gdpr.Receipt, 0, len(users))
+	for _, user := range users {
+		receipt, err := srv.erase(ctx, user, requestedBy)
+		if err != nil {
+			log.Errorf("error erasing user %s: %v", user.ID, err)
+			tracing.Error(span, err)
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	log.Infof("subject erased - users: %d", len(receipts))
+	return receipts, nil
+}
+
+// erase writes the tombstone before erasing the sources, the user record
+// last. Until the user record is gone a retry erases everything again and
+// replaces the tombstone.
+func (srv *serviceImpl) erase(ctx context.Context, user *models.UserDB, requestedBy string) (gdpr.Receipt, error) {
+	data, err := gdpr.Collect(ctx, user, srv.sources)
+	if err != nil {
+		return gdpr.Receipt{}, err
+	}
+
+	receipt := gdpr.Receipt{
+		ID:          uuid.NewString(),
+		UserID:      user.ID,
+		RequestedBy: requestedBy,
+		ErasedAt:    srv.clock.Now().UTC(),
+		Records:     data.Counts(),
+		Retained:    gdpr.RetainedStores,
+	}
+	if err = srv.signer.Sign(&receipt); err != nil {
+		return gdpr.Receipt{}, err
+	}
+
+	tombstone := gdpr.Tombstone{UserID: user.ID, ErasedAt: receipt.ErasedAt, Receipt: receipt}
+	if err = srv.tombstones.Put(ctx, tombstone); err != nil {
+		return gdpr.Receipt{}, err
+	}
+
+	for i := len(srv.sources) - 1; i >= 0; i-- {
+		source := srv.sources[i]
+		erased, err := source.Erase(ctx, user)
+		if err != nil {
+			return gdpr.Receipt{}, err
+		}
+		srv.log.WithContext(ctx).Debugf("erased %d %s records of user %s", erased, source.Name(), user.ID)
+	}
+	return receipt, nil
+}
+
+// resolve returns the users of the subject, by id first and otherwise by
+// email through every page of the store.
+func (srv *serviceImpl) resolve(ctx context.Context, subject gdpr.Subject) ([]*models.UserDB, error) {
+	if err := subject.Validate(); err != nil {
+		return nil, err
+	}
+
+	if subject.UserID != "" {
+		user, err := srv.store.Get(ctx, subject.UserID)
+		if errors.Is(err, userstore.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if subject.Email != "" && user.Email != subject.Email {
+			return nil, nil
+		}
+		return []*models.UserDB{user}, nil
+	}
+
+	var users []*models.UserDB
+	query := userstore.ListQuery{Limit: userstore.MaxPageSize, Filter: userstore.Filter{Email: subject.Email}}
+	for {
+		page, err := srv.store.List(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, page.Users...)
+		if page.NextCursor == "" {
+			return users, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,148 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/gdpr-lambda/internal/handler"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/gdpr"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) Export(ctx context.Context, subject gdpr.Subject) (*gdpr.Bundle, error) {
+	args := m.Called(ctx, subject)
+	bundle, _ := args.Get(0).(*gdpr.Bundle)
+	return bundle, args.Error(1)
+}
+
+func (m *MockService) Erase(ctx context.Context, subject gdpr.Subject, requestedBy string) ([]gdpr.Receipt, error) {
+	args := m.Called(ctx, subject, requestedBy)
+	receipts, _ := args.Get(0).([]gdpr.Receipt)
+	return receipts, args.Error(1)
+}
+
+var _ = Describe("Handler", func() {
+	var mockService *MockService
+	var sink *metrics.MemorySink
+	var h handler.Handler
+
+	appName := "gdpr-lambda-handler-test"
+
+	request := func(resource, body string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{Resource: resource, Path: resource, HTTPMethod: http.MethodPost, Body: body}
+	}
+
+	BeforeEach(func() {
+		log := logging.New(logging.Opts{AppName: appName, Level: "error"})
+		mockService = new(MockService)
+		sink = metrics.NewMemorySink()
+		h = handler.New(mockService, log, metrics.New(appName, sink))
+	})
+
+	Describe("exports", func() {
+		It("answers the bundle as an attachment", func() {
+			bundle := &gdpr.Bundle{Subject: gdpr.Subject{UserID: "usr-1"}, Users: []gdpr.UserData{{UserID: "usr-1"}}}
+			mockService.On("Export", mock.Anything, gdpr.Subject{UserID: "usr-1"}).Return(bundle, nil)
+
+			res, err := h.HandleRequest(context.Background(), request(handler.ResourceExports, `{"user_id": "usr-1"}`))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(res.Headers["Content-Disposition"]).To(ContainSubstring("attachment"))
+			Expect(res.Headers["Cache-Control"]).To(Equal("no-store"))
+
+			var actual gdpr.Bundle
+			Expect(json.Unmarshal([]byte(res.Body), &actual)).To(Succeed())
+			Expect(actual.Users).To(HaveLen(1))
+		})
+
+		It("decodes a base64 encoded body", func() {
+			mockService.On("Export", mock.Anything, gdpr.Subject{Email: "john@example.com"}).Return(&gdpr.Bundle{}, nil)
+
+			req := request(handler.ResourceExports, base64.StdEncoding.EncodeToString([]byte(`{"email": "john@example.com"}`)))
+			req.IsBase64Encoded = true
+			res, err := h.HandleRequest(context.Background(), req)
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("answers 404 for an unknown subject", func() {
+			mockService.On("Export", mock.Anything, mock.Anything).Return(nil, gdpr.ErrNotFound)
+
+			res, err := h.HandleExport(context.Background(), request(handler.ResourceExports, `{"user_id": "usr-9"}`))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(res.Headers["Content-Type"]).To(Equal(problem.ContentType))
+			Expect(sink.Sum("HandleExport", metrics.MetricNotFound)).To(Equal(float64(1)))
+		})
+	})
+
+	Describe("erasures", func() {
+		It("answers the receipts and names the caller", func() {
+			receipts := []gdpr.Receipt{{ID: "rcp-1", UserID: "usr-1", RequestedBy: "admin-1"}}
+			mockService.On("Erase", mock.Anything, gdpr.Subject{UserID: "usr-1"}, "admin-1").Return(receipts, nil)
+
+			ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "admin-1", Admin: true})
+			res, err := h.HandleRequest(ctx, request(handler.ResourceErasures, `{"user_id": "usr-1"}`))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+			var body struct {
+				Receipts []gdpr.Receipt `json:"receipts"`
+			}
+			Expect(json.Unmarshal([]byte(res.Body), &body)).To(Succeed())
+			Expect(body.Receipts).To(HaveLen(1))
+			Expect(body.Receipts[0].ID).To(Equal("rcp-1"))
+		})
+
+		It("answers 503 when the store is unavailable", func() {
+			mockService.On("Erase", mock.Anything, mock.Anything, "anonymous").Return(nil, fmt.Errorf("erasing: %w", clientopts.ErrUnavailable))
+
+			res, err := h.HandleErase(context.Background(), request(handler.ResourceErasures, `{"email": "john@example.com"}`))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("answers 500 without the details of other errors", func() {
+			mockService.On("Erase", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("table users is corrupted"))
+
+			res, err := h.HandleErase(context.Background(), request(handler.ResourceErasures, `{"user_id": "usr-1"}`))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(res.Body).NotTo(ContainSubstring("corrupted"))
+		})
+	})
+
+	DescribeTable("rejects invalid subjects",
+		func(resource, body string) {
+			res, err := h.HandleRequest(context.Background(), request(resource, body))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+			mockService.AssertNotCalled(GinkgoT(), "Export", mock.Anything, mock.Anything)
+			mockService.AssertNotCalled(GinkgoT(), "Erase", mock.Anything, mock.Anything, mock.Anything)
+		},
+		Entry("export of malformed json", handler.ResourceExports, `{"user_id": `),
+		Entry("export without id and email", handler.ResourceExports, `{}`),
+		Entry("erasure without id and email", handler.ResourceErasures, `{"user_id": ""}`),
+	)
+
+	It("answers 404 for an unknown resource", func() {
+		res, err := h.HandleRequest(context.Background(), request("/gdpr/unknown", `{}`))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusNotFound))
+	})
+})
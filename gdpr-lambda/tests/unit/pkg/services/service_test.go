@@ -0,0 +1,183 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/gdpr-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/gdpr"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"time"
+)
+
+// recordingSource holds records of every user and remembers the erasures.
+type recordingSource struct {
+	records map[string][]any
+	erased  []string
+	err     error
+}
+
+func (s *recordingSource) Name() string { return "notes" }
+
+func (s *recordingSource) Export(_ context.Context, user *models.UserDB) ([]any, error) {
+	return s.records[user.ID], nil
+}
+
+func (s *recordingSource) Erase(_ context.Context, user *models.UserDB) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.erased = append(s.erased, user.ID)
+	n := len(s.records[user.ID])
+	delete(s.records, user.ID)
+	return n, nil
+}
+
+var _ = Describe("Service", func() {
+	var ctx context.Context
+	var store userstore.UserRepository
+	var tombstones gdpr.Tombstones
+	var signer gdpr.Signer
+	var notes *recordingSource
+	var clk *clock.Fake
+	var srv service.Service
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		log := logging.New(logging.Opts{AppName: "gdpr-lambda-service-test", Level: "error"})
+		opts := clientopts.New(clientopts.DefaultPolicy())
+
+		client := dynamodbapi.NewInMemory()
+		Expect(db.New(client, log, opts, db.NewNoopCache()).ConfigureTable(ctx, "users")).To(Succeed())
+		now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		clk = clock.NewFake(now)
+		store = userstore.NewDynamoDB(client, "users", log, metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "", clk)
+
+		for _, user := range []*models.UserDB{
+			{ID: "usr-1", Name: "john", Email: "john@example.com", CreatedAt: now, UpdatedAt: now},
+			{ID: "usr-2", Name: "johnny", Email: "john@example.com", CreatedAt: now, UpdatedAt: now},
+			{ID: "usr-3", Name: "jane", Email: "jane@example.com", CreatedAt: now, UpdatedAt: now},
+		} {
+			Expect(store.Insert(ctx, user)).To(Succeed())
+		}
+
+		var err error
+		signer, err = gdpr.NewSigner(bytes.Repeat([]byte("k"), 32))
+		Expect(err).To(BeNil())
+		tombstones = gdpr.NewMemoryTombstones()
+		notes = &recordingSource{records: map[string][]any{"usr-1": {"first", "second"}}}
+		srv = service.New(store, tombstones, signer, log, clk, notes)
+	})
+
+	Describe("Export", func() {
+		It("gathers every source about the user", func() {
+			bundle, err := srv.Export(ctx, gdpr.Subject{UserID: "usr-1"})
+			Expect(err).To(BeNil())
+			Expect(bundle.GeneratedAt).To(Equal(clk.Now()))
+			Expect(bundle.Users).To(HaveLen(1))
+			Expect(bundle.Users[0].Records[gdpr.SourceUsers]).To(HaveLen(1))
+			Expect(bundle.Users[0].Records["notes"]).To(Equal([]any{"first", "second"}))
+		})
+
+		It("gathers every user of the email", func() {
+			bundle, err := srv.Export(ctx, gdpr.Subject{Email: "john@example.com"})
+			Expect(err).To(BeNil())
+			Expect(bundle.Users).To(HaveLen(2))
+			Expect(bundle.Users[1].Records["notes"]).To(BeEmpty())
+		})
+
+		It("returns not found for an unknown subject", func() {
+			_, err := srv.Export(ctx, gdpr.Subject{UserID: "usr-9"})
+			Expect(err).To(MatchError(gdpr.ErrNotFound))
+
+			_, err = srv.Export(ctx, gdpr.Subject{UserID: "usr-1", Email: "jane@example.com"})
+			Expect(err).To(MatchError(gdpr.ErrNotFound))
+		})
+
+		It("rejects an empty subject", func() {
+			_, err := srv.Export(ctx, gdpr.Subject{})
+			Expect(err).To(MatchError(gdpr.ErrInvalidSubject))
+		})
+	})
+
+	Describe("Erase", func() {
+		It("erases the user and leaves a tombstone with a signed receipt", func() {
+			clk.Advance(time.Hour)
+			receipts, err := srv.Erase(ctx, gdpr.Subject{UserID: "usr-1"}, "admin-1")
+			Expect(err).To(BeNil())
+			Expect(receipts).To(HaveLen(1))
+
+			receipt := receipts[0]
+			Expect(receipt.UserID).To(Equal("usr-1"))
+			Expect(receipt.RequestedBy).To(Equal("admin-1"))
+			Expect(receipt.ErasedAt).To(Equal(clk.Now()))
+			Expect(receipt.Retained).To(HaveKey("ratelimit"))
+			Expect(receipt.Retained).To(HaveKey("idempotency"))
+			Expect(receipt.Retained).To(HaveKey("outbox"))
+			Expect(receipt.Records).To(Equal(map[string]int{gdpr.SourceUsers: 1, "notes": 2}))
+			Expect(signer.Verify(receipt)).To(Succeed())
+
+			_, err = store.Get(ctx, "usr-1")
+			Expect(err).To(MatchError(userstore.ErrNotFound))
+			Expect(notes.erased).To(Equal([]string{"usr-1"}))
+
+			tombstone, err := tombstones.Get(ctx, "usr-1")
+			Expect(err).To(BeNil())
+			Expect(tombstone.Receipt.ID).To(Equal(receipt.ID))
+		})
+
+		It("answers a repeated request with the same receipt", func() {
+			first, err := srv.Erase(ctx, gdpr.Subject{UserID: "usr-1"}, "admin-1")
+			Expect(err).To(BeNil())
+
+			again, err := srv.Erase(ctx, gdpr.Subject{UserID: "usr-1"}, "admin-2")
+			Expect(err).To(BeNil())
+			Expect(again).To(HaveLen(1))
+			Expect(again[0].ID).To(Equal(first[0].ID))
+			Expect(again[0].RequestedBy).To(Equal("admin-1"))
+		})
+
+		It("erases every user of the email", func() {
+			receipts, err := srv.Erase(ctx, gdpr.Subject{Email: "john@example.com"}, "admin-1")
+			Expect(err).To(BeNil())
+			Expect(receipts).To(HaveLen(2))
+
+			_, err = store.Get(ctx, "usr-3")
+			Expect(err).To(BeNil())
+		})
+
+		It("keeps the user when a source fails so the erasure can be retried", func() {
+			notes.err = errors.New("notes unavailable")
+			_, err := srv.Erase(ctx, gdpr.Subject{UserID: "usr-1"}, "admin-1")
+			Expect(err).To(MatchError("notes unavailable"))
+
+			_, err = store.Get(ctx, "usr-1")
+			Expect(err).To(BeNil())
+
+			notes.err = nil
+			receipts, err := srv.Erase(ctx, gdpr.Subject{UserID: "usr-1"}, "admin-1")
+			Expect(err).To(BeNil())
+			Expect(receipts).To(HaveLen(1))
+			_, err = store.Get(ctx, "usr-1")
+			Expect(err).To(MatchError(userstore.ErrNotFound))
+		})
+
+		It("returns not found for an unknown subject", func() {
+			_, err := srv.Erase(ctx, gdpr.Subject{UserID: "usr-9"}, "admin-1")
+			Expect(err).To(MatchError(gdpr.ErrNotFound))
+
+			_, err = srv.Erase(ctx, gdpr.Subject{Email: "nobody@example.com"}, "admin-1")
+			Expect(err).To(MatchError(gdpr.ErrNotFound))
+		})
+	})
+})
@@ -1,55 +1,139 @@
 package main
 
 import (
+	"context"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/ricardojonathanromero/go-utilities/db/dynamodb"
-	"github.com/ricardojonathanromero/go-utilities/environment"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/internal/handler"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/repository"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
 	dbInfra "github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/wiring"
+	"os"
 )
 
-const (
-	logLevelEnv        = "LOG_LEVEL"
-	defaultLogLevelEnv = "info"
-	envTableName       = "DYNAMODB_TABLE_NAME"
-	defaultEmpty       = ""
-	appName            = "get-all-documents-lambda"
-)
+const appName = "get-all-documents-lambda"
 
 func main() {
-	logLevel := environment.GetEnv(logLevelEnv, defaultLogLevelEnv)
+	// configuration is loaded once, every problem is reported at once and
+	// ssm:// or secretsmanager:// references are resolved at cold start
+	var cfg config.GetAllDocuments
+	configCtx, cancelConfig := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	secrets, err := config.NewProvider(configCtx)
+	if err == nil {
+		err = config.Load(&cfg, config.WithContext(configCtx), config.WithProvider(secrets))
+	}
+	cancelConfig()
+	if err != nil {
+		logging.New(logging.Opts{AppName: appName}).Fatal(err.Error())
+	}
 
-	customLog := logger.NewLoggerWithOptions(logger.Opts{
+	customLog := logging.New(logging.Opts{
 		AppName: appName,
-		Level:   logLevel,
+		Level:   cfg.LogLevel,
 	})
 
-	// connect to db
-	db := dynamodb.New()
-	conn, err := db.Connect()
+	// metrics are written to stdout in embedded metric format
+	customMetrics := metrics.New(appName, metrics.NewEMFSink(os.Stdout, cfg.MetricsNamespace))
+
+	// spans are exported to the OTLP endpoint when OTEL_TRACES_EXPORTER=otlp
+	tracerProvider, err := tracing.Setup(context.Background(), tracing.Opts{
+		ServiceName: appName,
+		Exporter:    cfg.TracesExporter,
+	})
 	if err != nil {
-		customLog.Fatalf("error initializing db connection: %s", err.Error())
+		customLog.Fatalf("error configuring tracing: %v", err)
 	}
 
 	defer func() {
-		if err = db.Disconnect(); err != nil {
+		if err = tracerProvider.Shutdown(context.Background()); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	// per-call timeouts are derived from the invocation deadline
+	clientOpts := clientopts.New(cfg.DynamoDB.Policy())
+
+	// the stores share one DynamoDB connection, opened on first use
+	conn := wiring.NewConn()
+	defer func() {
+		if err = conn.Close(); err != nil {
 			customLog.Error(err.Error())
 		}
 	}()
 
-	// configure table
-	tableName := environment.GetEnv(envTableName, defaultEmpty)
-	err = dbInfra.New(conn, customLog).ConfigureTable(tableName)
+	// init dependency injection, the users are kept in the DynamoDB table
+	// unless USER_STORE=sql
+	repo, closeRepo := newRepository(cfg.Common, conn, customLog, customMetrics, clientOpts)
+	defer closeRepo()
+	srv := service.New(repo, customLog)
+
+	// callers are authenticated per AUTH_MODE and need the read scope, the
+	// handler lists the users they created to the non admins
+	authMiddleware, err := auth.Open(cfg.Auth, customLog, customMetrics)
 	if err != nil {
-		customLog.Fatalf("error configuring table: %v", err)
+		customLog.Fatalf("error configuring authentication: %v", err)
 	}
 
-	// init dependency injection
-	repo := repository.New(conn, tableName, customLog)
-	srv := service.New(repo, customLog)
+	// callers are throttled per route after authentication, so they are
+	// keyed by subject
+	limiterCtx, cancelLimiter := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	limiter, err := wiring.NewRateLimiter(limiterCtx, cfg.Common, cfg.RateLimit, conn, customLog, customMetrics, clientOpts)
+	cancelLimiter()
+	if err != nil {
+		customLog.Fatalf("error configuring rate limiting: %v", err)
+	}
+
+	h := handler.New(srv, customLog, customMetrics, cfg.Timezone)
+	lambda.Start(tracing.WithFlush(tracerProvider, authMiddleware.Require(auth.ScopeRead, limiter.Wrap(h.HandleRequest))))
+}
+
+// newRepository connects to the backend selected by USER_STORE, the DynamoDB
+// table through dbConn. The returned func releases the other backends.
+func newRepository(cfg config.Common, dbConn *wiring.Conn, log logging.Logger, m metrics.Metrics, opts clientopts.Options) (repository.Repository, func()) {
+	initCtx, cancelInit := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	defer cancelInit()
+
+	// PII attributes are encrypted at rest unless FIELD_ENCRYPTION=off
+	enc, err := fieldcrypt.Open(initCtx, cfg.Encryption)
+	if err != nil {
+		log.Fatalf("error configuring field encryption: %v", err)
+	}
+
+	if cfg.Storage.Backend == config.BackendSQL {
+		store, closer, err := userstore.OpenSQL(initCtx, cfg.Storage.SQL, log, m, opts, enc, cfg.Audit.TableName(), clock.New())
+		if err != nil {
+			log.Fatalf("error opening sql store: %v", err)
+		}
+
+		return repository.NewFromStore(store), func() {
+			if err = closer.Close(); err != nil {
+				log.Error(err.Error())
+			}
+		}
+	}
+
+	// connect to db
+	conn, err := dbConn.Client()
+	if err != nil {
+		log.Fatalf("error initializing db connection: %s", err.Error())
+	}
+
+	// provision table, production runs with verify or off and relies on the
+	// bootstrap command of the internal module to create it
+	tableName := cfg.DynamoDB.TableName
+	verifyCache := dbInfra.NewFileCache(os.TempDir(), dbInfra.DefaultVerifyTTL)
+	if err = dbInfra.New(conn, log, opts, verifyCache).Provision(initCtx, tableName, cfg.DynamoDB.Provisioning); err != nil {
+		log.Fatalf("error provisioning table: %v", err)
+	}
 
-	lambda.Start(handler.New(srv, customLog).HandleRequest)
+	return repository.New(conn, tableName, log, m, opts, enc), func() {}
 }
@@ -2,35 +2,102 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dto"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/timezone"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/encoding"
 	"net/http"
+	"time"
 )
 
 type Handler interface {
 	HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 }
 
+const operationHandleRequest = "HandleRequest"
+
 type handleImpl struct {
-	srv service.Service
-	log logger.Logger
+	srv     service.Service
+	log     logging.Logger
+	metrics metrics.Metrics
+	// timezone renders the times when the request asks for no zone.
+	timezone *time.Location
 }
 
-func New(srv service.Service, log logger.Logger) Handler {
+func New(srv service.Service, log logging.Logger, m metrics.Metrics, tz *time.Location) Handler {
 	return &handleImpl{
-		srv: srv,
-		log: log,
+		srv:      srv,
+		log:      log,
+		metrics:  m,
+		timezone: tz,
 	}
 }
 
-func (h *handleImpl) HandleRequest(ctx context.Context, _ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	h.log.Debug("handleRequest")
-	users, err := h.srv.LookingUpUsers(ctx)
+func (h *handleImpl) HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx = logging.WithAPIGatewayRequest(ctx, req)
+	ctx, span := tracing.StartServer(tracing.WithAPIGatewayRequest(ctx, req), operationHandleRequest)
+	defer span.End()
+
+	log := h.log.WithContext(ctx)
+	log.Debug("handleRequest")
+	defer h.metrics.HandlerLatency(operationHandleRequest, time.Now())
+	h.metrics.ColdStart(operationHandleRequest)
+
+	loc, err := timezone.FromRequest(req.Headers, req.QueryStringParameters, h.timezone)
+	if err != nil {
+		log.Warnf("timezone is not valid: %v", err)
+		h.metrics.Increment(operationHandleRequest, metrics.MetricValidationFailure)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			Body: `{"message": "timezone is not valid"}`,
+		}, nil
+	}
+
+	// the users are listed whatever their status unless one is asked
+	var status domain.Status
+	if raw, ok := req.QueryStringParameters["status"]; ok && raw != "" {
+		if status, err = domain.ParseStatus(raw); err != nil {
+			log.Warnf("status is not valid: %v", err)
+			h.metrics.Increment(operationHandleRequest, metrics.MetricValidationFailure)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Headers: map[string]string{
+					"Content-Type": "application/json",
+				},
+				Body: `{"message": "status is not valid"}`,
+			}, nil
+		}
+	}
+
+	users, err := h.srv.LookingUpUsers(ctx, status)
+	if errors.Is(err, clientopts.ErrUnavailable) {
+		log.Errorf("dynamodb unavailable: %v", err)
+		tracing.Error(span, err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusServiceUnavailable,
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			Body: `{"message": "service unavailable, retry later"}`,
+		}, nil
+	}
+
 	if err != nil {
-		h.log.Errorf("error from service: %v", err)
+		log.Errorf("error from service: %v", err)
+		tracing.Error(span, err)
+		h.metrics.Increment(operationHandleRequest, metrics.MetricConflict)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusConflict,
 			Headers: map[string]string{
@@ -40,12 +107,24 @@ func (h *handleImpl) HandleRequest(ctx context.Context, _ events.APIGatewayProxy
 		}, nil
 	}
 
-	h.log.Debug("success response!")
+	// non-admin callers only list the users they created
+	if identity, ok := auth.FromContext(ctx); ok && !identity.Admin {
+		owned := make([]domain.User, 0, len(users))
+		for _, user := range users {
+			if identity.CanAccessUser(user.OwnerSubject) {
+				owned = append(owned, user)
+			}
+		}
+		users = owned
+	}
+
+	log.Debug("success response!")
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
 		Headers: map[string]string{
 			"Content-Type": "application/json",
+			"Vary":         timezone.HeaderAcceptTimezone,
 		},
-		Body: encoding.ToString(users),
+		Body: encoding.ToString(dto.NewUserResponses(users, loc)),
 	}, nil
 }
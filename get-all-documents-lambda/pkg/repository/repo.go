@@ -4,52 +4,90 @@ import (
 	"context"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/ricardojonathanromero/go-utilities/logger"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"time"
 )
 
 type Repository interface {
-	FindAllDocuments(ctx context.Context) ([]*models.UserDB, error)
+	// FindAllDocuments returns the users in status, every user when empty.
+	FindAllDocuments(ctx context.Context, status string) ([]*models.UserDB, error)
 }
 
+const operationFindAllDocuments = "FindAllDocuments"
+
 type repositoryImpl struct {
-	conn      *dynamodb.Client
-	log       logger.Logger
+	conn      dynamodbapi.Scanner
+	log       logging.Logger
+	metrics   metrics.Metrics
+	opts      clientopts.Options
+	enc       fieldcrypt.Encryptor
 	tableName string
 }
 
-func New(conn *dynamodb.Client, tableName string, log logger.Logger) Repository {
+func New(conn dynamodbapi.Scanner, tableName string, log logging.Logger, m metrics.Metrics, opts clientopts.Options, enc fieldcrypt.Encryptor) Repository {
 	return &repositoryImpl{
 		conn:      conn,
 		log:       log,
+		metrics:   m,
+		opts:      opts,
+		enc:       enc,
 		tableName: tableName,
 	}
 }
 
-func (repo *repositoryImpl) FindAllDocuments(ctx context.Context) ([]*models.UserDB, error) {
+func (repo *repositoryImpl) FindAllDocuments(ctx context.Context, status string) ([]*models.UserDB, error) {
+	log := repo.log.WithContext(ctx)
 	// scan input
 	input := &dynamodb.ScanInput{
-		TableName: aws.String(repo.tableName),
+		TableName:              aws.String(repo.tableName),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	if status != "" {
+		input.FilterExpression = aws.String("#status = :status")
+		input.ExpressionAttributeNames = map[string]string{"#status": "Status"}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: status},
+		}
+		// the users stored before the lifecycle have no status
+		if status == models.DefaultStatus {
+			input.FilterExpression = aws.String("(#status = :status OR attribute_not_exists(#status))")
+		}
 	}
 
-	repo.log.Debugf("executing scan in table: %s", repo.tableName)
-	output, err := repo.conn.Scan(ctx, input)
+	callCtx, cancel, err := repo.opts.Context(ctx, operationFindAllDocuments)
 	if err != nil {
-		// eval error
-		repo.log.Errorf("error executing dynamodb fn: %s", err)
+		log.Errorf("error executing dynamodb fn: %s", err)
 		return nil, err
 	}
+	defer cancel()
+
+	log.Debugf("executing scan in table: %s", repo.tableName)
+	start := time.Now()
+	output, err := repo.conn.Scan(callCtx, input, tracing.DynamoDB, repo.opts.DynamoDB)
+	repo.metrics.RepositoryLatency(operationFindAllDocuments, start)
+	if err != nil {
+		// eval error
+		log.Errorf("error executing dynamodb fn: %s", err)
+		return nil, repo.opts.Err(operationFindAllDocuments, err)
+	}
 
-	repo.log.Debug("scan response received, serializing response ...")
+	repo.metrics.ConsumedCapacity(operationFindAllDocuments, output.ConsumedCapacity)
+	log.Debug("scan response received, serializing response ...")
 	var users []*models.UserDB
-	err = attributevalue.UnmarshalListOfMaps(output.Items, &users)
+	err = fieldcrypt.UnmarshalListOfMaps(ctx, repo.enc, output.Items, &users)
 	if err != nil {
-		repo.log.Errorf("error serializing reponse into model: %s", err)
+		log.Errorf("error serializing reponse into model: %s", err)
 		return nil, err
 	}
 
-	repo.log.Debugf("response serialized - total items: %d", len(users))
+	log.Debugf("response serialized - total items: %d", len(users))
 	return users, nil
 }
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+)
+
+type storeImpl struct {
+	store userstore.UserRepository
+}
+
+// NewFromStore reads the users through a userstore backend, used when
+// USER_STORE selects another database than the DynamoDB table.
+func NewFromStore(store userstore.UserRepository) Repository {
+	return &storeImpl{store: store}
+}
+
+func (repo *storeImpl) FindAllDocuments(ctx context.Context, status string) ([]*models.UserDB, error) {
+	users := []*models.UserDB{}
+	query := userstore.ListQuery{Limit: userstore.MaxPageSize, Filter: userstore.Filter{Status: status}}
+	for {
+		page, err := repo.store.List(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, page.Users...)
+
+		if page.NextCursor == "" {
+			return users, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
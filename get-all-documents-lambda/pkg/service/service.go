@@ -2,37 +2,48 @@ package service
 
 import (
 	"context"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/repository"
-	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/encoding"
 )
 
 type Service interface {
-	LookingUpUsers(ctx context.Context) ([]*models.UserDB, error)
+	// LookingUpUsers returns the users in status, every user when empty.
+	LookingUpUsers(ctx context.Context, status domain.Status) ([]domain.User, error)
 }
 
 type serviceImpl struct {
 	repo repository.Repository
-	log  logger.Logger
+	log  logging.Logger
 }
 
-func New(repo repository.Repository, log logger.Logger) Service {
+func New(repo repository.Repository, log logging.Logger) Service {
 	return &serviceImpl{
 		repo: repo,
 		log:  log,
 	}
 }
 
-func (srv *serviceImpl) LookingUpUsers(ctx context.Context) ([]*models.UserDB, error) {
-	srv.log.Debug("looking for all users")
-	users, err := srv.repo.FindAllDocuments(ctx)
+func (srv *serviceImpl) LookingUpUsers(ctx context.Context, status domain.Status) ([]domain.User, error) {
+	ctx, span := tracing.Start(ctx, "LookingUpUsers")
+	defer span.End()
+
+	log := srv.log.WithContext(ctx)
+	log.Debug("looking for all users")
+	users, err := srv.repo.FindAllDocuments(ctx, string(status))
 	if err != nil {
 		// do something
-		srv.log.Errorf("error from repository: %s", err)
+		log.Errorf("error from repository: %s", err)
+		tracing.Error(span, err)
 		return nil, err
 	}
 
-	srv.log.Debug(encoding.ToString(users))
-	return users, err
+	log.Debug(encoding.ToLogString(users))
+	result := make([]domain.User, 0, len(users))
+	for _, user := range users {
+		result = append(result, user.ToDomain())
+	}
+	return result, nil
 }
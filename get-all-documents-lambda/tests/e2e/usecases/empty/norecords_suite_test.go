@@ -6,8 +6,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/allocate"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/tests"
 	"testing"
@@ -15,7 +16,7 @@ import (
 )
 
 var _ = BeforeSuite(func() {
-	log = logger.NewLoggerWithOptions(logger.Opts{
+	log = logging.New(logging.Opts{
 		AppName: "create-user-lambda-e2e-single-record",
 		Level:   "debug",
 	})
@@ -32,11 +33,11 @@ var _ = BeforeSuite(func() {
 	}
 
 	log.Debugf("init db connection in port %d", port)
-	// init docker cli
-	dynamodbTestConn = tests.New(fmt.Sprintf("%d", port))
+	// in-memory unless E2E_DYNAMODB=docker
+	dynamodbTestConn = tests.NewFromEnv(fmt.Sprintf("%d", port))
 
-	// start container
-	log.Debugf("starting docker dynamodb container")
+	// start container, no-op in memory
+	log.Debugf("starting dynamodb")
 	err := dynamodbTestConn.StartDynamoDB()
 	Expect(err).To(BeNil())
 
@@ -69,13 +70,13 @@ var _ = BeforeSuite(func() {
 
 	// configure table
 	log.Debugf("configuring table")
-	err = db.New(conn, log).ConfigureTable(tableName)
+	err = db.New(conn, log, clientopts.New(clientopts.DefaultPolicy()), db.NewNoopCache()).ConfigureTable(context.Background(), tableName)
 	Expect(err).To(BeNil())
 })
 
 var _ = AfterSuite(func() {
 	if dynamodbTestConn != nil {
-		// ends docker container
+		// ends docker container or drops the in-memory tables
 		dynamodbTestConn.Shutdown()
 	}
 })
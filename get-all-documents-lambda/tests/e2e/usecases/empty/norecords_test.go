@@ -7,10 +7,13 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/internal/handler"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/repository"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/tests"
 	"net/http"
 	"time"
@@ -25,7 +28,7 @@ var (
 	port             int
 	dynamodbTestConn tests.DBSuite
 	conn             *dynamodb.Client
-	log              logger.Logger
+	log              logging.Logger
 )
 
 var _ = Describe("no records", func() {
@@ -34,9 +37,9 @@ var _ = Describe("no records", func() {
 	var ctx context.Context
 
 	BeforeEach(func() {
-		repo := repository.New(conn, tableName, log)
+		repo := repository.New(conn, tableName, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 		srv := service.New(repo, log)
-		hdl = handler.New(srv, log)
+		hdl = handler.New(srv, log, metrics.NewNoop(), time.UTC)
 
 		lambdaCtx = &lambdacontext.LambdaContext{
 			AwsRequestID:       "awsRequestId1234",
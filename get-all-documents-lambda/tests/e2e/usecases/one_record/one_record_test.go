@@ -9,10 +9,14 @@ import (
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/internal/handler"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/repository"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dto"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/tests"
 	"net/http"
@@ -28,7 +32,7 @@ var (
 	port             int
 	dynamodbTestConn tests.DBSuite
 	conn             *dynamodb.Client
-	log              logger.Logger
+	log              logging.Logger
 )
 
 var _ = Describe("one record", func() {
@@ -37,9 +41,9 @@ var _ = Describe("one record", func() {
 	var ctx context.Context
 
 	BeforeEach(func() {
-		repo := repository.New(conn, tableName, log)
+		repo := repository.New(conn, tableName, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 		srv := service.New(repo, log)
-		hdl = handler.New(srv, log)
+		hdl = handler.New(srv, log, metrics.NewNoop(), time.UTC)
 
 		lambdaCtx = &lambdacontext.LambdaContext{
 			AwsRequestID:       "awsRequestId1234",
@@ -92,7 +96,7 @@ var _ = Describe("one record", func() {
 					Expect(res.StatusCode).To(Equal(http.StatusOK))
 					Expect(res.Body).NotTo(BeEmpty())
 
-					var result []*models.UserDB
+					var result []dto.UserResponse
 					err := json.Unmarshal([]byte(res.Body), &result)
 					Expect(err).To(BeNil())
 					Expect(result).To(HaveLen(1))
@@ -8,10 +8,15 @@ import (
 	"github.com/aws/aws-lambda-go/lambdacontext"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/internal/handler"
-	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dto"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/codes"
 	"net/http"
 	"time"
 )
@@ -20,22 +25,22 @@ type MockService struct {
 	mock.Mock
 }
 
-func (m *MockService) LookingUpUsers(ctx context.Context) ([]*models.UserDB, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*models.UserDB), args.Error(1)
+func (m *MockService) LookingUpUsers(ctx context.Context, status domain.Status) ([]domain.User, error) {
+	args := m.Called(ctx, status)
+	return args.Get(0).([]domain.User), args.Error(1)
 }
 
 var _ = Describe("Handler", func() {
 	var mockService *MockService
 	var lambdaCtx *lambdacontext.LambdaContext
 	var ctx context.Context
-	var log logger.Logger
+	var log logging.Logger
 
 	appName := "get-all-documents-lambda-handler-test"
 	logLevel := "debug"
 
 	BeforeEach(func() {
-		log = logger.NewLoggerWithOptions(logger.Opts{AppName: appName, Level: logLevel})
+		log = logging.New(logging.Opts{AppName: appName, Level: logLevel})
 		mockService = new(MockService)
 		lambdaCtx = &lambdacontext.LambdaContext{
 			AwsRequestID:       "awsRequestId1234",
@@ -69,17 +74,17 @@ var _ = Describe("Handler", func() {
 						},
 					}
 
-					mockService.On("LookingUpUsers", ctx).
+					mockService.On("LookingUpUsers", mock.Anything, domain.Status("")).
 						Times(1).
-						Return([]*models.UserDB{
+						Return([]domain.User{
 							{
 								ID:        "1",
 								Name:      "john",
 								Lastname:  "smith",
 								Age:       28,
 								Email:     "john.smith@test.com",
-								CreatedAt: time.Now(),
-								UpdatedAt: time.Now(),
+								CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+								UpdatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
 							},
 						}, err)
 				})
@@ -87,19 +92,148 @@ var _ = Describe("Handler", func() {
 				It("can get 200 http code from response", func() {
 					defer cancel()
 
-					res, errRes := handler.New(mockService, log).HandleRequest(ctx, req)
+					res, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(ctx, req)
 					Expect(errRes).To(BeNil())
 					Expect(res).NotTo(BeNil())
 					Expect(res.Headers).To(HaveKeyWithValue("Content-Type", "application/json"))
 					Expect(res.StatusCode).To(Equal(http.StatusOK))
 					Expect(res.Body).NotTo(BeEmpty())
 
-					var expectRes []*models.UserDB
+					var expectRes []dto.UserResponse
 					err = json.Unmarshal([]byte(res.Body), &expectRes)
 					Expect(err).To(BeNil())
 					Expect(expectRes).To(HaveLen(1))
 					Expect(expectRes[0].ID).To(Equal("1"))
 				})
+
+				It("continues the trace sent in the traceparent header", func() {
+					defer cancel()
+
+					recorder := tracing.NewRecorder()
+					req.Headers["traceparent"] = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+					_, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(ctx, req)
+					Expect(errRes).To(BeNil())
+					Expect(tracing.SpanNames(recorder)).To(Equal([]string{"HandleRequest"}))
+
+					span := recorder.Ended()[0]
+					Expect(span.SpanContext().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+					Expect(span.Parent().SpanID().String()).To(Equal("00f067aa0ba902b7"))
+					Expect(span.Status().Code).NotTo(Equal(codes.Error))
+				})
+
+				It("renders the times in the timezone of the request", func() {
+					defer cancel()
+
+					req.Headers["Accept-Timezone"] = "America/Mexico_City"
+
+					res, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(ctx, req)
+					Expect(errRes).To(BeNil())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+					Expect(res.Headers).To(HaveKeyWithValue("Vary", "Accept-Timezone"))
+
+					var expectRes []map[string]any
+					Expect(json.Unmarshal([]byte(res.Body), &expectRes)).To(Succeed())
+					Expect(expectRes).To(HaveLen(1))
+					Expect(expectRes[0]["created_at"]).To(HaveSuffix("-06:00"))
+				})
+			})
+
+			When("the caller is not an admin", func() {
+				BeforeEach(func() {
+					mockService.On("LookingUpUsers", mock.Anything, domain.Status("")).
+						Return([]domain.User{
+							{ID: "1", Name: "john", OwnerSubject: "sub-1"},
+							{ID: "2", Name: "jane", OwnerSubject: "sub-2"},
+							{ID: "3", Name: "jim"},
+						}, nil)
+				})
+
+				It("lists the users it created only", func() {
+					defer cancel()
+
+					owner := auth.WithIdentity(ctx, &auth.Identity{Subject: "sub-1", Scopes: []string{auth.ScopeRead}})
+					res, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(owner, events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+					Expect(errRes).To(BeNil())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+					var users []dto.UserResponse
+					Expect(json.Unmarshal([]byte(res.Body), &users)).To(Succeed())
+					Expect(users).To(HaveLen(1))
+					Expect(users[0].ID).To(Equal("1"))
+					Expect(res.Body).NotTo(ContainSubstring("jane"))
+				})
+
+				It("lists every user to the admins", func() {
+					defer cancel()
+
+					admin := auth.WithIdentity(ctx, &auth.Identity{Subject: "admin-1", Admin: true})
+					res, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(admin, events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+					Expect(errRes).To(BeNil())
+
+					var users []dto.UserResponse
+					Expect(json.Unmarshal([]byte(res.Body), &users)).To(Succeed())
+					Expect(users).To(HaveLen(3))
+				})
+			})
+
+			When("the status is asked", func() {
+				It("passes the status to the service", func() {
+					defer cancel()
+
+					mockService.On("LookingUpUsers", mock.Anything, domain.StatusSuspended).
+						Times(1).
+						Return([]domain.User{}, nil)
+
+					req := events.APIGatewayProxyRequest{
+						Resource:              "/",
+						Path:                  "/",
+						HTTPMethod:            http.MethodGet,
+						QueryStringParameters: map[string]string{"status": "suspended"},
+					}
+
+					res, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(ctx, req)
+					Expect(errRes).To(BeNil())
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+					Expect(res.Body).To(MatchJSON(`[]`))
+					mockService.AssertExpectations(GinkgoT())
+				})
+
+				It("can get bad request response for an unknown status", func() {
+					defer cancel()
+
+					req := events.APIGatewayProxyRequest{
+						Resource:              "/",
+						Path:                  "/",
+						HTTPMethod:            http.MethodGet,
+						QueryStringParameters: map[string]string{"status": "deleted"},
+					}
+
+					res, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(ctx, req)
+					Expect(errRes).To(BeNil())
+					Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+					Expect(res.Body).To(MatchJSON(`{"message": "status is not valid"}`))
+					mockService.AssertNotCalled(GinkgoT(), "LookingUpUsers", mock.Anything, mock.Anything)
+				})
+			})
+
+			When("the timezone is not valid", func() {
+				It("can get bad request response without calling the service", func() {
+					defer cancel()
+
+					req := events.APIGatewayProxyRequest{
+						Resource:              "/",
+						Path:                  "/",
+						HTTPMethod:            http.MethodGet,
+						QueryStringParameters: map[string]string{"timezone": "Mars/Olympus"},
+					}
+
+					res, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(ctx, req)
+					Expect(errRes).To(BeNil())
+					Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+					Expect(res.Body).To(MatchJSON(`{"message": "timezone is not valid"}`))
+					mockService.AssertNotCalled(GinkgoT(), "LookingUpUsers", mock.Anything, mock.Anything)
+				})
 			})
 
 			When("service fails", func() {
@@ -115,8 +249,8 @@ var _ = Describe("Handler", func() {
 						},
 					}
 
-					var result []*models.UserDB
-					mockService.On("LookingUpUsers", ctx).
+					var result []domain.User
+					mockService.On("LookingUpUsers", mock.Anything, domain.Status("")).
 						Times(1).
 						Return(result, errors.New("internal error"))
 				})
@@ -124,7 +258,7 @@ var _ = Describe("Handler", func() {
 				It("can get conflict response", func() {
 					defer cancel()
 
-					res, errRes := handler.New(mockService, log).HandleRequest(ctx, req)
+					res, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(ctx, req)
 					Expect(errRes).To(BeNil())
 					Expect(res).NotTo(BeNil())
 					Expect(res.Headers).To(HaveKeyWithValue("Content-Type", "application/json"))
@@ -136,6 +270,17 @@ var _ = Describe("Handler", func() {
 					Expect(err).To(BeNil())
 					Expect(expectRes).To(HaveKeyWithValue("message", "internal error"))
 				})
+
+				It("flags the handler span as failed", func() {
+					defer cancel()
+
+					recorder := tracing.NewRecorder()
+
+					_, errRes := handler.New(mockService, log, metrics.NewNoop(), time.UTC).HandleRequest(ctx, req)
+					Expect(errRes).To(BeNil())
+					Expect(recorder.Ended()).To(HaveLen(1))
+					Expect(recorder.Ended()[0].Status().Code).To(Equal(codes.Error))
+				})
 			})
 		})
 	})
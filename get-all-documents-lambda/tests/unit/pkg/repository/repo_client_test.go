@@ -0,0 +1,83 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi/mocks"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+var _ = Describe("Repository with a mocked client", func() {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var scanner *mocks.Scanner
+	var repo repository.Repository
+
+	tableName := "my-table"
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		scanner = mocks.NewScanner(GinkgoT())
+		log := logging.New(logging.Opts{AppName: "get-all-documents-lambda-repository-test", Level: "debug"})
+		repo = repository.New(scanner, tableName, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("maps the scanned items", func() {
+		scanner.EXPECT().
+			Scan(mock.Anything, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+				return aws.ToString(in.TableName) == tableName
+			}), mock.Anything).
+			Return(&dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{
+				{"Id": &types.AttributeValueMemberS{Value: "1"}, "Name": &types.AttributeValueMemberS{Value: "john"}},
+			}}, nil).
+			Once()
+
+		users, err := repo.FindAllDocuments(ctx, "")
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].ID).To(Equal("1"))
+		Expect(users[0].Name).To(Equal("john"))
+	})
+
+	It("filters the scan on the status", func() {
+		scanner.EXPECT().
+			Scan(mock.Anything, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+				status, ok := in.ExpressionAttributeValues[":status"].(*types.AttributeValueMemberS)
+				return aws.ToString(in.FilterExpression) == "#status = :status" &&
+					in.ExpressionAttributeNames["#status"] == "Status" && ok && status.Value == "suspended"
+			}), mock.Anything).
+			Return(&dynamodb.ScanOutput{}, nil).
+			Once()
+
+		users, err := repo.FindAllDocuments(ctx, "suspended")
+		Expect(err).To(BeNil())
+		Expect(users).To(BeEmpty())
+	})
+
+	It("returns the error of the client", func() {
+		scanner.EXPECT().
+			Scan(mock.Anything, mock.Anything, mock.Anything).
+			Return(nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}).
+			Once()
+
+		users, err := repo.FindAllDocuments(ctx, "")
+		Expect(users).To(BeNil())
+		var notFound *types.ResourceNotFoundException
+		Expect(errors.As(err, &notFound)).To(BeTrue())
+	})
+})
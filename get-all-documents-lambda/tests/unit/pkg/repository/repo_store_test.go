@@ -0,0 +1,78 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"time"
+)
+
+var _ = Describe("Repository over a userstore", func() {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var store userstore.UserRepository
+	var repo repository.Repository
+
+	tableName := "users"
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		log := logging.New(logging.Opts{AppName: "get-all-documents-lambda-repository-test", Level: "debug"})
+		opts := clientopts.New(clientopts.DefaultPolicy())
+
+		client := dynamodbapi.NewInMemory()
+		Expect(db.New(client, log, opts, db.NewNoopCache()).ConfigureTable(ctx, tableName)).To(Succeed())
+
+		store = userstore.NewDynamoDB(client, tableName, log, metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "", clock.New())
+		repo = repository.NewFromStore(store)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("returns an empty list without users", func() {
+		users, err := repo.FindAllDocuments(ctx, "")
+
+		Expect(err).To(BeNil())
+		Expect(users).NotTo(BeNil())
+		Expect(users).To(BeEmpty())
+	})
+
+	It("returns every user", func() {
+		for i := 0; i < 3; i++ {
+			Expect(store.Insert(ctx, &models.UserDB{ID: fmt.Sprint(i), Name: "john", CreatedAt: time.Now()})).To(Succeed())
+		}
+
+		users, err := repo.FindAllDocuments(ctx, "")
+
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(3))
+	})
+
+	It("returns the users in the status, the ones without status are active", func() {
+		Expect(store.Insert(ctx, &models.UserDB{ID: "legacy", Name: "john", CreatedAt: time.Now()})).To(Succeed())
+		Expect(store.Insert(ctx, &models.UserDB{ID: "suspended", Name: "jane", Status: "suspended", CreatedAt: time.Now()})).To(Succeed())
+
+		users, err := repo.FindAllDocuments(ctx, "suspended")
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].ID).To(Equal("suspended"))
+
+		users, err = repo.FindAllDocuments(ctx, models.DefaultStatus)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].ID).To(Equal("legacy"))
+	})
+})
@@ -13,8 +13,11 @@ import (
 	"github.com/jarcoal/httpmock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
 	"net/http"
 	"time"
 )
@@ -39,7 +42,7 @@ func getDBClientWithHttpHandler(url string) (*dynamodb.Client, error) {
 
 var _ = Describe("Repository", func() {
 	var ctx context.Context
-	var log logger.Logger
+	var log logging.Logger
 	var conn *dynamodb.Client
 
 	appName := "get-all-documents-lambda-repository-test"
@@ -51,7 +54,7 @@ var _ = Describe("Repository", func() {
 		var err error
 		// configure dynamodb local session
 		ctx = context.Background()
-		log = logger.NewLoggerWithOptions(logger.Opts{AppName: appName, Level: logLevel})
+		log = logging.New(logging.Opts{AppName: appName, Level: logLevel})
 		conn, err = getDBClientWithHttpHandler(dynamodbLocalURL)
 		Expect(err).To(BeNil())
 	})
@@ -173,13 +176,13 @@ var _ = Describe("Repository", func() {
   }`
 					resp := httpmock.NewStringResponder(http.StatusOK, result)
 					httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
-					repo = repository.New(conn, tableName, log)
+					repo = repository.New(conn, tableName, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 				})
 
 				It("can get 4 elements", func() {
 					defer cancel()
 
-					users, err := repo.FindAllDocuments(ctx)
+					users, err := repo.FindAllDocuments(ctx, "")
 					Expect(err).To(BeNil())
 					Expect(users).NotTo(BeNil())
 					Expect(users).To(HaveLen(4))
@@ -195,13 +198,13 @@ var _ = Describe("Repository", func() {
 						result := `{"code":"TransactionConflictException","message":"A conflict occurs trying to scan documents"}`
 						resp := httpmock.NewStringResponder(http.StatusBadRequest, result)
 						httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
-						repo = repository.New(conn, tableName, log)
+						repo = repository.New(conn, tableName, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 					})
 
 					It("cannot be marshalled due to unsupported channel type", func() {
 						defer cancel()
 
-						users, err := repo.FindAllDocuments(ctx)
+						users, err := repo.FindAllDocuments(ctx, "")
 						Expect(users).To(BeNil())
 						Expect(err).NotTo(BeNil())
 
@@ -274,13 +277,13 @@ var _ = Describe("Repository", func() {
   }`
 						resp := httpmock.NewStringResponder(http.StatusOK, result)
 						httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
-						repo = repository.New(conn, tableName, log)
+						repo = repository.New(conn, tableName, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 					})
 
 					It("receives an error unmarshalling result", func() {
 						defer cancel()
 
-						users, err := repo.FindAllDocuments(ctx)
+						users, err := repo.FindAllDocuments(ctx, "")
 						Expect(users).To(BeNil())
 						Expect(err).NotTo(BeNil())
 
@@ -312,24 +315,19 @@ var _ = Describe("Repository", func() {
 					result := `{}`
 					resp := httpmock.NewStringResponder(http.StatusOK, result)
 					httpmock.RegisterResponder(http.MethodPost, dynamodbLocalURL, resp)
-					repo = repository.New(conn, tableName, log)
+					repo = repository.New(conn, tableName, log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop())
 				})
 
-				It("cannot send request by timeout", func() {
+				It("fails fast as unavailable without calling dynamodb", func() {
 					defer cancel()
 
 					time.Sleep(2 * time.Second) // sleep 2 secs
 
-					users, err := repo.FindAllDocuments(ctx)
+					users, err := repo.FindAllDocuments(ctx, "")
 					Expect(users).To(BeNil())
 					Expect(err).NotTo(BeNil())
-
-					var oe *smithy.OperationError
-					ok := errors.As(err, &oe)
-					Expect(ok).To(BeTrue())
-
-					unerr := oe.Unwrap()
-					Expect(errors.Is(unerr, context.DeadlineExceeded)).To(BeTrue())
+					Expect(errors.Is(err, clientopts.ErrUnavailable)).To(BeTrue())
+					Expect(httpmock.GetTotalCallCount()).To(BeZero())
 				})
 			})
 		})
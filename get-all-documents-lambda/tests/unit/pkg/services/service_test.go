@@ -4,8 +4,8 @@ import (
 	"context"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-all-documents-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
 	"github.com/stretchr/testify/mock"
 	"time"
@@ -15,20 +15,20 @@ type MockRepo struct {
 	mock.Mock
 }
 
-func (m *MockRepo) FindAllDocuments(ctx context.Context) ([]*models.UserDB, error) {
-	args := m.Called(ctx)
+func (m *MockRepo) FindAllDocuments(ctx context.Context, status string) ([]*models.UserDB, error) {
+	args := m.Called(ctx, status)
 	return args.Get(0).([]*models.UserDB), args.Error(1)
 }
 
 var _ = Describe("Service", func() {
 	Expect(nil)
 	var mockRepo *MockRepo
-	var log logger.Logger
+	var log logging.Logger
 	var ctx context.Context
 
 	BeforeEach(func() {
 		mockRepo = new(MockRepo)
-		log = logger.NewLoggerWithOptions(logger.Opts{
+		log = logging.New(logging.Opts{
 			AppName: "create-user-lambda-service-test",
 			Level:   "debug",
 		})
@@ -44,7 +44,7 @@ var _ = Describe("Service", func() {
 
 			When("request is valid and mock valid response from db", func() {
 				BeforeEach(func() {
-					mockRepo.On("FindAllDocuments", ctx).
+					mockRepo.On("FindAllDocuments", mock.Anything, "").
 						Times(1).
 						Return([]*models.UserDB{
 							{
@@ -62,7 +62,7 @@ var _ = Describe("Service", func() {
 				It("can save the record", func() {
 					defer cancel()
 
-					users, err := service.New(mockRepo, log).LookingUpUsers(ctx)
+					users, err := service.New(mockRepo, log).LookingUpUsers(ctx, "")
 					Expect(err).To(BeNil())
 					Expect(users).NotTo(BeNil())
 					Expect(users).To(HaveLen(1))
@@ -73,7 +73,7 @@ var _ = Describe("Service", func() {
 			When("db returns an error", func() {
 				BeforeEach(func() {
 					var resp []*models.UserDB
-					mockRepo.On("FindAllDocuments", ctx).
+					mockRepo.On("FindAllDocuments", mock.Anything, "").
 						Times(1).
 						Return(resp, context.DeadlineExceeded)
 				})
@@ -81,7 +81,7 @@ var _ = Describe("Service", func() {
 				It("can save the record", func() {
 					defer cancel()
 
-					users, err := service.New(mockRepo, log).LookingUpUsers(ctx)
+					users, err := service.New(mockRepo, log).LookingUpUsers(ctx, "")
 					Expect(users).To(BeNil())
 					Expect(err).NotTo(BeNil())
 					Expect(err).To(Equal(context.DeadlineExceeded))
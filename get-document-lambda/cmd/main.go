@@ -1,55 +1,188 @@
 package main
 
 import (
+	"context"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/ricardojonathanromero/go-utilities/db/dynamodb"
-	"github.com/ricardojonathanromero/go-utilities/environment"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/internal/handler"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/pkg/repository"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
 	dbInfra "github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/wiring"
+	"os"
 )
 
-const (
-	logLevelEnv        = "LOG_LEVEL"
-	defaultLogLevelEnv = "info"
-	envTableName       = "DYNAMODB_TABLE_NAME"
-	defaultEmpty       = ""
-	appName            = "get-document-lambda"
-)
+const appName = "get-document-lambda"
 
 func main() {
-	logLevel := environment.GetEnv(logLevelEnv, defaultLogLevelEnv)
+	// configuration is loaded once, every problem is reported at once and
+	// ssm:// or secretsmanager:// references are resolved at cold start
+	var cfg config.GetDocument
+	configCtx, cancelConfig := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	secrets, err := config.NewProvider(configCtx)
+	if err == nil {
+		err = config.Load(&cfg, config.WithContext(configCtx), config.WithProvider(secrets))
+	}
+	cancelConfig()
+	if err != nil {
+		logging.New(logging.Opts{AppName: appName}).Fatal(err.Error())
+	}
 
-	customLog := logger.NewLoggerWithOptions(logger.Opts{
+	customLog := logging.New(logging.Opts{
 		AppName: appName,
-		Level:   logLevel,
+		Level:   cfg.LogLevel,
 	})
 
-	// connect to db
-	db := dynamodb.New()
-	conn, err := db.Connect()
+	// metrics are written to stdout in embedded metric format
+	customMetrics := metrics.New(appName, metrics.NewEMFSink(os.Stdout, cfg.MetricsNamespace))
+
+	// spans are exported to the OTLP endpoint when OTEL_TRACES_EXPORTER=otlp
+	tracerProvider, err := tracing.Setup(context.Background(), tracing.Opts{
+		ServiceName: appName,
+		Exporter:    cfg.TracesExporter,
+	})
 	if err != nil {
-		customLog.Fatalf("error initializing db connection: %s", err.Error())
+		customLog.Fatalf("error configuring tracing: %v", err)
 	}
 
 	defer func() {
-		if err = db.Disconnect(); err != nil {
+		if err = tracerProvider.Shutdown(context.Background()); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	// per-call timeouts are derived from the invocation deadline
+	clientOpts := clientopts.New(cfg.DynamoDB.Policy())
+
+	// the stores share one DynamoDB connection, opened on first use
+	conn := wiring.NewConn()
+	defer func() {
+		if err = conn.Close(); err != nil {
 			customLog.Error(err.Error())
 		}
 	}()
 
-	// configure table
-	tableName := environment.GetEnv(envTableName, defaultEmpty)
-	err = dbInfra.New(conn, customLog).ConfigureTable(tableName)
+	// PII attributes are encrypted at rest unless FIELD_ENCRYPTION=off
+	encCtx, cancelEnc := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	enc, err := fieldcrypt.Open(encCtx, cfg.Encryption)
+	cancelEnc()
 	if err != nil {
-		customLog.Fatalf("error configuring table: %v", err)
+		customLog.Fatalf("error configuring field encryption: %v", err)
+	}
+
+	// hot users are served from CACHE_BACKEND when it is not none
+	cacheCtx, cancelCache := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	userCache, cacheCloser, err := cache.Open(cacheCtx, cfg.Cache)
+	cancelCache()
+	if err != nil {
+		customLog.Fatalf("error opening cache: %v", err)
+	}
+
+	defer func() {
+		if err = cacheCloser.Close(); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	// cached users are read encrypted and decrypted after the cache, so it
+	// never holds personal data in plaintext
+	userEnc := enc
+	if userCache != nil {
+		userEnc = fieldcrypt.NewNoop()
+	}
+
+	// init dependency injection, the users and their audit trail are kept
+	// in the DynamoDB tables unless USER_STORE=sql
+	repo, history, closeRepo := newRepository(cfg.Common, conn, userEnc, enc, customLog, customMetrics, clientOpts)
+	defer closeRepo()
+
+	if userCache != nil {
+		repo = repository.NewCached(repo, userCache, cfg.Cache, enc, customLog, customMetrics)
 	}
 
-	// init dependency injection
-	repo := repository.New(conn, tableName, customLog)
-	srv := service.New(repo, customLog)
+	srv := service.New(repo, history, customLog)
+
+	// callers are authenticated per AUTH_MODE and need the read scope
+	authMiddleware, err := auth.Open(cfg.Auth, customLog, customMetrics)
+	if err != nil {
+		customLog.Fatalf("error configuring authentication: %v", err)
+	}
+
+	// callers are throttled per route after authentication, so they are
+	// keyed by subject
+	limiterCtx, cancelLimiter := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	limiter, err := wiring.NewRateLimiter(limiterCtx, cfg.Common, cfg.RateLimit, conn, customLog, customMetrics, clientOpts)
+	cancelLimiter()
+	if err != nil {
+		customLog.Fatalf("error configuring rate limiting: %v", err)
+	}
+
+	h := handler.New(srv, customLog, customMetrics, cfg.Timezone)
+	lambda.Start(tracing.WithFlush(tracerProvider, authMiddleware.Require(auth.ScopeRead, limiter.Wrap(h.HandleRequest))))
+}
+
+// newRepository connects to the backend selected by USER_STORE, the DynamoDB
+// tables through dbConn, the audit trail is read from the same one. The
+// users are decrypted with userEnc and the audit trail with enc. The
+// returned func releases the other backends.
+func newRepository(cfg config.Common, dbConn *wiring.Conn, userEnc, enc fieldcrypt.Encryptor, log logging.Logger, m metrics.Metrics, opts clientopts.Options) (repository.Repository, audit.Store, func()) {
+	initCtx, cancelInit := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	defer cancelInit()
+
+	if cfg.Storage.Backend == config.BackendSQL {
+		store, sqlDB, err := userstore.OpenSQL(initCtx, cfg.Storage.SQL, log, m, opts, userEnc, cfg.Audit.TableName(), clock.New())
+		if err != nil {
+			log.Fatalf("error opening sql store: %v", err)
+		}
+
+		history := audit.NewNoop()
+		if cfg.Audit.Enabled {
+			history = audit.NewSQL(sqlDB, cfg.Audit.Table, log, m, opts, enc)
+		}
+
+		return repository.NewFromStore(store), history, func() {
+			if err = sqlDB.Close(); err != nil {
+				log.Error(err.Error())
+			}
+		}
+	}
+
+	// connect to db
+	conn, err := dbConn.Client()
+	if err != nil {
+		log.Fatalf("error initializing db connection: %s", err.Error())
+	}
+
+	// provision table, production runs with verify or off and relies on the
+	// bootstrap command of the internal module to create it
+	tableName := cfg.DynamoDB.TableName
+	verifyCache := dbInfra.NewFileCache(os.TempDir(), dbInfra.DefaultVerifyTTL)
+	if err = dbInfra.New(conn, log, opts, verifyCache).Provision(initCtx, tableName, cfg.DynamoDB.Provisioning); err != nil {
+		log.Fatalf("error provisioning table: %v", err)
+	}
+
+	history := audit.NewNoop()
+	if cfg.Audit.Enabled {
+		// the audit table follows the provisioning of the users table
+		if cfg.DynamoDB.Provisioning == dbInfra.ModeCreate || cfg.DynamoDB.Provisioning == dbInfra.ModeReconcile {
+			if err = audit.CreateTable(initCtx, conn, cfg.Audit.Table); err != nil {
+				log.Fatalf("error provisioning audit table: %v", err)
+			}
+		}
+		history = audit.NewDynamoDB(conn, cfg.Audit.Table, log, m, opts, enc)
+	}
 
-	lambda.Start(handler.New(srv, customLog))
+	// the table is keyed by Id and CreatedAt, the store queries it by Id
+	store := userstore.NewDynamoDB(conn, tableName, log, m, opts, userEnc, "", clock.New())
+	return repository.NewFromStore(store), history, func() {}
 }
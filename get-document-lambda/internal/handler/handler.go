@@ -2,67 +2,254 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/ricardojonathanromero/go-utilities/logger"
+	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/pkg/repository"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/pkg/service"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/conditional"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dto"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ids"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/timezone"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/encoding"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Handler interface {
+	// HandleRequest dispatches the proxy integration event on its resource.
+	HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 	HandleGetUser(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	HandleHistory(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 }
 
+const (
+	// ResourceHistory pages through the audit entries of a user, every other
+	// resource reads the user.
+	ResourceHistory = "/users/{id}/history"
+
+	operationGetUser = "HandleGetUser"
+	operationHistory = "HandleHistory"
+)
+
 type handleImpl struct {
-	srv service.Service
-	log logger.Logger
+	srv     service.Service
+	log     logging.Logger
+	metrics metrics.Metrics
+	// timezone renders the times when the request asks for no zone.
+	timezone *time.Location
 }
 
-func New(srv service.Service, log logger.Logger) Handler {
+func New(srv service.Service, log logging.Logger, m metrics.Metrics, tz *time.Location) Handler {
 	return &handleImpl{
-		srv: srv,
-		log: log,
+		srv:      srv,
+		log:      log,
+		metrics:  m,
+		timezone: tz,
 	}
 }
 
+func (h *handleImpl) HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if req.Resource == ResourceHistory {
+		return h.HandleHistory(ctx, req)
+	}
+	return h.HandleGetUser(ctx, req)
+}
+
 func (h *handleImpl) HandleGetUser(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	h.log.Debug("handleRequest")
+	ctx = logging.WithAPIGatewayRequest(ctx, req)
+	ctx, span := tracing.StartServer(tracing.WithAPIGatewayRequest(ctx, req), operationGetUser)
+	defer span.End()
+
+	log := h.log.WithContext(ctx)
+	log.Debug("handleRequest")
+	defer h.metrics.HandlerLatency(operationGetUser, time.Now())
+	h.metrics.ColdStart(operationGetUser)
 
-	h.log.Info("handle request")
+	log.Info("handle request")
 
-	id, ok := req.PathParameters["id"]
-	if !ok {
-		h.log.Errorf("id is not valid: %s", id)
+	id := req.PathParameters["id"]
+	if id == "" {
+		log.Warn("id is required")
+		h.metrics.Increment(operationGetUser, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "id is required"), nil
+	}
+
+	// malformed ids never reach the cache nor dynamodb
+	if err := ids.Validate(id); err != nil {
+		log.Warnf("id is not valid: %q", id)
+		h.metrics.Increment(operationGetUser, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "id is not valid"), nil
+	}
+
+	loc, err := timezone.FromRequest(req.Headers, req.QueryStringParameters, h.timezone)
+	if err != nil {
+		log.Warnf("timezone is not valid: %v", err)
+		h.metrics.Increment(operationGetUser, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "timezone is not valid"), nil
+	}
+
+	log.Debugf("looking for user: %s", id)
+	result, err := h.srv.LookingUpUser(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Infof("user not found: %s", id)
+		h.metrics.Increment(operationGetUser, metrics.MetricNotFound)
+		return h.problem(req, http.StatusNotFound, "user not found"), nil
+	}
+
+	if errors.Is(err, clientopts.ErrUnavailable) {
+		log.Errorf("dynamodb unavailable: %s", err)
+		tracing.Error(span, err)
 		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusConflict,
+			StatusCode: http.StatusServiceUnavailable,
 			Headers: map[string]string{
 				"Content-Type": "application/json",
 			},
-			Body: `{"message": "id is required"}`,
+			Body: `{"message": "service unavailable, retry later"}`,
 		}, nil
 	}
 
-	h.log.Debugf("looking for user: %s", id)
-	result, err := h.srv.LookingUpUser(ctx, id)
+	// the cause stays in the logs, it may name tables or hosts
 	if err != nil {
-		h.log.Errorf("error response from service: %s", err)
+		log.Errorf("error response from service: %s", err)
+		tracing.Error(span, err)
+		return h.problem(req, http.StatusInternalServerError, "the request could not be completed"), nil
+	}
+
+	// non-admin callers only read the users they created, the users of
+	// others are not found so that their ids cannot be probed
+	if identity, ok := auth.FromContext(ctx); ok && !identity.CanAccessUser(result.OwnerSubject) {
+		log.Warnf("subject %s denied access to user %s", identity.Subject, id)
+		h.metrics.Increment(operationGetUser, metrics.MetricForbidden)
+		return h.problem(req, http.StatusNotFound, "user not found"), nil
+	}
+
+	// the validators let clients revalidate their copy without the body,
+	// each timezone is a representation of its own
+	body := encoding.ToString(dto.NewUserResponse(*result, loc))
+	headers := map[string]string{
+		"Content-Type":         "application/json",
+		"Vary":                 timezone.HeaderAcceptTimezone,
+		conditional.HeaderETag: conditional.ETag([]byte(body)),
+	}
+	if lastModified := conditional.LastModified(result.UpdatedAt); lastModified != "" {
+		headers[conditional.HeaderLastModified] = lastModified
+	}
+
+	if conditional.NotModified(req.Headers, headers[conditional.HeaderETag], result.UpdatedAt) {
+		log.Info("not modified response")
+		delete(headers, "Content-Type")
 		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusConflict,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-			Body: fmt.Sprintf(`{"message": "%s"}`, err),
+			StatusCode: http.StatusNotModified,
+			Headers:    headers,
 		}, nil
 	}
 
-	h.log.Info("success response")
+	log.Info("success response")
 
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       body,
+	}, nil
+}
+
+func (h *handleImpl) HandleHistory(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx = logging.WithAPIGatewayRequest(ctx, req)
+	ctx, span := tracing.StartServer(tracing.WithAPIGatewayRequest(ctx, req), operationHistory)
+	defer span.End()
+
+	defer h.metrics.HandlerLatency(operationHistory, time.Now())
+	h.metrics.ColdStart(operationHistory)
+
+	log := h.log.WithContext(ctx)
+	id := req.PathParameters["id"]
+	if id == "" {
+		h.metrics.Increment(operationHistory, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "id is required"), nil
+	}
+	if err := ids.Validate(id); err != nil {
+		h.metrics.Increment(operationHistory, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "id is not valid"), nil
+	}
+
+	// non-admin callers only read the history of the users they created,
+	// the owner is read from the user. The history of a deleted user is
+	// left to the admins, the others are answered as for a missing user
+	if identity, ok := auth.FromContext(ctx); ok && !identity.Admin {
+		user, err := h.srv.LookingUpUser(ctx, id)
+		if errors.Is(err, clientopts.ErrUnavailable) {
+			tracing.Error(span, err)
+			return h.problem(req, http.StatusServiceUnavailable, "service unavailable, retry later"), nil
+		}
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			tracing.Error(span, err)
+			return h.problem(req, http.StatusInternalServerError, "the request could not be completed"), nil
+		}
+		if user == nil || !identity.CanAccessUser(user.OwnerSubject) {
+			log.Warnf("subject %s denied access to the history of user %s", identity.Subject, id)
+			h.metrics.Increment(operationHistory, metrics.MetricForbidden)
+			return h.problem(req, http.StatusNotFound, "user not found"), nil
+		}
+	}
+
+	query := audit.Query{Cursor: req.QueryStringParameters["cursor"]}
+	if limit, ok := req.QueryStringParameters["limit"]; ok {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > audit.MaxPageSize {
+			h.metrics.Increment(operationHistory, metrics.MetricValidationFailure)
+			return h.problem(req, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", audit.MaxPageSize)), nil
+		}
+		query.Limit = n
+	}
+	loc, err := timezone.FromRequest(req.Headers, req.QueryStringParameters, h.timezone)
+	if err != nil {
+		log.Warnf("timezone is not valid: %v", err)
+		h.metrics.Increment(operationHistory, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "timezone is not valid"), nil
+	}
+
+	page, err := h.srv.History(ctx, id, query)
+	switch {
+	case errors.Is(err, audit.ErrInvalidCursor):
+		h.metrics.Increment(operationHistory, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, err.Error()), nil
+	case errors.Is(err, clientopts.ErrUnavailable):
+		log.Errorf("audit store unavailable: %s", err)
+		tracing.Error(span, err)
+		return h.problem(req, http.StatusServiceUnavailable, "service unavailable, retry later"), nil
+	case err != nil:
+		log.Errorf("error reading history: %s", err)
+		tracing.Error(span, err)
+		return h.problem(req, http.StatusInternalServerError, "the request could not be completed"), nil
+	}
+
+	for i := range page.Entries {
+		page.Entries[i].Timestamp = page.Entries[i].Timestamp.In(loc)
+	}
+
+	log.Infof("history page of %d entries", len(page.Entries))
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
 		Headers: map[string]string{
-			"Content-Type": "application/json",
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+			"Vary":          timezone.HeaderAcceptTimezone,
 		},
-		Body: encoding.ToString(result),
+		Body: encoding.ToString(page),
 	}, nil
 }
+
+func (h *handleImpl) problem(req events.APIGatewayProxyRequest, status int, detail string) events.APIGatewayProxyResponse {
+	p := problem.New(status, detail)
+	p.Instance = req.Path
+	return p.Response(nil)
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+)
+
+type cachedImpl struct {
+	next  Repository
+	cache cache.ReadThrough[models.UserDB]
+	enc   fieldcrypt.Encryptor
+}
+
+// NewCached serves FindDocumentById from c and reads next on a miss. Found
+// users are kept for cfg.TTL and missing ones for cfg.NegativeTTL, so a
+// user is visible at most that late after a write.
+//
+// next returns the users as stored, encrypted, and they are decrypted with
+// enc after the cache, so c never holds their personal data in plaintext.
+func NewCached(next Repository, c cache.Cache, cfg config.Cache, enc fieldcrypt.Encryptor, log logging.Logger, m metrics.Metrics) Repository {
+	return &cachedImpl{
+		next: next,
+		cache: cache.NewReadThrough[models.UserDB](c, cache.Options{
+			Operation:   operationFindDocumentById,
+			Prefix:      userstore.CachePrefix,
+			Version:     cfg.Version,
+			TTL:         cfg.TTL,
+			NegativeTTL: cfg.NegativeTTL,
+			NotFound:    ErrNotFound,
+		}, log, m),
+		enc: enc,
+	}
+}
+
+func (repo *cachedImpl) FindDocumentById(ctx context.Context, id string) (*models.UserDB, error) {
+	user, err := repo.cache.Get(ctx, id, func(ctx context.Context) (*models.UserDB, error) {
+		return repo.next.FindDocumentById(ctx, id)
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if err = repo.enc.Decrypt(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
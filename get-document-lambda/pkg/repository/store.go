@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+)
+
+type storeImpl struct {
+	store userstore.UserRepository
+}
+
+// NewFromStore reads the users through a userstore backend, the DynamoDB
+// table or the database selected by USER_STORE.
+func NewFromStore(store userstore.UserRepository) Repository {
+	return &storeImpl{store: store}
+}
+
+func (repo *storeImpl) FindDocumentById(ctx context.Context, id string) (*models.UserDB, error) {
+	user, err := repo.store.Get(ctx, id)
+	if errors.Is(err, userstore.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return user, err
+}
@@ -2,38 +2,65 @@ package service
 
 import (
 	"context"
-	"github.com/ricardojonathanromero/go-utilities/logger"
 	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/pkg/repository"
-	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
 	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/encoding"
 )
 
 type Service interface {
-	LookingUpUser(ctx context.Context, id string) (*models.UserDB, error)
+	LookingUpUser(ctx context.Context, id string) (*domain.User, error)
+	// History returns a page of the audit entries of the user, newest first.
+	History(ctx context.Context, id string, query audit.Query) (*audit.Page, error)
 }
 
 type serviceImpl struct {
-	repo repository.Repository
-	log  logger.Logger
+	repo    repository.Repository
+	history audit.Store
+	log     logging.Logger
 }
 
-func New(repo repository.Repository, log logger.Logger) Service {
+func New(repo repository.Repository, history audit.Store, log logging.Logger) Service {
 	return &serviceImpl{
-		repo: repo,
-		log:  log,
+		repo:    repo,
+		history: history,
+		log:     log,
 	}
 }
 
-func (srv *serviceImpl) LookingUpUser(ctx context.Context, id string) (*models.UserDB, error) {
-	srv.log.Debug("processing service layer")
+func (srv *serviceImpl) LookingUpUser(ctx context.Context, id string) (*domain.User, error) {
+	ctx, span := tracing.Start(ctx, "LookingUpUser")
+	defer span.End()
 
-	srv.log.Debug("looking document")
+	log := srv.log.WithContext(ctx)
+	log.Debug("processing service layer")
+
+	log.Debug("looking document")
 	user, err := srv.repo.FindDocumentById(ctx, id)
 	if err != nil {
-		srv.log.Errorf("error from repository: %s", err)
+		log.Errorf("error from repository: %s", err)
+		tracing.Error(span, err)
 		return nil, err
 	}
 
-	srv.log.Debug(encoding.ToString(user))
-	return user, nil
+	log.Debug(encoding.ToLogString(user))
+	result := user.ToDomain()
+	return &result, nil
+}
+
+func (srv *serviceImpl) History(ctx context.Context, id string, query audit.Query) (*audit.Page, error) {
+	ctx, span := tracing.Start(ctx, "History")
+	defer span.End()
+
+	log := srv.log.WithContext(ctx)
+	log.Debug("reading history")
+	page, err := srv.history.History(ctx, id, query)
+	if err != nil {
+		log.Errorf("error from audit store: %s", err)
+		tracing.Error(span, err)
+		return nil, err
+	}
+	return page, nil
 }
@@ -0,0 +1,223 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/internal/handler"
+	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/conditional"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"time"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) LookingUpUser(ctx context.Context, id string) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	user, _ := args.Get(0).(*domain.User)
+	return user, args.Error(1)
+}
+
+func (m *MockService) History(ctx context.Context, id string, query audit.Query) (*audit.Page, error) {
+	args := m.Called(ctx, id, query)
+	page, _ := args.Get(0).(*audit.Page)
+	return page, args.Error(1)
+}
+
+var _ = Describe("Handler", func() {
+	var mockService *MockService
+	var h handler.Handler
+
+	appName := "get-document-lambda-handler-test"
+	id := "usr_0190a5e4-5b1c-7000-8000-000000000001"
+	updated := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	request := func(headers map[string]string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			Resource:       "/users/{id}",
+			Path:           "/users/" + id,
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"id": id},
+			Headers:        headers,
+		}
+	}
+
+	BeforeEach(func() {
+		log := logging.New(logging.Opts{AppName: appName, Level: "error"})
+		mockService = new(MockService)
+		mockService.On("LookingUpUser", mock.Anything, id).
+			Return(&domain.User{ID: id, Name: "john", Status: domain.StatusActive, OwnerSubject: "sub-1", CreatedAt: updated, UpdatedAt: updated}, nil)
+		h = handler.New(mockService, log, metrics.NewNoop(), time.UTC)
+	})
+
+	Context("ownership", func() {
+		owner := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "sub-1", Scopes: []string{auth.ScopeRead}})
+		other := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "sub-2", Scopes: []string{auth.ScopeRead}})
+		admin := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "admin-1", Admin: true})
+
+		history := func() events.APIGatewayProxyRequest {
+			req := request(nil)
+			req.Resource, req.Path = handler.ResourceHistory, "/users/"+id+"/history"
+			return req
+		}
+
+		BeforeEach(func() {
+			mockService.On("History", mock.Anything, id, mock.Anything).Return(&audit.Page{}, nil)
+		})
+
+		It("lets a non-admin read the user it created", func() {
+			res, err := h.HandleRequest(owner, request(nil))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(res.Body).To(ContainSubstring(id))
+
+			res, err = h.HandleRequest(owner, history())
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("answers the users of others as missing to the non-admins", func() {
+			missing := "usr_0190a5e4-5b1c-7000-8000-000000000002"
+			mockService.On("LookingUpUser", mock.Anything, missing).Return(nil, repository.ErrNotFound)
+			notFound := request(nil)
+			notFound.Path, notFound.PathParameters = "/users/"+id, map[string]string{"id": missing}
+			expected, err := h.HandleRequest(other, notFound)
+			Expect(err).To(BeNil())
+
+			res, err := h.HandleRequest(other, request(nil))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(res).To(Equal(expected))
+			Expect(res.Body).NotTo(ContainSubstring("john"))
+
+			res, err = h.HandleRequest(other, history())
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(res.Headers["Content-Type"]).To(Equal(problem.ContentType))
+			Expect(res.Body).To(ContainSubstring(`"detail":"user not found"`))
+			mockService.AssertNotCalled(GinkgoT(), "History", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("lets the admins read every user", func() {
+			res, err := h.HandleRequest(admin, request(nil))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+			res, err = h.HandleRequest(admin, history())
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("validation", func() {
+		It("rejects the malformed ids with a problem", func() {
+			req := request(nil)
+			req.PathParameters = map[string]string{"id": "not-an-id"}
+			res, err := h.HandleRequest(context.Background(), req)
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(res.Headers["Content-Type"]).To(Equal(problem.ContentType))
+			Expect(res.Body).To(ContainSubstring("id is not valid"))
+			mockService.AssertNotCalled(GinkgoT(), "LookingUpUser", mock.Anything, "not-an-id")
+		})
+
+		It("rejects a missing id with a problem", func() {
+			req := request(nil)
+			req.PathParameters = nil
+			res, err := h.HandleRequest(context.Background(), req)
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(res.Headers["Content-Type"]).To(Equal(problem.ContentType))
+			Expect(res.Body).To(ContainSubstring(`"detail":"id is required"`))
+		})
+
+		It("rejects an unknown timezone with the same problem on every route", func() {
+			user := request(map[string]string{"Accept-Timezone": "Mars/Olympus"})
+			history := request(map[string]string{"Accept-Timezone": "Mars/Olympus"})
+			history.Resource, history.Path = handler.ResourceHistory, "/users/"+id+"/history"
+
+			for _, req := range []events.APIGatewayProxyRequest{user, history} {
+				res, err := h.HandleRequest(context.Background(), req)
+				Expect(err).To(BeNil())
+				Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(res.Headers["Content-Type"]).To(Equal(problem.ContentType))
+				Expect(res.Body).To(ContainSubstring(`"detail":"timezone is not valid"`))
+			}
+			mockService.AssertNotCalled(GinkgoT(), "History", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Context("errors", func() {
+		It("answers 500 with a generic problem without the cause", func() {
+			other := "usr_0190a5e4-5b1c-7000-8000-000000000002"
+			mockService.On("LookingUpUser", mock.Anything, other).Return(nil, errors.New("table users-internal unreachable"))
+
+			req := request(nil)
+			req.PathParameters = map[string]string{"id": other}
+			res, err := h.HandleRequest(context.Background(), req)
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(res.Headers["Content-Type"]).To(Equal(problem.ContentType))
+			Expect(res.Body).To(ContainSubstring(`"detail":"the request could not be completed"`))
+			Expect(res.Body).NotTo(ContainSubstring("users-internal"))
+		})
+	})
+
+	Context("conditional requests", func() {
+		var etag string
+
+		BeforeEach(func() {
+			res, err := h.HandleRequest(context.Background(), request(nil))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			etag = res.Headers[conditional.HeaderETag]
+		})
+
+		It("returns the validators of the user", func() {
+			res, err := h.HandleRequest(context.Background(), request(nil))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(res.Headers[conditional.HeaderETag]).To(Equal(etag))
+			Expect(etag).To(HavePrefix(`"`))
+			Expect(res.Headers[conditional.HeaderLastModified]).To(Equal("Wed, 01 May 2024 10:00:00 GMT"))
+			Expect(res.Body).To(ContainSubstring(id))
+		})
+
+		It("answers 304 without body when the etag matches", func() {
+			res, err := h.HandleRequest(context.Background(), request(map[string]string{"if-none-match": etag}))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusNotModified))
+			Expect(res.Body).To(BeEmpty())
+			Expect(res.Headers[conditional.HeaderETag]).To(Equal(etag))
+			Expect(res.Headers).NotTo(HaveKey("Content-Type"))
+		})
+
+		It("answers 200 with the user when the etag does not match", func() {
+			res, err := h.HandleRequest(context.Background(), request(map[string]string{"If-None-Match": `"stale"`}))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(res.Body).To(ContainSubstring(id))
+			Expect(res.Headers[conditional.HeaderETag]).To(Equal(etag))
+		})
+
+		It("renders another representation per timezone", func() {
+			res, err := h.HandleRequest(context.Background(), request(map[string]string{"If-None-Match": etag, "Accept-Timezone": "America/Mexico_City"}))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(res.Headers[conditional.HeaderETag]).NotTo(Equal(etag))
+			Expect(res.Headers["Vary"]).To(Equal("Accept-Timezone"))
+		})
+	})
+})
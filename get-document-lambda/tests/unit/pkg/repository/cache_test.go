@@ -0,0 +1,71 @@
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"time"
+)
+
+var _ = Describe("Cached repository", func() {
+	var ctx context.Context
+	var userCache cache.Cache
+	var repo repository.Repository
+
+	tableName := "users"
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		log := logging.New(logging.Opts{AppName: "get-document-lambda-repository-test", Level: "error"})
+		opts := clientopts.New(clientopts.DefaultPolicy())
+
+		provider, err := fieldcrypt.NewLocal(fieldcrypt.LocalKey{ID: "k1", Key: bytes.Repeat([]byte{1}, 32)})
+		Expect(err).To(BeNil())
+		enc := fieldcrypt.New(provider, fieldcrypt.DefaultOptions())
+
+		client := dynamodbapi.NewInMemory()
+		Expect(db.New(client, log, opts, db.NewNoopCache()).ConfigureTable(ctx, tableName)).To(Succeed())
+		store := userstore.NewDynamoDB(client, tableName, log, metrics.NewNoop(), opts, enc, "", clock.New())
+		Expect(store.Insert(ctx, &models.UserDB{ID: "usr-1", Name: "john", Email: "john@example.com", CreatedAt: time.Now()})).To(Succeed())
+
+		userCache = cache.NewLRU(10)
+		cfg := config.Cache{Version: "1", TTL: time.Minute, NegativeTTL: time.Second}
+		stored := userstore.NewDynamoDB(client, tableName, log, metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "", clock.New())
+		repo = repository.NewCached(repository.NewFromStore(stored), userCache, cfg, enc, log, metrics.NewNoop())
+	})
+
+	It("keeps the users encrypted in the cache and decrypts them after it", func() {
+		for i := 0; i < 2; i++ {
+			user, err := repo.FindDocumentById(ctx, "usr-1")
+			Expect(err).To(BeNil())
+			Expect(user.Name).To(Equal("john"))
+			Expect(user.Email).To(Equal("john@example.com"))
+		}
+
+		raw, ok, err := userCache.Get(ctx, "user:usr-1")
+		Expect(err).To(BeNil())
+		Expect(ok).To(BeTrue())
+		Expect(string(raw)).NotTo(ContainSubstring("john"))
+	})
+
+	It("remembers the missing users", func() {
+		_, err := repo.FindDocumentById(ctx, "usr-9")
+		Expect(err).To(MatchError(repository.ErrNotFound))
+
+		_, err = repo.FindDocumentById(ctx, "usr-9")
+		Expect(err).To(MatchError(repository.ErrNotFound))
+	})
+})
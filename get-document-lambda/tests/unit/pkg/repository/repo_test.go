@@ -0,0 +1,52 @@
+package repository_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/get-document-lambda/pkg/repository"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbfake"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"time"
+)
+
+var _ = Describe("Repository", func() {
+	var ctx context.Context
+	var repo repository.Repository
+
+	tableName := "users"
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		log := logging.New(logging.Opts{AppName: "get-document-lambda-repository-test", Level: "error"})
+		opts := clientopts.New(clientopts.DefaultPolicy())
+
+		// the table of the db package, keyed by Id and CreatedAt
+		client := dynamodbfake.New().Client()
+		Expect(db.New(client, log, opts, db.NewNoopCache()).ConfigureTable(ctx, tableName)).To(Succeed())
+		store := userstore.NewDynamoDB(client, tableName, log, metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "", clock.New())
+		Expect(store.Insert(ctx, &models.UserDB{ID: "usr-1", Name: "john", Email: "john@example.com", CreatedAt: created, UpdatedAt: created})).To(Succeed())
+
+		repo = repository.NewFromStore(store)
+	})
+
+	It("finds the user by its id alone", func() {
+		user, err := repo.FindDocumentById(ctx, "usr-1")
+		Expect(err).To(BeNil())
+		Expect(user.Name).To(Equal("john"))
+		Expect(user.CreatedAt.Equal(created)).To(BeTrue())
+	})
+
+	It("returns not found for an unknown id", func() {
+		_, err := repo.FindDocumentById(ctx, "usr-9")
+		Expect(err).To(MatchError(repository.ErrNotFound))
+	})
+})
@@ -0,0 +1,296 @@
+// Package audit keeps the trail of the mutations of the users. The stores
+// of the userstore package append an Entry in the transaction of every
+// insert, update and delete, so a write and its entry succeed or fail
+// together. Entries are never updated, except by Redact when the user is
+// erased, and Store pages through them newest first.
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Action is the kind of mutation of an Entry.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+const (
+	// ActorSystem is the actor of the writes without identity nor WithActor.
+	ActorSystem = "system"
+
+	// DefaultPageSize is used by History when the query has no limit.
+	DefaultPageSize = 20
+	// MaxPageSize caps the limit of History.
+	MaxPageSize = 100
+
+	// idTimeLayout has a fixed width so the ids sort by time.
+	idTimeLayout = "2006-01-02T15:04:05.000000000Z"
+)
+
+// ErrInvalidCursor is returned by History for a cursor it did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Entry is one mutation of a user. The values of the fields tagged with
+// encrypt are stored encrypted like the user itself, Store decrypts them.
+type Entry struct {
+	UserID string `dynamodbav:"UserId" json:"user_id"`
+	// ID starts with the time of the entry, it orders the history.
+	ID        string    `dynamodbav:"EntryId" json:"id"`
+	Action    Action    `dynamodbav:"Action" json:"action"`
+	Actor     string    `dynamodbav:"Actor" json:"actor"`
+	RequestID string    `dynamodbav:"RequestId,omitempty" json:"request_id,omitempty"`
+	Timestamp time.Time `dynamodbav:"Timestamp" json:"timestamp"`
+	// Changes holds the fields that differ, keyed by their json name.
+	Changes map[string]Change `dynamodbav:"Changes" json:"changes"`
+}
+
+// Change is the value of a field before and after the mutation, Before is
+// nil for a created user and After for a deleted one. Both are nil once
+// the entry is redacted.
+type Change struct {
+	Before any `dynamodbav:"Before,omitempty" json:"before,omitempty"`
+	After  any `dynamodbav:"After,omitempty" json:"after,omitempty"`
+}
+
+type Query struct {
+	// Limit is the maximum number of entries of the page, DefaultPageSize
+	// when zero.
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first one.
+	Cursor string
+}
+
+type Page struct {
+	Entries []Entry `json:"entries"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type Store interface {
+	// History returns a page of the entries of the user, newest first.
+	History(ctx context.Context, userID string, query Query) (*Page, error)
+	// Redact drops the values of every entry of the user and returns the
+	// number of entries, the actions and dates are kept.
+	Redact(ctx context.Context, userID string) (int, error)
+}
+
+func (q Query) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultPageSize
+	case q.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return q.Limit
+	}
+}
+
+// The cursor is the id of the last entry of the page, hidden so clients do
+// not build their own.
+func encodeCursor(entryID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(entryID))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) == 0 {
+		return "", ErrInvalidCursor
+	}
+	return string(raw), nil
+}
+
+type actorKey struct{}
+type erasureKey struct{}
+
+// WithActor names the actor of the writes done with ctx when the caller
+// has no identity, such as the operator running a command.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// WithErasure marks the writes done with ctx as part of an erasure, their
+// entries keep which fields changed but not the values.
+func WithErasure(ctx context.Context) context.Context {
+	return context.WithValue(ctx, erasureKey{}, true)
+}
+
+// Actor is the subject of the caller of ctx, the actor given with
+// WithActor or ActorSystem.
+func Actor(ctx context.Context) string {
+	if identity, ok := auth.FromContext(ctx); ok && identity.Subject != "" {
+		return identity.Subject
+	}
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorSystem
+}
+
+// RequestID prefers the API Gateway request id, which the client sees, to
+// the one of the invocation.
+func RequestID(ctx context.Context) string {
+	fields := logging.FieldsFromContext(ctx)
+	if fields.APIRequestID != "" {
+		return fields.APIRequestID
+	}
+	return fields.RequestID
+}
+
+// userField is a field of models.UserDB recorded by the entries.
+type userField struct {
+	index int
+	name  string
+	// json is the key of the field in Changes.
+	json string
+}
+
+var userFields = func() []userField {
+	var fields []userField
+	t := reflect.TypeOf(models.UserDB{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		// the id is the key of the entry
+		if name == "" || name == "-" || name == "id" {
+			continue
+		}
+		fields = append(fields, userField{index: i, name: t.Field(i).Name, json: name})
+	}
+	return fields
+}()
+
+// NewEntry describes the mutation from before to after, before is nil for
+// a created user and after for a deleted one. The diff is computed on the
+// plaintext, the values of the fields tagged with encrypt are encrypted by
+// enc. The entry is timestamped by clk.
+func NewEntry(ctx context.Context, enc fieldcrypt.Encryptor, clk clock.Clock, action Action, before, after *models.UserDB) (*Entry, error) {
+	now := clk.Now().UTC()
+	entry := &Entry{
+		ID:        now.Format(idTimeLayout) + "#" + uuid.NewString(),
+		Action:    action,
+		Actor:     Actor(ctx),
+		RequestID: RequestID(ctx),
+		Timestamp: now,
+		Changes:   map[string]Change{},
+	}
+	if before != nil {
+		entry.UserID = before.ID
+	} else if after != nil {
+		entry.UserID = after.ID
+	}
+
+	erasure, _ := ctx.Value(erasureKey{}).(bool)
+	storedBefore, storedAfter := before, after
+	var err error
+	if before != nil && !erasure {
+		if storedBefore, err = fieldcrypt.Encrypted(ctx, enc, before); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil && !erasure {
+		if storedAfter, err = fieldcrypt.Encrypted(ctx, enc, after); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range userFields {
+		oldValue, hasOld := fieldValue(before, field)
+		newValue, hasNew := fieldValue(after, field)
+		if hasOld && hasNew && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		var change Change
+		if !erasure {
+			change.Before, _ = fieldValue(storedBefore, field)
+			change.After, _ = fieldValue(storedAfter, field)
+		}
+		entry.Changes[field.json] = change
+	}
+	return entry, nil
+}
+
+// fieldValue returns the value of the field of user as stored in Changes,
+// false when user is nil.
+func fieldValue(user *models.UserDB, field userField) (any, bool) {
+	if user == nil {
+		return nil, false
+	}
+	value := reflect.ValueOf(user).Elem().Field(field.index).Interface()
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano), true
+	}
+	return value, true
+}
+
+// decrypt replaces the encrypted values of the changes of entry by their
+// plaintext.
+func decrypt(ctx context.Context, enc fieldcrypt.Encryptor, entry *Entry) error {
+	for _, side := range []func(*Change) *any{
+		func(c *Change) *any { return &c.Before },
+		func(c *Change) *any { return &c.After },
+	} {
+		var user models.UserDB
+		v := reflect.ValueOf(&user).Elem()
+		var encrypted []userField
+		for _, field := range userFields {
+			change, ok := entry.Changes[field.json]
+			if !ok || fieldcrypt.Mode(user, field.name) == "" {
+				continue
+			}
+			if s, ok := (*side(&change)).(string); ok {
+				v.Field(field.index).SetString(s)
+				encrypted = append(encrypted, field)
+			}
+		}
+		if len(encrypted) == 0 {
+			continue
+		}
+
+		if err := enc.Decrypt(ctx, &user); err != nil {
+			return err
+		}
+		for _, field := range encrypted {
+			change := entry.Changes[field.json]
+			*side(&change) = v.Field(field.index).Interface()
+			entry.Changes[field.json] = change
+		}
+	}
+	return nil
+}
+
+// redacted returns the changes with the same fields and no values.
+func redacted(changes map[string]Change) map[string]Change {
+	out := make(map[string]Change, len(changes))
+	for name := range changes {
+		out[name] = Change{}
+	}
+	return out
+}
+
+type noopImpl struct{}
+
+// NewNoop is the Store of a disabled trail, it has no entries.
+func NewNoop() Store {
+	return noopImpl{}
+}
+
+func (noopImpl) History(context.Context, string, Query) (*Page, error) {
+	return &Page{Entries: []Entry{}}, nil
+}
+
+func (noopImpl) Redact(context.Context, string) (int, error) { return 0, nil }
@@ -0,0 +1,311 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// backend is a user store writing its trail, timestamped by clock, and
+// the raw stored entries.
+type backend struct {
+	users userstore.UserRepository
+	trail audit.Store
+	clock *clock.Fake
+	raw   func(t *testing.T) string
+}
+
+func newLog() logging.Logger {
+	return logging.New(logging.Opts{AppName: "audit-test", Level: "error"})
+}
+
+func newEncryptor(t *testing.T) fieldcrypt.Encryptor {
+	t.Helper()
+	provider, err := fieldcrypt.NewLocal(fieldcrypt.LocalKey{ID: "k1", Key: bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fieldcrypt.New(provider, fieldcrypt.DefaultOptions())
+}
+
+func backends() map[string]func(t *testing.T, enc fieldcrypt.Encryptor) backend {
+	return map[string]func(t *testing.T, enc fieldcrypt.Encryptor) backend{
+		"dynamodb": func(t *testing.T, enc fieldcrypt.Encryptor) backend {
+			ctx := context.Background()
+			opts := clientopts.New(clientopts.DefaultPolicy())
+			client := dynamodbapi.NewInMemory()
+			if err := db.New(client, newLog(), opts, db.NewNoopCache()).ConfigureTable(ctx, "users"); err != nil {
+				t.Fatalf("creating table: %v", err)
+			}
+			if err := audit.CreateTable(ctx, client, "audit"); err != nil {
+				t.Fatalf("creating audit table: %v", err)
+			}
+
+			clk := clock.NewFake(newUser().CreatedAt)
+			return backend{
+				users: userstore.NewDynamoDB(client, "users", newLog(), metrics.NewNoop(), opts, enc, "audit", clk),
+				trail: audit.NewDynamoDB(client, "audit", newLog(), metrics.NewNoop(), opts, enc),
+				clock: clk,
+				raw: func(t *testing.T) string {
+					out, err := client.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String("audit")})
+					if err != nil {
+						t.Fatal(err)
+					}
+					var items []map[string]any
+					if err = attributevalue.UnmarshalListOfMaps(out.Items, &items); err != nil {
+						t.Fatal(err)
+					}
+					return fmt.Sprint(items)
+				},
+			}
+		},
+		"sql": func(t *testing.T, enc fieldcrypt.Encryptor) backend {
+			ctx := context.Background()
+			opts := clientopts.New(clientopts.DefaultPolicy())
+			conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "users.db"))
+			if err != nil {
+				t.Fatalf("opening sqlite: %v", err)
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+			if err = userstore.Migrate(ctx, conn, "users"); err != nil {
+				t.Fatal(err)
+			}
+			if err = audit.Migrate(ctx, conn, "audit"); err != nil {
+				t.Fatal(err)
+			}
+
+			clk := clock.NewFake(newUser().CreatedAt)
+			return backend{
+				users: userstore.NewSQL(conn, "users", newLog(), metrics.NewNoop(), opts, enc, "audit", clk),
+				trail: audit.NewSQL(conn, "audit", newLog(), metrics.NewNoop(), opts, enc),
+				clock: clk,
+				raw: func(t *testing.T) string {
+					var changes []string
+					rows, err := conn.Query(`SELECT changes FROM audit`)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer rows.Close()
+					for rows.Next() {
+						var s string
+						if err = rows.Scan(&s); err != nil {
+							t.Fatal(err)
+						}
+						changes = append(changes, s)
+					}
+					return strings.Join(changes, "\n")
+				},
+			}
+		},
+	}
+}
+
+func newUser() *models.UserDB {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	return &models.UserDB{ID: "usr-1", Name: "john", Lastname: "doe", Age: 30, Email: "john@example.com", CreatedAt: created, UpdatedAt: created}
+}
+
+// requestContext is the context of an authenticated API Gateway request.
+func requestContext(subject, requestID string) context.Context {
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: subject})
+	return logging.WithAPIGatewayRequest(ctx, events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: requestID},
+	})
+}
+
+func TestTrail(t *testing.T) {
+	for name, newBackend := range backends() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("records every mutation", func(t *testing.T) {
+				b := newBackend(t, newEncryptor(t))
+				user := newUser()
+
+				if err := b.users.Insert(requestContext("usr-1", "req-1"), user); err != nil {
+					t.Fatal(err)
+				}
+				b.clock.Advance(time.Hour)
+				updated := *user
+				updated.Name = "johnny"
+				updated.UpdatedAt = user.UpdatedAt.Add(time.Hour)
+				if err := b.users.Update(requestContext("admin-1", "req-2"), &updated); err != nil {
+					t.Fatal(err)
+				}
+				b.clock.Advance(time.Hour)
+				if err := b.users.Delete(audit.WithActor(context.Background(), "operator"), user.ID); err != nil {
+					t.Fatal(err)
+				}
+
+				page, err := b.trail.History(context.Background(), user.ID, audit.Query{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page.Entries) != 3 || page.NextCursor != "" {
+					t.Fatalf("expected a single page of 3 entries, got %+v", page)
+				}
+
+				deleted, update, create := page.Entries[0], page.Entries[1], page.Entries[2]
+				if create.Action != audit.ActionCreate || create.Actor != "usr-1" || create.RequestID != "req-1" || create.UserID != user.ID {
+					t.Errorf("unexpected create entry %+v", create)
+				}
+				if create.Changes["name"].After != "john" || create.Changes["name"].Before != nil || len(create.Changes) != 9 {
+					t.Errorf("expected every field of the created user, got %+v", create.Changes)
+				}
+
+				if update.Action != audit.ActionUpdate || update.Actor != "admin-1" || update.RequestID != "req-2" {
+					t.Errorf("unexpected update entry %+v", update)
+				}
+				if len(update.Changes) != 2 || update.Changes["name"] != (audit.Change{Before: "john", After: "johnny"}) {
+					t.Errorf("expected the name and update date only, got %+v", update.Changes)
+				}
+				if _, ok := update.Changes["updated_at"]; !ok {
+					t.Errorf("expected the update date, got %+v", update.Changes)
+				}
+
+				if deleted.Action != audit.ActionDelete || deleted.Actor != "operator" || deleted.Changes["email"].Before != "john@example.com" || deleted.Changes["email"].After != nil {
+					t.Errorf("unexpected delete entry %+v", deleted)
+				}
+				if !create.Timestamp.Equal(user.CreatedAt) || !update.Timestamp.Equal(user.CreatedAt.Add(time.Hour)) || !deleted.Timestamp.Equal(user.CreatedAt.Add(2*time.Hour)) {
+					t.Errorf("expected the entries timestamped by the clock, got %v, %v and %v", create.Timestamp, update.Timestamp, deleted.Timestamp)
+				}
+
+				// the values of the encrypted fields are encrypted at rest
+				if raw := b.raw(t); strings.Contains(raw, "john") {
+					t.Errorf("expected no plaintext PII in the stored entries, got %s", raw)
+				}
+			})
+
+			t.Run("writes nothing when the write fails", func(t *testing.T) {
+				b := newBackend(t, fieldcrypt.NewNoop())
+				ctx := context.Background()
+
+				if err := b.users.Insert(ctx, newUser()); err != nil {
+					t.Fatal(err)
+				}
+				if err := b.users.Insert(ctx, newUser()); !errors.Is(err, userstore.ErrAlreadyExists) {
+					t.Fatalf("expected ErrAlreadyExists, got %v", err)
+				}
+				missing := newUser()
+				missing.ID = "usr-9"
+				if err := b.users.Update(ctx, missing); !errors.Is(err, userstore.ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got %v", err)
+				}
+				if err := b.users.Delete(ctx, "usr-9"); !errors.Is(err, userstore.ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got %v", err)
+				}
+
+				page, err := b.trail.History(ctx, "usr-1", audit.Query{})
+				if err != nil || len(page.Entries) != 1 || page.Entries[0].Actor != audit.ActorSystem {
+					t.Fatalf("expected the entry of the first insert only, got %+v, %v", page, err)
+				}
+				if page, err = b.trail.History(ctx, "usr-9", audit.Query{}); err != nil || len(page.Entries) != 0 {
+					t.Fatalf("expected no entry, got %+v, %v", page, err)
+				}
+			})
+
+			t.Run("pages through the entries", func(t *testing.T) {
+				b := newBackend(t, fieldcrypt.NewNoop())
+				ctx := context.Background()
+				user := newUser()
+				if err := b.users.Insert(ctx, user); err != nil {
+					t.Fatal(err)
+				}
+				for age := int32(31); age < 35; age++ {
+					b.clock.Advance(time.Second)
+					user.Age = age
+					if err := b.users.Update(ctx, user); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				var ids []string
+				query := audit.Query{Limit: 2}
+				for {
+					page, err := b.trail.History(ctx, user.ID, query)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(page.Entries) > 2 {
+						t.Fatalf("expected at most 2 entries, got %d", len(page.Entries))
+					}
+					for _, entry := range page.Entries {
+						ids = append(ids, entry.ID)
+					}
+					if page.NextCursor == "" {
+						break
+					}
+					query.Cursor = page.NextCursor
+				}
+
+				if len(ids) != 5 {
+					t.Fatalf("expected 5 entries, got %v", ids)
+				}
+				for i := 1; i < len(ids); i++ {
+					if ids[i] >= ids[i-1] {
+						t.Errorf("expected the entries newest first, got %v", ids)
+					}
+				}
+
+				if _, err := b.trail.History(ctx, user.ID, audit.Query{Cursor: "%%%"}); !errors.Is(err, audit.ErrInvalidCursor) {
+					t.Errorf("expected ErrInvalidCursor, got %v", err)
+				}
+			})
+
+			t.Run("keeps no values of an erasure", func(t *testing.T) {
+				b := newBackend(t, fieldcrypt.NewNoop())
+				ctx := context.Background()
+				user := newUser()
+				if err := b.users.Insert(ctx, user); err != nil {
+					t.Fatal(err)
+				}
+				if err := b.users.Delete(audit.WithErasure(ctx), user.ID); err != nil {
+					t.Fatal(err)
+				}
+
+				n, err := b.trail.Redact(ctx, user.ID)
+				if err != nil || n != 2 {
+					t.Fatalf("expected 2 redacted entries, got %d, %v", n, err)
+				}
+
+				page, err := b.trail.History(ctx, user.ID, audit.Query{})
+				if err != nil || len(page.Entries) != 2 {
+					t.Fatalf("unexpected history %+v, %v", page, err)
+				}
+				for _, entry := range page.Entries {
+					if _, ok := entry.Changes["email"]; !ok {
+						t.Errorf("expected the changed fields kept, got %+v", entry.Changes)
+					}
+					for field, change := range entry.Changes {
+						if change != (audit.Change{}) {
+							t.Errorf("expected no value of %s, got %+v", field, change)
+						}
+					}
+				}
+				if raw := b.raw(t); strings.Contains(raw, "john") {
+					t.Errorf("expected no PII left, got %s", raw)
+				}
+			})
+		})
+	}
+}
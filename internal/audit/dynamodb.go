@@ -0,0 +1,232 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"time"
+)
+
+const (
+	operationHistory = "History"
+	operationRedact  = "Redact"
+
+	attributeUserID  = "UserId"
+	attributeEntryID = "EntryId"
+)
+
+// TransactPut is the item of a TransactWriteItems call appending entry to
+// the table, it fails the transaction if the entry already exists.
+func TransactPut(tableName string, entry *Entry) (types.TransactWriteItem, error) {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName:                aws.String(tableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#entryId)"),
+		ExpressionAttributeNames: map[string]string{"#entryId": attributeEntryID},
+	}}, nil
+}
+
+// DynamoDBAPI is the part of the client used by NewDynamoDB.
+type DynamoDBAPI interface {
+	dynamodbapi.ItemPutter
+	dynamodbapi.Querier
+}
+
+type dynamoImpl struct {
+	conn      DynamoDBAPI
+	tableName string
+	log       logging.Logger
+	metrics   metrics.Metrics
+	opts      clientopts.Options
+	enc       fieldcrypt.Encryptor
+}
+
+// NewDynamoDB reads the entries of the table created by CreateTable, keyed
+// by user id and entry id.
+func NewDynamoDB(conn DynamoDBAPI, tableName string, log logging.Logger, m metrics.Metrics, opts clientopts.Options, enc fieldcrypt.Encryptor) Store {
+	return &dynamoImpl{conn: conn, tableName: tableName, log: log, metrics: m, opts: opts, enc: enc}
+}
+
+// call runs fn with the per-call context of operation and records its
+// latency.
+func (d *dynamoImpl) call(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	callCtx, cancel, err := d.opts.Context(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	start := time.Now()
+	err = fn(callCtx)
+	d.metrics.RepositoryLatency(operation, start)
+	return d.opts.Err(operation, err)
+}
+
+// query returns up to limit entries of the user older than the entry
+// start, all of them when limit is zero.
+func (d *dynamoImpl) query(ctx context.Context, operation, userID, start string, limit int) ([]Entry, map[string]types.AttributeValue, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(d.tableName),
+		KeyConditionExpression:    aws.String("#userId = :userId"),
+		ExpressionAttributeNames:  map[string]string{"#userId": attributeUserID},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":userId": &types.AttributeValueMemberS{Value: userID}},
+		ScanIndexForward:          aws.Bool(false),
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}
+	if limit > 0 {
+		input.Limit = aws.Int32(int32(limit))
+	}
+	if start != "" {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			attributeUserID:  &types.AttributeValueMemberS{Value: userID},
+			attributeEntryID: &types.AttributeValueMemberS{Value: start},
+		}
+	}
+
+	var entries []Entry
+	for {
+		var out *dynamodb.QueryOutput
+		err := d.call(ctx, operation, func(ctx context.Context) (err error) {
+			out, err = d.conn.Query(ctx, input, tracing.DynamoDB, d.opts.DynamoDB)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		d.metrics.ConsumedCapacity(operation, out.ConsumedCapacity)
+
+		var page []Entry
+		if err = attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, page...)
+
+		if limit > 0 || out.LastEvaluatedKey == nil {
+			return entries, out.LastEvaluatedKey, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func (d *dynamoImpl) History(ctx context.Context, userID string, query Query) (*Page, error) {
+	log := d.log.WithContext(ctx)
+
+	var start string
+	if query.Cursor != "" {
+		var err error
+		if start, err = decodeCursor(query.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, last, err := d.query(ctx, operationHistory, userID, start, query.limit())
+	if err != nil {
+		log.Errorf("error querying history of %s: %v", userID, err)
+		return nil, err
+	}
+
+	page := &Page{Entries: []Entry{}}
+	for i := range entries {
+		if err = decrypt(ctx, d.enc, &entries[i]); err != nil {
+			log.Errorf("error decrypting entry %s: %v", entries[i].ID, err)
+			return nil, err
+		}
+		page.Entries = append(page.Entries, entries[i])
+	}
+	if last != nil && len(entries) > 0 {
+		page.NextCursor = encodeCursor(entries[len(entries)-1].ID)
+	}
+	return page, nil
+}
+
+func (d *dynamoImpl) Redact(ctx context.Context, userID string) (int, error) {
+	log := d.log.WithContext(ctx)
+
+	entries, _, err := d.query(ctx, operationRedact, userID, "", 0)
+	if err != nil {
+		log.Errorf("error querying history of %s: %v", userID, err)
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		entry.Changes = redacted(entry.Changes)
+		item, err := attributevalue.MarshalMap(entry)
+		if err != nil {
+			return 0, err
+		}
+
+		err = d.call(ctx, operationRedact, func(ctx context.Context) error {
+			out, err := d.conn.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName:              aws.String(d.tableName),
+				Item:                   item,
+				ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+			}, tracing.DynamoDB, d.opts.DynamoDB)
+			if err == nil {
+				d.metrics.ConsumedCapacity(operationRedact, out.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			log.Errorf("error redacting entry %s: %v", entry.ID, err)
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// TableAPI is the part of the client used by CreateTable.
+type TableAPI interface {
+	dynamodbapi.TableCreator
+	dynamodbapi.TableDescriber
+}
+
+// CreateTable creates the table of the entries when missing and waits
+// until it is active.
+func CreateTable(ctx context.Context, conn TableAPI, tableName string) error {
+	_, err := conn.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(attributeUserID), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(attributeEntryID), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(attributeUserID), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(attributeEntryID), KeyType: types.KeyTypeRange},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	}, tracing.DynamoDB)
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("error creating table %s: %w", tableName, err)
+	}
+
+	maxWait := clientopts.InitTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(conn, func(o *dynamodb.TableExistsWaiterOptions) {
+		o.MinDelay = 500 * time.Millisecond
+		o.MaxDelay = 2 * time.Second
+		o.ClientOptions = append(o.ClientOptions, tracing.DynamoDB)
+	})
+	if err = waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, maxWait); err != nil {
+		return fmt.Errorf("error waiting for table %s: %w", tableName, err)
+	}
+	return nil
+}
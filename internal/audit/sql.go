@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const entryColumns = "user_id, entry_id, action, actor, request_id, recorded_at, changes"
+
+// Execer is satisfied by *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Migrate creates the table of NewSQL when missing.
+func Migrate(ctx context.Context, db *sql.DB, tableName string) error {
+	statement := `CREATE TABLE IF NOT EXISTS ` + quoteIdentifier(tableName) + ` (
+		user_id     TEXT NOT NULL,
+		entry_id    TEXT NOT NULL,
+		action      TEXT NOT NULL,
+		actor       TEXT NOT NULL,
+		request_id  TEXT NOT NULL,
+		recorded_at TEXT NOT NULL,
+		changes     TEXT NOT NULL,
+		PRIMARY KEY (user_id, entry_id)
+	)`
+	if _, err := db.ExecContext(ctx, statement); err != nil {
+		return fmt.Errorf("error migrating %s: %w", tableName, err)
+	}
+	return nil
+}
+
+// Insert appends entry to the table with the transaction tx, so it is
+// committed along the write it describes.
+func Insert(ctx context.Context, tx Execer, tableName string, entry *Entry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO `+quoteIdentifier(tableName)+` (`+entryColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.UserID, entry.ID, string(entry.Action), entry.Actor, entry.RequestID, entry.Timestamp.UTC().Format(time.RFC3339Nano), string(changes))
+	return err
+}
+
+// quoteIdentifier quotes a table name the same way for PostgreSQL and
+// SQLite.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+type sqlImpl struct {
+	db      *sql.DB
+	table   string
+	log     logging.Logger
+	metrics metrics.Metrics
+	opts    clientopts.Options
+	enc     fieldcrypt.Encryptor
+}
+
+// NewSQL reads the entries of a table of db, created by Migrate.
+func NewSQL(db *sql.DB, tableName string, log logging.Logger, m metrics.Metrics, opts clientopts.Options, enc fieldcrypt.Encryptor) Store {
+	return &sqlImpl{
+		db:      db,
+		table:   quoteIdentifier(tableName),
+		log:     log,
+		metrics: m,
+		opts:    opts,
+		enc:     enc,
+	}
+}
+
+// call runs fn with the per-call context of operation and records its
+// latency.
+func (s *sqlImpl) call(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	callCtx, cancel, err := s.opts.Context(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	start := time.Now()
+	err = fn(callCtx)
+	s.metrics.RepositoryLatency(operation, start)
+	return s.opts.Err(operation, err)
+}
+
+// selectEntries returns up to limit entries of the user older than the
+// entry start, all of them when limit is zero.
+func (s *sqlImpl) selectEntries(ctx context.Context, operation, userID, start string, limit int) ([]Entry, error) {
+	statement := `SELECT ` + entryColumns + ` FROM ` + s.table + ` WHERE user_id = $1`
+	args := []any{userID}
+	if start != "" {
+		args = append(args, start)
+		statement += ` AND entry_id < $2`
+	}
+	statement += ` ORDER BY entry_id DESC`
+	if limit > 0 {
+		statement += ` LIMIT ` + strconv.Itoa(limit)
+	}
+
+	var entries []Entry
+	err := s.call(ctx, operation, func(ctx context.Context) error {
+		rows, err := s.db.QueryContext(ctx, statement, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var entry Entry
+			var recordedAt, changes string
+			if err = rows.Scan(&entry.UserID, &entry.ID, &entry.Action, &entry.Actor, &entry.RequestID, &recordedAt, &changes); err != nil {
+				return err
+			}
+			if entry.Timestamp, err = time.Parse(time.RFC3339Nano, recordedAt); err != nil {
+				return err
+			}
+			if err = json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return rows.Err()
+	})
+	return entries, err
+}
+
+func (s *sqlImpl) History(ctx context.Context, userID string, query Query) (*Page, error) {
+	log := s.log.WithContext(ctx)
+
+	var start string
+	if query.Cursor != "" {
+		var err error
+		if start, err = decodeCursor(query.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	// one more entry than the limit tells whether there is a next page
+	limit := query.limit()
+	entries, err := s.selectEntries(ctx, operationHistory, userID, start, limit+1)
+	if err != nil {
+		log.Errorf("error selecting history of %s: %v", userID, err)
+		return nil, err
+	}
+
+	page := &Page{Entries: []Entry{}}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		page.NextCursor = encodeCursor(entries[limit-1].ID)
+	}
+	for i := range entries {
+		if err = decrypt(ctx, s.enc, &entries[i]); err != nil {
+			log.Errorf("error decrypting entry %s: %v", entries[i].ID, err)
+			return nil, err
+		}
+		page.Entries = append(page.Entries, entries[i])
+	}
+	return page, nil
+}
+
+func (s *sqlImpl) Redact(ctx context.Context, userID string) (int, error) {
+	log := s.log.WithContext(ctx)
+
+	entries, err := s.selectEntries(ctx, operationRedact, userID, "", 0)
+	if err != nil {
+		log.Errorf("error selecting history of %s: %v", userID, err)
+		return 0, err
+	}
+
+	statement := `UPDATE ` + s.table + ` SET changes = $1 WHERE user_id = $2 AND entry_id = $3`
+	for _, entry := range entries {
+		changes, err := json.Marshal(redacted(entry.Changes))
+		if err != nil {
+			return 0, err
+		}
+
+		err = s.call(ctx, operationRedact, func(ctx context.Context) error {
+			_, err := s.db.ExecContext(ctx, statement, string(changes), entry.UserID, entry.ID)
+			return err
+		})
+		if err != nil {
+			log.Errorf("error redacting entry %s: %v", entry.ID, err)
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
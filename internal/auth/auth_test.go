@@ -0,0 +1,347 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// keys holds the signing keys of the tests and their JWKS.
+type keys struct {
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	jwks []byte
+}
+
+func newKeys(t *testing.T) keys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+	}})
+	return keys{rsa: rsaKey, ec: ecKey, jwks: jwks}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "user-1",
+		"email": "john@example.com",
+		"scope": "users:read users:write",
+		"iss":   "https://issuer.example.com",
+		"aud":   "users-api",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func bearer(token string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{Headers: map[string]string{"authorization": "Bearer " + token}}
+}
+
+func TestAuthorizer(t *testing.T) {
+	authenticator := auth.NewAuthorizer("admin")
+
+	tests := []struct {
+		name       string
+		authorizer map[string]any
+		subject    string
+		scopes     []string
+		admin      bool
+	}{
+		{
+			name: "cognito user pool",
+			authorizer: map[string]any{"claims": map[string]any{
+				"sub": "user-1", "scope": "users:read", "cognito:groups": "[admin readers]",
+			}},
+			subject: "user-1", scopes: []string{"users:read"}, admin: true,
+		},
+		{
+			name: "http api jwt",
+			authorizer: map[string]any{"jwt": map[string]any{"claims": map[string]any{
+				"sub": "user-2", "scope": "users:read users:write",
+			}}},
+			subject: "user-2", scopes: []string{"users:read", "users:write"},
+		},
+		{
+			name:       "lambda authorizer",
+			authorizer: map[string]any{"principalId": "user-3", "scope": "users:read,users:admin"},
+			subject:    "user-3", scopes: []string{"users:read", "users:admin"}, admin: true,
+		},
+		{
+			name:       "scp array",
+			authorizer: map[string]any{"claims": map[string]any{"sub": "user-4", "scp": []any{"users:read"}}},
+			subject:    "user-4", scopes: []string{"users:read"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{RequestContext: events.APIGatewayProxyRequestContext{Authorizer: tc.authorizer}}
+			id, err := authenticator.Authenticate(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if id.Subject != tc.subject || !slices.Equal(id.Scopes, tc.scopes) || id.Admin != tc.admin {
+				t.Errorf("unexpected identity: %+v", id)
+			}
+		})
+	}
+
+	for name, authorizer := range map[string]map[string]any{
+		"no context": nil,
+		"no subject": {"claims": map[string]any{"scope": "users:read"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{RequestContext: events.APIGatewayProxyRequestContext{Authorizer: authorizer}}
+			if _, err := authenticator.Authenticate(context.Background(), req); !errors.Is(err, auth.ErrUnauthenticated) {
+				t.Errorf("expected ErrUnauthenticated, got %v", err)
+			}
+		})
+	}
+}
+
+func TestJWT(t *testing.T) {
+	k := newKeys(t)
+	keySet, err := auth.ParseJWKS(k.jwks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keySet) != 2 {
+		t.Fatalf("expected the two signing keys, got %d", len(keySet))
+	}
+	authenticator := auth.NewJWT(keySet, "https://issuer.example.com", "users-api", "admin")
+
+	for name, token := range map[string]string{
+		"rsa": sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, validClaims()),
+		"ec":  sign(t, jwt.SigningMethodES256, "ec-1", k.ec, validClaims()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			id, err := authenticator.Authenticate(context.Background(), bearer(token))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if id.Subject != "user-1" || id.Email != "john@example.com" || !id.HasScope(auth.ScopeWrite) || id.Admin {
+				t.Errorf("unexpected identity: %+v", id)
+			}
+		})
+	}
+
+	with := func(name string, value any) jwt.MapClaims {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	rejected := map[string]events.APIGatewayProxyRequest{
+		"no header":      {},
+		"basic scheme":   {Headers: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}},
+		"malformed":      bearer("not-a-jwt"),
+		"expired":        bearer(sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, with("exp", time.Now().Add(-time.Hour).Unix()))),
+		"no expiration":  bearer(sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, with("exp", nil))),
+		"wrong issuer":   bearer(sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, with("iss", "https://evil.example.com"))),
+		"wrong audience": bearer(sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, with("aud", "other-api"))),
+		"unknown kid":    bearer(sign(t, jwt.SigningMethodRS256, "rsa-2", k.rsa, validClaims())),
+		"wrong key":      bearer(sign(t, jwt.SigningMethodRS256, "rsa-1", other, validClaims())),
+		"hmac":           bearer(sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), validClaims())),
+		"no subject":     bearer(sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, with("sub", nil))),
+	}
+	for name, req := range rejected {
+		t.Run(name, func(t *testing.T) {
+			if _, err := authenticator.Authenticate(context.Background(), req); !errors.Is(err, auth.ErrUnauthenticated) {
+				t.Errorf("expected ErrUnauthenticated, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	for name, jwks := range map[string]string{
+		"not json":     `keys`,
+		"no key":       `{"keys": []}`,
+		"only hmac":    `{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`,
+		"bad modulus":  `{"keys": [{"kty": "RSA", "kid": "1", "n": "***", "e": "AQAB"}]}`,
+		"bad curve":    `{"keys": [{"kty": "EC", "kid": "1", "crv": "P-192", "x": "AQ", "y": "AQ"}]}`,
+		"not on curve": `{"keys": [{"kty": "EC", "kid": "1", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := auth.ParseJWKS([]byte(jwks)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	log := logging.New(logging.Opts{AppName: "auth-test", Level: "error"})
+	sink := metrics.NewMemorySink()
+	mw := auth.NewMiddleware(auth.NewAuthorizer("admin"), log, metrics.New("auth-test", sink))
+
+	var received *auth.Identity
+	next := mw.Require(auth.ScopeWrite, func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		received, _ = auth.FromContext(ctx)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusCreated}, nil
+	})
+
+	withClaims := func(claims map[string]any) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			Path:           "/users",
+			RequestContext: events.APIGatewayProxyRequestContext{Authorizer: map[string]any{"claims": claims}},
+		}
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		res, err := next(context.Background(), withClaims(map[string]any{"sub": "user-1", "scope": "users:write"}))
+		if err != nil || res.StatusCode != http.StatusCreated {
+			t.Fatalf("unexpected response: %+v, %v", res, err)
+		}
+		if received == nil || received.Subject != "user-1" {
+			t.Errorf("identity not passed to the handler: %+v", received)
+		}
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		res, _ := next(context.Background(), events.APIGatewayProxyRequest{Path: "/users"})
+		if res.StatusCode != http.StatusUnauthorized || res.Headers["Content-Type"] != problem.ContentType || res.Headers["WWW-Authenticate"] != "Bearer" {
+			t.Errorf("unexpected response: %+v", res)
+		}
+		if sink.Sum("Authorize", metrics.MetricUnauthenticated) != 1 {
+			t.Errorf("denial not counted")
+		}
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		res, _ := next(context.Background(), withClaims(map[string]any{"sub": "user-1", "scope": "users:read"}))
+		if res.StatusCode != http.StatusForbidden || res.Headers["WWW-Authenticate"] != `Bearer error="insufficient_scope", scope="users:write"` {
+			t.Errorf("unexpected response: %+v", res)
+		}
+
+		var body problem.Problem
+		if err := json.Unmarshal([]byte(res.Body), &body); err != nil || body.Status != http.StatusForbidden || body.Instance != "/users" {
+			t.Errorf("unexpected problem: %s", res.Body)
+		}
+	})
+
+	t.Run("admin group", func(t *testing.T) {
+		admin := mw.Require(auth.ScopeAdmin, func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+		})
+
+		if res, _ := admin(context.Background(), withClaims(map[string]any{"sub": "user-2", "cognito:groups": "admin"})); res.StatusCode != http.StatusOK {
+			t.Errorf("expected the admin group to hold the admin scope, got %+v", res)
+		}
+		if res, _ := admin(context.Background(), withClaims(map[string]any{"sub": "user-1", "scope": "users:write"})); res.StatusCode != http.StatusForbidden {
+			t.Errorf("unexpected response: %+v", res)
+		}
+	})
+}
+
+func TestCanAccessUser(t *testing.T) {
+	owner := &auth.Identity{Subject: "sub-1"}
+	admin := &auth.Identity{Subject: "admin-1", Admin: true}
+
+	if !owner.CanAccessUser("sub-1") || owner.CanAccessUser("sub-2") {
+		t.Error("expected the owner to access its users only")
+	}
+	if owner.CanAccessUser("") || (&auth.Identity{}).CanAccessUser("") {
+		t.Error("expected the users without owner to be left to the admins")
+	}
+	if !admin.CanAccessUser("sub-1") || !admin.CanAccessUser("") {
+		t.Error("expected the admins to access every user")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	log := logging.New(logging.Opts{AppName: "auth-test", Level: "error"})
+	k := newKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, k.jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+
+	mw, err := auth.Open(config.Auth{Mode: config.AuthOff}, log, metrics.NewNoop())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res, _ := mw.Require(auth.ScopeRead, handler)(context.Background(), events.APIGatewayProxyRequest{}); res.StatusCode != http.StatusOK {
+		t.Errorf("off mode must not authenticate: %+v", res)
+	}
+
+	for _, cfg := range []config.Auth{
+		{Mode: config.AuthJWT, JWKSFile: path},
+		{Mode: config.AuthJWT, JWKSFile: path, Issuer: "https://issuer.example.com"},
+		{Mode: config.AuthJWT, JWKSFile: path, Audience: "users-api"},
+	} {
+		if _, err = auth.Open(cfg, log, metrics.NewNoop()); err == nil {
+			t.Errorf("expected an error without issuer or audience: %+v", cfg)
+		}
+	}
+
+	mw, err = auth.Open(config.Auth{Mode: config.AuthJWT, JWKSFile: path, Issuer: "https://issuer.example.com", Audience: "users-api"}, log, metrics.NewNoop())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	token := sign(t, jwt.SigningMethodRS256, "rsa-1", k.rsa, validClaims())
+	if res, _ := mw.Require(auth.ScopeRead, handler)(context.Background(), bearer(token)); res.StatusCode != http.StatusOK {
+		t.Errorf("valid token rejected: %+v", res)
+	}
+	if res, _ := mw.Require(auth.ScopeRead, handler)(context.Background(), bearer("expired")); res.Headers["WWW-Authenticate"] != `Bearer error="invalid_token"` {
+		t.Errorf("unexpected challenge: %+v", res)
+	}
+
+	if _, err = auth.Open(config.Auth{Mode: config.AuthJWT, JWKSFile: filepath.Join(t.TempDir(), "missing.json"), Issuer: "https://issuer.example.com", Audience: "users-api"}, log, metrics.NewNoop()); err == nil {
+		t.Error("expected an error for a missing jwks file")
+	}
+}
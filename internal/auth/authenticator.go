@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"strings"
+	"time"
+)
+
+type Authenticator interface {
+	// Authenticate returns the identity of the caller, the error wraps
+	// ErrUnauthenticated when the request carries no valid credentials.
+	Authenticate(ctx context.Context, req events.APIGatewayProxyRequest) (*Identity, error)
+}
+
+type authorizerImpl struct {
+	adminGroup string
+}
+
+// NewAuthorizer trusts the claims API Gateway puts in the request context
+// once its authorizer (Cognito user pool, JWT or lambda) accepted the call.
+func NewAuthorizer(adminGroup string) Authenticator {
+	return &authorizerImpl{adminGroup: adminGroup}
+}
+
+func (a *authorizerImpl) Authenticate(_ context.Context, req events.APIGatewayProxyRequest) (*Identity, error) {
+	authorizer := req.RequestContext.Authorizer
+	if len(authorizer) == 0 {
+		return nil, fmt.Errorf("%w: no authorizer context", ErrUnauthenticated)
+	}
+
+	claims := authorizer
+	if nested, ok := authorizer["claims"].(map[string]any); ok {
+		// cognito user pool authorizer
+		claims = nested
+	} else if jwtContext, ok := authorizer["jwt"].(map[string]any); ok {
+		if nested, ok = jwtContext["claims"].(map[string]any); ok {
+			claims = nested
+		}
+	}
+	return identityFromClaims(claims, a.adminGroup)
+}
+
+// signingMethods are the algorithms accepted in bearer tokens, none and the
+// HMAC ones are never accepted with a public key set.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+type jwtImpl struct {
+	keys       KeySet
+	parser     *jwt.Parser
+	adminGroup string
+}
+
+// NewJWT validates the bearer token of the Authorization header against
+// keys, issuer and audience. Tokens must expire.
+func NewJWT(keys KeySet, issuer, audience, adminGroup string) Authenticator {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+	}
+
+	return &jwtImpl{keys: keys, parser: jwt.NewParser(options...), adminGroup: adminGroup}
+}
+
+func (a *jwtImpl) Authenticate(_ context.Context, req events.APIGatewayProxyRequest) (*Identity, error) {
+	raw, ok := bearerToken(req.Headers)
+	if !ok {
+		return nil, fmt.Errorf("%w: no bearer token", ErrUnauthenticated)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+	return identityFromClaims(claims, a.adminGroup)
+}
+
+// bearerToken reads the Authorization header, whatever the case of its
+// name and scheme.
+func bearerToken(headers map[string]string) (string, bool) {
+	for key, value := range headers {
+		if !strings.EqualFold(key, "Authorization") {
+			continue
+		}
+		scheme, token, ok := strings.Cut(strings.TrimSpace(value), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return "", false
+		}
+		return strings.TrimSpace(token), true
+	}
+	return "", false
+}
@@ -0,0 +1,106 @@
+// Package auth authenticates the callers of the API Gateway handlers, from
+// the claims of the API Gateway authorizer or from a bearer JWT validated
+// against a local JWKS, and enforces the scopes of the routes.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeRead  = "users:read"
+	ScopeWrite = "users:write"
+	// ScopeAdmin grants access to every user, like the admin group.
+	ScopeAdmin = "users:admin"
+)
+
+var (
+	// ErrUnauthenticated is answered with 401.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is answered with 403.
+	ErrForbidden = errors.New("forbidden")
+)
+
+type Identity struct {
+	Subject string
+	Email   string
+	Scopes  []string
+	Groups  []string
+	Admin   bool
+}
+
+func (id *Identity) HasScope(scope string) bool {
+	return slices.Contains(id.Scopes, scope)
+}
+
+// CanAccessUser tells whether the caller may access the user created by
+// ownerSubject, admins access every user and the others the users they
+// created. The users without owner are left to the admins.
+func (id *Identity) CanAccessUser(ownerSubject string) bool {
+	return id.Admin || (ownerSubject != "" && id.Subject == ownerSubject)
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity of the caller, false when the handler
+// runs without authentication.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// identityFromClaims reads the standard and Cognito claims. The authorizer
+// of API Gateway flattens them to strings, so lists are accepted as JSON
+// arrays, "[a b]" or comma and space separated strings.
+func identityFromClaims(claims map[string]any, adminGroup string) (*Identity, error) {
+	subject := stringClaim(claims, "sub")
+	if subject == "" {
+		// context of a lambda authorizer
+		subject = stringClaim(claims, "principalId")
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrUnauthenticated)
+	}
+
+	id := &Identity{
+		Subject: subject,
+		Email:   stringClaim(claims, "email"),
+		Scopes:  append(listClaim(claims, "scope"), listClaim(claims, "scp")...),
+		Groups:  append(listClaim(claims, "cognito:groups"), listClaim(claims, "groups")...),
+	}
+	id.Admin = id.HasScope(ScopeAdmin) || (adminGroup != "" && slices.Contains(id.Groups, adminGroup))
+	return id, nil
+}
+
+func stringClaim(claims map[string]any, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+func listClaim(claims map[string]any, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+	case []any:
+		var values []string
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	case []string:
+		return value
+	default:
+		return nil
+	}
+}
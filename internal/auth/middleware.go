@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"net/http"
+)
+
+const operationAuthorize = "Authorize"
+
+// HandlerFunc is the signature of the API Gateway proxy handlers.
+type HandlerFunc func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+type Middleware interface {
+	// Require authenticates the caller and checks scope before calling
+	// next with the identity in its context, see FromContext.
+	Require(scope string, next HandlerFunc) HandlerFunc
+}
+
+type middlewareImpl struct {
+	authenticator Authenticator
+	log           logging.Logger
+	metrics       metrics.Metrics
+}
+
+// NewMiddleware answers 401 to unauthenticated callers and 403 to the ones
+// missing the scope of the route, with problem responses. A nil
+// authenticator turns authentication off.
+func NewMiddleware(authenticator Authenticator, log logging.Logger, m metrics.Metrics) Middleware {
+	return &middlewareImpl{authenticator: authenticator, log: log, metrics: m}
+}
+
+// Open returns the middleware of the AUTH_MODE of cfg, the JWKS file is read
+// once.
+func Open(cfg config.Auth, log logging.Logger, m metrics.Metrics) (Middleware, error) {
+	switch cfg.Mode {
+	case config.AuthOff:
+		return NewMiddleware(nil, log, m), nil
+	case config.AuthJWT:
+		// any token signed by the keys would pass without both, including
+		// the tokens the issuer grants to other APIs
+		if cfg.Issuer == "" || cfg.Audience == "" {
+			return nil, fmt.Errorf("AUTH_ISSUER and AUTH_AUDIENCE are required with AUTH_MODE=%s", config.AuthJWT)
+		}
+		keys, err := LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading AUTH_JWKS_FILE: %w", err)
+		}
+		return NewMiddleware(NewJWT(keys, cfg.Issuer, cfg.Audience, cfg.AdminGroup), log, m), nil
+	default:
+		return NewMiddleware(NewAuthorizer(cfg.AdminGroup), log, m), nil
+	}
+}
+
+func (mw *middlewareImpl) Require(scope string, next HandlerFunc) HandlerFunc {
+	if mw.authenticator == nil {
+		return next
+	}
+
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		log := mw.log.WithContext(logging.WithAPIGatewayRequest(ctx, req))
+
+		id, err := mw.authenticator.Authenticate(ctx, req)
+		if err != nil {
+			log.Warnf("request not authenticated: %v", err)
+			mw.metrics.Increment(operationAuthorize, metrics.MetricUnauthenticated)
+			challenge := "Bearer"
+			if hasAuthorization(req.Headers) {
+				challenge = `Bearer error="invalid_token"`
+			}
+			return Unauthenticated(req, challenge), nil
+		}
+
+		// members of the admin group hold the admin scope implicitly
+		if !id.HasScope(scope) && !(scope == ScopeAdmin && id.Admin) {
+			log.Warnf("subject %s lacks scope %s", id.Subject, scope)
+			mw.metrics.Increment(operationAuthorize, metrics.MetricForbidden)
+			return Forbidden(req, fmt.Sprintf("the %s scope is required", scope), map[string]string{
+				"WWW-Authenticate": fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope),
+			}), nil
+		}
+
+		return next(WithIdentity(ctx, id), req)
+	}
+}
+
+func hasAuthorization(headers map[string]string) bool {
+	_, ok := bearerToken(headers)
+	return ok
+}
+
+// Unauthenticated is the 401 problem response, challenge is the value of
+// WWW-Authenticate.
+func Unauthenticated(req events.APIGatewayProxyRequest, challenge string) events.APIGatewayProxyResponse {
+	p := problem.New(http.StatusUnauthorized, "valid credentials are required")
+	p.Instance = req.Path
+	return p.Response(map[string]string{"WWW-Authenticate": challenge})
+}
+
+// Forbidden is the 403 problem response, for handlers refusing a resource
+// to an authenticated caller.
+func Forbidden(req events.APIGatewayProxyRequest, detail string, headers map[string]string) events.APIGatewayProxyResponse {
+	p := problem.New(http.StatusForbidden, detail)
+	p.Instance = req.Path
+	return p.Response(headers)
+}
@@ -0,0 +1,18 @@
+// Package cache is the key/value store behind the read-through caches of the
+// repositories. NewLRU keeps the entries in the container, NewRedis shares
+// them between containers through a Redis-protocol server.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+type Cache interface {
+	// Get returns the value of key, false when it is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key, a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,131 @@
+package cache_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"testing"
+	"time"
+)
+
+func assertGet(t *testing.T, c cache.Cache, key, expected string) {
+	t.Helper()
+	value, ok, err := c.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected == "" {
+		if ok {
+			t.Errorf("expected %s to be missing, got %q", key, value)
+		}
+		return
+	}
+	if !ok || string(value) != expected {
+		t.Errorf("expected %s=%q, got %q (found %v)", key, expected, value, ok)
+	}
+}
+
+// testCache checks the behaviour shared by the backends, expire moves the
+// time of the backend past ttl.
+func testCache(t *testing.T, c cache.Cache, expire func(ttl time.Duration)) {
+	ctx := context.Background()
+
+	assertGet(t, c, "missing", "")
+
+	if err := c.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGet(t, c, "a", "1")
+
+	if err := c.Set(ctx, "a", []byte("2"), time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGet(t, c, "a", "2")
+
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGet(t, c, "a", "")
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatalf("deleting a missing key: %v", err)
+	}
+
+	if err := c.Set(ctx, "short", []byte("1"), 20*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expire(20 * time.Millisecond)
+	assertGet(t, c, "short", "")
+}
+
+func TestLRU(t *testing.T) {
+	testCache(t, cache.NewLRU(10), func(ttl time.Duration) { time.Sleep(ttl) })
+}
+
+func TestLRUEviction(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(2)
+
+	_ = c.Set(ctx, "a", []byte("a"), time.Minute)
+	_ = c.Set(ctx, "b", []byte("b"), time.Minute)
+	// reading a makes b the least recently used entry
+	assertGet(t, c, "a", "a")
+	_ = c.Set(ctx, "c", []byte("c"), time.Minute)
+
+	assertGet(t, c, "a", "a")
+	assertGet(t, c, "b", "")
+	assertGet(t, c, "c", "c")
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	testCache(t, cache.NewRedis(client), server.FastForward)
+}
+
+func TestRedisUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = client.Close() })
+	server.Close()
+
+	if _, _, err := cache.NewRedis(client).Get(context.Background(), "a"); err == nil {
+		t.Fatal("expected an error with the server down")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+
+	for _, tc := range []struct {
+		cfg      config.Cache
+		expected bool
+	}{
+		{config.Cache{Backend: config.CacheNone}, false},
+		{config.Cache{Backend: config.CacheMemory, Size: 10}, true},
+		{config.Cache{Backend: config.CacheRedis, RedisAddr: server.Addr(), RedisPassword: config.NewSecret("secret")}, true},
+	} {
+		t.Run(tc.cfg.Backend, func(t *testing.T) {
+			c, closer, err := cache.Open(context.Background(), tc.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer closer.Close()
+
+			if (c != nil) != tc.expected {
+				t.Fatalf("unexpected cache %v", c)
+			}
+			if c == nil {
+				return
+			}
+			if err := c.Set(context.Background(), "a", []byte("1"), time.Minute); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertGet(t, c, "a", "1")
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"io"
+)
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Open returns the backend selected by cfg, nil when caching is off. The
+// password of Redis is resolved once, at cold start.
+func Open(ctx context.Context, cfg config.Cache) (Cache, io.Closer, error) {
+	switch cfg.Backend {
+	case config.CacheMemory:
+		return NewLRU(cfg.Size), nopCloser{}, nil
+	case config.CacheRedis:
+		password, err := cfg.RedisPassword.Value(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error resolving CACHE_REDIS_PASSWORD: %w", err)
+		}
+
+		opts := &redis.Options{Addr: cfg.RedisAddr, Password: password}
+		if cfg.RedisTLS {
+			opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+
+		client := redis.NewClient(opts)
+		return NewRedis(client), client, nil
+	default:
+		return nil, nopCloser{}, nil
+	}
+}
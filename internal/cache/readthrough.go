@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"time"
+)
+
+// Options of NewReadThrough.
+type Options struct {
+	// Operation dimensions the hit ratio metric.
+	Operation string
+	// Prefix namespaces the keys, such as "user:".
+	Prefix string
+	// Version is stored with every entry, entries written with another
+	// version (by an older deployment sharing a Redis) are misses. Bumping
+	// it invalidates the whole cache.
+	Version string
+	// TTL bounds the staleness of a found value.
+	TTL time.Duration
+	// NegativeTTL is how long a missing value is remembered, zero disables
+	// negative caching.
+	NegativeTTL time.Duration
+	// NotFound is the error of load for a missing value, it is returned
+	// again for negative entries.
+	NotFound error
+}
+
+// ReadThrough answers from the cache and loads missing values from the
+// source. Failures of the cache are logged and fall back to the source, the
+// cache never fails a read.
+type ReadThrough[T any] interface {
+	Get(ctx context.Context, id string, load func(ctx context.Context) (*T, error)) (*T, error)
+	// Invalidate drops the entry of id, after a write for instance.
+	Invalidate(ctx context.Context, id string) error
+}
+
+type entry[T any] struct {
+	Version string `json:"v"`
+	Found   bool   `json:"found"`
+	Value   *T     `json:"value,omitempty"`
+}
+
+type readThroughImpl[T any] struct {
+	cache   Cache
+	opts    Options
+	log     logging.Logger
+	metrics metrics.Metrics
+}
+
+func NewReadThrough[T any](c Cache, opts Options, log logging.Logger, m metrics.Metrics) ReadThrough[T] {
+	return &readThroughImpl[T]{
+		cache:   c,
+		opts:    opts,
+		log:     log,
+		metrics: m,
+	}
+}
+
+func (r *readThroughImpl[T]) key(id string) string {
+	return r.opts.Prefix + id
+}
+
+func (r *readThroughImpl[T]) Get(ctx context.Context, id string, load func(ctx context.Context) (*T, error)) (*T, error) {
+	log := r.log.WithContext(ctx)
+	key := r.key(id)
+
+	if e, ok := r.lookup(ctx, key); ok {
+		r.metrics.CacheLookup(r.opts.Operation, true)
+		if !e.Found {
+			return nil, r.opts.NotFound
+		}
+		return e.Value, nil
+	}
+	r.metrics.CacheLookup(r.opts.Operation, false)
+
+	value, err := load(ctx)
+	switch {
+	case err == nil:
+		r.store(ctx, key, entry[T]{Version: r.opts.Version, Found: true, Value: value}, r.opts.TTL)
+	case r.opts.NotFound != nil && errors.Is(err, r.opts.NotFound) && r.opts.NegativeTTL > 0:
+		r.store(ctx, key, entry[T]{Version: r.opts.Version}, r.opts.NegativeTTL)
+	default:
+		log.Debugf("not caching %s: %v", key, err)
+	}
+	return value, err
+}
+
+// lookup returns the entry of key, false on a miss, an entry of another
+// version or an error of the cache.
+func (r *readThroughImpl[T]) lookup(ctx context.Context, key string) (entry[T], bool) {
+	log := r.log.WithContext(ctx)
+
+	var e entry[T]
+	raw, ok, err := r.cache.Get(ctx, key)
+	if err != nil {
+		log.Warnf("error reading cache %s: %v", key, err)
+		return e, false
+	}
+	if !ok {
+		return e, false
+	}
+
+	if err = json.Unmarshal(raw, &e); err != nil || e.Version != r.opts.Version {
+		log.Debugf("discarding cache entry %s of version %q", key, e.Version)
+		return e, false
+	}
+	return e, true
+}
+
+func (r *readThroughImpl[T]) store(ctx context.Context, key string, e entry[T], ttl time.Duration) {
+	log := r.log.WithContext(ctx)
+
+	raw, err := json.Marshal(e)
+	if err != nil {
+		log.Warnf("error encoding cache entry %s: %v", key, err)
+		return
+	}
+	if err = r.cache.Set(ctx, key, raw, ttl); err != nil {
+		log.Warnf("error writing cache %s: %v", key, err)
+	}
+}
+
+func (r *readThroughImpl[T]) Invalidate(ctx context.Context, id string) error {
+	return r.cache.Delete(ctx, r.key(id))
+}
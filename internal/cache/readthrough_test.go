@@ -0,0 +1,150 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"testing"
+	"time"
+)
+
+var errNotFound = errors.New("not found")
+
+type user struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// source counts the loads of a fake repository.
+type source struct {
+	users map[string]*user
+	err   error
+	loads int
+}
+
+func (s *source) load(id string) func(ctx context.Context) (*user, error) {
+	return func(context.Context) (*user, error) {
+		s.loads++
+		if s.err != nil {
+			return nil, s.err
+		}
+		if u, ok := s.users[id]; ok {
+			return u, nil
+		}
+		return nil, errNotFound
+	}
+}
+
+// failingCache fails every call, like an unreachable Redis.
+type failingCache struct{}
+
+func (failingCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingCache) Delete(context.Context, string) error {
+	return errors.New("connection refused")
+}
+
+func newReadThrough(c cache.Cache, sink metrics.Sink, version string) cache.ReadThrough[user] {
+	return cache.NewReadThrough[user](c, cache.Options{
+		Operation:   "FindUser",
+		Prefix:      "user:",
+		Version:     version,
+		TTL:         time.Minute,
+		NegativeTTL: 30 * time.Millisecond,
+		NotFound:    errNotFound,
+	}, logging.New(logging.Opts{AppName: "cache-test", Level: "error"}), metrics.New("cache-test", sink))
+}
+
+func TestReadThrough(t *testing.T) {
+	ctx := context.Background()
+	src := &source{users: map[string]*user{"1": {ID: "1", Name: "john"}}}
+	sink := metrics.NewMemorySink()
+	r := newReadThrough(cache.NewLRU(10), sink, "1")
+
+	for i := 0; i < 3; i++ {
+		u, err := r.Get(ctx, "1", src.load("1"))
+		if err != nil || u.Name != "john" {
+			t.Fatalf("unexpected result: %v, %v", u, err)
+		}
+	}
+	if src.loads != 1 {
+		t.Errorf("expected a single load, got %d", src.loads)
+	}
+	if hits := sink.Values("FindUser", metrics.MetricCacheHit); len(hits) != 3 || sink.Sum("FindUser", metrics.MetricCacheHit) != 2 {
+		t.Errorf("expected a miss then two hits, got %v", hits)
+	}
+
+	if err := r.Invalidate(ctx, "1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	src.users["1"].Name = "jane"
+	if u, _ := r.Get(ctx, "1", src.load("1")); u.Name != "jane" || src.loads != 2 {
+		t.Errorf("expected a reload after invalidation, got %v after %d loads", u, src.loads)
+	}
+}
+
+func TestReadThroughNegative(t *testing.T) {
+	ctx := context.Background()
+	src := &source{users: map[string]*user{}}
+	r := newReadThrough(cache.NewLRU(10), metrics.NewMemorySink(), "1")
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Get(ctx, "missing", src.load("missing")); !errors.Is(err, errNotFound) {
+			t.Fatalf("expected errNotFound, got %v", err)
+		}
+	}
+	if src.loads != 1 {
+		t.Errorf("expected the miss to be cached, got %d loads", src.loads)
+	}
+
+	// the user created meanwhile shows up once the negative entry expires
+	src.users["missing"] = &user{ID: "missing"}
+	time.Sleep(30 * time.Millisecond)
+	if u, err := r.Get(ctx, "missing", src.load("missing")); err != nil || u.ID != "missing" {
+		t.Errorf("expected the user after the negative ttl, got %v, %v", u, err)
+	}
+}
+
+func TestReadThroughSkipsErrors(t *testing.T) {
+	ctx := context.Background()
+	src := &source{err: errors.New("throttled")}
+	r := newReadThrough(cache.NewLRU(10), metrics.NewMemorySink(), "1")
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Get(ctx, "1", src.load("1")); err == nil {
+			t.Fatal("expected the error of the source")
+		}
+	}
+	if src.loads != 2 {
+		t.Errorf("errors must not be cached, got %d loads", src.loads)
+	}
+}
+
+func TestReadThroughVersion(t *testing.T) {
+	ctx := context.Background()
+	src := &source{users: map[string]*user{"1": {ID: "1"}}}
+	shared := cache.NewLRU(10)
+
+	_, _ = newReadThrough(shared, metrics.NewMemorySink(), "1").Get(ctx, "1", src.load("1"))
+	_, _ = newReadThrough(shared, metrics.NewMemorySink(), "2").Get(ctx, "1", src.load("1"))
+	if src.loads != 2 {
+		t.Errorf("entries of another version must be ignored, got %d loads", src.loads)
+	}
+}
+
+func TestReadThroughCacheDown(t *testing.T) {
+	src := &source{users: map[string]*user{"1": {ID: "1"}}}
+	r := newReadThrough(failingCache{}, metrics.NewMemorySink(), "1")
+
+	if u, err := r.Get(context.Background(), "1", src.load("1")); err != nil || u.ID != "1" {
+		t.Fatalf("expected the source to answer, got %v, %v", u, err)
+	}
+}
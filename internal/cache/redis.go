@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type redisImpl struct {
+	client redis.Cmdable
+}
+
+// NewRedis stores the entries in a Redis-protocol server (Redis, Valkey,
+// ElastiCache), expiration is left to the server.
+func NewRedis(client redis.Cmdable) Cache {
+	return &redisImpl{client: client}
+}
+
+func (c *redisImpl) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *redisImpl) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (c *redisImpl) Delete(ctx context.Context, key string) error {
+	return c.client.Del(ctx, key).Err()
+}
@@ -0,0 +1,100 @@
+package clientopts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"time"
+)
+
+// ErrUnavailable is returned when the time left in the invocation is not
+// enough to complete a call, so the handler can answer before the runtime
+// kills the invocation.
+var ErrUnavailable = errors.New("service unavailable")
+
+// InitTimeout is the time lambda gives to the init phase, calls made from
+// main have no invocation deadline and use it instead.
+const InitTimeout = 10 * time.Second
+
+type Policy struct {
+	// CallTimeout caps a single call, retries included.
+	CallTimeout time.Duration
+	// SafetyMargin is kept out of every call to build the response.
+	SafetyMargin time.Duration
+	// MinCallTime is the smallest budget worth starting a call with.
+	MinCallTime time.Duration
+	// MaxAttempts includes the first attempt.
+	MaxAttempts int
+	// MaxBackoff caps the jittered exponential delay between attempts.
+	MaxBackoff time.Duration
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		CallTimeout:  3 * time.Second,
+		SafetyMargin: 250 * time.Millisecond,
+		MinCallTime:  50 * time.Millisecond,
+		MaxAttempts:  5,
+		MaxBackoff:   time.Second,
+	}
+}
+
+// Options applies the per-call timeout and retry policy to the AWS calls of
+// a repository. The retryer is shared so the adaptive rate limit learns from
+// every call of the container; once throttled, attempts wait for the client
+// side rate limit, bounded by the per-call deadline.
+type Options interface {
+	// Context derives the context of a single call from the remaining time of
+	// ctx, it fails with ErrUnavailable when the budget is already spent.
+	Context(ctx context.Context, operation string) (context.Context, context.CancelFunc, error)
+	// DynamoDB is passed as option to every dynamodb call.
+	DynamoDB(o *dynamodb.Options)
+	// Err reports calls cut by the derived deadline as ErrUnavailable.
+	Err(operation string, err error) error
+}
+
+type optionsImpl struct {
+	policy  Policy
+	retryer *retry.AdaptiveMode
+}
+
+func New(policy Policy) Options {
+	retryer := retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+		o.StandardOptions = append(o.StandardOptions, func(so *retry.StandardOptions) {
+			so.MaxAttempts = policy.MaxAttempts
+			so.MaxBackoff = policy.MaxBackoff
+			so.Backoff = retry.NewExponentialJitterBackoff(policy.MaxBackoff)
+		})
+	})
+
+	return &optionsImpl{policy: policy, retryer: retryer}
+}
+
+func (opts *optionsImpl) Context(ctx context.Context, operation string) (context.Context, context.CancelFunc, error) {
+	timeout := opts.policy.CallTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline) - opts.policy.SafetyMargin
+		if remaining < opts.policy.MinCallTime {
+			return ctx, func() {}, fmt.Errorf("%w: no time left for %s", ErrUnavailable, operation)
+		}
+
+		timeout = min(timeout, remaining)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	return callCtx, cancel, nil
+}
+
+func (opts *optionsImpl) DynamoDB(o *dynamodb.Options) {
+	o.Retryer = opts.retryer
+}
+
+func (opts *optionsImpl) Err(operation string, err error) error {
+	if err != nil && errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s timed out: %w", ErrUnavailable, operation, err)
+	}
+
+	return err
+}
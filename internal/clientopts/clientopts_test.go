@@ -0,0 +1,151 @@
+package clientopts_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContext(t *testing.T) {
+	opts := clientopts.New(clientopts.DefaultPolicy())
+
+	t.Run("without deadline uses the call timeout", func(t *testing.T) {
+		ctx, cancel, err := opts.Context(context.Background(), "GetItem")
+		defer cancel()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > 3*time.Second {
+			t.Errorf("unexpected deadline: %v", deadline)
+		}
+	})
+
+	t.Run("keeps the safety margin of the invocation", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(context.Background(), time.Second)
+		defer cancelParent()
+
+		ctx, cancel, err := opts.Context(parent, "GetItem")
+		defer cancel()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		deadline, _ := ctx.Deadline()
+		parentDeadline, _ := parent.Deadline()
+		if margin := parentDeadline.Sub(deadline); margin < 200*time.Millisecond {
+			t.Errorf("margin not kept: %s", margin)
+		}
+	})
+
+	t.Run("fails fast when the budget is spent", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancelParent()
+
+		_, cancel, err := opts.Context(parent, "GetItem")
+		defer cancel()
+		if !errors.Is(err, clientopts.ErrUnavailable) {
+			t.Errorf("expected unavailable, got %v", err)
+		}
+	})
+}
+
+func TestErr(t *testing.T) {
+	opts := clientopts.New(clientopts.DefaultPolicy())
+
+	if err := opts.Err("Scan", context.DeadlineExceeded); !errors.Is(err, clientopts.ErrUnavailable) {
+		t.Errorf("deadline not translated: %v", err)
+	}
+
+	generic := errors.New("validation error")
+	if err := opts.Err("Scan", generic); err != generic {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := opts.Err("Scan", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func newClient(handler http.HandlerFunc) (*dynamodb.Client, func()) {
+	server := httptest.NewServer(handler)
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	return client, server.Close
+}
+
+func getItem(ctx context.Context, client *dynamodb.Client, opts clientopts.Options) error {
+	callCtx, cancel, err := opts.Context(ctx, "GetItem")
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	_, err = client.GetItem(callCtx, &dynamodb.GetItemInput{
+		TableName: aws.String("users"),
+		Key:       map[string]types.AttributeValue{"Id": &types.AttributeValueMemberS{Value: "1"}},
+	}, opts.DynamoDB)
+	return opts.Err("GetItem", err)
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("retries throttled calls", func(t *testing.T) {
+		var attempts atomic.Int32
+		client, closeFn := newClient(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+			if attempts.Add(1) < 2 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"slow down"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{}`))
+		})
+		defer closeFn()
+
+		policy := clientopts.DefaultPolicy()
+		policy.MaxBackoff = 10 * time.Millisecond
+
+		if err := getItem(context.Background(), client, clientopts.New(policy)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if attempts.Load() != 2 {
+			t.Errorf("unexpected attempts: %d", attempts.Load())
+		}
+	})
+
+	t.Run("returns unavailable when the call outlives the budget", func(t *testing.T) {
+		client, closeFn := newClient(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(500 * time.Millisecond):
+			}
+		})
+		defer closeFn()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := getItem(ctx, client, clientopts.New(clientopts.DefaultPolicy()))
+		if !errors.Is(err, clientopts.ErrUnavailable) {
+			t.Fatalf("expected unavailable, got %v", err)
+		}
+
+		if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+			t.Errorf("call did not keep the safety margin: %s", elapsed)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+// Package clock tells the time to the services, so the timestamps they
+// store are always UTC and tests can choose them.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	// Now returns the current time in UTC.
+	Now() time.Time
+}
+
+type systemImpl struct{}
+
+// New returns the clock of the system.
+func New() Clock {
+	return systemImpl{}
+}
+
+func (systemImpl) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// Fake is a clock for tests, it only moves with Set and Advance.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake returns a clock stopped at now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now.UTC()}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set moves the clock to now.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now.UTC()
+}
+
+// Advance moves the clock forward by d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
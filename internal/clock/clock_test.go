@@ -0,0 +1,41 @@
+package clock_test
+
+import (
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"testing"
+	"time"
+)
+
+func TestSystem(t *testing.T) {
+	now := clock.New().Now()
+	if now.Location() != time.UTC {
+		t.Errorf("expected UTC, got %v", now.Location())
+	}
+	if d := time.Since(now); d < 0 || d > time.Second {
+		t.Errorf("expected the current time, got %v", now)
+	}
+}
+
+func TestFake(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, paris)
+
+	c := clock.NewFake(start)
+	if now := c.Now(); !now.Equal(start) || now.Location() != time.UTC {
+		t.Errorf("expected %v in UTC, got %v", start, now)
+	}
+
+	c.Advance(90 * time.Second)
+	if now := c.Now(); !now.Equal(start.Add(90 * time.Second)) {
+		t.Errorf("expected the clock advanced, got %v", now)
+	}
+
+	later := start.Add(24 * time.Hour)
+	c.Set(later)
+	if now := c.Now(); !now.Equal(later) || now.Location() != time.UTC {
+		t.Errorf("expected %v in UTC, got %v", later, now)
+	}
+}
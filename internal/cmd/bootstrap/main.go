@@ -0,0 +1,121 @@
+// Command bootstrap provisions the users table once, from a deploy pipeline
+// or by hand, so the lambdas can run with DYNAMODB_PROVISIONING=off and
+// read/write-only IAM roles.
+//
+//	DYNAMODB_TABLE_NAME=users go run ./cmd/bootstrap -mode reconcile
+//
+// The rate limit table of RATE_LIMIT_BACKEND=dynamodb is created with
+// -rate-limit-table, the tombstone table of the gdpr lambda with
+// -tombstone-table, the audit trail of AUDIT_TABLE with -audit-table and
+// the verification tokens of VERIFICATION_TOKEN_TABLE, with its TTL, with
+// -verification-table.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/ricardojonathanromero/go-utilities/db/dynamodb"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	dbInfra "github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/gdpr"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ratelimit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"os"
+	"time"
+)
+
+const (
+	envTableName = "DYNAMODB_TABLE_NAME"
+	appName      = "bootstrap"
+)
+
+func main() {
+	tableName := flag.String("table", "", "table to provision, defaults to DYNAMODB_TABLE_NAME")
+	modeFlag := flag.String("mode", string(dbInfra.ModeCreate), "provisioning mode: verify, create or reconcile")
+	rateLimitTable := flag.String("rate-limit-table", "", "rate limit table to create as well, for RATE_LIMIT_BACKEND=dynamodb")
+	tombstoneTable := flag.String("tombstone-table", "", "tombstone table of the erased users to create as well, GDPR_TOMBSTONE_TABLE")
+	auditTable := flag.String("audit-table", "", "audit trail of the user mutations to create as well, AUDIT_TABLE")
+	verificationTable := flag.String("verification-table", "", "verification tokens of the new users to create as well, VERIFICATION_TOKEN_TABLE")
+	timeout := flag.Duration("timeout", 2*time.Minute, "maximum time to wait for the table")
+	flag.Parse()
+
+	// the -table flag takes precedence over the environment
+	var cfg config.Common
+	secrets, err := config.NewProvider(context.Background())
+	if err == nil {
+		err = config.LoadFrom(&cfg, func(key string) (string, bool) {
+			if key == envTableName && len(*tableName) > 0 {
+				return *tableName, true
+			}
+			return os.LookupEnv(key)
+		}, config.WithProvider(secrets))
+	}
+	if err != nil {
+		logging.New(logging.Opts{AppName: appName}).Fatal(err.Error())
+	}
+
+	customLog := logging.New(logging.Opts{
+		AppName: appName,
+		Level:   cfg.LogLevel,
+	})
+
+	mode, err := dbInfra.ParseMode(*modeFlag)
+	if err != nil {
+		customLog.Fatal(err.Error())
+	}
+
+	// connect to db
+	db := dynamodb.New()
+	conn, err := db.Connect()
+	if err != nil {
+		customLog.Fatalf("error initializing db connection: %s", err.Error())
+	}
+
+	defer func() {
+		if err = db.Disconnect(); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	// the cache is skipped, bootstrap must always look at the real table
+	err = dbInfra.New(conn, customLog, clientopts.New(cfg.DynamoDB.Policy()), dbInfra.NewNoopCache()).Provision(ctx, cfg.DynamoDB.TableName, mode)
+	if err != nil {
+		customLog.Fatalf("error provisioning table %s: %v", cfg.DynamoDB.TableName, err)
+	}
+
+	customLog.Infof("table %s provisioned in mode %s", cfg.DynamoDB.TableName, mode)
+
+	if len(*rateLimitTable) > 0 {
+		if err = ratelimit.CreateTable(ctx, conn, *rateLimitTable); err != nil {
+			customLog.Fatalf("error provisioning table %s: %v", *rateLimitTable, err)
+		}
+		customLog.Infof("rate limit table %s provisioned", *rateLimitTable)
+	}
+
+	if len(*tombstoneTable) > 0 {
+		if err = gdpr.CreateTable(ctx, conn, *tombstoneTable); err != nil {
+			customLog.Fatalf("error provisioning table %s: %v", *tombstoneTable, err)
+		}
+		customLog.Infof("tombstone table %s provisioned", *tombstoneTable)
+	}
+
+	if len(*auditTable) > 0 {
+		if err = audit.CreateTable(ctx, conn, *auditTable); err != nil {
+			customLog.Fatalf("error provisioning table %s: %v", *auditTable, err)
+		}
+		customLog.Infof("audit table %s provisioned", *auditTable)
+	}
+
+	if len(*verificationTable) > 0 {
+		if err = verification.CreateTable(ctx, conn, *verificationTable); err != nil {
+			customLog.Fatalf("error provisioning table %s: %v", *verificationTable, err)
+		}
+		customLog.Infof("verification table %s provisioned", *verificationTable)
+	}
+}
@@ -0,0 +1,54 @@
+// Command config loads the configuration of an application from the current
+// environment and prints it, secret values masked. It exits with status 1
+// listing every problem when the configuration is invalid. References are
+// resolved the same way the lambdas do, see config.Sources.
+//
+//	DYNAMODB_TABLE_NAME=users go run ./cmd/config -app create-user-lambda
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	apps := config.Apps()
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	app := flag.String("app", "", "application to load: "+strings.Join(names, ", "))
+	flag.Parse()
+
+	newConfig, ok := apps[*app]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown app %q, expected one of %s\n", *app, strings.Join(names, ", "))
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	cfg := newConfig()
+	secrets, loadErr := config.NewProvider(ctx)
+	if loadErr == nil {
+		loadErr = config.Load(cfg, config.WithContext(ctx), config.WithProvider(secrets))
+	}
+	cancel()
+
+	if err := config.Dump(os.Stdout, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if loadErr != nil {
+		fmt.Fprintln(os.Stderr, loadErr)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,158 @@
+// Command devserver runs the whole API on a laptop. Every route is served by
+// the real lambda, built from its module and started as a child process, so
+// handlers, config loading and table provisioning behave as deployed.
+//
+//	go run ./cmd/devserver -root .. -env ../.env.local
+//	curl localhost:8080/users/1234
+//
+// Data lives in an in-memory DynamoDB by default and is lost on exit, run
+// with -store dynamodb to use DynamoDB Local instead:
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	go run ./cmd/devserver -root .. -store dynamodb
+//
+// The env file is watched, saving it restarts the functions with the new
+// values and rebuilds them from the current code.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/devserver"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbfake"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const appName = "devserver"
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	root := flag.String("root", ".", "repository root, holding one directory per lambda")
+	envFile := flag.String("env", ".env.local", "env file passed to the functions, reloaded on change")
+	store := flag.String("store", "memory", "memory runs an in-memory DynamoDB, dynamodb uses -dynamodb-endpoint")
+	endpoint := flag.String("dynamodb-endpoint", "http://localhost:8000", "DynamoDB Local endpoint")
+	table := flag.String("table", "users", "table used by the functions")
+	logLevel := flag.String("log-level", "info", "log level, debug logs every payload")
+	flag.Parse()
+
+	customLog := logging.New(logging.Opts{
+		AppName: appName,
+		Level:   *logLevel,
+	})
+
+	switch *store {
+	case "memory":
+		memoryEndpoint, closeStore, err := serveMemoryStore()
+		if err != nil {
+			customLog.Errorf("error starting in-memory dynamodb: %v", err)
+			os.Exit(1)
+		}
+		defer closeStore()
+
+		customLog.Infof("in-memory dynamodb on %s", memoryEndpoint)
+		*endpoint = memoryEndpoint
+	case "dynamodb":
+	default:
+		customLog.Errorf("unknown store %q, expected memory or dynamodb", *store)
+		os.Exit(2)
+	}
+
+	// defaults first, the environment and the env file override them
+	defaults := []string{
+		"AWS_REGION=us-east-1",
+		"AWS_ACCESS_KEY_ID=local",
+		"AWS_SECRET_ACCESS_KEY=local",
+		"AWS_ENDPOINT_URL_DYNAMODB=" + *endpoint,
+		"DYNAMODB_URL=" + *endpoint,
+		"DYNAMODB_TABLE_NAME=" + *table,
+		"DYNAMODB_PROVISIONING=create",
+		// no API Gateway in front of the functions to authenticate the caller
+		"AUTH_MODE=off",
+		// erasure receipts are signed with a throwaway key
+		"GDPR_RECEIPT_KEY=devserver-receipt-key-not-a-secret",
+	}
+
+	env := func() []string {
+		values := append(append([]string{}, defaults...), os.Environ()...)
+
+		file, err := devserver.ReadEnvFile(*envFile)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				customLog.Errorf("error reading %s: %v", *envFile, err)
+			}
+			return values
+		}
+
+		for key, value := range file {
+			values = append(values, fmt.Sprintf("%s=%s", key, value))
+		}
+		return values
+	}
+
+	routes := devserver.DefaultRoutes()
+	functions := map[string]devserver.Function{}
+	for _, route := range routes {
+		if _, ok := functions[route.Function]; ok {
+			continue
+		}
+		dir := filepath.Join(*root, route.Function)
+		functions[route.Function] = devserver.NewProcessFunction(route.Function, dir, env, customLog, os.Stdout)
+	}
+
+	server := devserver.New(routes, functions, customLog)
+	defer func() {
+		if err := server.Close(); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go devserver.Watch(ctx, *envFile, time.Second, func() {
+		customLog.Infof("%s changed, reloading functions", *envFile)
+		if err := server.Reload(); err != nil {
+			customLog.Errorf("error reloading functions: %v", err)
+		}
+	})
+
+	httpServer := &http.Server{Addr: *addr, Handler: server, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	for _, route := range routes {
+		customLog.Infof("%s %s -> %s", route.Method, route.Path, route.Function)
+	}
+	customLog.Infof("listening on http://%s", *addr)
+
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		customLog.Errorf("error serving: %v", err)
+	}
+}
+
+// serveMemoryStore serves a dynamodbfake on a free local port for the
+// functions, which connect through the SDK like they would to DynamoDB Local.
+func serveMemoryStore() (string, func(), error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+
+	server := &http.Server{Handler: dynamodbfake.New(), ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = server.Serve(listener) }()
+
+	return "http://" + listener.Addr().String(), func() { _ = server.Close() }, nil
+}
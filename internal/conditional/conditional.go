@@ -0,0 +1,110 @@
+// Package conditional implements the HTTP conditional requests of RFC 9110
+// for the API Gateway handlers: validators on reads (ETag, Last-Modified)
+// answered with 304, and preconditions on writes (If-Match,
+// If-Unmodified-Since) answered with 412.
+package conditional
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderETag              = "ETag"
+	HeaderLastModified      = "Last-Modified"
+	HeaderIfMatch           = "If-Match"
+	HeaderIfNoneMatch       = "If-None-Match"
+	HeaderIfModifiedSince   = "If-Modified-Since"
+	HeaderIfUnmodifiedSince = "If-Unmodified-Since"
+)
+
+// ETag returns the strong entity tag of a representation, a hash of its
+// bytes, so equal bodies always share a tag whatever container served them.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// LastModified formats t as an HTTP date, empty for the zero time.
+func LastModified(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(http.TimeFormat)
+}
+
+// Header returns the value of name, API Gateway keeps the case sent by the
+// client.
+func Header(headers map[string]string, name string) (string, bool) {
+	if value, ok := headers[name]; ok {
+		return value, true
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// NotModified tells whether a GET can be answered with 304. If-None-Match
+// is compared weakly; If-Modified-Since is only evaluated without
+// If-None-Match, at the second precision of HTTP dates.
+func NotModified(headers map[string]string, etag string, lastModified time.Time) bool {
+	if value, ok := Header(headers, HeaderIfNoneMatch); ok {
+		return matches(value, etag, false)
+	}
+
+	if value, ok := Header(headers, HeaderIfModifiedSince); ok && !lastModified.IsZero() {
+		since, err := http.ParseTime(value)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// PreconditionFailed tells whether a write must be rejected with 412 to
+// avoid a lost update. exists is false when there is no current
+// representation, If-Match: * then fails. If-Match is compared strongly;
+// If-Unmodified-Since is only evaluated without If-Match.
+func PreconditionFailed(headers map[string]string, etag string, lastModified time.Time, exists bool) bool {
+	if value, ok := Header(headers, HeaderIfMatch); ok {
+		return !exists || !matches(value, etag, true)
+	}
+
+	if value, ok := Header(headers, HeaderIfUnmodifiedSince); ok && exists && !lastModified.IsZero() {
+		since, err := http.ParseTime(value)
+		if err != nil {
+			return false
+		}
+		return lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// matches compares etag with the list of a header, "*" matches any
+// current representation. A strong comparison never matches a weak tag.
+func matches(list, etag string, strong bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if strong {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package conditional_test
+
+import (
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/conditional"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	a, b := conditional.ETag([]byte(`{"id":"1"}`)), conditional.ETag([]byte(`{"id":"2"}`))
+	if a != conditional.ETag([]byte(`{"id":"1"}`)) {
+		t.Errorf("equal bodies must share a tag")
+	}
+	if a == b {
+		t.Errorf("different bodies must have different tags")
+	}
+	if a[0] != '"' || a[len(a)-1] != '"' {
+		t.Errorf("tag must be a quoted strong tag: %s", a)
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	modified := time.Date(2024, 4, 1, 10, 30, 15, 500, time.FixedZone("PDT", -7*3600))
+	if value := conditional.LastModified(modified); value != "Mon, 01 Apr 2024 17:30:15 GMT" {
+		t.Errorf("unexpected date: %s", value)
+	}
+	if value := conditional.LastModified(time.Time{}); value != "" {
+		t.Errorf("zero time must not be formatted: %s", value)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := `"abc"`
+	modified := time.Date(2024, 4, 1, 10, 30, 15, 500, time.UTC)
+	before := modified.Add(-time.Hour).Format(http.TimeFormat)
+	same := modified.Format(http.TimeFormat)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected bool
+	}{
+		{"no validator", nil, false},
+		{"matching etag", map[string]string{"If-None-Match": `"abc"`}, true},
+		{"lower case header", map[string]string{"if-none-match": `"abc"`}, true},
+		{"weak match", map[string]string{"If-None-Match": `W/"abc"`}, true},
+		{"etag in list", map[string]string{"If-None-Match": `"x", "abc"`}, true},
+		{"any", map[string]string{"If-None-Match": "*"}, true},
+		{"other etag", map[string]string{"If-None-Match": `"x"`}, false},
+		{"not modified since", map[string]string{"If-Modified-Since": same}, true},
+		{"modified since", map[string]string{"If-Modified-Since": before}, false},
+		{"invalid date", map[string]string{"If-Modified-Since": "yesterday"}, false},
+		{"etag wins over date", map[string]string{"If-None-Match": `"x"`, "If-Modified-Since": same}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := conditional.NotModified(tc.headers, etag, modified); actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPreconditionFailed(t *testing.T) {
+	etag := `"abc"`
+	modified := time.Date(2024, 4, 1, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		exists   bool
+		expected bool
+	}{
+		{"no precondition", nil, true, false},
+		{"matching etag", map[string]string{"If-Match": `"abc"`}, true, false},
+		{"lost update", map[string]string{"If-Match": `"old"`}, true, true},
+		{"weak etag never matches", map[string]string{"If-Match": `W/"abc"`}, true, true},
+		{"any with a representation", map[string]string{"If-Match": "*"}, true, false},
+		{"any without representation", map[string]string{"If-Match": "*"}, false, true},
+		{"unmodified", map[string]string{"If-Unmodified-Since": modified.Format(http.TimeFormat)}, true, false},
+		{"modified", map[string]string{"If-Unmodified-Since": modified.Add(-time.Minute).Format(http.TimeFormat)}, true, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := conditional.PreconditionFailed(tc.headers, etag, modified, tc.exists); actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
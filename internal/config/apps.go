@@ -0,0 +1,291 @@
+package config
+
+import (
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"time"
+)
+
+// Common is embedded by the configuration of every lambda.
+type Common struct {
+	LogLevel         string `env:"LOG_LEVEL" default:"info" enum:"debug,info,warn,error"`
+	MetricsNamespace string `env:"METRICS_NAMESPACE" default:"lambda-golang-example"`
+	TracesExporter   string `env:"OTEL_TRACES_EXPORTER" default:"none" enum:"none,otlp"`
+	DynamoDB         DynamoDB
+	Storage          Storage
+	Encryption       Encryption
+	Audit            Audit
+}
+
+type DynamoDB struct {
+	TableName    string        `env:"DYNAMODB_TABLE_NAME" required:"true"`
+	Provisioning db.Mode       `env:"DYNAMODB_PROVISIONING" default:"create" enum:"off,verify,create,reconcile"`
+	CallTimeout  time.Duration `env:"DYNAMODB_CALL_TIMEOUT" default:"3s"`
+	MaxAttempts  int           `env:"DYNAMODB_MAX_ATTEMPTS" default:"5"`
+	MaxBackoff   time.Duration `env:"DYNAMODB_MAX_BACKOFF" default:"1s"`
+}
+
+// Policy returns the default client policy with the configured overrides.
+func (d DynamoDB) Policy() clientopts.Policy {
+	policy := clientopts.DefaultPolicy()
+	policy.CallTimeout = d.CallTimeout
+	policy.MaxAttempts = d.MaxAttempts
+	policy.MaxBackoff = d.MaxBackoff
+	return policy
+}
+
+// Storage selects where the users are kept, the DynamoDB table by default
+// or a database/sql database. The verification tokens stay on DynamoDB
+// with either, see Verification.
+type Storage struct {
+	Backend string `env:"USER_STORE" default:"dynamodb" enum:"dynamodb,sql"`
+	SQL     SQL
+}
+
+const (
+	BackendDynamoDB = "dynamodb"
+	BackendSQL      = "sql"
+)
+
+type SQL struct {
+	// Driver is the database/sql driver name, pgx for PostgreSQL.
+	Driver string `env:"SQL_DRIVER" default:"pgx"`
+	DSN    Secret `env:"SQL_DSN"`
+	Table  string `env:"SQL_TABLE" default:"users"`
+	// Migrate creates the table at cold start, like DYNAMODB_PROVISIONING=create.
+	Migrate bool `env:"SQL_MIGRATE" default:"false"`
+}
+
+// Audit is the trail of the user mutations, written in the transaction of
+// every write of the users and kept in the database of USER_STORE.
+type Audit struct {
+	Enabled bool   `env:"AUDIT_ENABLED" default:"true"`
+	Table   string `env:"AUDIT_TABLE" default:"user-audit"`
+}
+
+// TableName returns the table of the trail, empty when it is disabled.
+func (a Audit) TableName() string {
+	if !a.Enabled {
+		return ""
+	}
+	return a.Table
+}
+
+// Encryption selects the key provider of the attributes tagged with
+// encrypt, they are stored in plaintext when off.
+type Encryption struct {
+	Provider string `env:"FIELD_ENCRYPTION" default:"off" enum:"off,local,kms"`
+	// LocalKeys are comma separated id=base64 AES-256 keys, the first one
+	// encrypts new values.
+	LocalKeys Secret `env:"FIELD_ENCRYPTION_LOCAL_KEYS"`
+	// KMSKeys are comma separated KMS key ids or ARNs, the first one
+	// encrypts new values.
+	KMSKeys string `env:"FIELD_ENCRYPTION_KMS_KEYS"`
+	// DeterministicKeys are comma separated keyID=base64 data keys wrapped
+	// by each KMS key, for the deterministic attributes.
+	DeterministicKeys string        `env:"FIELD_ENCRYPTION_DETERMINISTIC_KEYS"`
+	DataKeyTTL        time.Duration `env:"FIELD_ENCRYPTION_DATA_KEY_TTL" default:"5m"`
+}
+
+const (
+	EncryptionOff   = "off"
+	EncryptionLocal = "local"
+	EncryptionKMS   = "kms"
+)
+
+// Auth selects how the callers of the API are authenticated.
+type Auth struct {
+	// Mode authorizer trusts the claims of the API Gateway authorizer, jwt
+	// validates the bearer token against JWKSFile, Issuer and Audience,
+	// which are required then.
+	Mode       string `env:"AUTH_MODE" default:"authorizer" enum:"off,authorizer,jwt"`
+	JWKSFile   string `env:"AUTH_JWKS_FILE" default:"jwks.json"`
+	Issuer     string `env:"AUTH_ISSUER"`
+	Audience   string `env:"AUTH_AUDIENCE"`
+	AdminGroup string `env:"AUTH_ADMIN_GROUP" default:"admin"`
+}
+
+const (
+	AuthOff        = "off"
+	AuthAuthorizer = "authorizer"
+	AuthJWT        = "jwt"
+)
+
+// RateLimit throttles the callers of the API per route, the buckets are
+// kept in the container or in a DynamoDB table shared by every container.
+type RateLimit struct {
+	Backend string `env:"RATE_LIMIT_BACKEND" default:"memory" enum:"off,memory,dynamodb"`
+	Table   string `env:"RATE_LIMIT_TABLE" default:"rate-limits"`
+	// Default is the limit of the routes missing from Routes, as
+	// requests/period[:burst].
+	Default string `env:"RATE_LIMIT_DEFAULT" default:"50/1s:100"`
+	// Routes are comma separated METHOD /resource=limit, off disables a route.
+	// The verification emails are limited per caller on top of the resend
+	// interval per user.
+	Routes string `env:"RATE_LIMIT_ROUTES" default:"GET /users=5/1s:10,POST /users/{id}/verify/resend=5/1h:5"`
+}
+
+const (
+	RateLimitOff      = "off"
+	RateLimitMemory   = "memory"
+	RateLimitDynamoDB = "dynamodb"
+)
+
+type CreateUser struct {
+	Common
+	Auth         Auth
+	RateLimit    RateLimit
+	IDs          IDs
+	Verification Verification
+}
+
+// IDs selects how the ids of the new users are generated, both formats
+// sort by creation time.
+type IDs struct {
+	Format string `env:"ID_FORMAT" default:"uuidv7" enum:"uuidv7,ulid"`
+	// Prefix such as usr_ starts every new id, they are bare when empty.
+	Prefix string `env:"ID_PREFIX"`
+}
+
+type GetAllDocuments struct {
+	Common
+	Auth      Auth
+	RateLimit RateLimit
+	// Timezone renders the times of the responses when the request asks
+	// for no zone, they are stored in UTC.
+	Timezone *time.Location `env:"DEFAULT_TIMEZONE" default:"UTC"`
+}
+
+type GetDocument struct {
+	Common
+	Auth      Auth
+	RateLimit RateLimit
+	Cache     Cache
+	// Timezone renders the times of the responses when the request asks
+	// for no zone, they are stored in UTC.
+	Timezone *time.Location `env:"DEFAULT_TIMEZONE" default:"UTC"`
+}
+
+// Cache configures the read-through cache of a repository, off by default.
+type Cache struct {
+	Backend     string        `env:"CACHE_BACKEND" default:"none" enum:"none,memory,redis"`
+	Size        int           `env:"CACHE_SIZE" default:"10000"`
+	TTL         time.Duration `env:"CACHE_TTL" default:"30s"`
+	NegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL" default:"5s"`
+	// Version is bumped to drop the entries written by older deployments.
+	Version       string `env:"CACHE_VERSION" default:"1"`
+	RedisAddr     string `env:"CACHE_REDIS_ADDR" default:"localhost:6379"`
+	RedisPassword Secret `env:"CACHE_REDIS_PASSWORD"`
+	RedisTLS      bool   `env:"CACHE_REDIS_TLS" default:"false"`
+}
+
+const (
+	CacheNone   = "none"
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+)
+
+type ExportUsers struct {
+	Common
+	Bucket    string `env:"EXPORT_BUCKET" required:"true"`
+	KeyPrefix string `env:"EXPORT_KEY_PREFIX" default:"exports/users"`
+}
+
+// GDPR configures the data subject export and erasure requests.
+type GDPR struct {
+	Common
+	Auth      Auth
+	RateLimit RateLimit
+	// Cache is the cache of get-document, the erased users are dropped from
+	// it when it is shared, CACHE_BACKEND=redis.
+	Cache Cache
+	// VerificationTable is the VERIFICATION_TOKEN_TABLE of create-user and
+	// user-status, the tokens of the erased users are deleted.
+	VerificationTable string `env:"VERIFICATION_TOKEN_TABLE" default:"user-verification-tokens"`
+	// TombstoneTable keeps a tombstone and the receipt of every erased user.
+	TombstoneTable string `env:"GDPR_TOMBSTONE_TABLE" default:"user-tombstones"`
+	// ReceiptKey signs the erasure receipts with HMAC-SHA256, at least 32
+	// bytes.
+	ReceiptKey Secret `env:"GDPR_RECEIPT_KEY" required:"true"`
+}
+
+// UserStatus configures the suspension, reactivation and closure of the
+// accounts.
+type UserStatus struct {
+	Common
+	Auth         Auth
+	RateLimit    RateLimit
+	Events       Events
+	Verification Verification
+	// Cache is the cache of get-document, the changed users are dropped
+	// from it when it is shared, CACHE_BACKEND=redis.
+	Cache Cache
+	// Timezone is the DEFAULT_TIMEZONE of get-document, the If-Match of the
+	// transitions is compared with the ETags it returned.
+	Timezone *time.Location `env:"DEFAULT_TIMEZONE" default:"UTC"`
+}
+
+// Verification configures the tokens mailed to the new users to verify
+// their email. The tokens are kept on DynamoDB whatever USER_STORE, so
+// create-user and user-status need the DynamoDB settings of Common and
+// access to TokenTable with USER_STORE=sql as well.
+type Verification struct {
+	// TokenTable keeps the hash of the live token of every user, expired
+	// ones are removed by the TTL of the table on ExpiresAt.
+	TokenTable string        `env:"VERIFICATION_TOKEN_TABLE" default:"user-verification-tokens"`
+	TokenTTL   time.Duration `env:"VERIFICATION_TOKEN_TTL" default:"24h"`
+	// ResendInterval is the least time between two emails to a user.
+	ResendInterval time.Duration `env:"VERIFICATION_RESEND_INTERVAL" default:"1m"`
+	// LinkURL is the page that posts the token of its query to
+	// /users/verify.
+	LinkURL string `env:"VERIFICATION_LINK_URL" default:"http://localhost:3000/verify"`
+	Mailer  Mailer
+}
+
+// Mailer selects how the emails are sent, the log backend writes them with
+// the other log lines.
+type Mailer struct {
+	Backend      string `env:"MAILER_BACKEND" default:"log" enum:"off,log,smtp"`
+	From         string `env:"MAILER_FROM" default:"no-reply@example.com"`
+	SMTPAddr     string `env:"MAILER_SMTP_ADDR" default:"localhost:587"`
+	SMTPUsername string `env:"MAILER_SMTP_USERNAME"`
+	SMTPPassword Secret `env:"MAILER_SMTP_PASSWORD"`
+	// SMTPTLS requires STARTTLS, only a relay on the loopback interface
+	// should go without.
+	SMTPTLS bool `env:"MAILER_SMTP_TLS" default:"true"`
+}
+
+const (
+	MailerOff  = "off"
+	MailerLog  = "log"
+	MailerSMTP = "smtp"
+)
+
+// Events selects where the events of the users are published, the log
+// backend writes them with the other log lines.
+type Events struct {
+	Backend  string `env:"EVENTS_BACKEND" default:"log" enum:"off,log,sqs"`
+	QueueURL string `env:"EVENTS_QUEUE_URL"`
+}
+
+const (
+	EventsOff = "off"
+	EventsLog = "log"
+	EventsSQS = "sqs"
+)
+
+// Apps maps the application names to an empty configuration, used by the
+// dump command.
+func Apps() map[string]func() any {
+	return map[string]func() any{
+		"create-user-lambda":       func() any { return &CreateUser{} },
+		"get-all-documents-lambda": func() any { return &GetAllDocuments{} },
+		"get-document-lambda":      func() any { return &GetDocument{} },
+		"export-users-lambda":      func() any { return &ExportUsers{} },
+		"export-users":             func() any { return &Common{} },
+		"gdpr-lambda":              func() any { return &GDPR{} },
+		"gdpr":                     func() any { return &GDPR{} },
+		"user-status-lambda":       func() any { return &UserStatus{} },
+		"bootstrap":                func() any { return &Common{} },
+	}
+}
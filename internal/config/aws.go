@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smTypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// SSMAPI is the part of the ssm client used to resolve references.
+type SSMAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// SecretsManagerAPI is the part of the secretsmanager client used to resolve
+// references.
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+type awsProvider struct {
+	ssm     SSMAPI
+	secrets SecretsManagerAPI
+}
+
+func NewAWSProvider(ssmClient SSMAPI, secretsClient SecretsManagerAPI) Provider {
+	return &awsProvider{ssm: ssmClient, secrets: secretsClient}
+}
+
+func (p *awsProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	scheme, name, key := splitReference(ref)
+	switch scheme {
+	case SchemeSSM:
+		return p.parameter(ctx, ref, name)
+	case SchemeSecretsManager:
+		return p.secret(ctx, ref, name, key)
+	default:
+		return "", fmt.Errorf("unsupported reference %q", ref)
+	}
+}
+
+func (p *awsProvider) parameter(ctx context.Context, ref, name string) (string, error) {
+	out, err := p.ssm.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		var nfErr *ssmTypes.ParameterNotFound
+		if errors.As(err, &nfErr) {
+			return "", fmt.Errorf("%w: %s", ErrReferenceNotFound, ref)
+		}
+		return "", fmt.Errorf("error resolving %s: %w", ref, err)
+	}
+
+	return aws.ToString(out.Parameter.Value), nil
+}
+
+func (p *awsProvider) secret(ctx context.Context, ref, name, key string) (string, error) {
+	out, err := p.secrets.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(name)})
+	if err != nil {
+		var nfErr *smTypes.ResourceNotFoundException
+		if errors.As(err, &nfErr) {
+			return "", fmt.Errorf("%w: %s", ErrReferenceNotFound, ref)
+		}
+		return "", fmt.Errorf("error resolving %s: %w", ref, err)
+	}
+
+	value := aws.ToString(out.SecretString)
+	if len(key) == 0 {
+		return value, nil
+	}
+
+	return jsonField(ref, value, key)
+}
+
+// jsonField picks key out of a secret stored as a JSON object, the format
+// used by the console for key/value secrets.
+func jsonField(ref, value, key string) (string, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", ref, err)
+	}
+
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrReferenceNotFound, ref)
+	}
+
+	if s, ok := field.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(field), nil
+}
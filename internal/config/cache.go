@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	value     string
+	fetchedAt time.Time
+}
+
+type cachingProvider struct {
+	next    Provider
+	refresh time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewCachingProvider keeps every resolved reference for the life of the
+// container and fetches it again once refresh has passed, so rotated secrets
+// are picked up without a cold start. A refresh of zero never fetches again.
+// When a refresh fails the previous value is served and the next call tries
+// again.
+func NewCachingProvider(next Provider, refresh time.Duration) Provider {
+	return &cachingProvider{next: next, refresh: refresh, entries: map[string]cacheEntry{}}
+}
+
+func (p *cachingProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	p.mu.Lock()
+	entry, ok := p.entries[ref]
+	p.mu.Unlock()
+
+	if ok && (p.refresh <= 0 || time.Since(entry.fetchedAt) < p.refresh) {
+		return entry.value, nil
+	}
+
+	value, err := p.next.Resolve(ctx, ref)
+	if err != nil {
+		if ok {
+			return entry.value, nil
+		}
+		return "", err
+	}
+
+	p.mu.Lock()
+	p.entries[ref] = cacheEntry{value: value, fetchedAt: time.Now()}
+	p.mu.Unlock()
+
+	return value, nil
+}
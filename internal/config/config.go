@@ -0,0 +1,214 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tags understood by Load:
+//
+//	env       name of the variable
+//	default   value used when the variable is unset or empty
+//	required  "true" reports a missing variable without default
+//	enum      comma separated list of accepted values
+//	secret    "true" masks the value in Dump
+//
+// Fields can be strings, bools, ints, floats, time.Duration, *time.Location
+// or Secret. Struct fields without env tag are loaded recursively. Values
+// holding a ssm:// or secretsmanager:// reference are resolved through the
+// provider given with WithProvider.
+const (
+	tagEnv      = "env"
+	tagDefault  = "default"
+	tagRequired = "required"
+	tagEnum     = "enum"
+	tagSecret   = "secret"
+)
+
+// LookupFunc has the signature of os.LookupEnv.
+type LookupFunc func(key string) (string, bool)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	locationType = reflect.TypeOf((*time.Location)(nil))
+)
+
+type loader struct {
+	ctx      context.Context
+	lookup   LookupFunc
+	provider Provider
+}
+
+type Option func(l *loader)
+
+// WithContext bounds the calls made to resolve references.
+func WithContext(ctx context.Context) Option {
+	return func(l *loader) {
+		l.ctx = ctx
+	}
+}
+
+// WithProvider resolves ssm:// and secretsmanager:// references, without it
+// a reference is reported as a problem.
+func WithProvider(provider Provider) Option {
+	return func(l *loader) {
+		l.provider = provider
+	}
+}
+
+// Load fills dst, a pointer to struct, from the environment. Every problem
+// found is reported at once so a broken deploy is fixed in one go.
+func Load(dst any, opts ...Option) error {
+	return LoadFrom(dst, os.LookupEnv, opts...)
+}
+
+func LoadFrom(dst any, lookup LookupFunc, opts ...Option) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: expected pointer to struct, got %T", dst)
+	}
+
+	l := &loader{ctx: context.Background(), lookup: lookup}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	var problems []error
+	walk(v.Elem(), func(field reflect.StructField, value reflect.Value) {
+		if err := l.load(field, value); err != nil {
+			problems = append(problems, err)
+		}
+	})
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
+	}
+	return nil
+}
+
+// walk calls fn for every field tagged with env, in declaration order.
+func walk(v reflect.Value, fn func(field reflect.StructField, value reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if _, ok := field.Tag.Lookup(tagEnv); ok {
+			fn(field, v.Field(i))
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			walk(v.Field(i), fn)
+		}
+	}
+}
+
+func (l *loader) load(field reflect.StructField, value reflect.Value) error {
+	name := field.Tag.Get(tagEnv)
+
+	raw, _ := l.lookup(name)
+	raw = strings.TrimSpace(raw)
+	if len(raw) == 0 {
+		raw = field.Tag.Get(tagDefault)
+	}
+
+	if len(raw) == 0 {
+		if field.Tag.Get(tagRequired) == "true" {
+			return fmt.Errorf("%s is required", name)
+		}
+		return nil
+	}
+
+	ref := raw
+	if IsReference(ref) {
+		if l.provider == nil {
+			return fmt.Errorf("%s: no provider configured to resolve %s", name, ref)
+		}
+
+		resolved, err := l.provider.Resolve(l.ctx, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		raw = resolved
+	}
+
+	if value.Type() == secretType {
+		value.Set(reflect.ValueOf(Secret{ref: ref, value: raw, provider: l.provider}))
+		return nil
+	}
+
+	if enum, ok := field.Tag.Lookup(tagEnum); ok {
+		raw = strings.ToLower(raw)
+		if !contains(strings.Split(enum, ","), raw) {
+			return fmt.Errorf("%s must be one of %s, got %q", name, enum, raw)
+		}
+	}
+
+	if err := set(value, raw); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func set(value reflect.Value, raw string) error {
+	switch value.Type() {
+	case durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		value.SetInt(int64(d))
+		return nil
+	case locationType:
+		loc, err := time.LoadLocation(raw)
+		if err != nil {
+			return fmt.Errorf("unknown time zone %q", raw)
+		}
+		value.Set(reflect.ValueOf(loc))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", raw)
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		value.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.TrimSpace(v) == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package config_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+func lookup(env map[string]string) config.LookupFunc {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	var cfg config.CreateUser
+	if err := config.LoadFrom(&cfg, lookup(map[string]string{"DYNAMODB_TABLE_NAME": "users"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.LogLevel != "info" || cfg.TracesExporter != "none" || cfg.MetricsNamespace != "lambda-golang-example" {
+		t.Errorf("unexpected common defaults: %+v", cfg.Common)
+	}
+
+	if cfg.DynamoDB.TableName != "users" || cfg.DynamoDB.Provisioning != db.ModeCreate {
+		t.Errorf("unexpected dynamodb config: %+v", cfg.DynamoDB)
+	}
+
+	policy := cfg.DynamoDB.Policy()
+	if policy.CallTimeout != 3*time.Second || policy.MaxAttempts != 5 || policy.MaxBackoff != time.Second {
+		t.Errorf("unexpected policy: %+v", policy)
+	}
+
+	var reader config.GetDocument
+	if err := config.LoadFrom(&reader, lookup(map[string]string{"DYNAMODB_TABLE_NAME": "users"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reader.Timezone != time.UTC {
+		t.Errorf("unexpected timezone: %v", reader.Timezone)
+	}
+}
+
+func TestLoadValues(t *testing.T) {
+	var cfg config.ExportUsers
+	err := config.LoadFrom(&cfg, lookup(map[string]string{
+		"LOG_LEVEL":             "DEBUG",
+		"DYNAMODB_TABLE_NAME":   "users",
+		"DYNAMODB_PROVISIONING": "off",
+		"DYNAMODB_CALL_TIMEOUT": "500ms",
+		"DYNAMODB_MAX_ATTEMPTS": "2",
+		"EXPORT_BUCKET":         "exports",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.LogLevel != "debug" || cfg.DynamoDB.Provisioning != db.ModeOff {
+		t.Errorf("enum values not normalized: %+v", cfg.Common)
+	}
+
+	if cfg.DynamoDB.CallTimeout != 500*time.Millisecond || cfg.DynamoDB.MaxAttempts != 2 {
+		t.Errorf("unexpected dynamodb config: %+v", cfg.DynamoDB)
+	}
+
+	if cfg.Bucket != "exports" || cfg.KeyPrefix != "exports/users" {
+		t.Errorf("unexpected export config: %+v", cfg)
+	}
+}
+
+func TestLoadVerificationWithSQLStorage(t *testing.T) {
+	// the tokens stay on DynamoDB when the users are kept in sql
+	var cfg config.UserStatus
+	err := config.LoadFrom(&cfg, lookup(map[string]string{
+		"DYNAMODB_TABLE_NAME": "users",
+		"USER_STORE":          config.BackendSQL,
+		"SQL_DSN":             "postgres://localhost/users",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Storage.Backend != config.BackendSQL || cfg.Verification.TokenTable != "user-verification-tokens" {
+		t.Errorf("unexpected verification config: %+v, %+v", cfg.Storage, cfg.Verification)
+	}
+	if cfg.DynamoDB.Provisioning != db.ModeCreate {
+		t.Errorf("unexpected dynamodb config: %+v", cfg.DynamoDB)
+	}
+
+	if err = config.LoadFrom(&cfg, lookup(map[string]string{"USER_STORE": config.BackendSQL})); err == nil || !strings.Contains(err.Error(), "DYNAMODB_TABLE_NAME") {
+		t.Errorf("expected DYNAMODB_TABLE_NAME to stay required, got %v", err)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	var cfg config.GetDocument
+	err := config.LoadFrom(&cfg, lookup(map[string]string{
+		"LOG_LEVEL":             "verbose",
+		"DYNAMODB_CALL_TIMEOUT": "soon",
+		"DYNAMODB_MAX_ATTEMPTS": "many",
+		"DEFAULT_TIMEZONE":      "NotValid",
+	}))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	for _, expected := range []string{
+		"LOG_LEVEL must be one of debug,info,warn,error",
+		"DYNAMODB_TABLE_NAME is required",
+		`DYNAMODB_CALL_TIMEOUT: invalid duration "soon"`,
+		`DYNAMODB_MAX_ATTEMPTS: invalid integer "many"`,
+		`DEFAULT_TIMEZONE: unknown time zone "NotValid"`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("missing %q in:\n%v", expected, err)
+		}
+	}
+
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) || len(joined.Unwrap()) != 5 {
+		t.Errorf("expected 5 problems, got %v", err)
+	}
+}
+
+func TestLoadRejectsNonPointer(t *testing.T) {
+	if err := config.LoadFrom(config.Common{}, lookup(nil)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestDump(t *testing.T) {
+	cfg := struct {
+		config.Common
+		APIKey  string        `env:"API_KEY" secret:"true"`
+		Timeout time.Duration `env:"TIMEOUT" default:"2s"`
+	}{}
+
+	err := config.LoadFrom(&cfg, lookup(map[string]string{
+		"DYNAMODB_TABLE_NAME": "users",
+		"API_KEY":             "super-secret",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err = config.Dump(&out, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dump := out.String()
+	if strings.Contains(dump, "super-secret") {
+		t.Errorf("secret leaked:\n%s", dump)
+	}
+
+	for _, expected := range []string{"LOG_LEVEL=info\n", "DYNAMODB_TABLE_NAME=users\n", "API_KEY=******\n", "TIMEOUT=2s\n"} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("missing %q in:\n%s", expected, dump)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+const masked = "******"
+
+// Dump writes cfg as ENV=value lines in declaration order, secret values are
+// masked so the output can be pasted in tickets.
+func Dump(w io.Writer, cfg any) error {
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("config: expected struct, got %T", cfg)
+	}
+
+	var err error
+	walk(v, func(field reflect.StructField, value reflect.Value) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, "%s=%s\n", field.Tag.Get(tagEnv), format(field, value))
+	})
+	return err
+}
+
+func format(field reflect.StructField, value reflect.Value) string {
+	if value.IsZero() {
+		return ""
+	}
+
+	if field.Tag.Get(tagSecret) == "true" || value.Type() == secretType {
+		return masked
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case *time.Location:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
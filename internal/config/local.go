@@ -0,0 +1,64 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+type memoryProvider struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// NewMemoryProvider resolves references from values, keyed by the full
+// reference, e.g. "ssm:///prod/users/table".
+func NewMemoryProvider(values map[string]string) Provider {
+	copied := make(map[string]string, len(values))
+	for ref, value := range values {
+		copied[ref] = value
+	}
+	return &memoryProvider{values: copied}
+}
+
+func (p *memoryProvider) Resolve(_ context.Context, ref string) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	value, ok := p.values[ref]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrReferenceNotFound, ref)
+	}
+	return value, nil
+}
+
+type fileProvider struct {
+	path string
+}
+
+// NewFileProvider resolves references from a JSON object stored in path,
+// keyed like NewMemoryProvider. The file is read on every call so local edits
+// are picked up once the cache refreshes.
+func NewFileProvider(path string) Provider {
+	return &fileProvider{path: path}
+}
+
+func (p *fileProvider) Resolve(_ context.Context, ref string) (string, error) {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", p.path, err)
+	}
+
+	var values map[string]string
+	if err = json.Unmarshal(content, &values); err != nil {
+		return "", fmt.Errorf("error decoding %s: %w", p.path, err)
+	}
+
+	value, ok := values[ref]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrReferenceNotFound, ref)
+	}
+	return value, nil
+}
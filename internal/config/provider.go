@@ -0,0 +1,45 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Values starting with one of these schemes are resolved through a Provider
+// instead of being used as they are:
+//
+//	ssm:///prod/users/table             parameter, decrypted when SecureString
+//	secretsmanager://prod/cursor-key    secret string
+//	secretsmanager://prod/webhook#key   field of a JSON secret
+const (
+	SchemeSSM            = "ssm://"
+	SchemeSecretsManager = "secretsmanager://"
+)
+
+var ErrReferenceNotFound = errors.New("reference not found")
+
+// Provider resolves ssm:// and secretsmanager:// references.
+type Provider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, SchemeSSM) || strings.HasPrefix(value, SchemeSecretsManager)
+}
+
+// splitReference returns the scheme, the parameter or secret name and the
+// JSON key after #, if any.
+func splitReference(ref string) (scheme, name, key string) {
+	for _, s := range []string{SchemeSSM, SchemeSecretsManager} {
+		if strings.HasPrefix(ref, s) {
+			scheme, name = s, strings.TrimPrefix(ref, s)
+			break
+		}
+	}
+
+	if scheme == SchemeSecretsManager {
+		name, key, _ = strings.Cut(name, "#")
+	}
+	return scheme, name, key
+}
@@ -0,0 +1,230 @@
+package config_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProvider counts the calls that reach the wrapped provider.
+type countingProvider struct {
+	next  config.Provider
+	calls atomic.Int32
+	fail  atomic.Bool
+}
+
+func (p *countingProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	p.calls.Add(1)
+	if p.fail.Load() {
+		return "", errors.New("throttled")
+	}
+	return p.next.Resolve(ctx, ref)
+}
+
+func TestLoadResolvesReferences(t *testing.T) {
+	provider := config.NewMemoryProvider(map[string]string{
+		"ssm:///prod/users/table":          "users-prod",
+		"secretsmanager://prod/cursor-key": "k1",
+	})
+
+	cfg := struct {
+		config.Common
+		CursorKey config.Secret `env:"CURSOR_SIGNING_KEY" required:"true"`
+	}{}
+
+	err := config.LoadFrom(&cfg, lookup(map[string]string{
+		"DYNAMODB_TABLE_NAME": "ssm:///prod/users/table",
+		"CURSOR_SIGNING_KEY":  "secretsmanager://prod/cursor-key",
+	}), config.WithProvider(provider))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.DynamoDB.TableName != "users-prod" {
+		t.Errorf("table name not resolved: %q", cfg.DynamoDB.TableName)
+	}
+
+	key, err := cfg.CursorKey.Value(context.Background())
+	if err != nil || key != "k1" {
+		t.Errorf("unexpected secret: %q %v", key, err)
+	}
+
+	if cfg.CursorKey.String() == "k1" {
+		t.Error("secret printed in clear")
+	}
+}
+
+func TestLoadReportsUnresolvedReferences(t *testing.T) {
+	var cfg config.Common
+	env := lookup(map[string]string{"DYNAMODB_TABLE_NAME": "ssm:///prod/users/table"})
+
+	err := config.LoadFrom(&cfg, env)
+	if err == nil || !strings.Contains(err.Error(), "no provider configured") {
+		t.Errorf("expected missing provider, got %v", err)
+	}
+
+	err = config.LoadFrom(&cfg, env, config.WithProvider(config.NewMemoryProvider(nil)))
+	if !errors.Is(err, config.ErrReferenceNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	write := func(values map[string]string) {
+		content, _ := json.Marshal(values)
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	provider := config.NewFileProvider(path)
+	ctx := context.Background()
+
+	write(map[string]string{"ssm://table": "users"})
+	if value, err := provider.Resolve(ctx, "ssm://table"); err != nil || value != "users" {
+		t.Errorf("unexpected value: %q %v", value, err)
+	}
+
+	write(map[string]string{"ssm://table": "users-v2"})
+	if value, _ := provider.Resolve(ctx, "ssm://table"); value != "users-v2" {
+		t.Errorf("file change not picked up: %q", value)
+	}
+
+	if _, err := provider.Resolve(ctx, "ssm://missing"); !errors.Is(err, config.ErrReferenceNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestCachingProvider(t *testing.T) {
+	ctx := context.Background()
+	ref := "secretsmanager://prod/cursor-key"
+
+	t.Run("caches until refresh", func(t *testing.T) {
+		counting := &countingProvider{next: config.NewMemoryProvider(map[string]string{ref: "k1"})}
+		provider := config.NewCachingProvider(counting, 20*time.Millisecond)
+
+		for i := 0; i < 3; i++ {
+			if value, err := provider.Resolve(ctx, ref); err != nil || value != "k1" {
+				t.Fatalf("unexpected value: %q %v", value, err)
+			}
+		}
+
+		if counting.calls.Load() != 1 {
+			t.Errorf("expected 1 call, got %d", counting.calls.Load())
+		}
+
+		time.Sleep(30 * time.Millisecond)
+		_, _ = provider.Resolve(ctx, ref)
+		if counting.calls.Load() != 2 {
+			t.Errorf("expected refresh, got %d calls", counting.calls.Load())
+		}
+	})
+
+	t.Run("serves the previous value when refresh fails", func(t *testing.T) {
+		counting := &countingProvider{next: config.NewMemoryProvider(map[string]string{ref: "k1"})}
+		provider := config.NewCachingProvider(counting, time.Millisecond)
+
+		_, _ = provider.Resolve(ctx, ref)
+		counting.fail.Store(true)
+		time.Sleep(5 * time.Millisecond)
+
+		if value, err := provider.Resolve(ctx, ref); err != nil || value != "k1" {
+			t.Errorf("unexpected value: %q %v", value, err)
+		}
+
+		if _, err := provider.Resolve(ctx, "ssm://other"); err == nil {
+			t.Error("expected error for a reference never resolved")
+		}
+	})
+
+	t.Run("secret follows rotation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		_ = os.WriteFile(path, []byte(`{"`+ref+`": "k1"}`), 0o600)
+		provider := config.NewCachingProvider(config.NewFileProvider(path), time.Millisecond)
+
+		cfg := struct {
+			Key config.Secret `env:"KEY"`
+		}{}
+		if err := config.LoadFrom(&cfg, lookup(map[string]string{"KEY": ref}), config.WithProvider(provider)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_ = os.WriteFile(path, []byte(`{"`+ref+`": "k2"}`), 0o600)
+		time.Sleep(5 * time.Millisecond)
+
+		if value, _ := cfg.Key.Value(ctx); value != "k2" {
+			t.Errorf("rotation not picked up: %q", value)
+		}
+	})
+}
+
+// fakeAWS answers GetParameter and GetSecretValue.
+func fakeAWS(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&input)
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		var body any
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.GetParameter":
+			if input["Name"] != "/prod/users/table" || input["WithDecryption"] != true {
+				w.WriteHeader(http.StatusBadRequest)
+				body = map[string]string{"__type": "ParameterNotFound"}
+				break
+			}
+			body = map[string]any{"Parameter": map[string]string{"Name": "/prod/users/table", "Value": "users-prod"}}
+		case "secretsmanager.GetSecretValue":
+			if input["SecretId"] != "prod/webhook" {
+				w.WriteHeader(http.StatusBadRequest)
+				body = map[string]string{"__type": "ResourceNotFoundException"}
+				break
+			}
+			body = map[string]string{"SecretString": `{"key":"whsec","version":2}`}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestAWSProvider(t *testing.T) {
+	url := fakeAWS(t)
+	ssmClient := ssm.New(ssm.Options{Region: "us-east-1", BaseEndpoint: aws.String(url), Credentials: aws.AnonymousCredentials{}})
+	secretsClient := secretsmanager.New(secretsmanager.Options{Region: "us-east-1", BaseEndpoint: aws.String(url), Credentials: aws.AnonymousCredentials{}})
+	provider := config.NewAWSProvider(ssmClient, secretsClient)
+	ctx := context.Background()
+
+	for ref, expected := range map[string]string{
+		"ssm:///prod/users/table":               "users-prod",
+		"secretsmanager://prod/webhook":         `{"key":"whsec","version":2}`,
+		"secretsmanager://prod/webhook#key":     "whsec",
+		"secretsmanager://prod/webhook#version": "2",
+	} {
+		if value, err := provider.Resolve(ctx, ref); err != nil || value != expected {
+			t.Errorf("%s: unexpected value %q %v", ref, value, err)
+		}
+	}
+
+	for _, ref := range []string{"ssm:///prod/missing", "secretsmanager://prod/missing", "secretsmanager://prod/webhook#missing"} {
+		if _, err := provider.Resolve(ctx, ref); !errors.Is(err, config.ErrReferenceNotFound) {
+			t.Errorf("%s: expected not found, got %v", ref, err)
+		}
+	}
+}
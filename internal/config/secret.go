@@ -0,0 +1,34 @@
+package config
+
+import (
+	"context"
+	"reflect"
+)
+
+var secretType = reflect.TypeOf(Secret{})
+
+// Secret is a setting that can be rotated while the container runs, such as
+// a signing key. Load resolves it once to fail fast, Value resolves it again
+// through the provider, whose cache decides when it is actually fetched.
+// Secret is always masked by Dump and String.
+type Secret struct {
+	ref      string
+	value    string
+	provider Provider
+}
+
+// NewSecret returns a static secret, mostly useful in tests.
+func NewSecret(value string) Secret {
+	return Secret{value: value}
+}
+
+func (s Secret) Value(ctx context.Context) (string, error) {
+	if s.provider == nil || !IsReference(s.ref) {
+		return s.value, nil
+	}
+	return s.provider.Resolve(ctx, s.ref)
+}
+
+func (s Secret) String() string {
+	return masked
+}
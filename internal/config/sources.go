@@ -0,0 +1,39 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"time"
+)
+
+// Sources selects where references are resolved from, it is loaded before
+// the configuration of the application.
+type Sources struct {
+	// SecretsFile replaces SSM and Secrets Manager with a local JSON file.
+	SecretsFile     string        `env:"CONFIG_SECRETS_FILE"`
+	RefreshInterval time.Duration `env:"CONFIG_REFRESH_INTERVAL" default:"5m"`
+}
+
+// NewProvider returns the cached provider configured by Sources, to be
+// created once per container and passed to Load with WithProvider.
+func NewProvider(ctx context.Context) (Provider, error) {
+	var sources Sources
+	if err := Load(&sources); err != nil {
+		return nil, err
+	}
+
+	if len(sources.SecretsFile) > 0 {
+		return NewCachingProvider(NewFileProvider(sources.SecretsFile), sources.RefreshInterval), nil
+	}
+
+	cfg, err := awsConfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading aws config: %w", err)
+	}
+
+	provider := NewAWSProvider(ssm.NewFromConfig(cfg), secretsmanager.NewFromConfig(cfg))
+	return NewCachingProvider(provider, sources.RefreshInterval), nil
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultVerifyTTL is how long a successful verification is trusted.
+const DefaultVerifyTTL = time.Hour
+
+// Cache remembers the tables already verified so cold starts of the same
+// execution environment skip the DescribeTable round trip.
+type Cache interface {
+	Verified(tableName string) bool
+	MarkVerified(tableName string)
+}
+
+type fileCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewFileCache keeps a marker file per table in dir, lambda keeps /tmp
+// between runtime restarts of the same execution environment.
+func NewFileCache(dir string, ttl time.Duration) Cache {
+	return &fileCache{dir: dir, ttl: ttl}
+}
+
+func (c *fileCache) path(tableName string) string {
+	return filepath.Join(c.dir, "dynamodb-verified-"+filepath.Base(tableName))
+}
+
+func (c *fileCache) Verified(tableName string) bool {
+	info, err := os.Stat(c.path(tableName))
+	if err != nil {
+		return false
+	}
+
+	return time.Since(info.ModTime()) < c.ttl
+}
+
+func (c *fileCache) MarkVerified(tableName string) {
+	// a failed write only costs a DescribeTable on the next cold start
+	_ = os.WriteFile(c.path(tableName), []byte(time.Now().UTC().Format(time.RFC3339)), 0o600)
+}
+
+type noopCache struct{}
+
+// NewNoopCache verifies the table every time.
+func NewNoopCache() Cache {
+	return noopCache{}
+}
+
+func (noopCache) Verified(string) bool { return false }
+
+func (noopCache) MarkVerified(string) {}
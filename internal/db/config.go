@@ -3,78 +3,285 @@ package db
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/smithy-go"
-	"github.com/ricardojonathanromero/go-utilities/logger"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
 	"time"
 )
 
+var (
+	ErrTableNotFound  = errors.New("table not found")
+	ErrTableNotReady  = errors.New("table not active")
+	ErrSchemaMismatch = errors.New("table key schema does not match the definition")
+)
+
 type DB interface {
-	ConfigureTable(tableName string) error
+	// ConfigureTable creates the table when missing, same as ModeCreate.
+	ConfigureTable(ctx context.Context, tableName string) error
+	// Provision verifies, creates or reconciles the table according to mode.
+	// It runs from main, where ctx should be bounded by clientopts.InitTimeout.
+	Provision(ctx context.Context, tableName string, mode Mode) error
 }
 
 type dbInfra struct {
-	conn *dynamodb.Client
-	log  logger.Logger
+	conn  dynamodbapi.TableAPI
+	log   logging.Logger
+	opts  clientopts.Options
+	cache Cache
 }
 
-func New(conn *dynamodb.Client, log logger.Logger) DB {
-	return &dbInfra{conn: conn, log: log}
+// New provisions the tables on conn, a nil cache verifies them every time.
+func New(conn dynamodbapi.TableAPI, log logging.Logger, opts clientopts.Options, cache Cache) DB {
+	if cache == nil {
+		cache = NewNoopCache()
+	}
+	return &dbInfra{conn: conn, log: log, opts: opts, cache: cache}
 }
 
-func (check *dbInfra) ConfigureTable(tableName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+func (check *dbInfra) ConfigureTable(ctx context.Context, tableName string) error {
+	return check.Provision(ctx, tableName, ModeCreate)
+}
 
-	check.log.Debug("describe table")
-	out, err := check.conn.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
-	if err != nil {
-		var nfErr *types.ResourceNotFoundException
-		if errors.As(err, &nfErr) {
+func (check *dbInfra) Provision(ctx context.Context, tableName string, mode Mode) error {
+	check.log.Debugf("provisioning table %s in mode %s", tableName, mode)
+
+	switch mode {
+	case ModeOff:
+		check.log.Debug("provisioning disabled")
+		return nil
+	case ModeVerify:
+		return check.verify(ctx, tableName)
+	case ModeCreate:
+		err := check.verify(ctx, tableName)
+		if errors.Is(err, ErrTableNotFound) {
+			check.log.Debug("resource not exists, create")
+			return check.configureTable(ctx, tableName)
+		}
+		return err
+	case ModeReconcile:
+		table, err := check.describe(ctx, tableName)
+		if errors.Is(err, ErrTableNotFound) {
 			check.log.Debug("resource not exists, create")
-			return check.configureTable(tableName)
+			return check.configureTable(ctx, tableName)
 		}
+		if err != nil {
+			return err
+		}
+		return check.reconcile(ctx, table)
+	default:
+		return fmt.Errorf("unsupported provisioning mode %q", mode)
+	}
+}
+
+func (check *dbInfra) verify(ctx context.Context, tableName string) error {
+	if check.cache.Verified(tableName) {
+		check.log.Debug("table verified recently, skip describe")
+		return nil
+	}
 
-		var ae smithy.APIError
-		if errors.As(err, &ae) {
-			check.log.Errorf("code: %s, message: %s, fault: %s", ae.ErrorCode(), ae.ErrorMessage(), ae.ErrorFault().String())
+	table, err := check.describe(ctx, tableName)
+	if err != nil {
+		return err
+	}
+
+	if err = matchKeySchema(table, getTableDefinition(tableName)); err != nil {
+		check.log.Errorf("error verifying table: %v", err)
+		return err
+	}
+
+	if table.TableStatus != types.TableStatusActive {
+		check.log.Errorf("table %s is %s", tableName, table.TableStatus)
+		return fmt.Errorf("%w: %s is %s", ErrTableNotReady, tableName, table.TableStatus)
+	}
+
+	check.cache.MarkVerified(tableName)
+	check.log.Info("table already configured")
+	return nil
+}
+
+func (check *dbInfra) describe(ctx context.Context, tableName string) (*types.TableDescription, error) {
+	var table *types.TableDescription
+	err := check.call(ctx, "DescribeTable", func(callCtx context.Context) error {
+		check.log.Debug("describe table")
+		out, err := check.conn.DescribeTable(callCtx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, tracing.DynamoDB, check.opts.DynamoDB)
+		if err != nil {
+			var nfErr *types.ResourceNotFoundException
+			if errors.As(err, &nfErr) {
+				return fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+			}
 			return err
 		}
 
-		var oe *smithy.OperationError
-		if errors.As(err, &oe) {
-			check.log.Errorf("failed to call service: %s, operation: %s, error: %v", oe.Service(), oe.Service(), oe.Unwrap())
+		table = out.Table
+		return nil
+	})
+
+	return table, err
+}
+
+func (check *dbInfra) configureTable(ctx context.Context, tableName string) error {
+	// table not created
+	err := check.call(ctx, "CreateTable", func(callCtx context.Context) error {
+		check.log.Debug("creating table")
+		_, err := check.conn.CreateTable(callCtx, getTableDefinition(tableName), tracing.DynamoDB, check.opts.DynamoDB)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = check.waitActive(ctx, tableName); err != nil {
+		check.log.Errorf("error waiting for table: %v", err)
+		return err
+	}
+
+	check.cache.MarkVerified(tableName)
+	check.log.Info("table configured")
+	return nil
+}
+
+// waitActive blocks until the new table accepts reads and writes, bounded by
+// the deadline of ctx.
+func (check *dbInfra) waitActive(ctx context.Context, tableName string) error {
+	maxWait := clientopts.InitTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(check.conn, func(o *dynamodb.TableExistsWaiterOptions) {
+		o.MinDelay = 500 * time.Millisecond
+		o.MaxDelay = 2 * time.Second
+		o.ClientOptions = append(o.ClientOptions, tracing.DynamoDB)
+	})
+
+	err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, maxWait)
+	return check.opts.Err("CreateTable", err)
+}
+
+// reconcile brings the billing mode and tags back to the definition. The key
+// schema cannot be changed in place, a mismatch is reported as an error.
+func (check *dbInfra) reconcile(ctx context.Context, table *types.TableDescription) error {
+	tableName := aws.ToString(table.TableName)
+	expected := getTableDefinition(tableName)
+	if err := matchKeySchema(table, expected); err != nil {
+		check.log.Errorf("error reconciling table: %v", err)
+		return err
+	}
+
+	if billingMode(table) != expected.BillingMode {
+		check.log.Infof("updating billing mode to %s", expected.BillingMode)
+		err := check.call(ctx, "UpdateTable", func(callCtx context.Context) error {
+			_, err := check.conn.UpdateTable(callCtx, &dynamodb.UpdateTableInput{
+				TableName:   table.TableName,
+				BillingMode: expected.BillingMode,
+			}, tracing.DynamoDB, check.opts.DynamoDB)
+			return err
+		})
+		if err != nil {
 			return err
 		}
+	}
 
-		check.log.Errorf("error describing table: %v", err)
+	var current []types.Tag
+	err := check.call(ctx, "ListTagsOfResource", func(callCtx context.Context) error {
+		out, err := check.conn.ListTagsOfResource(callCtx, &dynamodb.ListTagsOfResourceInput{ResourceArn: table.TableArn}, tracing.DynamoDB, check.opts.DynamoDB)
+		if err == nil {
+			current = out.Tags
+		}
+		return err
+	})
+	if err != nil {
 		return err
 	}
 
-	check.log.Debugf("check if table name is expected: %v", *out.Table)
-	if *out.Table.TableName == tableName {
-		check.log.Info("table already configured")
-		return nil
+	if missing := missingTags(current, expected.Tags); len(missing) > 0 {
+		check.log.Infof("updating %d tags", len(missing))
+		err = check.call(ctx, "TagResource", func(callCtx context.Context) error {
+			_, err := check.conn.TagResource(callCtx, &dynamodb.TagResourceInput{ResourceArn: table.TableArn, Tags: missing}, tracing.DynamoDB, check.opts.DynamoDB)
+			return err
+		})
+		if err != nil {
+			return err
+		}
 	}
 
+	check.cache.MarkVerified(tableName)
+	check.log.Info("table reconciled")
 	return nil
 }
 
-func (check *dbInfra) configureTable(tableName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// call runs fn with the per-call timeout of operation and logs the failure.
+func (check *dbInfra) call(ctx context.Context, operation string, fn func(callCtx context.Context) error) error {
+	callCtx, cancel, err := check.opts.Context(ctx, operation)
+	if err != nil {
+		check.log.Errorf("error calling %s: %v", operation, err)
+		return err
+	}
 	defer cancel()
 
-	// table not created
-	check.log.Debug("creating table")
-	input := getTableDefinition(tableName)
-	_, err := check.conn.CreateTable(ctx, input)
-	if err != nil {
-		check.log.Errorf("error creating table: %v", err)
+	err = fn(callCtx)
+	if err == nil || errors.Is(err, ErrTableNotFound) {
 		return err
 	}
 
-	check.log.Info("table configured")
+	var ae smithy.APIError
+	var oe *smithy.OperationError
+	switch {
+	case errors.As(err, &ae):
+		check.log.Errorf("code: %s, message: %s, fault: %s", ae.ErrorCode(), ae.ErrorMessage(), ae.ErrorFault().String())
+	case errors.As(err, &oe):
+		check.log.Errorf("failed to call service: %s, operation: %s, error: %v", oe.Service(), oe.Operation(), oe.Unwrap())
+	default:
+		check.log.Errorf("error calling %s: %v", operation, err)
+	}
+
+	return check.opts.Err(operation, err)
+}
+
+func matchKeySchema(table *types.TableDescription, expected *dynamodb.CreateTableInput) error {
+	keys := make(map[string]types.KeyType, len(table.KeySchema))
+	for _, key := range table.KeySchema {
+		keys[aws.ToString(key.AttributeName)] = key.KeyType
+	}
+
+	if len(keys) != len(expected.KeySchema) {
+		return fmt.Errorf("%w: %s", ErrSchemaMismatch, aws.ToString(table.TableName))
+	}
+
+	for _, key := range expected.KeySchema {
+		if keys[aws.ToString(key.AttributeName)] != key.KeyType {
+			return fmt.Errorf("%w: %s expects %s as %s", ErrSchemaMismatch, aws.ToString(table.TableName), aws.ToString(key.AttributeName), key.KeyType)
+		}
+	}
+
 	return nil
 }
+
+func billingMode(table *types.TableDescription) types.BillingMode {
+	// tables that never switched to on-demand report no billing summary
+	if table.BillingModeSummary == nil {
+		return types.BillingModeProvisioned
+	}
+	return table.BillingModeSummary.BillingMode
+}
+
+func missingTags(current, expected []types.Tag) []types.Tag {
+	values := make(map[string]string, len(current))
+	for _, tag := range current {
+		values[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	var missing []types.Tag
+	for _, tag := range expected {
+		if value, ok := values[aws.ToString(tag.Key)]; !ok || value != aws.ToString(tag.Value) {
+			missing = append(missing, tag)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,231 @@
+package db_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const tableName = "users"
+
+// fakeTable answers the control plane operations used by Provision.
+type fakeTable struct {
+	mu          sync.Mutex
+	exists      bool
+	rangeKey    string
+	billingMode string
+	tags        []map[string]string
+	calls       map[string]int
+}
+
+func newFakeTable(exists bool) *fakeTable {
+	return &fakeTable{
+		exists:      exists,
+		rangeKey:    "CreatedAt",
+		billingMode: "PAY_PER_REQUEST",
+		tags:        []map[string]string{{"Key": "OWNER", "Value": "Ricardo Romero"}},
+		calls:       map[string]int{},
+	}
+}
+
+func (f *fakeTable) description() map[string]any {
+	return map[string]any{
+		"TableName":   tableName,
+		"TableArn":    "arn:aws:dynamodb:us-east-1:000000000000:table/" + tableName,
+		"TableStatus": "ACTIVE",
+		"KeySchema": []map[string]string{
+			{"AttributeName": "Id", "KeyType": "HASH"},
+			{"AttributeName": f.rangeKey, "KeyType": "RANGE"},
+		},
+		"BillingModeSummary": map[string]string{"BillingMode": f.billingMode},
+	}
+}
+
+func (f *fakeTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+	f.calls[operation]++
+
+	var input map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&input)
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	var body any
+	switch operation {
+	case "DescribeTable":
+		if !f.exists {
+			w.WriteHeader(http.StatusBadRequest)
+			body = map[string]string{"__type": "com.amazonaws.dynamodb.v20120810#ResourceNotFoundException", "message": "Requested resource not found"}
+			break
+		}
+		body = map[string]any{"Table": f.description()}
+	case "CreateTable":
+		f.exists = true
+		body = map[string]any{"TableDescription": f.description()}
+	case "UpdateTable":
+		f.billingMode = input["BillingMode"].(string)
+		body = map[string]any{"TableDescription": f.description()}
+	case "ListTagsOfResource":
+		body = map[string]any{"Tags": f.tags}
+	case "TagResource":
+		for _, tag := range input["Tags"].([]any) {
+			tag := tag.(map[string]any)
+			f.tags = append(f.tags, map[string]string{"Key": tag["Key"].(string), "Value": tag["Value"].(string)})
+		}
+		body = map[string]any{}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		body = map[string]string{"__type": "UnknownOperationException"}
+	}
+
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeTable) count(operation string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[operation]
+}
+
+func newDB(t *testing.T, fake *fakeTable, cache db.Cache) db.DB {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	conn := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+
+	log := logging.New(logging.Opts{AppName: "db-test", Level: "debug", Output: io.Discard})
+	return db.New(conn, log, clientopts.New(clientopts.DefaultPolicy()), cache)
+}
+
+func TestParseMode(t *testing.T) {
+	for _, value := range []string{"off", "verify", " Create ", "RECONCILE"} {
+		if _, err := db.ParseMode(value); err != nil {
+			t.Errorf("mode %q rejected: %v", value, err)
+		}
+	}
+
+	if _, err := db.ParseMode("always"); err == nil {
+		t.Errorf("unknown mode accepted")
+	}
+}
+
+func TestProvision(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("off does not call dynamodb", func(t *testing.T) {
+		fake := newFakeTable(false)
+		if err := newDB(t, fake, db.NewNoopCache()).Provision(ctx, tableName, db.ModeOff); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(fake.calls) != 0 {
+			t.Errorf("unexpected calls: %v", fake.calls)
+		}
+	})
+
+	t.Run("verify fails on missing table", func(t *testing.T) {
+		fake := newFakeTable(false)
+		err := newDB(t, fake, db.NewNoopCache()).Provision(ctx, tableName, db.ModeVerify)
+		if !errors.Is(err, db.ErrTableNotFound) || fake.count("CreateTable") != 0 {
+			t.Errorf("unexpected result: %v %v", err, fake.calls)
+		}
+	})
+
+	t.Run("verify detects a different key schema", func(t *testing.T) {
+		fake := newFakeTable(true)
+		fake.rangeKey = "Email"
+		err := newDB(t, fake, db.NewNoopCache()).Provision(ctx, tableName, db.ModeVerify)
+		if !errors.Is(err, db.ErrSchemaMismatch) {
+			t.Errorf("expected schema mismatch, got %v", err)
+		}
+	})
+
+	t.Run("verify without cache", func(t *testing.T) {
+		fake := newFakeTable(true)
+		for i := 0; i < 2; i++ {
+			if err := newDB(t, fake, nil).Provision(ctx, tableName, db.ModeVerify); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if fake.count("DescribeTable") != 2 {
+			t.Errorf("expected a describe per call: %v", fake.calls)
+		}
+	})
+
+	t.Run("verify result is cached", func(t *testing.T) {
+		fake := newFakeTable(true)
+		cache := db.NewFileCache(t.TempDir(), db.DefaultVerifyTTL)
+
+		for i := 0; i < 2; i++ {
+			if err := newDB(t, fake, cache).Provision(ctx, tableName, db.ModeVerify); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if fake.count("DescribeTable") != 1 {
+			t.Errorf("describe not cached: %v", fake.calls)
+		}
+	})
+
+	t.Run("create creates the missing table", func(t *testing.T) {
+		fake := newFakeTable(false)
+		if err := newDB(t, fake, db.NewNoopCache()).Provision(ctx, tableName, db.ModeCreate); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if fake.count("CreateTable") != 1 || !fake.exists {
+			t.Errorf("table not created: %v", fake.calls)
+		}
+	})
+
+	t.Run("create keeps an existing table", func(t *testing.T) {
+		fake := newFakeTable(true)
+		if err := newDB(t, fake, db.NewNoopCache()).ConfigureTable(ctx, tableName); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if fake.count("CreateTable") != 0 {
+			t.Errorf("unexpected calls: %v", fake.calls)
+		}
+	})
+
+	t.Run("reconcile fixes billing mode and tags", func(t *testing.T) {
+		fake := newFakeTable(true)
+		fake.billingMode = "PROVISIONED"
+		fake.tags = nil
+
+		if err := newDB(t, fake, db.NewNoopCache()).Provision(ctx, tableName, db.ModeReconcile); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if fake.billingMode != "PAY_PER_REQUEST" || len(fake.tags) != 1 {
+			t.Errorf("table not reconciled: %s %v", fake.billingMode, fake.tags)
+		}
+
+		if err := newDB(t, fake, db.NewNoopCache()).Provision(ctx, tableName, db.ModeReconcile); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if fake.count("UpdateTable") != 1 || fake.count("TagResource") != 1 {
+			t.Errorf("reconcile is not idempotent: %v", fake.calls)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvProvisioning selects what a lambda does with its table on cold start.
+const EnvProvisioning = "DYNAMODB_PROVISIONING"
+
+// Mode controls how much the table is managed by the process using it.
+type Mode string
+
+const (
+	// ModeOff trusts the table exists, only read/write permissions are needed.
+	ModeOff Mode = "off"
+	// ModeVerify checks the table is active and has the expected key schema,
+	// it needs dynamodb:DescribeTable.
+	ModeVerify Mode = "verify"
+	// ModeCreate creates the table when missing and verifies it otherwise.
+	ModeCreate Mode = "create"
+	// ModeReconcile creates the table when missing and updates the billing
+	// mode and tags that drifted from the definition.
+	ModeReconcile Mode = "reconcile"
+)
+
+func ParseMode(value string) (Mode, error) {
+	mode := Mode(strings.ToLower(strings.TrimSpace(value)))
+	switch mode {
+	case ModeOff, ModeVerify, ModeCreate, ModeReconcile:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported provisioning mode %q, expected off, verify, create or reconcile", value)
+	}
+}
@@ -30,10 +30,6 @@ func getTableDefinition(tableName string) *dynamodb.CreateTableInput {
 		},
 		TableName:   aws.String(tableName),
 		BillingMode: types.BillingModePayPerRequest,
-		ProvisionedThroughput: &types.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(5),
-			WriteCapacityUnits: aws.Int64(5),
-		},
 		Tags: []types.Tag{
 			{
 				Key:   aws.String("OWNER"),
@@ -0,0 +1,122 @@
+package devserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"io"
+	"net"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	stage     = "local"
+	accountID = "000000000000"
+	apiID     = "devserver"
+)
+
+// ToProxyRequest translates r into the event API Gateway sends to a proxy
+// integration, binary bodies are base64 encoded.
+func ToProxyRequest(r *http.Request, route Route, params map[string]string) (events.APIGatewayProxyRequest, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, fmt.Errorf("error reading body: %w", err)
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:                        route.Path,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         map[string]string{},
+		MultiValueHeaders:               map[string][]string{},
+		QueryStringParameters:           map[string]string{},
+		MultiValueQueryStringParameters: map[string][]string{},
+		PathParameters:                  params,
+		StageVariables:                  map[string]string{},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:        accountID,
+			APIID:            apiID,
+			Stage:            stage,
+			RequestID:        uuid.NewString(),
+			ResourcePath:     route.Path,
+			Path:             "/" + stage + r.URL.Path,
+			HTTPMethod:       r.Method,
+			RequestTimeEpoch: time.Now().UnixMilli(),
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  sourceIP(r),
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}
+
+	for key, values := range r.Header {
+		req.Headers[key] = values[len(values)-1]
+		req.MultiValueHeaders[key] = values
+	}
+
+	if len(r.Host) > 0 {
+		req.Headers["Host"] = r.Host
+		req.MultiValueHeaders["Host"] = []string{r.Host}
+	}
+
+	for key, values := range r.URL.Query() {
+		req.QueryStringParameters[key] = values[len(values)-1]
+		req.MultiValueQueryStringParameters[key] = values
+	}
+
+	if utf8.Valid(body) {
+		req.Body = string(body)
+	} else {
+		req.Body = base64.StdEncoding.EncodeToString(body)
+		req.IsBase64Encoded = true
+	}
+
+	return req, nil
+}
+
+// WriteResponse writes the payload returned by a function. Payloads shaped
+// like events.APIGatewayProxyResponse are unwrapped, anything else is
+// returned as a 200 JSON document.
+func WriteResponse(w http.ResponseWriter, payload []byte) error {
+	var res events.APIGatewayProxyResponse
+	if err := json.Unmarshal(payload, &res); err != nil || res.StatusCode == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(payload)
+		return err
+	}
+
+	for key, value := range res.Headers {
+		w.Header().Set(key, value)
+	}
+	for key, values := range res.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	body := []byte(res.Body)
+	if res.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(res.Body)
+		if err != nil {
+			return fmt.Errorf("error decoding body: %w", err)
+		}
+		body = decoded
+	}
+
+	w.WriteHeader(res.StatusCode)
+	_, err := w.Write(body)
+	return err
+}
+
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,121 @@
+package devserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/google/uuid"
+	"net/rpc"
+	"time"
+)
+
+// DefaultTimeout is the deadline given to every invocation, the API Gateway
+// integration timeout.
+const DefaultTimeout = 29 * time.Second
+
+// ErrFunction is returned when the function fails instead of answering,
+// API Gateway reports it as 502.
+var ErrFunction = errors.New("function error")
+
+// Function is a lambda reachable by the dev server.
+type Function interface {
+	Invoke(ctx context.Context, payload []byte) ([]byte, error)
+	// Reload drops the running instance, the next invocation starts a new
+	// one with the current environment.
+	Reload() error
+	Close() error
+}
+
+type handlerFunction struct {
+	handler lambda.Handler
+}
+
+// NewHandlerFunction runs handler in process, handler has any of the
+// signatures accepted by lambda.Start.
+func NewHandlerFunction(handler any) Function {
+	return &handlerFunction{handler: lambda.NewHandler(handler)}
+}
+
+func (f *handlerFunction) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	out, err := f.handler.Invoke(ctx, payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFunction, err)
+	}
+	return out, nil
+}
+
+func (f *handlerFunction) Reload() error {
+	return nil
+}
+
+func (f *handlerFunction) Close() error {
+	return nil
+}
+
+// invokeRPC calls a function started with _LAMBDA_SERVER_PORT, the protocol of
+// the go1.x runtime still supported by lambda.Start.
+func invokeRPC(ctx context.Context, addr, name string, payload []byte) ([]byte, error) {
+	client, err := rpc.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", name, err)
+	}
+	defer func() { _ = client.Close() }()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultTimeout)
+	}
+
+	req := messages.InvokeRequest{
+		Payload:            payload,
+		RequestId:          uuid.NewString(),
+		InvokedFunctionArn: fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", stage, accountID, name),
+		Deadline: messages.InvokeRequest_Timestamp{
+			Seconds: deadline.Unix(),
+			Nanos:   int64(deadline.Nanosecond()),
+		},
+	}
+
+	var res messages.InvokeResponse
+	call := client.Go("Function.Invoke", &req, &res, nil)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-call.Done:
+	}
+
+	if call.Error != nil {
+		return nil, fmt.Errorf("error invoking %s: %w", name, call.Error)
+	}
+
+	if res.Error != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrFunction, res.Error.Type, res.Error.Message)
+	}
+
+	return res.Payload, nil
+}
+
+type rpcFunction struct {
+	name string
+	addr string
+}
+
+// NewRPCFunction invokes a function already listening on addr, for example
+// one started by hand with _LAMBDA_SERVER_PORT under a debugger.
+func NewRPCFunction(name, addr string) Function {
+	return &rpcFunction{name: name, addr: addr}
+}
+
+func (f *rpcFunction) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return invokeRPC(ctx, f.addr, f.name, payload)
+}
+
+func (f *rpcFunction) Reload() error {
+	return nil
+}
+
+func (f *rpcFunction) Close() error {
+	return nil
+}
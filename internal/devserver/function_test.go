@@ -0,0 +1,112 @@
+package devserver_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/devserver"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serveRPC starts handler the way lambda.Start does with _LAMBDA_SERVER_PORT.
+func serveRPC(t *testing.T, handler any) string {
+	server := rpc.NewServer()
+	//nolint:staticcheck // the go1.x protocol is what the dev server speaks
+	if err := server.Register(lambda.NewFunction(lambda.NewHandler(handler))); err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lis.Close() })
+
+	go server.Accept(lis)
+	return lis.Addr().String()
+}
+
+func TestRPCFunction(t *testing.T) {
+	addr := serveRPC(t, func(ctx context.Context, name string) (string, error) {
+		lc, _ := lambdacontext.FromContext(ctx)
+		if _, ok := ctx.Deadline(); !ok || len(lc.AwsRequestID) == 0 {
+			return "", errors.New("missing invocation metadata")
+		}
+		if name == "fail" {
+			return "", errors.New("boom")
+		}
+		return "hello " + name, nil
+	})
+
+	fn := devserver.NewRPCFunction("greeter", addr)
+	ctx := context.Background()
+
+	out, err := fn.Invoke(ctx, []byte(`"john"`))
+	if err != nil || string(out) != `"hello john"` {
+		t.Errorf("unexpected result: %s %v", out, err)
+	}
+
+	if _, err = fn.Invoke(ctx, []byte(`"fail"`)); !errors.Is(err, devserver.ErrFunction) {
+		t.Errorf("expected function error, got %v", err)
+	}
+
+	if _, err = devserver.NewRPCFunction("missing", "localhost:1").Invoke(ctx, nil); err == nil || errors.Is(err, devserver.ErrFunction) {
+		t.Errorf("expected connection error, got %v", err)
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := "# local settings\n\nLOG_LEVEL=debug\nexport TZ_LOCATION=\"America/Mexico_City\"\nEXPORT_KEY_PREFIX='dev/users'\nEMPTY=\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := devserver.ReadEnvFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{"LOG_LEVEL": "debug", "TZ_LOCATION": "America/Mexico_City", "EXPORT_KEY_PREFIX": "dev/users", "EMPTY": ""}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, values[key])
+		}
+	}
+
+	_ = os.WriteFile(path, []byte("NOT A PAIR\n"), 0o600)
+	if _, err = devserver.ReadEnvFile(path); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	_ = os.WriteFile(path, []byte("LOG_LEVEL=info\n"), 0o600)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var changes atomic.Int32
+	go devserver.Watch(ctx, path, 5*time.Millisecond, func() { changes.Add(1) })
+
+	time.Sleep(20 * time.Millisecond)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(path, future, future)
+
+	deadline := time.Now().Add(time.Second)
+	for changes.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if changes.Load() != 1 {
+		t.Errorf("expected 1 change, got %d", changes.Load())
+	}
+}
@@ -0,0 +1,191 @@
+package devserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StartTimeout covers the build and the init phase of a function, which may
+// create the table on the first run.
+const StartTimeout = time.Minute
+
+type processFunction struct {
+	name   string
+	dir    string
+	env    func() []string
+	log    logging.Logger
+	output io.Writer
+
+	mu     sync.Mutex
+	binDir string
+	cmd    *exec.Cmd
+	exited chan struct{}
+	addr   string
+}
+
+// NewProcessFunction builds the main package in dir/cmd and runs it as a
+// child process listening on _LAMBDA_SERVER_PORT. The binary is built on the
+// first invocation after a Reload, so code changes are picked up as well.
+// env is called on every start and output receives the logs of the function.
+func NewProcessFunction(name, dir string, env func() []string, log logging.Logger, output io.Writer) Function {
+	return &processFunction{name: name, dir: dir, env: env, log: log, output: output}
+}
+
+func (f *processFunction) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	addr, err := f.running(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return invokeRPC(ctx, addr, f.name, payload)
+}
+
+func (f *processFunction) running(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cmd != nil {
+		select {
+		case <-f.exited:
+			f.log.Warnf("function %s exited, starting it again", f.name)
+			f.cmd = nil
+		default:
+			return f.addr, nil
+		}
+	}
+
+	if err := f.start(ctx); err != nil {
+		f.log.Errorf("error starting function %s: %v", f.name, err)
+		return "", err
+	}
+	return f.addr, nil
+}
+
+func (f *processFunction) start(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, StartTimeout)
+	defer cancel()
+
+	if len(f.binDir) == 0 {
+		dir, err := os.MkdirTemp("", "devserver-")
+		if err != nil {
+			return err
+		}
+		f.binDir = dir
+	}
+
+	bin := filepath.Join(f.binDir, f.name)
+	f.log.Infof("building function %s from %s", f.name, f.dir)
+	build := exec.CommandContext(ctx, "go", "build", "-o", bin, "./cmd")
+	build.Dir = f.dir
+	if out, err := build.CombinedOutput(); err != nil {
+		return fmt.Errorf("error building %s: %w\n%s", f.name, err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(bin)
+	cmd.Env = append(f.env(), "_LAMBDA_SERVER_PORT="+strconv.Itoa(port), "AWS_LAMBDA_FUNCTION_NAME="+f.name)
+	cmd.Stdout = f.output
+	cmd.Stderr = f.output
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("error starting %s: %w", f.name, err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	addr := net.JoinHostPort("localhost", strconv.Itoa(port))
+	if err = waitReady(ctx, addr, exited); err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("function %s not ready: %w", f.name, err)
+	}
+
+	f.cmd, f.exited, f.addr = cmd, exited, addr
+	f.log.Infof("function %s listening on %s", f.name, addr)
+	return nil
+}
+
+// waitReady pings the function until it answers, the process exits or ctx
+// is done.
+func waitReady(ctx context.Context, addr string, exited <-chan struct{}) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if client, err := rpc.Dial("tcp", addr); err == nil {
+			var req, res struct{}
+			err = client.Call("Function.Ping", &req, &res)
+			_ = client.Close()
+			if err == nil {
+				return nil
+			}
+		}
+
+		select {
+		case <-exited:
+			return errors.New("process exited during init, see its logs")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *processFunction) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stop()
+}
+
+func (f *processFunction) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := f.stop()
+	if len(f.binDir) > 0 {
+		err = errors.Join(err, os.RemoveAll(f.binDir))
+		f.binDir = ""
+	}
+	return err
+}
+
+func (f *processFunction) stop() error {
+	if f.cmd == nil {
+		return nil
+	}
+
+	f.log.Infof("stopping function %s", f.name)
+	err := f.cmd.Process.Kill()
+	<-f.exited
+	f.cmd = nil
+
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	return err
+}
+
+func freePort() (int, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, fmt.Errorf("error finding a free port: %w", err)
+	}
+	defer func() { _ = lis.Close() }()
+	return lis.Addr().(*net.TCPAddr).Port, nil
+}
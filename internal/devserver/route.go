@@ -0,0 +1,81 @@
+package devserver
+
+import (
+	"strings"
+)
+
+// Integration tells how the HTTP request reaches the function, same as the
+// integration type of the API Gateway method.
+type Integration string
+
+const (
+	// IntegrationProxy sends an events.APIGatewayProxyRequest.
+	IntegrationProxy Integration = "proxy"
+	// IntegrationBody sends the request body as the payload.
+	IntegrationBody Integration = "body"
+)
+
+type Route struct {
+	Method string
+	// Path is the resource path, segments like {id} are path parameters.
+	Path        string
+	Function    string
+	Integration Integration
+}
+
+// DefaultRoutes mirrors the API deployed for the lambdas of this repository,
+// functions are named after their module directory.
+func DefaultRoutes() []Route {
+	return []Route{
+		{Method: "POST", Path: "/users", Function: "create-user-lambda", Integration: IntegrationProxy},
+		{Method: "GET", Path: "/users", Function: "get-all-documents-lambda", Integration: IntegrationProxy},
+		{Method: "GET", Path: "/users/{id}", Function: "get-document-lambda", Integration: IntegrationProxy},
+		{Method: "GET", Path: "/users/{id}/history", Function: "get-document-lambda", Integration: IntegrationProxy},
+		{Method: "POST", Path: "/exports", Function: "export-users-lambda", Integration: IntegrationBody},
+		{Method: "POST", Path: "/gdpr/exports", Function: "gdpr-lambda", Integration: IntegrationProxy},
+		{Method: "POST", Path: "/gdpr/erasures", Function: "gdpr-lambda", Integration: IntegrationProxy},
+		{Method: "POST", Path: "/users/{id}/suspend", Function: "user-status-lambda", Integration: IntegrationProxy},
+		{Method: "POST", Path: "/users/{id}/reactivate", Function: "user-status-lambda", Integration: IntegrationProxy},
+		{Method: "POST", Path: "/users/{id}/close", Function: "user-status-lambda", Integration: IntegrationProxy},
+		{Method: "POST", Path: "/users/verify", Function: "user-status-lambda", Integration: IntegrationProxy},
+		{Method: "POST", Path: "/users/{id}/verify/resend", Function: "user-status-lambda", Integration: IntegrationProxy},
+	}
+}
+
+// match returns the path parameters when method and path belong to route.
+func (route Route) match(method, path string) (map[string]string, bool) {
+	if !strings.EqualFold(route.Method, method) {
+		return nil, false
+	}
+
+	expected := segments(route.Path)
+	actual := segments(path)
+	if len(expected) != len(actual) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range expected {
+		if name, ok := strings.CutPrefix(segment, "{"); ok && strings.HasSuffix(name, "}") {
+			if len(actual[i]) == 0 {
+				return nil, false
+			}
+			params[strings.TrimSuffix(name, "}")] = actual[i]
+			continue
+		}
+
+		if segment != actual[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func segments(path string) []string {
+	path = strings.Trim(path, "/")
+	if len(path) == 0 {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
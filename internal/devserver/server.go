@@ -0,0 +1,147 @@
+package devserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Server translates HTTP requests into lambda invocations, the way API
+// Gateway does for the deployed API.
+type Server interface {
+	http.Handler
+	// Reload restarts every function, used when the configuration changes.
+	Reload() error
+	Close() error
+}
+
+type serverImpl struct {
+	routes    []Route
+	functions map[string]Function
+	log       logging.Logger
+	timeout   time.Duration
+}
+
+// New returns a server dispatching routes to functions, keyed by
+// Route.Function.
+func New(routes []Route, functions map[string]Function, log logging.Logger) Server {
+	return &serverImpl{routes: routes, functions: functions, log: log, timeout: DefaultTimeout}
+}
+
+// statusRecorder keeps the status code for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (s *serverImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	function := "-"
+
+	defer func() {
+		s.log.Infof("%s %s -> %s %d %s", r.Method, r.URL.RequestURI(), function, rec.status, time.Since(start).Round(time.Millisecond))
+	}()
+
+	route, params, ok := s.match(r.Method, r.URL.Path)
+	if !ok {
+		writeMessage(rec, http.StatusNotFound, "Not Found")
+		return
+	}
+	function = route.Function
+
+	fn, ok := s.functions[route.Function]
+	if !ok {
+		s.log.Errorf("function %s is not configured", route.Function)
+		writeMessage(rec, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	payload, err := s.payload(r, route, params)
+	if err != nil {
+		s.log.Errorf("error reading request: %v", err)
+		writeMessage(rec, http.StatusBadRequest, "Bad Request")
+		return
+	}
+	s.log.Debugf("request payload: %s", payload)
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	out, err := fn.Invoke(ctx, payload)
+	if err != nil {
+		s.log.Errorf("error invoking %s: %v", route.Function, err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			writeMessage(rec, http.StatusGatewayTimeout, "Endpoint request timed out")
+			return
+		}
+		writeMessage(rec, http.StatusBadGateway, "Internal server error")
+		return
+	}
+	s.log.Debugf("response payload: %s", out)
+
+	if err = WriteResponse(rec, out); err != nil {
+		s.log.Errorf("error writing response: %v", err)
+	}
+}
+
+func (s *serverImpl) match(method, path string) (Route, map[string]string, bool) {
+	for _, route := range s.routes {
+		if params, ok := route.match(method, path); ok {
+			return route, params, true
+		}
+	}
+	return Route{}, nil, false
+}
+
+func (s *serverImpl) payload(r *http.Request, route Route, params map[string]string) ([]byte, error) {
+	switch route.Integration {
+	case IntegrationBody:
+		return io.ReadAll(r.Body)
+	case IntegrationProxy:
+		req, err := ToProxyRequest(r, route, params)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(req)
+	default:
+		return nil, fmt.Errorf("unsupported integration %q", route.Integration)
+	}
+}
+
+func (s *serverImpl) Reload() error {
+	var errs []error
+	for name, fn := range s.functions {
+		if err := fn.Reload(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *serverImpl) Close() error {
+	var errs []error
+	for name, fn := range s.functions {
+		if err := fn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeMessage answers with the body API Gateway uses for its own errors.
+func writeMessage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
@@ -0,0 +1,169 @@
+package devserver_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/devserver"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type user struct {
+	Name string `json:"name"`
+}
+
+// reloadCounter counts the reloads requested by the server.
+type reloadCounter struct {
+	devserver.Function
+	reloads atomic.Int32
+}
+
+func (f *reloadCounter) Reload() error {
+	f.reloads.Add(1)
+	return nil
+}
+
+func newServer(t *testing.T, functions map[string]devserver.Function) *httptest.Server {
+	log := logging.New(logging.Opts{AppName: "devserver-test", Level: "debug", Output: io.Discard})
+	server := httptest.NewServer(devserver.New(devserver.DefaultRoutes(), functions, log))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func do(t *testing.T, method, url, body string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Log-Level", "debug")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	content, _ := io.ReadAll(res.Body)
+	return res, string(content)
+}
+
+func TestServerProxyIntegration(t *testing.T) {
+	var received events.APIGatewayProxyRequest
+	server := newServer(t, map[string]devserver.Function{
+		"get-document-lambda": devserver.NewHandlerFunction(func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			received = req
+			return events.APIGatewayProxyResponse{
+				StatusCode:        http.StatusOK,
+				Headers:           map[string]string{"Content-Type": "application/json"},
+				MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}},
+				Body:              `{"id":"` + req.PathParameters["id"] + `"}`,
+			}, nil
+		}),
+	})
+
+	res, body := do(t, http.MethodGet, server.URL+"/users/1234?fields=name&fields=email", "")
+	if res.StatusCode != http.StatusOK || body != `{"id":"1234"}` {
+		t.Fatalf("unexpected response: %d %s", res.StatusCode, body)
+	}
+
+	if len(res.Header.Values("Set-Cookie")) != 2 || res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers not copied: %v", res.Header)
+	}
+
+	if received.Resource != "/users/{id}" || received.Path != "/users/1234" || received.HTTPMethod != http.MethodGet {
+		t.Errorf("unexpected request: %+v", received)
+	}
+
+	if received.QueryStringParameters["fields"] != "email" || len(received.MultiValueQueryStringParameters["fields"]) != 2 {
+		t.Errorf("unexpected query: %v %v", received.QueryStringParameters, received.MultiValueQueryStringParameters)
+	}
+
+	if received.Headers["X-Log-Level"] != "debug" || len(received.RequestContext.RequestID) == 0 {
+		t.Errorf("unexpected headers or context: %v %+v", received.Headers, received.RequestContext)
+	}
+}
+
+func TestServerBodyIntegration(t *testing.T) {
+	server := newServer(t, map[string]devserver.Function{
+		"export-users-lambda": devserver.NewHandlerFunction(func(req user) (user, error) {
+			return req, nil
+		}),
+	})
+
+	// payloads that are not proxy responses are returned as they are
+	res, body := do(t, http.MethodPost, server.URL+"/exports", `{"name":"john"}`)
+	if res.StatusCode != http.StatusOK || strings.TrimSpace(body) != `{"name":"john"}` {
+		t.Errorf("unexpected response: %d %s", res.StatusCode, body)
+	}
+}
+
+func TestServerBinaryBodies(t *testing.T) {
+	server := newServer(t, map[string]devserver.Function{
+		"get-all-documents-lambda": devserver.NewHandlerFunction(func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return events.APIGatewayProxyResponse{
+				StatusCode:      http.StatusOK,
+				Body:            base64.StdEncoding.EncodeToString([]byte{0xff, 0x00}),
+				IsBase64Encoded: true,
+			}, nil
+		}),
+	})
+
+	res, body := do(t, http.MethodGet, server.URL+"/users", "")
+	if res.StatusCode != http.StatusOK || body != string([]byte{0xff, 0x00}) {
+		t.Errorf("unexpected response: %d %q", res.StatusCode, body)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	server := newServer(t, map[string]devserver.Function{
+		"get-document-lambda": devserver.NewHandlerFunction(func(ctx context.Context) error {
+			return errors.New("panic in handler")
+		}),
+		"get-all-documents-lambda": devserver.NewHandlerFunction(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	})
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/unknown", http.StatusNotFound},
+		{http.MethodDelete, "/users/1234", http.StatusNotFound},
+		{http.MethodGet, "/users/1234", http.StatusBadGateway},
+		{http.MethodPost, "/users", http.StatusBadGateway},
+	} {
+		res, body := do(t, tc.method, server.URL+tc.path, "")
+		var message map[string]string
+		if res.StatusCode != tc.status || json.Unmarshal([]byte(body), &message) != nil || len(message["message"]) == 0 {
+			t.Errorf("%s %s: unexpected response %d %s", tc.method, tc.path, res.StatusCode, body)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/users", nil)
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Error("expected the client to give up on a hanging function")
+	}
+}
+
+func TestServerReload(t *testing.T) {
+	counter := &reloadCounter{Function: devserver.NewHandlerFunction(func() error { return nil })}
+	log := logging.New(logging.Opts{AppName: "devserver-test", Output: io.Discard})
+	server := devserver.New(devserver.DefaultRoutes(), map[string]devserver.Function{"create-user-lambda": counter}, log)
+
+	if err := server.Reload(); err != nil || counter.reloads.Load() != 1 {
+		t.Errorf("function not reloaded: %v %d", err, counter.reloads.Load())
+	}
+}
@@ -0,0 +1,58 @@
+// Package domain holds the entities the services work with. They carry no
+// encoding tags: the dto package maps them to and from the API, the models
+// package to and from the stores.
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status is the lifecycle state of a user.
+type Status string
+
+const (
+	// StatusPendingVerification is a user whose email is not verified yet.
+	StatusPendingVerification Status = "pending_verification"
+	StatusActive              Status = "active"
+	// StatusSuspended is a user blocked by an admin, it can be reactivated.
+	StatusSuspended Status = "suspended"
+	// StatusClosed is a user whose account is closed for good.
+	StatusClosed Status = "closed"
+)
+
+// ErrInvalidStatus is returned by ParseStatus for an unknown status.
+var ErrInvalidStatus = errors.New("invalid status")
+
+// Statuses lists every status, in lifecycle order.
+func Statuses() []Status {
+	return []Status{StatusPendingVerification, StatusActive, StatusSuspended, StatusClosed}
+}
+
+// ParseStatus returns the status named s.
+func ParseStatus(s string) (Status, error) {
+	for _, status := range Statuses() {
+		if string(status) == s {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrInvalidStatus, s)
+}
+
+// User is a registered user, its times are in UTC.
+type User struct {
+	ID       string
+	Name     string
+	Lastname string
+	Age      int32
+	Email    string
+	Status   Status
+	// StatusReason explains the last change of Status.
+	StatusReason string
+	// OwnerSubject is the subject of the caller who created the user, empty
+	// for the users created without authentication.
+	OwnerSubject string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
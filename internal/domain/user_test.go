@@ -0,0 +1,22 @@
+package domain_test
+
+import (
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	for _, status := range domain.Statuses() {
+		parsed, err := domain.ParseStatus(string(status))
+		if err != nil || parsed != status {
+			t.Errorf("expected %s, got %s, %v", status, parsed, err)
+		}
+	}
+
+	for _, s := range []string{"", "Active", "deleted"} {
+		if _, err := domain.ParseStatus(s); !errors.Is(err, domain.ErrInvalidStatus) {
+			t.Errorf("%q: expected ErrInvalidStatus, got %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package dto holds the representations of the API shared by the lambdas,
+// mapped from the domain entities.
+package dto
+
+import (
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"time"
+)
+
+// UserResponse is a user as returned by the API.
+type UserResponse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name" pii:"mask"`
+	Lastname string `json:"lastname" pii:"mask"`
+	Age      int32  `json:"age"`
+	Email    string `json:"email" pii:"mask"`
+	Status   string `json:"status"`
+	// StatusReason explains the last change of Status.
+	StatusReason string    `json:"status_reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// NewUserResponse renders user with its times in loc, UTC when nil.
+func NewUserResponse(user domain.User, loc *time.Location) UserResponse {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return UserResponse{
+		ID:           user.ID,
+		Name:         user.Name,
+		Lastname:     user.Lastname,
+		Age:          user.Age,
+		Email:        user.Email,
+		Status:       string(user.Status),
+		StatusReason: user.StatusReason,
+		CreatedAt:    user.CreatedAt.In(loc),
+		UpdatedAt:    user.UpdatedAt.In(loc),
+	}
+}
+
+// NewUserResponses renders every user of users, never nil so an empty list
+// is encoded as [].
+func NewUserResponses(users []domain.User, loc *time.Location) []UserResponse {
+	out := make([]UserResponse, 0, len(users))
+	for _, user := range users {
+		out = append(out, NewUserResponse(user, loc))
+	}
+	return out
+}
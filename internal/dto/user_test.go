@@ -0,0 +1,47 @@
+package dto_test
+
+import (
+	"encoding/json"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dto"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/testutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUserResponseMapsEveryField(t *testing.T) {
+	var user domain.User
+	testutil.Fill(&user)
+
+	res := dto.NewUserResponse(user, time.UTC)
+	if zero := testutil.ZeroFields(res); len(zero) != 0 {
+		t.Errorf("fields of UserResponse left unmapped: %v", zero)
+	}
+	// the owner only serves the authorization, it is not part of the API
+	if unmapped := testutil.UnmappedFields(user, res); !reflect.DeepEqual(unmapped, []string{"OwnerSubject"}) {
+		t.Errorf("fields of domain.User missing from UserResponse: %v", unmapped)
+	}
+}
+
+func TestUserResponseJSON(t *testing.T) {
+	mexico, err := time.LoadLocation("America/Mexico_City")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	user := domain.User{ID: "usr-1", Name: "john", Lastname: "doe", Age: 30, Email: "john@example.com", Status: domain.StatusActive, CreatedAt: created, UpdatedAt: created}
+
+	body, err := json.Marshal(dto.NewUserResponse(user, mexico))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"id":"usr-1","name":"john","lastname":"doe","age":30,"email":"john@example.com","status":"active","created_at":"2024-05-01T04:00:00-06:00","updated_at":"2024-05-01T04:00:00-06:00"}`
+	if string(body) != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+
+	if body, _ = json.Marshal(dto.NewUserResponses(nil, nil)); string(body) != "[]" {
+		t.Errorf("expected an empty list, got %s", body)
+	}
+}
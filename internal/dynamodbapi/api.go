@@ -0,0 +1,84 @@
+// Package dynamodbapi declares the slices of the DynamoDB client the
+// repositories depend on. *dynamodb.Client satisfies all of them, so do the
+// mocks of the mocks package and the in-memory client of NewInMemory, which
+// lets tests and decorators (caching, metrics, tracing) stand in for the SDK.
+package dynamodbapi
+
+//go:generate mockery --config ../.mockery.yaml
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+type ItemGetter interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+}
+
+type ItemPutter interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+type ItemUpdater interface {
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+}
+
+type ItemDeleter interface {
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+type Querier interface {
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
+// Scanner matches dynamodb.ScanAPIClient, so it can drive a
+// dynamodb.ScanPaginator.
+type Scanner interface {
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+type TransactWriter interface {
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// TableDescriber matches dynamodb.DescribeTableAPIClient, so it can drive
+// the table waiters.
+type TableDescriber interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
+type TableCreator interface {
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+}
+
+type TableUpdater interface {
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+}
+
+type Tagger interface {
+	ListTagsOfResource(ctx context.Context, params *dynamodb.ListTagsOfResourceInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error)
+	TagResource(ctx context.Context, params *dynamodb.TagResourceInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TagResourceOutput, error)
+}
+
+// TableAPI is what table provisioning needs.
+type TableAPI interface {
+	TableDescriber
+	TableCreator
+	TableUpdater
+	Tagger
+}
+
+// Client is every operation of this package, for code wiring the
+// repositories from a single client.
+type Client interface {
+	ItemGetter
+	ItemPutter
+	ItemUpdater
+	ItemDeleter
+	Querier
+	Scanner
+	TransactWriter
+	TableAPI
+}
+
+var _ Client = (*dynamodb.Client)(nil)
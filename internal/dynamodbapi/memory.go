@@ -0,0 +1,11 @@
+package dynamodbapi
+
+import (
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbfake"
+)
+
+// NewInMemory returns a client backed by a new dynamodbfake, with no table.
+// Every call shares the state of that fake only.
+func NewInMemory() Client {
+	return dynamodbfake.New().Client()
+}
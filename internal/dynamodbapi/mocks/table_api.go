@@ -0,0 +1,403 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	dynamodb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// TableAPI is an autogenerated mock type for the TableAPI type
+type TableAPI struct {
+	mock.Mock
+}
+
+type TableAPI_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *TableAPI) EXPECT() *TableAPI_Expecter {
+	return &TableAPI_Expecter{mock: &_m.Mock}
+}
+
+// CreateTable provides a mock function with given fields: ctx, params, optFns
+func (_m *TableAPI) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	var tmpRet mock.Arguments
+	if len(optFns) > 0 {
+		tmpRet = _m.Called(ctx, params, optFns)
+	} else {
+		tmpRet = _m.Called(ctx, params)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateTable")
+	}
+
+	var r0 *dynamodb.CreateTableOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.CreateTableInput, ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)); ok {
+		return rf(ctx, params, optFns...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.CreateTableInput, ...func(*dynamodb.Options)) *dynamodb.CreateTableOutput); ok {
+		r0 = rf(ctx, params, optFns...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dynamodb.CreateTableOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *dynamodb.CreateTableInput, ...func(*dynamodb.Options)) error); ok {
+		r1 = rf(ctx, params, optFns...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TableAPI_CreateTable_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateTable'
+type TableAPI_CreateTable_Call struct {
+	*mock.Call
+}
+
+// CreateTable is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params *dynamodb.CreateTableInput
+//   - optFns ...func(*dynamodb.Options)
+func (_e *TableAPI_Expecter) CreateTable(ctx interface{}, params interface{}, optFns ...interface{}) *TableAPI_CreateTable_Call {
+	return &TableAPI_CreateTable_Call{Call: _e.mock.On("CreateTable",
+		append([]interface{}{ctx, params}, optFns...)...)}
+}
+
+func (_c *TableAPI_CreateTable_Call) Run(run func(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options))) *TableAPI_CreateTable_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]func(*dynamodb.Options), len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(func(*dynamodb.Options))
+			}
+		}
+		run(args[0].(context.Context), args[1].(*dynamodb.CreateTableInput), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *TableAPI_CreateTable_Call) Return(_a0 *dynamodb.CreateTableOutput, _a1 error) *TableAPI_CreateTable_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *TableAPI_CreateTable_Call) RunAndReturn(run func(context.Context, *dynamodb.CreateTableInput, ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)) *TableAPI_CreateTable_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DescribeTable provides a mock function with given fields: ctx, params, optFns
+func (_m *TableAPI) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	var tmpRet mock.Arguments
+	if len(optFns) > 0 {
+		tmpRet = _m.Called(ctx, params, optFns)
+	} else {
+		tmpRet = _m.Called(ctx, params)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for DescribeTable")
+	}
+
+	var r0 *dynamodb.DescribeTableOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.DescribeTableInput, ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)); ok {
+		return rf(ctx, params, optFns...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.DescribeTableInput, ...func(*dynamodb.Options)) *dynamodb.DescribeTableOutput); ok {
+		r0 = rf(ctx, params, optFns...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dynamodb.DescribeTableOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *dynamodb.DescribeTableInput, ...func(*dynamodb.Options)) error); ok {
+		r1 = rf(ctx, params, optFns...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TableAPI_DescribeTable_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DescribeTable'
+type TableAPI_DescribeTable_Call struct {
+	*mock.Call
+}
+
+// DescribeTable is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params *dynamodb.DescribeTableInput
+//   - optFns ...func(*dynamodb.Options)
+func (_e *TableAPI_Expecter) DescribeTable(ctx interface{}, params interface{}, optFns ...interface{}) *TableAPI_DescribeTable_Call {
+	return &TableAPI_DescribeTable_Call{Call: _e.mock.On("DescribeTable",
+		append([]interface{}{ctx, params}, optFns...)...)}
+}
+
+func (_c *TableAPI_DescribeTable_Call) Run(run func(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options))) *TableAPI_DescribeTable_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]func(*dynamodb.Options), len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(func(*dynamodb.Options))
+			}
+		}
+		run(args[0].(context.Context), args[1].(*dynamodb.DescribeTableInput), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *TableAPI_DescribeTable_Call) Return(_a0 *dynamodb.DescribeTableOutput, _a1 error) *TableAPI_DescribeTable_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *TableAPI_DescribeTable_Call) RunAndReturn(run func(context.Context, *dynamodb.DescribeTableInput, ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)) *TableAPI_DescribeTable_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListTagsOfResource provides a mock function with given fields: ctx, params, optFns
+func (_m *TableAPI) ListTagsOfResource(ctx context.Context, params *dynamodb.ListTagsOfResourceInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error) {
+	var tmpRet mock.Arguments
+	if len(optFns) > 0 {
+		tmpRet = _m.Called(ctx, params, optFns)
+	} else {
+		tmpRet = _m.Called(ctx, params)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTagsOfResource")
+	}
+
+	var r0 *dynamodb.ListTagsOfResourceOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.ListTagsOfResourceInput, ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error)); ok {
+		return rf(ctx, params, optFns...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.ListTagsOfResourceInput, ...func(*dynamodb.Options)) *dynamodb.ListTagsOfResourceOutput); ok {
+		r0 = rf(ctx, params, optFns...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dynamodb.ListTagsOfResourceOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *dynamodb.ListTagsOfResourceInput, ...func(*dynamodb.Options)) error); ok {
+		r1 = rf(ctx, params, optFns...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TableAPI_ListTagsOfResource_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListTagsOfResource'
+type TableAPI_ListTagsOfResource_Call struct {
+	*mock.Call
+}
+
+// ListTagsOfResource is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params *dynamodb.ListTagsOfResourceInput
+//   - optFns ...func(*dynamodb.Options)
+func (_e *TableAPI_Expecter) ListTagsOfResource(ctx interface{}, params interface{}, optFns ...interface{}) *TableAPI_ListTagsOfResource_Call {
+	return &TableAPI_ListTagsOfResource_Call{Call: _e.mock.On("ListTagsOfResource",
+		append([]interface{}{ctx, params}, optFns...)...)}
+}
+
+func (_c *TableAPI_ListTagsOfResource_Call) Run(run func(ctx context.Context, params *dynamodb.ListTagsOfResourceInput, optFns ...func(*dynamodb.Options))) *TableAPI_ListTagsOfResource_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]func(*dynamodb.Options), len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(func(*dynamodb.Options))
+			}
+		}
+		run(args[0].(context.Context), args[1].(*dynamodb.ListTagsOfResourceInput), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *TableAPI_ListTagsOfResource_Call) Return(_a0 *dynamodb.ListTagsOfResourceOutput, _a1 error) *TableAPI_ListTagsOfResource_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *TableAPI_ListTagsOfResource_Call) RunAndReturn(run func(context.Context, *dynamodb.ListTagsOfResourceInput, ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error)) *TableAPI_ListTagsOfResource_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// TagResource provides a mock function with given fields: ctx, params, optFns
+func (_m *TableAPI) TagResource(ctx context.Context, params *dynamodb.TagResourceInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TagResourceOutput, error) {
+	var tmpRet mock.Arguments
+	if len(optFns) > 0 {
+		tmpRet = _m.Called(ctx, params, optFns)
+	} else {
+		tmpRet = _m.Called(ctx, params)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for TagResource")
+	}
+
+	var r0 *dynamodb.TagResourceOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.TagResourceInput, ...func(*dynamodb.Options)) (*dynamodb.TagResourceOutput, error)); ok {
+		return rf(ctx, params, optFns...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.TagResourceInput, ...func(*dynamodb.Options)) *dynamodb.TagResourceOutput); ok {
+		r0 = rf(ctx, params, optFns...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dynamodb.TagResourceOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *dynamodb.TagResourceInput, ...func(*dynamodb.Options)) error); ok {
+		r1 = rf(ctx, params, optFns...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TableAPI_TagResource_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TagResource'
+type TableAPI_TagResource_Call struct {
+	*mock.Call
+}
+
+// TagResource is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params *dynamodb.TagResourceInput
+//   - optFns ...func(*dynamodb.Options)
+func (_e *TableAPI_Expecter) TagResource(ctx interface{}, params interface{}, optFns ...interface{}) *TableAPI_TagResource_Call {
+	return &TableAPI_TagResource_Call{Call: _e.mock.On("TagResource",
+		append([]interface{}{ctx, params}, optFns...)...)}
+}
+
+func (_c *TableAPI_TagResource_Call) Run(run func(ctx context.Context, params *dynamodb.TagResourceInput, optFns ...func(*dynamodb.Options))) *TableAPI_TagResource_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]func(*dynamodb.Options), len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(func(*dynamodb.Options))
+			}
+		}
+		run(args[0].(context.Context), args[1].(*dynamodb.TagResourceInput), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *TableAPI_TagResource_Call) Return(_a0 *dynamodb.TagResourceOutput, _a1 error) *TableAPI_TagResource_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *TableAPI_TagResource_Call) RunAndReturn(run func(context.Context, *dynamodb.TagResourceInput, ...func(*dynamodb.Options)) (*dynamodb.TagResourceOutput, error)) *TableAPI_TagResource_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateTable provides a mock function with given fields: ctx, params, optFns
+func (_m *TableAPI) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	var tmpRet mock.Arguments
+	if len(optFns) > 0 {
+		tmpRet = _m.Called(ctx, params, optFns)
+	} else {
+		tmpRet = _m.Called(ctx, params)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateTable")
+	}
+
+	var r0 *dynamodb.UpdateTableOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.UpdateTableInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)); ok {
+		return rf(ctx, params, optFns...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.UpdateTableInput, ...func(*dynamodb.Options)) *dynamodb.UpdateTableOutput); ok {
+		r0 = rf(ctx, params, optFns...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dynamodb.UpdateTableOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *dynamodb.UpdateTableInput, ...func(*dynamodb.Options)) error); ok {
+		r1 = rf(ctx, params, optFns...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TableAPI_UpdateTable_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateTable'
+type TableAPI_UpdateTable_Call struct {
+	*mock.Call
+}
+
+// UpdateTable is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params *dynamodb.UpdateTableInput
+//   - optFns ...func(*dynamodb.Options)
+func (_e *TableAPI_Expecter) UpdateTable(ctx interface{}, params interface{}, optFns ...interface{}) *TableAPI_UpdateTable_Call {
+	return &TableAPI_UpdateTable_Call{Call: _e.mock.On("UpdateTable",
+		append([]interface{}{ctx, params}, optFns...)...)}
+}
+
+func (_c *TableAPI_UpdateTable_Call) Run(run func(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options))) *TableAPI_UpdateTable_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]func(*dynamodb.Options), len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(func(*dynamodb.Options))
+			}
+		}
+		run(args[0].(context.Context), args[1].(*dynamodb.UpdateTableInput), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *TableAPI_UpdateTable_Call) Return(_a0 *dynamodb.UpdateTableOutput, _a1 error) *TableAPI_UpdateTable_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *TableAPI_UpdateTable_Call) RunAndReturn(run func(context.Context, *dynamodb.UpdateTableInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)) *TableAPI_UpdateTable_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewTableAPI creates a new instance of TableAPI. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewTableAPI(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *TableAPI {
+	mock := &TableAPI{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,184 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	dynamodb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// Tagger is an autogenerated mock type for the Tagger type
+type Tagger struct {
+	mock.Mock
+}
+
+type Tagger_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *Tagger) EXPECT() *Tagger_Expecter {
+	return &Tagger_Expecter{mock: &_m.Mock}
+}
+
+// ListTagsOfResource provides a mock function with given fields: ctx, params, optFns
+func (_m *Tagger) ListTagsOfResource(ctx context.Context, params *dynamodb.ListTagsOfResourceInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error) {
+	var tmpRet mock.Arguments
+	if len(optFns) > 0 {
+		tmpRet = _m.Called(ctx, params, optFns)
+	} else {
+		tmpRet = _m.Called(ctx, params)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTagsOfResource")
+	}
+
+	var r0 *dynamodb.ListTagsOfResourceOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.ListTagsOfResourceInput, ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error)); ok {
+		return rf(ctx, params, optFns...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.ListTagsOfResourceInput, ...func(*dynamodb.Options)) *dynamodb.ListTagsOfResourceOutput); ok {
+		r0 = rf(ctx, params, optFns...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dynamodb.ListTagsOfResourceOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *dynamodb.ListTagsOfResourceInput, ...func(*dynamodb.Options)) error); ok {
+		r1 = rf(ctx, params, optFns...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Tagger_ListTagsOfResource_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListTagsOfResource'
+type Tagger_ListTagsOfResource_Call struct {
+	*mock.Call
+}
+
+// ListTagsOfResource is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params *dynamodb.ListTagsOfResourceInput
+//   - optFns ...func(*dynamodb.Options)
+func (_e *Tagger_Expecter) ListTagsOfResource(ctx interface{}, params interface{}, optFns ...interface{}) *Tagger_ListTagsOfResource_Call {
+	return &Tagger_ListTagsOfResource_Call{Call: _e.mock.On("ListTagsOfResource",
+		append([]interface{}{ctx, params}, optFns...)...)}
+}
+
+func (_c *Tagger_ListTagsOfResource_Call) Run(run func(ctx context.Context, params *dynamodb.ListTagsOfResourceInput, optFns ...func(*dynamodb.Options))) *Tagger_ListTagsOfResource_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]func(*dynamodb.Options), len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(func(*dynamodb.Options))
+			}
+		}
+		run(args[0].(context.Context), args[1].(*dynamodb.ListTagsOfResourceInput), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *Tagger_ListTagsOfResource_Call) Return(_a0 *dynamodb.ListTagsOfResourceOutput, _a1 error) *Tagger_ListTagsOfResource_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Tagger_ListTagsOfResource_Call) RunAndReturn(run func(context.Context, *dynamodb.ListTagsOfResourceInput, ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error)) *Tagger_ListTagsOfResource_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// TagResource provides a mock function with given fields: ctx, params, optFns
+func (_m *Tagger) TagResource(ctx context.Context, params *dynamodb.TagResourceInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TagResourceOutput, error) {
+	var tmpRet mock.Arguments
+	if len(optFns) > 0 {
+		tmpRet = _m.Called(ctx, params, optFns)
+	} else {
+		tmpRet = _m.Called(ctx, params)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for TagResource")
+	}
+
+	var r0 *dynamodb.TagResourceOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.TagResourceInput, ...func(*dynamodb.Options)) (*dynamodb.TagResourceOutput, error)); ok {
+		return rf(ctx, params, optFns...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *dynamodb.TagResourceInput, ...func(*dynamodb.Options)) *dynamodb.TagResourceOutput); ok {
+		r0 = rf(ctx, params, optFns...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dynamodb.TagResourceOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *dynamodb.TagResourceInput, ...func(*dynamodb.Options)) error); ok {
+		r1 = rf(ctx, params, optFns...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Tagger_TagResource_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TagResource'
+type Tagger_TagResource_Call struct {
+	*mock.Call
+}
+
+// TagResource is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params *dynamodb.TagResourceInput
+//   - optFns ...func(*dynamodb.Options)
+func (_e *Tagger_Expecter) TagResource(ctx interface{}, params interface{}, optFns ...interface{}) *Tagger_TagResource_Call {
+	return &Tagger_TagResource_Call{Call: _e.mock.On("TagResource",
+		append([]interface{}{ctx, params}, optFns...)...)}
+}
+
+func (_c *Tagger_TagResource_Call) Run(run func(ctx context.Context, params *dynamodb.TagResourceInput, optFns ...func(*dynamodb.Options))) *Tagger_TagResource_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := make([]func(*dynamodb.Options), len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(func(*dynamodb.Options))
+			}
+		}
+		run(args[0].(context.Context), args[1].(*dynamodb.TagResourceInput), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *Tagger_TagResource_Call) Return(_a0 *dynamodb.TagResourceOutput, _a1 error) *Tagger_TagResource_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Tagger_TagResource_Call) RunAndReturn(run func(context.Context, *dynamodb.TagResourceInput, ...func(*dynamodb.Options)) (*dynamodb.TagResourceOutput, error)) *Tagger_TagResource_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewTagger creates a new instance of Tagger. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewTagger(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Tagger {
+	mock := &Tagger{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,349 @@
+package dynamodbfake
+
+import (
+	"fmt"
+)
+
+const (
+	maxBatchGet      = 100
+	maxBatchWrite    = 25
+	maxTransactItems = 100
+)
+
+type keysAndAttributes struct {
+	expressions
+	legacy
+	Keys                 []item `json:"Keys"`
+	ProjectionExpression string `json:"ProjectionExpression"`
+	ConsistentRead       bool   `json:"ConsistentRead"`
+}
+
+type batchGetItemInput struct {
+	RequestItems           map[string]keysAndAttributes `json:"RequestItems"`
+	ReturnConsumedCapacity string                       `json:"ReturnConsumedCapacity"`
+}
+
+type writeRequest struct {
+	PutRequest *struct {
+		Item item `json:"Item"`
+	} `json:"PutRequest"`
+	DeleteRequest *struct {
+		Key item `json:"Key"`
+	} `json:"DeleteRequest"`
+}
+
+type batchWriteItemInput struct {
+	RequestItems           map[string][]writeRequest `json:"RequestItems"`
+	ReturnConsumedCapacity string                    `json:"ReturnConsumedCapacity"`
+}
+
+func (f *fakeImpl) batchGetItem(in *batchGetItemInput) (any, error) {
+	var total int
+	for _, request := range in.RequestItems {
+		total += len(request.Keys)
+	}
+	if total == 0 || total > maxBatchGet {
+		return nil, validationError(fmt.Sprintf("Too many items requested for the BatchGetItem call, at most %d keys are allowed", maxBatchGet))
+	}
+
+	responses := map[string][]item{}
+	var capacity []map[string]any
+	for name, request := range in.RequestItems {
+		if err := request.legacy.check(); err != nil {
+			return nil, err
+		}
+
+		t, err := f.table(name)
+		if err != nil {
+			return nil, err
+		}
+
+		paths, p, err := parseProjection(request.ProjectionExpression, request.ExpressionAttributeNames)
+		if err != nil {
+			return nil, err
+		}
+		if err = request.checkUsed(p); err != nil {
+			return nil, err
+		}
+
+		seen := map[string]bool{}
+		items := []item{}
+		var size int
+		for _, k := range request.Keys {
+			key, err := t.primaryKey(k)
+			if err != nil {
+				return nil, err
+			}
+			if id := t.key.id(key); seen[id] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			} else {
+				seen[id] = true
+			}
+
+			if current := t.get(key); current != nil {
+				size += itemSize(current)
+				items = append(items, project(current, paths))
+			}
+		}
+		responses[name] = items
+		capacity = append(capacity, map[string]any{"TableName": name, "CapacityUnits": readUnits(size, request.ConsistentRead)})
+	}
+
+	out := map[string]any{"Responses": responses, "UnprocessedKeys": map[string]any{}}
+	if in.ReturnConsumedCapacity != "" && in.ReturnConsumedCapacity != "NONE" {
+		out["ConsumedCapacity"] = capacity
+	}
+	return out, nil
+}
+
+func (f *fakeImpl) batchWriteItem(in *batchWriteItemInput) (any, error) {
+	var total int
+	for _, requests := range in.RequestItems {
+		total += len(requests)
+	}
+	if total == 0 || total > maxBatchWrite {
+		return nil, validationError(fmt.Sprintf("1 validation error detected: Value at 'requestItems' failed to satisfy constraint: Member must have length less than or equal to %d", maxBatchWrite))
+	}
+
+	// validate everything first, a batch with an invalid request writes
+	// nothing
+	type write struct {
+		table *table
+		put   item
+		key   item
+	}
+	var writes []write
+	for name, requests := range in.RequestItems {
+		t, err := f.table(name)
+		if err != nil {
+			return nil, err
+		}
+
+		seen := map[string]bool{}
+		for _, request := range requests {
+			w := write{table: t}
+			switch {
+			case request.PutRequest != nil && request.DeleteRequest == nil:
+				if err = t.validateItem(request.PutRequest.Item); err != nil {
+					return nil, err
+				}
+				w.put = request.PutRequest.Item.clone()
+				w.key, _ = t.key.key(w.put)
+			case request.DeleteRequest != nil && request.PutRequest == nil:
+				if w.key, err = t.primaryKey(request.DeleteRequest.Key); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, validationError("Supplied WriteRequest must contain exactly one of PutRequest or DeleteRequest")
+			}
+
+			if id := t.key.id(w.key); seen[id] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			} else {
+				seen[id] = true
+			}
+			writes = append(writes, w)
+		}
+	}
+
+	capacity := map[string]float64{}
+	for _, w := range writes {
+		if w.put != nil {
+			w.table.put(w.put)
+			capacity[w.table.name] += writeUnits(itemSize(w.put))
+			continue
+		}
+		w.table.delete(w.key)
+		capacity[w.table.name]++
+	}
+
+	out := map[string]any{"UnprocessedItems": map[string]any{}}
+	if in.ReturnConsumedCapacity != "" && in.ReturnConsumedCapacity != "NONE" {
+		var consumed []map[string]any
+		for name, units := range capacity {
+			consumed = append(consumed, map[string]any{"TableName": name, "CapacityUnits": units})
+		}
+		out["ConsumedCapacity"] = consumed
+	}
+	return out, nil
+}
+
+type transactAction struct {
+	expressions
+	TableName                           string `json:"TableName"`
+	Key                                 item   `json:"Key"`
+	Item                                item   `json:"Item"`
+	ConditionExpression                 string `json:"ConditionExpression"`
+	UpdateExpression                    string `json:"UpdateExpression"`
+	ProjectionExpression                string `json:"ProjectionExpression"`
+	ReturnValuesOnConditionCheckFailure string `json:"ReturnValuesOnConditionCheckFailure"`
+}
+
+type transactWriteItem struct {
+	ConditionCheck *transactAction `json:"ConditionCheck"`
+	Put            *transactAction `json:"Put"`
+	Delete         *transactAction `json:"Delete"`
+	Update         *transactAction `json:"Update"`
+}
+
+type transactWriteItemsInput struct {
+	TransactItems          []transactWriteItem `json:"TransactItems"`
+	ReturnConsumedCapacity string              `json:"ReturnConsumedCapacity"`
+}
+
+type transactGetItemsInput struct {
+	TransactItems []struct {
+		Get *transactAction `json:"Get"`
+	} `json:"TransactItems"`
+	ReturnConsumedCapacity string `json:"ReturnConsumedCapacity"`
+}
+
+// transactWriteItems checks every condition against the current state and
+// computes the new items before writing any of them, so a failure leaves
+// the tables untouched.
+func (f *fakeImpl) transactWriteItems(in *transactWriteItemsInput) (any, error) {
+	if len(in.TransactItems) == 0 || len(in.TransactItems) > maxTransactItems {
+		return nil, validationError(fmt.Sprintf("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: Member must have length less than or equal to %d", maxTransactItems))
+	}
+
+	type write struct {
+		table  *table
+		key    item
+		result item // nil deletes
+		check  bool
+	}
+
+	writes := make([]write, len(in.TransactItems))
+	reasons := make([]cancellationReason, len(in.TransactItems))
+	seen := map[string]bool{}
+	var failed bool
+
+	for i, ti := range in.TransactItems {
+		action, kind, err := ti.action()
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := f.table(action.TableName)
+		if err != nil {
+			return nil, err
+		}
+
+		var key item
+		if kind == "Put" {
+			if err = t.validateItem(action.Item); err != nil {
+				return nil, err
+			}
+			key, _ = t.key.key(action.Item)
+		} else if key, err = t.primaryKey(action.Key); err != nil {
+			return nil, err
+		}
+
+		id := t.name + "/" + t.key.id(key)
+		if seen[id] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		}
+		seen[id] = true
+
+		current := t.get(key)
+		w := write{table: t, key: key}
+		switch kind {
+		case "ConditionCheck":
+			if action.ConditionExpression == "" {
+				return nil, validationError("The expression can not be empty; ConditionCheck requires a ConditionExpression")
+			}
+			w.check = true
+			err = checkCondition(action.ConditionExpression, action.expressions, current, action.ReturnValuesOnConditionCheckFailure)
+		case "Put":
+			w.result = action.Item.clone()
+			err = checkCondition(action.ConditionExpression, action.expressions, current, action.ReturnValuesOnConditionCheckFailure)
+		case "Delete":
+			err = checkCondition(action.ConditionExpression, action.expressions, current, action.ReturnValuesOnConditionCheckFailure)
+		case "Update":
+			w.result, _, err = prepareUpdate(t, key, current, action.UpdateExpression, action.ConditionExpression, action.expressions, action.ReturnValuesOnConditionCheckFailure)
+		}
+
+		reasons[i] = cancellationReason{Code: "None"}
+		if err != nil {
+			apiErr, ok := err.(*apiError)
+			if !ok || apiErr.code != "ConditionalCheckFailedException" {
+				return nil, err
+			}
+			failed = true
+			reasons[i] = cancellationReason{Code: "ConditionalCheckFailed", Message: apiErr.message}
+			if old, ok := apiErr.fields["Item"].(item); ok {
+				reasons[i].Item = old
+			}
+		}
+		writes[i] = w
+	}
+
+	if failed {
+		return nil, transactionCanceled(reasons)
+	}
+
+	for _, w := range writes {
+		switch {
+		case w.check:
+		case w.result == nil:
+			w.table.delete(w.key)
+		default:
+			w.table.put(w.result)
+		}
+	}
+
+	return map[string]any{}, nil
+}
+
+func (ti transactWriteItem) action() (*transactAction, string, error) {
+	var action *transactAction
+	var kind string
+	var n int
+	for name, a := range map[string]*transactAction{"ConditionCheck": ti.ConditionCheck, "Put": ti.Put, "Delete": ti.Delete, "Update": ti.Update} {
+		if a != nil {
+			action, kind = a, name
+			n++
+		}
+	}
+	if n != 1 {
+		return nil, "", validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+	return action, kind, nil
+}
+
+func (f *fakeImpl) transactGetItems(in *transactGetItemsInput) (any, error) {
+	if len(in.TransactItems) == 0 || len(in.TransactItems) > maxTransactItems {
+		return nil, validationError(fmt.Sprintf("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: Member must have length less than or equal to %d", maxTransactItems))
+	}
+
+	responses := make([]map[string]any, len(in.TransactItems))
+	for i, ti := range in.TransactItems {
+		if ti.Get == nil {
+			return nil, validationError("TransactItems can only contain Get")
+		}
+
+		t, err := f.table(ti.Get.TableName)
+		if err != nil {
+			return nil, err
+		}
+		key, err := t.primaryKey(ti.Get.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		paths, p, err := parseProjection(ti.Get.ProjectionExpression, ti.Get.ExpressionAttributeNames)
+		if err != nil {
+			return nil, err
+		}
+		if err = ti.Get.checkUsed(p); err != nil {
+			return nil, err
+		}
+
+		responses[i] = map[string]any{}
+		if current := t.get(key); current != nil {
+			responses[i]["Item"] = project(current, paths)
+		}
+	}
+
+	return map[string]any{"Responses": responses}, nil
+}
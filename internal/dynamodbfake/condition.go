@@ -0,0 +1,366 @@
+package dynamodbfake
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// condition is a parsed ConditionExpression, FilterExpression or
+// KeyConditionExpression.
+type condition interface {
+	eval(it item) bool
+}
+
+// operand is a path, a value placeholder or size(path).
+type operand interface {
+	resolve(it item) (value, bool)
+}
+
+type pathOperand struct{ path path }
+
+func (o pathOperand) resolve(it item) (value, bool) { return o.path.get(it) }
+
+type valueOperand struct{ value value }
+
+func (o valueOperand) resolve(item) (value, bool) { return o.value, true }
+
+type sizeOperand struct{ path path }
+
+func (o sizeOperand) resolve(it item) (value, bool) {
+	v, ok := o.path.get(it)
+	if !ok {
+		return value{}, false
+	}
+	n, ok := size(v)
+	if !ok {
+		return value{}, false
+	}
+	return value{kind: typeN, str: fmt.Sprint(n)}, true
+}
+
+type andCondition struct{ left, right condition }
+
+func (c andCondition) eval(it item) bool { return c.left.eval(it) && c.right.eval(it) }
+
+type orCondition struct{ left, right condition }
+
+func (c orCondition) eval(it item) bool { return c.left.eval(it) || c.right.eval(it) }
+
+type notCondition struct{ inner condition }
+
+func (c notCondition) eval(it item) bool { return !c.inner.eval(it) }
+
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+func (c comparison) eval(it item) bool {
+	l, lok := c.left.resolve(it)
+	r, rok := c.right.resolve(it)
+	if !lok || !rok {
+		// a missing attribute is different from anything
+		return c.op == "<>"
+	}
+
+	switch c.op {
+	case "=":
+		return equal(l, r)
+	case "<>":
+		return !equal(l, r)
+	}
+
+	n, ok := compare(l, r)
+	if !ok {
+		return false
+	}
+
+	switch c.op {
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	case ">":
+		return n > 0
+	default:
+		return n >= 0
+	}
+}
+
+type between struct{ operand, low, high operand }
+
+func (c between) eval(it item) bool {
+	v, ok := c.operand.resolve(it)
+	low, lok := c.low.resolve(it)
+	high, hok := c.high.resolve(it)
+	if !ok || !lok || !hok {
+		return false
+	}
+
+	a, aok := compare(v, low)
+	b, bok := compare(v, high)
+	return aok && bok && a >= 0 && b <= 0
+}
+
+type in struct {
+	operand operand
+	list    []operand
+}
+
+func (c in) eval(it item) bool {
+	v, ok := c.operand.resolve(it)
+	if !ok {
+		return false
+	}
+	for _, candidate := range c.list {
+		if cv, ok := candidate.resolve(it); ok && equal(v, cv) {
+			return true
+		}
+	}
+	return false
+}
+
+type function struct {
+	name string
+	path path
+	arg  operand
+}
+
+func (c function) eval(it item) bool {
+	v, ok := c.path.get(it)
+	switch c.name {
+	case "attribute_exists":
+		return ok
+	case "attribute_not_exists":
+		return !ok
+	}
+
+	if !ok {
+		return false
+	}
+
+	arg, aok := c.arg.resolve(it)
+	if !aok {
+		return false
+	}
+
+	switch c.name {
+	case "attribute_type":
+		return arg.kind == typeS && v.kind == arg.str
+	case "begins_with":
+		switch {
+		case v.kind == typeS && arg.kind == typeS:
+			return strings.HasPrefix(v.str, arg.str)
+		case v.kind == typeB && arg.kind == typeB:
+			return bytes.HasPrefix(v.bin, arg.bin)
+		}
+		return false
+	default: // contains
+		switch v.kind {
+		case typeS:
+			return arg.kind == typeS && strings.Contains(v.str, arg.str)
+		case typeSS:
+			return arg.kind == typeS && containsMember(v.strs, arg.str, false)
+		case typeNS:
+			return arg.kind == typeN && containsMember(v.strs, arg.str, true)
+		case typeBS:
+			return arg.kind == typeB && containsBinary(v.bins, arg.bin)
+		case typeL:
+			for _, e := range v.l {
+				if equal(e, arg) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// parseCondition parses expression, an empty expression returns nil.
+func parseCondition(expression string, names map[string]string, values map[string]value) (condition, *parser, error) {
+	if len(strings.TrimSpace(expression)) == 0 {
+		return nil, nil, nil
+	}
+
+	p, err := newParser(expression, names, values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := p.or()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, p, p.done()
+}
+
+func (p *parser) or() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (condition, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		inner, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{inner: inner}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (condition, error) {
+	if p.isSymbol("(") {
+		p.next()
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	}
+
+	if t := p.peek(); t.kind == tokenIdent && p.tokens[p.pos+1].text == "(" {
+		name := strings.ToLower(t.text)
+		switch name {
+		case "attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains":
+			return p.function(name)
+		}
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.syntaxError()
+		}
+		p.next()
+		high, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return between{operand: left, low: low, high: high}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if err = p.expect("("); err != nil {
+			return nil, err
+		}
+		var list []operand
+		for {
+			o, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, o)
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+		return in{operand: left, list: list}, p.expect(")")
+	}
+
+	t := p.peek()
+	switch t.text {
+	case "=", "<>", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{op: t.text, left: left, right: right}, nil
+	default:
+		return nil, p.syntaxError()
+	}
+}
+
+func (p *parser) function(name string) (condition, error) {
+	p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	target, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+
+	fn := function{name: name, path: target}
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+		if fn.arg, err = p.operand(); err != nil {
+			return nil, err
+		}
+	}
+
+	return fn, p.expect(")")
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenValue:
+		p.next()
+		v, err := p.value(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return valueOperand{value: v}, nil
+	case t.kind == tokenIdent && strings.EqualFold(t.text, "size") && p.tokens[p.pos+1].text == "(":
+		p.next()
+		p.next()
+		target, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return sizeOperand{path: target}, p.expect(")")
+	default:
+		target, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{path: target}, nil
+	}
+}
@@ -0,0 +1,77 @@
+package dynamodbfake
+
+import (
+	"net/http"
+)
+
+const errorPrefix = "com.amazonaws.dynamodb.v20120810#"
+
+// apiError is written as the JSON error document of DynamoDB, which the SDK
+// turns into the typed errors of the types package.
+type apiError struct {
+	code    string
+	message string
+	status  int
+	fields  map[string]any
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func (e *apiError) body() map[string]any {
+	body := map[string]any{"__type": errorPrefix + e.code, "message": e.message}
+	for k, v := range e.fields {
+		body[k] = v
+	}
+	return body
+}
+
+func validationError(message string) error {
+	return &apiError{code: "ValidationException", message: message, status: http.StatusBadRequest}
+}
+
+func resourceNotFound() error {
+	return &apiError{code: "ResourceNotFoundException", message: "Requested resource not found", status: http.StatusBadRequest}
+}
+
+func resourceInUse(message string) error {
+	return &apiError{code: "ResourceInUseException", message: message, status: http.StatusBadRequest}
+}
+
+func conditionalCheckFailed(old item) error {
+	err := &apiError{code: "ConditionalCheckFailedException", message: "The conditional request failed", status: http.StatusBadRequest}
+	if old != nil {
+		err.fields = map[string]any{"Item": old}
+	}
+	return err
+}
+
+// cancellationReason is one entry of TransactionCanceledException, Code is
+// "None" for the items that did not fail.
+type cancellationReason struct {
+	Code    string `json:"Code"`
+	Message string `json:"Message,omitempty"`
+	Item    item   `json:"Item,omitempty"`
+}
+
+func transactionCanceled(reasons []cancellationReason) error {
+	codes := ""
+	for i, reason := range reasons {
+		if i > 0 {
+			codes += ", "
+		}
+		codes += reason.Code
+	}
+
+	return &apiError{
+		code:    "TransactionCanceledException",
+		message: "Transaction cancelled, please refer cancellation reasons for specific reasons [" + codes + "]",
+		status:  http.StatusBadRequest,
+		fields:  map[string]any{"CancellationReasons": reasons},
+	}
+}
+
+func unknownOperation(operation string) error {
+	return &apiError{code: "UnknownOperationException", message: "Operation " + operation + " is not supported by the fake", status: http.StatusBadRequest}
+}
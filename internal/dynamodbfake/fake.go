@@ -0,0 +1,149 @@
+// Package dynamodbfake is an in-memory implementation of the DynamoDB JSON
+// protocol, for tests and local runs that should not need Docker.
+//
+// It keeps tables, items and secondary indexes in memory and supports the
+// expressions of the service: key conditions, filters, projections,
+// conditions and updates. The legacy parameters (Expected, KeyConditions,
+// AttributeUpdates...) are rejected. Capacity is never throttled.
+package dynamodbfake
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const targetPrefix = "DynamoDB_20120810."
+
+// Endpoint is the base endpoint of the clients returned by Client, requests
+// never leave the process.
+const Endpoint = "http://dynamodbfake"
+
+type Fake interface {
+	// ServeHTTP answers the requests of the SDK, so the fake can also run
+	// behind httptest.NewServer or a local listener.
+	http.Handler
+	// Client returns an SDK client calling the fake without a network.
+	Client() *dynamodb.Client
+	// Reset drops every table.
+	Reset()
+}
+
+type fakeImpl struct {
+	mu     sync.Mutex
+	tables map[string]*table
+	now    func() time.Time
+}
+
+func New() Fake {
+	return &fakeImpl{tables: map[string]*table{}, now: time.Now}
+}
+
+type operation func(f *fakeImpl, body []byte) (any, error)
+
+// handle decodes the body into the input of fn.
+func handle[T any](fn func(f *fakeImpl, in *T) (any, error)) operation {
+	return func(f *fakeImpl, body []byte) (any, error) {
+		in := new(T)
+		if err := json.Unmarshal(body, in); err != nil {
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				return nil, apiErr
+			}
+			return nil, &apiError{code: "SerializationException", message: err.Error(), status: http.StatusBadRequest}
+		}
+		return fn(f, in)
+	}
+}
+
+var operations = map[string]operation{
+	"CreateTable":        handle((*fakeImpl).createTable),
+	"DescribeTable":      handle((*fakeImpl).describeTable),
+	"DeleteTable":        handle((*fakeImpl).deleteTable),
+	"ListTables":         handle((*fakeImpl).listTables),
+	"UpdateTable":        handle((*fakeImpl).updateTable),
+	"ListTagsOfResource": handle((*fakeImpl).listTagsOfResource),
+	"TagResource":        handle((*fakeImpl).tagResource),
+	"UpdateTimeToLive":   handle((*fakeImpl).updateTimeToLive),
+	"DescribeTimeToLive": handle((*fakeImpl).describeTimeToLive),
+	"UntagResource":      handle((*fakeImpl).untagResource),
+	"GetItem":            handle((*fakeImpl).getItem),
+	"PutItem":            handle((*fakeImpl).putItem),
+	"DeleteItem":         handle((*fakeImpl).deleteItem),
+	"UpdateItem":         handle((*fakeImpl).updateItem),
+	"Query":              handle((*fakeImpl).query),
+	"Scan":               handle((*fakeImpl).scan),
+	"BatchGetItem":       handle((*fakeImpl).batchGetItem),
+	"BatchWriteItem":     handle((*fakeImpl).batchWriteItem),
+	"TransactWriteItems": handle((*fakeImpl).transactWriteItems),
+	"TransactGetItems":   handle((*fakeImpl).transactGetItems),
+}
+
+func (f *fakeImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	name := strings.TrimPrefix(target, targetPrefix)
+
+	var out any
+	var err error
+	op, ok := operations[name]
+	if !strings.HasPrefix(target, targetPrefix) || !ok {
+		err = unknownOperation(name)
+	} else {
+		var body []byte
+		if body, err = io.ReadAll(r.Body); err == nil {
+			f.mu.Lock()
+			out, err = op(f, body)
+			f.mu.Unlock()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if err != nil {
+		apiErr, ok := err.(*apiError)
+		if !ok {
+			apiErr = &apiError{code: "InternalServerError", message: err.Error(), status: http.StatusInternalServerError}
+		}
+		w.WriteHeader(apiErr.status)
+		_ = json.NewEncoder(w).Encode(apiErr.body())
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func (f *fakeImpl) Client() *dynamodb.Client {
+	return dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(Endpoint),
+		Credentials:  aws.AnonymousCredentials{},
+		HTTPClient:   handlerClient{handler: f},
+	})
+}
+
+func (f *fakeImpl) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tables = map[string]*table{}
+}
+
+// handlerClient serves the requests of the SDK with a handler in process.
+type handlerClient struct {
+	handler http.Handler
+}
+
+func (c handlerClient) Do(r *http.Request) (*http.Response, error) {
+	if err := r.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
@@ -0,0 +1,344 @@
+package dynamodbfake_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbfake"
+	"net/http/httptest"
+	"testing"
+)
+
+const tableName = "users"
+
+func s(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+func n(v string) types.AttributeValue { return &types.AttributeValueMemberN{Value: v} }
+
+// isValidation matches ValidationException, which has no type in the SDK.
+func isValidation(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException"
+}
+
+func newClient(t *testing.T) *dynamodb.Client {
+	t.Helper()
+
+	client := dynamodbfake.New().Client()
+	_, err := client.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("Id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("CreatedAt"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("Email"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("Id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("CreatedAt"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName:  aws.String("by-email"),
+			KeySchema:  []types.KeySchemaElement{{AttributeName: aws.String("Email"), KeyType: types.KeyTypeHash}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+		}},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	return client
+}
+
+func put(t *testing.T, client *dynamodb.Client, id, createdAt string, extra map[string]types.AttributeValue) {
+	t.Helper()
+
+	it := map[string]types.AttributeValue{"Id": s(id), "CreatedAt": s(createdAt)}
+	for k, v := range extra {
+		it[k] = v
+	}
+	if _, err := client.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: it}); err != nil {
+		t.Fatalf("put %s: %v", id, err)
+	}
+}
+
+func TestTableLifecycle(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String("Id"), AttributeType: types.ScalarAttributeTypeS}},
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String("Id"), KeyType: types.KeyTypeHash}},
+		BillingMode:          types.BillingModePayPerRequest,
+	})
+	var inUse *types.ResourceInUseException
+	if !errors.As(err, &inUse) {
+		t.Fatalf("duplicate table: expected ResourceInUseException, got %v", err)
+	}
+
+	out, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		t.Fatalf("describe: %v", err)
+	}
+	if out.Table.TableStatus != types.TableStatusActive || len(out.Table.KeySchema) != 2 || len(out.Table.GlobalSecondaryIndexes) != 1 {
+		t.Fatalf("unexpected description %+v", out.Table)
+	}
+	if out.Table.BillingModeSummary.BillingMode != types.BillingModePayPerRequest {
+		t.Fatalf("billing mode = %s", out.Table.BillingModeSummary.BillingMode)
+	}
+
+	_, err = client.TagResource(ctx, &dynamodb.TagResourceInput{ResourceArn: out.Table.TableArn, Tags: []types.Tag{{Key: aws.String("OWNER"), Value: aws.String("me")}}})
+	if err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+	tags, err := client.ListTagsOfResource(ctx, &dynamodb.ListTagsOfResourceInput{ResourceArn: out.Table.TableArn})
+	if err != nil || len(tags.Tags) != 1 {
+		t.Fatalf("tags = %v, %v", tags, err)
+	}
+
+	enable := &dynamodb.UpdateTimeToLiveInput{
+		TableName:               aws.String(tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{AttributeName: aws.String("ExpiresAt"), Enabled: aws.Bool(true)},
+	}
+	if _, err = client.UpdateTimeToLive(ctx, enable); err != nil {
+		t.Fatalf("enable ttl: %v", err)
+	}
+	if _, err = client.UpdateTimeToLive(ctx, enable); !isValidation(err) {
+		t.Fatalf("enabling twice: expected ValidationException, got %v", err)
+	}
+	ttl, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)})
+	if err != nil {
+		t.Fatalf("describe ttl: %v", err)
+	}
+	if ttl.TimeToLiveDescription.TimeToLiveStatus != types.TimeToLiveStatusEnabled || aws.ToString(ttl.TimeToLiveDescription.AttributeName) != "ExpiresAt" {
+		t.Fatalf("unexpected ttl %+v", ttl.TimeToLiveDescription)
+	}
+
+	if _, err = client.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(tableName)}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	_, err = client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected ResourceNotFoundException, got %v", err)
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+	put(t, client, "1", "2024", map[string]types.AttributeValue{"Name": s("ada")})
+
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                           aws.String(tableName),
+		Item:                                map[string]types.AttributeValue{"Id": s("1"), "CreatedAt": s("2024")},
+		ConditionExpression:                 aws.String("attribute_not_exists(Id)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var failed *types.ConditionalCheckFailedException
+	if !errors.As(err, &failed) {
+		t.Fatalf("expected ConditionalCheckFailedException, got %v", err)
+	}
+	if failed.Item["Name"].(*types.AttributeValueMemberS).Value != "ada" {
+		t.Fatalf("old item = %v", failed.Item)
+	}
+
+	_, err = client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(tableName), Key: map[string]types.AttributeValue{"Id": s("1")}})
+	if !isValidation(err) {
+		t.Fatalf("partial key: expected ValidationException, got %v", err)
+	}
+
+	_, err = client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(tableName),
+		Key:                       map[string]types.AttributeValue{"Id": s("1"), "CreatedAt": s("2024")},
+		ConditionExpression:       aws.String("#n = :n"),
+		ExpressionAttributeNames:  map[string]string{"#n": "Name"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":n": s("ada")},
+	})
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+}
+
+func TestUpdateItem(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+	put(t, client, "1", "2024", map[string]types.AttributeValue{
+		"Age":  n("30"),
+		"Tags": &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"List": &types.AttributeValueMemberL{Value: []types.AttributeValue{s("x")}},
+	})
+
+	out, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(tableName),
+		Key:              map[string]types.AttributeValue{"Id": s("1"), "CreatedAt": s("2024")},
+		UpdateExpression: aws.String("SET Age = Age + :one, List = list_append(List, :more), Nick = if_not_exists(Nick, :nick) REMOVE Gone ADD Visits :one DELETE Tags :a"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":  n("1"),
+			":more": &types.AttributeValueMemberL{Value: []types.AttributeValue{s("y")}},
+			":nick": s("ace"),
+			":a":    &types.AttributeValueMemberSS{Value: []string{"a"}},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	attrs := out.Attributes
+	if attrs["Age"].(*types.AttributeValueMemberN).Value != "31" || attrs["Visits"].(*types.AttributeValueMemberN).Value != "1" {
+		t.Fatalf("numbers = %v %v", attrs["Age"], attrs["Visits"])
+	}
+	if l := attrs["List"].(*types.AttributeValueMemberL).Value; len(l) != 2 {
+		t.Fatalf("list = %v", l)
+	}
+	if tags := attrs["Tags"].(*types.AttributeValueMemberSS).Value; len(tags) != 1 || tags[0] != "b" {
+		t.Fatalf("tags = %v", tags)
+	}
+	if attrs["Nick"].(*types.AttributeValueMemberS).Value != "ace" {
+		t.Fatalf("nick = %v", attrs["Nick"])
+	}
+
+	_, err = client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(tableName),
+		Key:                       map[string]types.AttributeValue{"Id": s("1"), "CreatedAt": s("2024")},
+		UpdateExpression:          aws.String("SET Age = :age"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":age": n("1"), ":unused": n("2")},
+	})
+	if !isValidation(err) {
+		t.Fatalf("unused value: expected ValidationException, got %v", err)
+	}
+}
+
+func TestQueryAndScan(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+	for _, createdAt := range []string{"2024-01", "2024-02", "2024-03", "2024-04"} {
+		put(t, client, "1", createdAt, map[string]types.AttributeValue{"Email": s("a@b.c")})
+	}
+	put(t, client, "2", "2024-01", nil)
+
+	var pages int
+	var ids []string
+	var start map[string]types.AttributeValue
+	for {
+		out, err := client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(tableName),
+			KeyConditionExpression:    aws.String("Id = :id AND CreatedAt > :after"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":id": s("1"), ":after": s("2024-01")},
+			ScanIndexForward:          aws.Bool(false),
+			Limit:                     aws.Int32(2),
+			ExclusiveStartKey:         start,
+		})
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		pages++
+		for _, it := range out.Items {
+			ids = append(ids, it["CreatedAt"].(*types.AttributeValueMemberS).Value)
+		}
+		if start = out.LastEvaluatedKey; start == nil {
+			break
+		}
+	}
+	if pages != 2 || len(ids) != 3 || ids[0] != "2024-04" || ids[2] != "2024-02" {
+		t.Fatalf("pages = %d, ids = %v", pages, ids)
+	}
+
+	byEmail, err := client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(tableName),
+		IndexName:                 aws.String("by-email"),
+		KeyConditionExpression:    aws.String("Email = :e"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":e": s("a@b.c")},
+	})
+	if err != nil || byEmail.Count != 4 {
+		t.Fatalf("index query = %v, %v", byEmail, err)
+	}
+
+	scan, err := client.Scan(ctx, &dynamodb.ScanInput{
+		TableName:                 aws.String(tableName),
+		FilterExpression:          aws.String("attribute_not_exists(Email)"),
+		ExpressionAttributeValues: nil,
+	})
+	if err != nil || scan.Count != 1 || scan.ScannedCount != 5 {
+		t.Fatalf("scan = %+v, %v", scan, err)
+	}
+}
+
+func TestBatchAndTransactions(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	_, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{
+		tableName: {
+			{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{"Id": s("1"), "CreatedAt": s("x")}}},
+			{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{"Id": s("2"), "CreatedAt": s("x")}}},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("batch write: %v", err)
+	}
+
+	got, err := client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: map[string]types.KeysAndAttributes{
+		tableName: {Keys: []map[string]types.AttributeValue{
+			{"Id": s("1"), "CreatedAt": s("x")},
+			{"Id": s("3"), "CreatedAt": s("x")},
+		}},
+	}})
+	if err != nil || len(got.Responses[tableName]) != 1 {
+		t.Fatalf("batch get = %v, %v", got, err)
+	}
+
+	_, err = client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String(tableName), Item: map[string]types.AttributeValue{"Id": s("3"), "CreatedAt": s("x")}}},
+		{ConditionCheck: &types.ConditionCheck{
+			TableName:           aws.String(tableName),
+			Key:                 map[string]types.AttributeValue{"Id": s("1"), "CreatedAt": s("x")},
+			ConditionExpression: aws.String("attribute_not_exists(Id)"),
+		}},
+	}})
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		t.Fatalf("expected TransactionCanceledException, got %v", err)
+	}
+	if len(canceled.CancellationReasons) != 2 || aws.ToString(canceled.CancellationReasons[1].Code) != "ConditionalCheckFailed" {
+		t.Fatalf("reasons = %+v", canceled.CancellationReasons)
+	}
+
+	out, err := client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(tableName), Key: map[string]types.AttributeValue{"Id": s("3"), "CreatedAt": s("x")}})
+	if err != nil || out.Item != nil {
+		t.Fatalf("canceled transaction wrote %v, %v", out, err)
+	}
+
+	_, err = client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String(tableName), Item: map[string]types.AttributeValue{"Id": s("3"), "CreatedAt": s("x")}}},
+		{Delete: &types.Delete{TableName: aws.String(tableName), Key: map[string]types.AttributeValue{"Id": s("1"), "CreatedAt": s("x")}}},
+	}})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+
+	scan, err := client.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String(tableName), Select: types.SelectCount})
+	if err != nil || scan.Count != 2 {
+		t.Fatalf("scan = %+v, %v", scan, err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	fake := dynamodbfake.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+
+	out, err := client.ListTables(context.Background(), &dynamodb.ListTablesInput{})
+	if err != nil || len(out.TableNames) != 0 {
+		t.Fatalf("list tables = %v, %v", out, err)
+	}
+}
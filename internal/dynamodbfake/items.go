@@ -0,0 +1,359 @@
+package dynamodbfake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// expressions holds the substitutions shared by every expression of a
+// request.
+type expressions struct {
+	ExpressionAttributeNames  map[string]string `json:"ExpressionAttributeNames"`
+	ExpressionAttributeValues map[string]value  `json:"ExpressionAttributeValues"`
+}
+
+// checkUsed rejects names and values that no expression of the request
+// refers to, like the service does.
+func (e expressions) checkUsed(parsers ...*parser) error {
+	usedNames, usedValues := map[string]bool{}, map[string]bool{}
+	for _, p := range parsers {
+		if p == nil {
+			continue
+		}
+		for k := range p.usedNames {
+			usedNames[k] = true
+		}
+		for k := range p.usedValues {
+			usedValues[k] = true
+		}
+	}
+
+	if unused := unusedKeys(e.ExpressionAttributeNames, usedNames); len(unused) > 0 {
+		return validationError(fmt.Sprintf("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", strings.Join(unused, ", ")))
+	}
+	if unused := unusedKeys(e.ExpressionAttributeValues, usedValues); len(unused) > 0 {
+		return validationError(fmt.Sprintf("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", strings.Join(unused, ", ")))
+	}
+	return nil
+}
+
+func unusedKeys[V any](provided map[string]V, used map[string]bool) []string {
+	var unused []string
+	for k := range provided {
+		if !used[k] {
+			unused = append(unused, k)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// legacy lists the pre-expression parameters, the fake only speaks
+// expressions.
+type legacy struct {
+	Expected            any `json:"Expected"`
+	AttributesToGet     any `json:"AttributesToGet"`
+	AttributeUpdates    any `json:"AttributeUpdates"`
+	KeyConditions       any `json:"KeyConditions"`
+	QueryFilter         any `json:"QueryFilter"`
+	ScanFilter          any `json:"ScanFilter"`
+	ConditionalOperator any `json:"ConditionalOperator"`
+}
+
+func (l legacy) check() error {
+	for name, v := range map[string]any{
+		"Expected": l.Expected, "AttributesToGet": l.AttributesToGet, "AttributeUpdates": l.AttributeUpdates,
+		"KeyConditions": l.KeyConditions, "QueryFilter": l.QueryFilter, "ScanFilter": l.ScanFilter,
+		"ConditionalOperator": l.ConditionalOperator,
+	} {
+		if v != nil {
+			return validationError(name + " is a legacy parameter not supported by the fake, use expressions instead")
+		}
+	}
+	return nil
+}
+
+type getItemInput struct {
+	expressions
+	legacy
+	TableName              string `json:"TableName"`
+	Key                    item   `json:"Key"`
+	ProjectionExpression   string `json:"ProjectionExpression"`
+	ConsistentRead         bool   `json:"ConsistentRead"`
+	ReturnConsumedCapacity string `json:"ReturnConsumedCapacity"`
+}
+
+type putItemInput struct {
+	expressions
+	legacy
+	TableName                           string `json:"TableName"`
+	Item                                item   `json:"Item"`
+	ConditionExpression                 string `json:"ConditionExpression"`
+	ReturnValues                        string `json:"ReturnValues"`
+	ReturnValuesOnConditionCheckFailure string `json:"ReturnValuesOnConditionCheckFailure"`
+	ReturnConsumedCapacity              string `json:"ReturnConsumedCapacity"`
+}
+
+type deleteItemInput struct {
+	expressions
+	legacy
+	TableName                           string `json:"TableName"`
+	Key                                 item   `json:"Key"`
+	ConditionExpression                 string `json:"ConditionExpression"`
+	ReturnValues                        string `json:"ReturnValues"`
+	ReturnValuesOnConditionCheckFailure string `json:"ReturnValuesOnConditionCheckFailure"`
+	ReturnConsumedCapacity              string `json:"ReturnConsumedCapacity"`
+}
+
+type updateItemInput struct {
+	expressions
+	legacy
+	TableName                           string `json:"TableName"`
+	Key                                 item   `json:"Key"`
+	UpdateExpression                    string `json:"UpdateExpression"`
+	ConditionExpression                 string `json:"ConditionExpression"`
+	ReturnValues                        string `json:"ReturnValues"`
+	ReturnValuesOnConditionCheckFailure string `json:"ReturnValuesOnConditionCheckFailure"`
+	ReturnConsumedCapacity              string `json:"ReturnConsumedCapacity"`
+}
+
+// consumed reports the capacity of a request when asked for, reads and
+// writes count one unit per started 4KB and 1KB.
+func consumed(out map[string]any, mode string, t *table, units float64) {
+	if mode == "" || mode == "NONE" {
+		return
+	}
+	out["ConsumedCapacity"] = map[string]any{"TableName": t.name, "CapacityUnits": units}
+}
+
+func readUnits(size int, consistent bool) float64 {
+	units := float64((size + 4095) / 4096)
+	if units == 0 {
+		units = 1
+	}
+	if !consistent {
+		units /= 2
+	}
+	return units
+}
+
+func writeUnits(size int) float64 {
+	units := float64((size + 1023) / 1024)
+	if units == 0 {
+		units = 1
+	}
+	return units
+}
+
+func (f *fakeImpl) getItem(in *getItemInput) (any, error) {
+	if err := in.legacy.check(); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := t.primaryKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, p, err := parseProjection(in.ProjectionExpression, in.ExpressionAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+	if err = in.checkUsed(p); err != nil {
+		return nil, err
+	}
+
+	out := map[string]any{}
+	current := t.get(key)
+	if current != nil {
+		out["Item"] = project(current, paths)
+	}
+	consumed(out, in.ReturnConsumedCapacity, t, readUnits(itemSize(current), in.ConsistentRead))
+	return out, nil
+}
+
+// checkCondition evaluates expression against the current item, a missing
+// item is evaluated as an empty one.
+func checkCondition(expression string, e expressions, current item, returnOld string, extra ...*parser) error {
+	c, p, err := parseCondition(expression, e.ExpressionAttributeNames, e.ExpressionAttributeValues)
+	if err != nil {
+		return err
+	}
+	if err = e.checkUsed(append(extra, p)...); err != nil {
+		return err
+	}
+
+	if c == nil || c.eval(orEmpty(current)) {
+		return nil
+	}
+	if returnOld == "ALL_OLD" {
+		return conditionalCheckFailed(current)
+	}
+	return conditionalCheckFailed(nil)
+}
+
+func orEmpty(it item) item {
+	if it == nil {
+		return item{}
+	}
+	return it
+}
+
+func (f *fakeImpl) putItem(in *putItemInput) (any, error) {
+	if err := in.legacy.check(); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.validateItem(in.Item); err != nil {
+		return nil, err
+	}
+	if err = checkReturnValues(in.ReturnValues, "NONE", "ALL_OLD"); err != nil {
+		return nil, err
+	}
+
+	current := t.get(in.Item)
+	if err = checkCondition(in.ConditionExpression, in.expressions, current, in.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+
+	t.put(in.Item.clone())
+
+	out := map[string]any{}
+	if in.ReturnValues == "ALL_OLD" && current != nil {
+		out["Attributes"] = current
+	}
+	consumed(out, in.ReturnConsumedCapacity, t, writeUnits(max(itemSize(current), itemSize(in.Item))))
+	return out, nil
+}
+
+func (f *fakeImpl) deleteItem(in *deleteItemInput) (any, error) {
+	if err := in.legacy.check(); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := t.primaryKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkReturnValues(in.ReturnValues, "NONE", "ALL_OLD"); err != nil {
+		return nil, err
+	}
+
+	current := t.get(key)
+	if err = checkCondition(in.ConditionExpression, in.expressions, current, in.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+
+	t.delete(key)
+
+	out := map[string]any{}
+	if in.ReturnValues == "ALL_OLD" && current != nil {
+		out["Attributes"] = current
+	}
+	consumed(out, in.ReturnConsumedCapacity, t, writeUnits(itemSize(current)))
+	return out, nil
+}
+
+func (f *fakeImpl) updateItem(in *updateItemInput) (any, error) {
+	if err := in.legacy.check(); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := t.primaryKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkReturnValues(in.ReturnValues, "NONE", "ALL_OLD", "UPDATED_OLD", "ALL_NEW", "UPDATED_NEW"); err != nil {
+		return nil, err
+	}
+
+	current := t.get(key)
+	updated, u, err := prepareUpdate(t, key, current, in.UpdateExpression, in.ConditionExpression, in.expressions, in.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	t.put(updated)
+
+	out := map[string]any{}
+	var attributes item
+	switch in.ReturnValues {
+	case "ALL_OLD":
+		attributes = current
+	case "UPDATED_OLD":
+		attributes = project(current, u.paths())
+	case "ALL_NEW":
+		attributes = updated
+	case "UPDATED_NEW":
+		attributes = project(updated, u.paths())
+	}
+	if len(attributes) > 0 {
+		out["Attributes"] = attributes
+	}
+	consumed(out, in.ReturnConsumedCapacity, t, writeUnits(max(itemSize(current), itemSize(updated))))
+	return out, nil
+}
+
+// prepareUpdate checks the condition and computes the new item without
+// writing it, so transactions can validate every action first.
+func prepareUpdate(t *table, key, current item, updateExpression, conditionExpression string, e expressions, returnOld string) (item, *update, error) {
+	u, up, err := parseUpdate(updateExpression, e.ExpressionAttributeNames, e.ExpressionAttributeValues)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, p := range u.paths() {
+		if p.top() == t.key.hash || p.top() == t.key.rng {
+			return nil, nil, validationError(fmt.Sprintf("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", p.top()))
+		}
+	}
+
+	if err = checkCondition(conditionExpression, e, current, returnOld, up); err != nil {
+		return nil, nil, err
+	}
+
+	base := current
+	if base == nil {
+		base = key.clone()
+	}
+	updated, err := u.apply(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = t.validateItem(updated); err != nil {
+		return nil, nil, err
+	}
+	return updated, u, nil
+}
+
+func checkReturnValues(returnValues string, allowed ...string) error {
+	if returnValues == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if returnValues == a {
+			return nil
+		}
+	}
+	return validationError("ReturnValues can only be " + strings.Join(allowed, " or ") + " for this operation")
+}
@@ -0,0 +1,215 @@
+package dynamodbfake
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenName  // #name
+	tokenValue // :value
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#' || r == ':':
+			start := i
+			i++
+			for i < len(runes) && isIdentRune(runes[i]) {
+				i++
+			}
+			if i == start+1 {
+				return nil, validationError(fmt.Sprintf("Invalid expression: syntax error at %q", string(r)))
+			}
+			kind := tokenName
+			if r == ':' {
+				kind = tokenValue
+			}
+			tokens = append(tokens, token{kind: kind, text: string(runes[start:i])})
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i])})
+		case isIdentRune(r):
+			start := i
+			for i < len(runes) && isIdentRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i])})
+		default:
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				if two == "<>" || two == "<=" || two == ">=" {
+					tokens = append(tokens, token{kind: tokenSymbol, text: two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("()[],.=<>+-", r) {
+				return nil, validationError(fmt.Sprintf("Invalid expression: unexpected character %q", string(r)))
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(r)})
+			i++
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// parser holds the tokens of an expression and the substitutions of
+// ExpressionAttributeNames and ExpressionAttributeValues.
+type parser struct {
+	tokens []token
+	pos    int
+	names  map[string]string
+	values map[string]value
+
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newParser(expression string, names map[string]string, values map[string]value) (*parser, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens, names: names, values: values, usedNames: map[string]bool{}, usedValues: map[string]bool{}}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isSymbol(text string) bool {
+	t := p.peek()
+	return t.kind == tokenSymbol && t.text == text
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) expect(text string) error {
+	if !p.isSymbol(text) {
+		return p.syntaxError()
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) syntaxError() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return validationError("Invalid expression: unexpected end of expression")
+	}
+	return validationError(fmt.Sprintf("Invalid expression: syntax error; token: %q", t.text))
+}
+
+func (p *parser) done() error {
+	if p.peek().kind != tokenEOF {
+		return p.syntaxError()
+	}
+	return nil
+}
+
+// path parses attribute names joined by dots and list indexes,
+// e.g. #address.city or tags[0].
+func (p *parser) path() (path, error) {
+	var result path
+
+	for {
+		t := p.next()
+		switch t.kind {
+		case tokenIdent:
+			if isReserved(t.text) {
+				return nil, validationError(fmt.Sprintf("Invalid expression: Attribute name is a reserved keyword; reserved keyword: %s", t.text))
+			}
+			result = append(result, pathElement{name: t.text})
+		case tokenName:
+			name, ok := p.names[t.text]
+			if !ok {
+				return nil, validationError(fmt.Sprintf("Invalid expression: An expression attribute name used in the document path is not defined; attribute name: %s", t.text))
+			}
+			p.usedNames[t.text] = true
+			result = append(result, pathElement{name: name})
+		default:
+			return nil, validationError(fmt.Sprintf("Invalid expression: syntax error; token: %q", t.text))
+		}
+
+		for p.isSymbol("[") {
+			p.next()
+			index := p.next()
+			if index.kind != tokenNumber {
+				return nil, p.syntaxError()
+			}
+			var n int
+			_, _ = fmt.Sscanf(index.text, "%d", &n)
+			result = append(result, pathElement{index: n, isIndex: true})
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+
+		if !p.isSymbol(".") {
+			return result, nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) value(name string) (value, error) {
+	v, ok := p.values[name]
+	if !ok {
+		return value{}, validationError(fmt.Sprintf("Invalid expression: An expression attribute value used in expression is not defined; attribute value: %s", name))
+	}
+	p.usedValues[name] = true
+	return v, nil
+}
+
+// reserved lists the keywords that collide with attribute names the
+// repositories could use, the full list of DynamoDB is much longer.
+var reserved = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "BETWEEN": true, "IN": true,
+	"SET": true, "REMOVE": true, "ADD": true, "DELETE": true,
+	"NAME": true, "STATUS": true, "DATA": true, "DATE": true, "TIMESTAMP": true,
+	"VALUE": true, "VALUES": true, "KEY": true, "KEYS": true, "SIZE": true,
+	"TYPE": true, "USER": true, "USERS": true, "COUNT": true, "TTL": true,
+}
+
+func isReserved(name string) bool {
+	return reserved[strings.ToUpper(name)]
+}
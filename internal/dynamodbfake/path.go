@@ -0,0 +1,206 @@
+package dynamodbfake
+
+import (
+	"strconv"
+	"strings"
+)
+
+type pathElement struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// path is a document path such as address.city or tags[0].
+type path []pathElement
+
+func (p path) String() string {
+	var b strings.Builder
+	for i, e := range p {
+		if e.isIndex {
+			b.WriteString("[" + strconv.Itoa(e.index) + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(e.name)
+	}
+	return b.String()
+}
+
+func (p path) top() string {
+	return p[0].name
+}
+
+func (p path) get(it item) (value, bool) {
+	current, ok := it[p[0].name]
+	if !ok {
+		return value{}, false
+	}
+
+	for _, e := range p[1:] {
+		switch {
+		case e.isIndex && current.kind == typeL:
+			if e.index >= len(current.l) {
+				return value{}, false
+			}
+			current = current.l[e.index]
+		case !e.isIndex && current.kind == typeM:
+			if current, ok = current.m[e.name]; !ok {
+				return value{}, false
+			}
+		default:
+			return value{}, false
+		}
+	}
+
+	return current, true
+}
+
+// set writes v at the path, the parents must exist. Indexes past the end of
+// a list append to it.
+func (p path) set(it item, v value) error {
+	if len(p) == 1 {
+		it[p[0].name] = v
+		return nil
+	}
+
+	parent, ok := p[:len(p)-1].get(it)
+	if !ok {
+		return validationError("The document path provided in the update expression is invalid for update")
+	}
+
+	last := p[len(p)-1]
+	switch {
+	case last.isIndex && parent.kind == typeL:
+		if last.index >= len(parent.l) {
+			parent.l = append(parent.l, v)
+		} else {
+			parent.l[last.index] = v
+		}
+	case !last.isIndex && parent.kind == typeM:
+		if parent.m == nil {
+			parent.m = map[string]value{}
+		}
+		parent.m[last.name] = v
+	default:
+		return validationError("The document path provided in the update expression is invalid for update")
+	}
+
+	// lists are values, write the parent back in case append reallocated
+	return p[:len(p)-1].set(it, parent)
+}
+
+func (p path) remove(it item) {
+	if len(p) == 1 {
+		delete(it, p[0].name)
+		return
+	}
+
+	parent, ok := p[:len(p)-1].get(it)
+	if !ok {
+		return
+	}
+
+	last := p[len(p)-1]
+	switch {
+	case last.isIndex && parent.kind == typeL:
+		if last.index < len(parent.l) {
+			parent.l = append(parent.l[:last.index:last.index], parent.l[last.index+1:]...)
+		}
+	case !last.isIndex && parent.kind == typeM:
+		delete(parent.m, last.name)
+	default:
+		return
+	}
+
+	_ = p[:len(p)-1].set(it, parent)
+}
+
+// project keeps the paths of it, nil projects everything.
+func project(it item, paths []path) item {
+	if it == nil || paths == nil {
+		return it
+	}
+
+	result := item{}
+	for _, p := range paths {
+		v, ok := p.get(it)
+		if !ok {
+			continue
+		}
+
+		// rebuild the parents of nested paths, lists keep the projected
+		// elements in order
+		if len(p) == 1 {
+			result[p.top()] = v.clone()
+			continue
+		}
+		mergeProjection(result, it, p, v)
+	}
+	return result
+}
+
+func mergeProjection(result, source item, p path, v value) {
+	src := source[p.top()]
+	dst, ok := result[p.top()]
+	if !ok {
+		dst = value{kind: src.kind}
+	}
+	result[p.top()] = mergeValue(dst, src, p[1:], v)
+}
+
+func mergeValue(dst, src value, rest path, v value) value {
+	if len(rest) == 0 {
+		return v.clone()
+	}
+
+	e := rest[0]
+	switch {
+	case e.isIndex && src.kind == typeL:
+		dst.kind = typeL
+		child := value{kind: src.l[e.index].kind}
+		dst.l = append(dst.l, mergeValue(child, src.l[e.index], rest[1:], v))
+	case !e.isIndex && src.kind == typeM:
+		dst.kind = typeM
+		if dst.m == nil {
+			dst.m = map[string]value{}
+		}
+		child, ok := dst.m[e.name]
+		if !ok {
+			child = value{kind: src.m[e.name].kind}
+		}
+		dst.m[e.name] = mergeValue(child, src.m[e.name], rest[1:], v)
+	}
+	return dst
+}
+
+// parseProjection parses a ProjectionExpression, an empty expression
+// returns nil.
+func parseProjection(expression string, names map[string]string) ([]path, *parser, error) {
+	if len(strings.TrimSpace(expression)) == 0 {
+		return nil, nil, nil
+	}
+
+	p, err := newParser(expression, names, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var paths []path
+	for {
+		pth, err := p.path()
+		if err != nil {
+			return nil, nil, err
+		}
+		paths = append(paths, pth)
+
+		if !p.isSymbol(",") {
+			break
+		}
+		p.next()
+	}
+
+	return paths, p, p.done()
+}
@@ -0,0 +1,304 @@
+package dynamodbfake
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// maxPageSize is the 1MB of data a single Query or Scan evaluates.
+const maxPageSize = 1 << 20
+
+type readInput struct {
+	expressions
+	legacy
+	TableName              string `json:"TableName"`
+	IndexName              string `json:"IndexName"`
+	FilterExpression       string `json:"FilterExpression"`
+	ProjectionExpression   string `json:"ProjectionExpression"`
+	Select                 string `json:"Select"`
+	Limit                  int    `json:"Limit"`
+	ExclusiveStartKey      item   `json:"ExclusiveStartKey"`
+	ConsistentRead         bool   `json:"ConsistentRead"`
+	ReturnConsumedCapacity string `json:"ReturnConsumedCapacity"`
+}
+
+type queryInput struct {
+	readInput
+	KeyConditionExpression string `json:"KeyConditionExpression"`
+	ScanIndexForward       *bool  `json:"ScanIndexForward"`
+}
+
+type scanInput struct {
+	readInput
+	Segment       *int `json:"Segment"`
+	TotalSegments *int `json:"TotalSegments"`
+}
+
+// read holds what Query and Scan share once the request is parsed.
+type read struct {
+	table      *table
+	index      *index
+	filter     condition
+	projection []path
+	count      bool
+}
+
+func (f *fakeImpl) prepareRead(in *readInput, extra ...*parser) (*read, error) {
+	if err := in.legacy.check(); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &read{table: t}
+	if in.IndexName != "" {
+		if r.index, err = t.index(in.IndexName); err != nil {
+			return nil, err
+		}
+		if r.index.global && in.ConsistentRead {
+			return nil, validationError("Consistent reads are not supported on global secondary indexes")
+		}
+	}
+
+	if in.Limit < 0 {
+		return nil, validationError("1 validation error detected: Value at 'limit' failed to satisfy constraint: Member must have value greater than or equal to 1")
+	}
+
+	filter, fp, err := parseCondition(in.FilterExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	r.filter = filter
+
+	projection, pp, err := parseProjection(in.ProjectionExpression, in.ExpressionAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+	r.projection = projection
+
+	switch in.Select {
+	case "", "ALL_ATTRIBUTES", "ALL_PROJECTED_ATTRIBUTES":
+		if projection != nil && in.Select != "" {
+			return nil, validationError("Cannot specify the ProjectionExpression when choosing to get ALL_ATTRIBUTES")
+		}
+	case "SPECIFIC_ATTRIBUTES":
+	case "COUNT":
+		if projection != nil {
+			return nil, validationError("Cannot specify the ProjectionExpression when choosing to get COUNT")
+		}
+		r.count = true
+	default:
+		return nil, validationError(fmt.Sprintf("1 validation error detected: Value '%s' at 'select' failed to satisfy constraint: Member must satisfy enum value set: [SPECIFIC_ATTRIBUTES, COUNT, ALL_ATTRIBUTES, ALL_PROJECTED_ATTRIBUTES]", in.Select))
+	}
+
+	if err = in.checkUsed(append(extra, fp, pp)...); err != nil {
+		return nil, err
+	}
+
+	if in.ExclusiveStartKey != nil {
+		if _, err = t.primaryKey(filterKey(in.ExclusiveStartKey, t.key.names())); err != nil {
+			return nil, validationError("The provided starting key is invalid: The provided key element does not match the schema")
+		}
+	}
+
+	return r, nil
+}
+
+func filterKey(key item, names []string) item {
+	k := item{}
+	for _, name := range names {
+		if v, ok := key[name]; ok {
+			k[name] = v
+		}
+	}
+	return k
+}
+
+// page walks candidates from the start key, applies the limit on evaluated
+// items, then filters and projects.
+func (r *read) page(in *readInput, candidates []item, forward bool) map[string]any {
+	var items []item
+	var scanned, size int
+	var last item
+	var stopped bool
+
+	for _, it := range candidates {
+		if in.ExclusiveStartKey != nil {
+			after := r.table.after(r.index, it, in.ExclusiveStartKey)
+			if !forward {
+				after = r.table.less(r.index, it, in.ExclusiveStartKey)
+			}
+			if !after {
+				continue
+			}
+		}
+
+		if in.Limit > 0 && scanned == in.Limit || size >= maxPageSize {
+			stopped = true
+			break
+		}
+
+		scanned++
+		size += itemSize(it)
+		last = it
+
+		visible := r.table.projectIndex(r.index, it)
+		if r.filter != nil && !r.filter.eval(visible) {
+			continue
+		}
+		items = append(items, project(visible.clone(), r.projection))
+	}
+
+	out := map[string]any{"Count": len(items), "ScannedCount": scanned}
+	if !r.count {
+		if items == nil {
+			items = []item{}
+		}
+		out["Items"] = items
+	}
+
+	// a page that stops early carries the key to resume from
+	if stopped {
+		out["LastEvaluatedKey"] = r.table.lastEvaluatedKey(r.index, last)
+	}
+
+	consumed(out, in.ReturnConsumedCapacity, r.table, readUnits(size, in.ConsistentRead))
+	return out
+}
+
+func (f *fakeImpl) query(in *queryInput) (any, error) {
+	if in.KeyConditionExpression == "" {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request.")
+	}
+
+	keyCondition, kp, err := parseCondition(in.KeyConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := f.prepareRead(&in.readInput, kp)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := r.table.key
+	if r.index != nil {
+		schema = r.index.key
+	}
+	if err = checkKeyCondition(keyCondition, schema); err != nil {
+		return nil, err
+	}
+
+	var candidates []item
+	for _, it := range r.table.scope(r.index) {
+		if keyCondition.eval(it) {
+			candidates = append(candidates, it)
+		}
+	}
+
+	forward := in.ScanIndexForward == nil || *in.ScanIndexForward
+	if !forward {
+		for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	}
+
+	return r.page(&in.readInput, candidates, forward), nil
+}
+
+// checkKeyCondition accepts an equality on the hash key, optionally AND a
+// single condition on the sort key.
+func checkKeyCondition(c condition, schema keySchema) error {
+	var parts []condition
+	if and, ok := c.(andCondition); ok {
+		parts = []condition{and.left, and.right}
+	} else {
+		parts = []condition{c}
+	}
+
+	var hasHash bool
+	for _, part := range parts {
+		name, ok := keyConditionAttribute(part)
+		switch {
+		case !ok:
+			return validationError("Invalid operator used in KeyConditionExpression")
+		case name == schema.hash:
+			if cmp, isCmp := part.(comparison); !isCmp || cmp.op != "=" {
+				return validationError("Query key condition not supported")
+			}
+			hasHash = true
+		case name == schema.rng:
+			if cmp, isCmp := part.(comparison); isCmp && cmp.op == "<>" {
+				return validationError("Query key condition not supported")
+			}
+		default:
+			return validationError(fmt.Sprintf("Query condition missed key schema element: %s", schema.hash))
+		}
+	}
+
+	if !hasHash {
+		return validationError(fmt.Sprintf("Query condition missed key schema element: %s", schema.hash))
+	}
+	return nil
+}
+
+func keyConditionAttribute(c condition) (string, bool) {
+	var o operand
+	switch v := c.(type) {
+	case comparison:
+		o = v.left
+	case between:
+		o = v.operand
+	case function:
+		if v.name != "begins_with" {
+			return "", false
+		}
+		return v.path.top(), len(v.path) == 1
+	default:
+		return "", false
+	}
+
+	p, ok := o.(pathOperand)
+	if !ok || len(p.path) != 1 {
+		return "", false
+	}
+	return p.path.top(), true
+}
+
+func (f *fakeImpl) scan(in *scanInput) (any, error) {
+	r, err := f.prepareRead(&in.readInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if (in.Segment == nil) != (in.TotalSegments == nil) {
+		return nil, validationError("The TotalSegments parameter is required but was not present in the request when Segment parameter is present")
+	}
+
+	candidates := r.table.scope(r.index)
+	if in.TotalSegments != nil {
+		if *in.TotalSegments < 1 || *in.Segment < 0 || *in.Segment >= *in.TotalSegments {
+			return nil, validationError("The Segment parameter is zero-based and must be less than parameter TotalSegments")
+		}
+
+		var segment []item
+		for _, it := range candidates {
+			if segmentOf(r.table, it, *in.TotalSegments) == *in.Segment {
+				segment = append(segment, it)
+			}
+		}
+		candidates = segment
+	}
+
+	return r.page(&in.readInput, candidates, true), nil
+}
+
+// segmentOf spreads items over parallel scan segments by hash key.
+func segmentOf(t *table, it item, total int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(keyString(it[t.key.hash])))
+	return int(h.Sum32() % uint32(total))
+}
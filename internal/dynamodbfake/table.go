@@ -0,0 +1,359 @@
+package dynamodbfake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const arnPrefix = "arn:aws:dynamodb:us-east-1:000000000000:table/"
+
+type keySchema struct {
+	hash  string
+	rng   string // empty without a sort key
+	types map[string]string
+}
+
+// key extracts the primary key of it, ok is false when an element is
+// missing or has the wrong type.
+func (k keySchema) key(it item) (item, bool) {
+	key := item{}
+	for _, name := range k.names() {
+		v, ok := it[name]
+		if !ok || v.kind != k.types[name] {
+			return nil, false
+		}
+		key[name] = v
+	}
+	return key, true
+}
+
+func (k keySchema) names() []string {
+	if k.rng == "" {
+		return []string{k.hash}
+	}
+	return []string{k.hash, k.rng}
+}
+
+func (k keySchema) id(it item) string {
+	id := keyString(it[k.hash])
+	if k.rng != "" {
+		id += "|" + keyString(it[k.rng])
+	}
+	return id
+}
+
+func (k keySchema) description() []map[string]string {
+	schema := []map[string]string{{"AttributeName": k.hash, "KeyType": "HASH"}}
+	if k.rng != "" {
+		schema = append(schema, map[string]string{"AttributeName": k.rng, "KeyType": "RANGE"})
+	}
+	return schema
+}
+
+// index is a global or local secondary index, items without its key
+// attributes are not part of it.
+type index struct {
+	name       string
+	key        keySchema
+	global     bool
+	projection string
+	nonKey     []string
+	throughput *throughput
+}
+
+func (ix *index) projectionDescription() map[string]any {
+	projection := map[string]any{"ProjectionType": ix.projection}
+	if len(ix.nonKey) > 0 {
+		projection["NonKeyAttributes"] = ix.nonKey
+	}
+	return projection
+}
+
+type throughput struct {
+	ReadCapacityUnits  int64 `json:"ReadCapacityUnits"`
+	WriteCapacityUnits int64 `json:"WriteCapacityUnits"`
+}
+
+type table struct {
+	name        string
+	key         keySchema
+	attributes  map[string]string
+	indexes     []*index
+	billingMode string
+	throughput  *throughput
+	tags        map[string]string
+	// ttl is the attribute of the time to live, empty when disabled. The
+	// fake never expires items, like DynamoDB they may outlive it.
+	ttl     string
+	created time.Time
+	items   map[string]item
+}
+
+func (t *table) arn() string {
+	return arnPrefix + t.name
+}
+
+func (t *table) index(name string) (*index, error) {
+	for _, ix := range t.indexes {
+		if ix.name == name {
+			return ix, nil
+		}
+	}
+	return nil, validationError(fmt.Sprintf("The table does not have the specified index: %s", name))
+}
+
+// primaryKey validates a Key parameter: exactly the key attributes with
+// their declared types.
+func (t *table) primaryKey(key item) (item, error) {
+	k, ok := t.key.key(key)
+	if !ok || len(key) != len(k) {
+		return nil, validationError("The provided key element does not match the schema")
+	}
+	return k, nil
+}
+
+func (t *table) get(key item) item {
+	return t.items[t.key.id(key)]
+}
+
+// validateItem checks the key attributes and the index attributes of a new
+// item, like the service does before writing.
+func (t *table) validateItem(it item) error {
+	if _, ok := t.key.key(it); !ok {
+		for _, name := range t.key.names() {
+			if _, present := it[name]; !present {
+				return validationError(fmt.Sprintf("One or more parameter values were invalid: Missing the key %s in the item", name))
+			}
+		}
+		return validationError("One or more parameter values were invalid: Type mismatch for key")
+	}
+
+	for _, ix := range t.indexes {
+		for _, name := range ix.key.names() {
+			if v, ok := it[name]; ok && v.kind != ix.key.types[name] {
+				return validationError(fmt.Sprintf("One or more parameter values were invalid: Type mismatch for Index Key %s Expected: %s Actual: %s IndexName: %s", name, ix.key.types[name], v.kind, ix.name))
+			}
+		}
+	}
+
+	for name, v := range it {
+		if v.kind == typeS && v.str == "" && t.isKeyAttribute(name) {
+			return validationError(fmt.Sprintf("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", name))
+		}
+	}
+	return nil
+}
+
+func (t *table) isKeyAttribute(name string) bool {
+	if name == t.key.hash || name == t.key.rng {
+		return true
+	}
+	for _, ix := range t.indexes {
+		if name == ix.key.hash || name == ix.key.rng {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *table) put(it item) {
+	t.items[t.key.id(it)] = it
+}
+
+func (t *table) delete(key item) {
+	delete(t.items, t.key.id(key))
+}
+
+// scope lists the items visible through ix, nil for the table itself, in
+// the order the service returns them for a Scan.
+func (t *table) scope(ix *index) []item {
+	schema := t.key
+	if ix != nil {
+		schema = ix.key
+	}
+
+	var items []item
+	for _, it := range t.items {
+		if _, ok := schema.key(it); ok {
+			items = append(items, it)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return t.less(ix, items[i], items[j])
+	})
+	return items
+}
+
+// less orders items by hash key, then sort key, then the table key so that
+// index entries with equal keys keep a stable order.
+func (t *table) less(ix *index, a, b item) bool {
+	var names []string
+	if ix != nil {
+		names = append(names, ix.key.names()...)
+	}
+	names = append(names, t.key.names()...)
+
+	for i, name := range names {
+		if ix != nil && i == 0 || ix == nil && name == t.key.hash {
+			// hash keys have no meaningful order, keep them grouped
+			if c := strings.Compare(keyString(a[name]), keyString(b[name])); c != 0 {
+				return c < 0
+			}
+			continue
+		}
+		if c, _ := compare(a[name], b[name]); c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+// projectIndex keeps the attributes projected into ix.
+func (t *table) projectIndex(ix *index, it item) item {
+	if ix == nil || ix.projection == "ALL" {
+		return it
+	}
+
+	result := item{}
+	names := append(append([]string{}, t.key.names()...), ix.key.names()...)
+	if ix.projection == "INCLUDE" {
+		names = append(names, ix.nonKey...)
+	}
+	for _, name := range names {
+		if v, ok := it[name]; ok {
+			result[name] = v
+		}
+	}
+	return result
+}
+
+// lastEvaluatedKey holds the table key and, for an index, the index key.
+func (t *table) lastEvaluatedKey(ix *index, it item) item {
+	key := item{}
+	for _, name := range t.key.names() {
+		key[name] = it[name]
+	}
+	if ix != nil {
+		for _, name := range ix.key.names() {
+			key[name] = it[name]
+		}
+	}
+	return key
+}
+
+// after reports whether it comes after the ExclusiveStartKey start in the
+// ordering of ix.
+func (t *table) after(ix *index, it, start item) bool {
+	return t.less(ix, start, it)
+}
+
+func (t *table) description() map[string]any {
+	var definitions []map[string]string
+	for name, kind := range t.attributes {
+		definitions = append(definitions, map[string]string{"AttributeName": name, "AttributeType": kind})
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i]["AttributeName"] < definitions[j]["AttributeName"]
+	})
+
+	var size int
+	for _, it := range t.items {
+		size += itemSize(it)
+	}
+
+	description := map[string]any{
+		"TableName":            t.name,
+		"TableArn":             t.arn(),
+		"TableId":              fmt.Sprintf("%x", t.created.UnixNano()),
+		"TableStatus":          "ACTIVE",
+		"CreationDateTime":     float64(t.created.UnixMilli()) / 1000,
+		"KeySchema":            t.key.description(),
+		"AttributeDefinitions": definitions,
+		"ItemCount":            len(t.items),
+		"TableSizeBytes":       size,
+		"BillingModeSummary":   map[string]string{"BillingMode": t.billingMode},
+		"ProvisionedThroughput": map[string]any{
+			"ReadCapacityUnits":      t.capacity().ReadCapacityUnits,
+			"WriteCapacityUnits":     t.capacity().WriteCapacityUnits,
+			"NumberOfDecreasesToday": 0,
+		},
+	}
+
+	var globals, locals []map[string]any
+	for _, ix := range t.indexes {
+		d := map[string]any{
+			"IndexName":      ix.name,
+			"IndexArn":       t.arn() + "/index/" + ix.name,
+			"KeySchema":      ix.key.description(),
+			"Projection":     ix.projectionDescription(),
+			"ItemCount":      len(t.scope(ix)),
+			"IndexSizeBytes": 0,
+		}
+		if !ix.global {
+			locals = append(locals, d)
+			continue
+		}
+		d["IndexStatus"] = "ACTIVE"
+		if ix.throughput != nil {
+			d["ProvisionedThroughput"] = ix.throughput
+		}
+		globals = append(globals, d)
+	}
+	if globals != nil {
+		description["GlobalSecondaryIndexes"] = globals
+	}
+	if locals != nil {
+		description["LocalSecondaryIndexes"] = locals
+	}
+	return description
+}
+
+func (t *table) capacity() throughput {
+	if t.throughput == nil {
+		return throughput{}
+	}
+	return *t.throughput
+}
+
+// itemSize approximates the size DynamoDB bills: names plus values.
+func itemSize(it item) int {
+	var n int
+	for name, v := range it {
+		n += len(name) + valueSize(v)
+	}
+	return n
+}
+
+func valueSize(v value) int {
+	switch v.kind {
+	case typeS, typeN:
+		return len(v.str)
+	case typeB:
+		return len(v.bin)
+	case typeBOOL, typeNULL:
+		return 1
+	case typeSS, typeNS:
+		var n int
+		for _, s := range v.strs {
+			n += len(s)
+		}
+		return n
+	case typeBS:
+		var n int
+		for _, b := range v.bins {
+			n += len(b)
+		}
+		return n
+	case typeM:
+		return 3 + itemSize(v.m)
+	default:
+		n := 3
+		for _, e := range v.l {
+			n += 1 + valueSize(e)
+		}
+		return n
+	}
+}
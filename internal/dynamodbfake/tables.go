@@ -0,0 +1,431 @@
+package dynamodbfake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type attributeDefinition struct {
+	AttributeName string `json:"AttributeName"`
+	AttributeType string `json:"AttributeType"`
+}
+
+type keySchemaElement struct {
+	AttributeName string `json:"AttributeName"`
+	KeyType       string `json:"KeyType"`
+}
+
+type projectionInput struct {
+	ProjectionType   string   `json:"ProjectionType"`
+	NonKeyAttributes []string `json:"NonKeyAttributes"`
+}
+
+type indexInput struct {
+	IndexName             string             `json:"IndexName"`
+	KeySchema             []keySchemaElement `json:"KeySchema"`
+	Projection            projectionInput    `json:"Projection"`
+	ProvisionedThroughput *throughput        `json:"ProvisionedThroughput"`
+}
+
+type tag struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+type createTableInput struct {
+	TableName              string                `json:"TableName"`
+	AttributeDefinitions   []attributeDefinition `json:"AttributeDefinitions"`
+	KeySchema              []keySchemaElement    `json:"KeySchema"`
+	BillingMode            string                `json:"BillingMode"`
+	ProvisionedThroughput  *throughput           `json:"ProvisionedThroughput"`
+	GlobalSecondaryIndexes []indexInput          `json:"GlobalSecondaryIndexes"`
+	LocalSecondaryIndexes  []indexInput          `json:"LocalSecondaryIndexes"`
+	Tags                   []tag                 `json:"Tags"`
+}
+
+type tableNameInput struct {
+	TableName string `json:"TableName"`
+}
+
+type listTablesInput struct {
+	ExclusiveStartTableName string `json:"ExclusiveStartTableName"`
+	Limit                   int    `json:"Limit"`
+}
+
+type indexUpdate struct {
+	Create *indexInput `json:"Create"`
+	Delete *struct {
+		IndexName string `json:"IndexName"`
+	} `json:"Delete"`
+	Update *struct {
+		IndexName             string      `json:"IndexName"`
+		ProvisionedThroughput *throughput `json:"ProvisionedThroughput"`
+	} `json:"Update"`
+}
+
+type updateTableInput struct {
+	TableName                   string                `json:"TableName"`
+	AttributeDefinitions        []attributeDefinition `json:"AttributeDefinitions"`
+	BillingMode                 string                `json:"BillingMode"`
+	ProvisionedThroughput       *throughput           `json:"ProvisionedThroughput"`
+	GlobalSecondaryIndexUpdates []indexUpdate         `json:"GlobalSecondaryIndexUpdates"`
+}
+
+type resourceInput struct {
+	ResourceArn string   `json:"ResourceArn"`
+	Tags        []tag    `json:"Tags"`
+	TagKeys     []string `json:"TagKeys"`
+}
+
+type timeToLiveInput struct {
+	TableName               string `json:"TableName"`
+	TimeToLiveSpecification struct {
+		AttributeName string `json:"AttributeName"`
+		Enabled       bool   `json:"Enabled"`
+	} `json:"TimeToLiveSpecification"`
+}
+
+func (f *fakeImpl) table(name string) (*table, error) {
+	if len(name) < 3 {
+		return nil, validationError("1 validation error detected: Value at 'tableName' failed to satisfy constraint: Member must have length greater than or equal to 3")
+	}
+	t, ok := f.tables[name]
+	if !ok {
+		return nil, resourceNotFound()
+	}
+	return t, nil
+}
+
+func (f *fakeImpl) tableByArn(arn string) (*table, error) {
+	if !strings.HasPrefix(arn, arnPrefix) {
+		return nil, validationError(fmt.Sprintf("Invalid TableArn: %s", arn))
+	}
+	return f.table(strings.TrimPrefix(arn, arnPrefix))
+}
+
+func (f *fakeImpl) createTable(in *createTableInput) (any, error) {
+	if _, ok := f.tables[in.TableName]; ok {
+		return nil, resourceInUse("Table already exists: " + in.TableName)
+	}
+	if len(in.TableName) < 3 {
+		return nil, validationError("1 validation error detected: Value at 'tableName' failed to satisfy constraint: Member must have length greater than or equal to 3")
+	}
+
+	attributes := map[string]string{}
+	for _, d := range in.AttributeDefinitions {
+		switch d.AttributeType {
+		case typeS, typeN, typeB:
+		default:
+			return nil, validationError(fmt.Sprintf("1 validation error detected: Value '%s' at 'attributeDefinitions.member.attributeType' failed to satisfy constraint: Member must satisfy enum value set: [B, N, S]", d.AttributeType))
+		}
+		attributes[d.AttributeName] = d.AttributeType
+	}
+
+	key, err := newKeySchema(in.KeySchema, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	billingMode := in.BillingMode
+	if billingMode == "" {
+		billingMode = "PROVISIONED"
+	}
+	if err = checkThroughput(billingMode, in.ProvisionedThroughput); err != nil {
+		return nil, err
+	}
+
+	t := &table{
+		name:        in.TableName,
+		key:         key,
+		attributes:  attributes,
+		billingMode: billingMode,
+		throughput:  in.ProvisionedThroughput,
+		tags:        map[string]string{},
+		created:     f.now(),
+		items:       map[string]item{},
+	}
+
+	for _, ix := range in.GlobalSecondaryIndexes {
+		if err = t.addIndex(ix, true); err != nil {
+			return nil, err
+		}
+	}
+	for _, ix := range in.LocalSecondaryIndexes {
+		if key.rng == "" {
+			return nil, validationError("One or more parameter values were invalid: Table KeySchema does not have a range key, which is required when specifying a LocalSecondaryIndex")
+		}
+		if err = t.addIndex(ix, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = checkDefinitionsUsed(t); err != nil {
+		return nil, err
+	}
+
+	for _, tg := range in.Tags {
+		t.tags[tg.Key] = tg.Value
+	}
+
+	f.tables[t.name] = t
+	return map[string]any{"TableDescription": t.description()}, nil
+}
+
+func newKeySchema(elements []keySchemaElement, attributes map[string]string) (keySchema, error) {
+	if len(elements) == 0 || len(elements) > 2 {
+		return keySchema{}, validationError("1 validation error detected: Value at 'keySchema' failed to satisfy constraint: Member must have length less than or equal to 2")
+	}
+
+	k := keySchema{types: map[string]string{}}
+	for _, e := range elements {
+		kind, ok := attributes[e.AttributeName]
+		if !ok {
+			return keySchema{}, validationError(fmt.Sprintf("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions. Keys: [%s]", e.AttributeName))
+		}
+		k.types[e.AttributeName] = kind
+
+		switch e.KeyType {
+		case "HASH":
+			k.hash = e.AttributeName
+		case "RANGE":
+			k.rng = e.AttributeName
+		default:
+			return keySchema{}, validationError(fmt.Sprintf("1 validation error detected: Value '%s' at 'keySchema.member.keyType' failed to satisfy constraint: Member must satisfy enum value set: [HASH, RANGE]", e.KeyType))
+		}
+	}
+
+	if k.hash == "" || k.hash == k.rng {
+		return keySchema{}, validationError("Invalid KeySchema: The first KeySchemaElement is not a HASH key type")
+	}
+	return k, nil
+}
+
+func checkThroughput(billingMode string, tp *throughput) error {
+	switch billingMode {
+	case "PROVISIONED":
+		if tp == nil || tp.ReadCapacityUnits <= 0 || tp.WriteCapacityUnits <= 0 {
+			return validationError("One or more parameter values were invalid: ReadCapacityUnits and WriteCapacityUnits must both be specified when BillingMode is PROVISIONED")
+		}
+	case "PAY_PER_REQUEST":
+		if tp != nil {
+			return validationError("One or more parameter values were invalid: Neither ReadCapacityUnits nor WriteCapacityUnits can be specified when BillingMode is PAY_PER_REQUEST")
+		}
+	default:
+		return validationError(fmt.Sprintf("1 validation error detected: Value '%s' at 'billingMode' failed to satisfy constraint: Member must satisfy enum value set: [PROVISIONED, PAY_PER_REQUEST]", billingMode))
+	}
+	return nil
+}
+
+func checkDefinitionsUsed(t *table) error {
+	for name := range t.attributes {
+		if !t.isKeyAttribute(name) {
+			return validationError("One or more parameter values were invalid: Number of attributes in KeySchema does not exactly match number of attributes defined in AttributeDefinitions")
+		}
+	}
+	return nil
+}
+
+func (t *table) addIndex(in indexInput, global bool) error {
+	if _, err := t.index(in.IndexName); err == nil {
+		return validationError("One or more parameter values were invalid: Duplicate index name: " + in.IndexName)
+	}
+
+	key, err := newKeySchema(in.KeySchema, t.attributes)
+	if err != nil {
+		return err
+	}
+	if !global && (key.hash != t.key.hash || key.rng == "") {
+		return validationError("One or more parameter values were invalid: Index KeySchema does not have the same leading hash key as table KeySchema for index: " + in.IndexName)
+	}
+
+	switch in.Projection.ProjectionType {
+	case "":
+		in.Projection.ProjectionType = "ALL"
+	case "ALL", "KEYS_ONLY", "INCLUDE":
+	default:
+		return validationError(fmt.Sprintf("1 validation error detected: Value '%s' at 'projection.projectionType' failed to satisfy constraint: Member must satisfy enum value set: [ALL, INCLUDE, KEYS_ONLY]", in.Projection.ProjectionType))
+	}
+
+	if global {
+		if err = checkThroughput(t.billingMode, in.ProvisionedThroughput); err != nil {
+			return err
+		}
+	}
+
+	t.indexes = append(t.indexes, &index{
+		name:       in.IndexName,
+		key:        key,
+		global:     global,
+		projection: in.Projection.ProjectionType,
+		nonKey:     in.Projection.NonKeyAttributes,
+		throughput: in.ProvisionedThroughput,
+	})
+	return nil
+}
+
+func (f *fakeImpl) describeTable(in *tableNameInput) (any, error) {
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"Table": t.description()}, nil
+}
+
+func (f *fakeImpl) deleteTable(in *tableNameInput) (any, error) {
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(f.tables, t.name)
+	description := t.description()
+	description["TableStatus"] = "DELETING"
+	return map[string]any{"TableDescription": description}, nil
+}
+
+func (f *fakeImpl) listTables(in *listTablesInput) (any, error) {
+	names := make([]string, 0, len(f.tables))
+	for name := range f.tables {
+		if name > in.ExclusiveStartTableName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	limit := in.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+
+	out := map[string]any{}
+	if len(names) > limit {
+		names = names[:limit]
+		out["LastEvaluatedTableName"] = names[limit-1]
+	}
+	out["TableNames"] = names
+	return out, nil
+}
+
+func (f *fakeImpl) updateTable(in *updateTableInput) (any, error) {
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range in.AttributeDefinitions {
+		t.attributes[d.AttributeName] = d.AttributeType
+	}
+
+	if in.BillingMode != "" || in.ProvisionedThroughput != nil {
+		billingMode := in.BillingMode
+		if billingMode == "" {
+			billingMode = t.billingMode
+		}
+		if err = checkThroughput(billingMode, in.ProvisionedThroughput); err != nil {
+			return nil, err
+		}
+		t.billingMode = billingMode
+		t.throughput = in.ProvisionedThroughput
+	}
+
+	for _, u := range in.GlobalSecondaryIndexUpdates {
+		switch {
+		case u.Create != nil:
+			err = t.addIndex(*u.Create, true)
+		case u.Delete != nil:
+			err = t.deleteIndex(u.Delete.IndexName)
+		case u.Update != nil:
+			var ix *index
+			if ix, err = t.index(u.Update.IndexName); err == nil {
+				ix.throughput = u.Update.ProvisionedThroughput
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]any{"TableDescription": t.description()}, nil
+}
+
+func (t *table) deleteIndex(name string) error {
+	for i, ix := range t.indexes {
+		if ix.name == name && ix.global {
+			t.indexes = append(t.indexes[:i], t.indexes[i+1:]...)
+			return nil
+		}
+	}
+	return resourceNotFound()
+}
+
+func (f *fakeImpl) listTagsOfResource(in *resourceInput) (any, error) {
+	t, err := f.tableByArn(in.ResourceArn)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]tag, 0, len(t.tags))
+	for k, v := range t.tags {
+		tags = append(tags, tag{Key: k, Value: v})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	return map[string]any{"Tags": tags}, nil
+}
+
+func (f *fakeImpl) tagResource(in *resourceInput) (any, error) {
+	t, err := f.tableByArn(in.ResourceArn)
+	if err != nil {
+		return nil, err
+	}
+	for _, tg := range in.Tags {
+		t.tags[tg.Key] = tg.Value
+	}
+	return map[string]any{}, nil
+}
+
+func (f *fakeImpl) untagResource(in *resourceInput) (any, error) {
+	t, err := f.tableByArn(in.ResourceArn)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range in.TagKeys {
+		delete(t.tags, k)
+	}
+	return map[string]any{}, nil
+}
+
+func (f *fakeImpl) updateTimeToLive(in *timeToLiveInput) (any, error) {
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := in.TimeToLiveSpecification
+	switch {
+	case spec.AttributeName == "":
+		return nil, validationError("TimeToLiveSpecification.AttributeName is required")
+	case spec.Enabled && t.ttl != "":
+		return nil, validationError("TimeToLive is already enabled")
+	case !spec.Enabled && t.ttl == "":
+		return nil, validationError("TimeToLive is already disabled")
+	case spec.Enabled:
+		t.ttl = spec.AttributeName
+	default:
+		t.ttl = ""
+	}
+	return map[string]any{"TimeToLiveSpecification": spec}, nil
+}
+
+func (f *fakeImpl) describeTimeToLive(in *timeToLiveInput) (any, error) {
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	description := map[string]any{"TimeToLiveStatus": "DISABLED"}
+	if t.ttl != "" {
+		description = map[string]any{"TimeToLiveStatus": "ENABLED", "AttributeName": t.ttl}
+	}
+	return map[string]any{"TimeToLiveDescription": description}, nil
+}
@@ -0,0 +1,359 @@
+package dynamodbfake
+
+import (
+	"math/big"
+	"strings"
+)
+
+// setOperand is the right side of a SET action.
+type setOperand interface {
+	resolve(it item) (value, error)
+}
+
+type plainOperand struct{ operand operand }
+
+func (o plainOperand) resolve(it item) (value, error) {
+	v, ok := o.operand.resolve(it)
+	if !ok {
+		return value{}, validationError("The provided expression refers to an attribute that does not exist in the item")
+	}
+	return v, nil
+}
+
+type ifNotExists struct {
+	path     path
+	fallback setOperand
+}
+
+func (o ifNotExists) resolve(it item) (value, error) {
+	if v, ok := o.path.get(it); ok {
+		return v, nil
+	}
+	return o.fallback.resolve(it)
+}
+
+type listAppend struct{ left, right setOperand }
+
+func (o listAppend) resolve(it item) (value, error) {
+	l, err := o.left.resolve(it)
+	if err != nil {
+		return value{}, err
+	}
+	r, err := o.right.resolve(it)
+	if err != nil {
+		return value{}, err
+	}
+	if l.kind != typeL || r.kind != typeL {
+		return value{}, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator or function: list_append")
+	}
+	return value{kind: typeL, l: append(append([]value{}, l.l...), r.l...)}, nil
+}
+
+type arithmetic struct {
+	op          string
+	left, right setOperand
+}
+
+func (o arithmetic) resolve(it item) (value, error) {
+	l, err := o.left.resolve(it)
+	if err != nil {
+		return value{}, err
+	}
+	r, err := o.right.resolve(it)
+	if err != nil {
+		return value{}, err
+	}
+	if l.kind != typeN || r.kind != typeN {
+		return value{}, validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	if o.op == "+" {
+		return numberValue(new(big.Rat).Add(l.number(), r.number())), nil
+	}
+	return numberValue(new(big.Rat).Sub(l.number(), r.number())), nil
+}
+
+type setAction struct {
+	path    path
+	operand setOperand
+}
+
+type pathValue struct {
+	path  path
+	value value
+}
+
+// update is a parsed UpdateExpression.
+type update struct {
+	set    []setAction
+	remove []path
+	add    []pathValue
+	delete []pathValue
+}
+
+// paths returns the top level attributes touched, used by UPDATED_OLD and
+// UPDATED_NEW.
+func (u update) paths() []path {
+	var paths []path
+	for _, a := range u.set {
+		paths = append(paths, a.path)
+	}
+	paths = append(paths, u.remove...)
+	for _, a := range u.add {
+		paths = append(paths, a.path)
+	}
+	for _, a := range u.delete {
+		paths = append(paths, a.path)
+	}
+	return paths
+}
+
+// apply evaluates every action against the original item, then writes the
+// results in order.
+func (u update) apply(it item) (item, error) {
+	original := it.clone()
+	updated := it.clone()
+
+	for _, a := range u.set {
+		v, err := a.operand.resolve(original)
+		if err != nil {
+			return nil, err
+		}
+		if err = a.path.set(updated, v.clone()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range u.remove {
+		p.remove(updated)
+	}
+
+	for _, a := range u.add {
+		current, ok := a.path.get(updated)
+		result, err := addValue(current, ok, a.value)
+		if err != nil {
+			return nil, err
+		}
+		if err = a.path.set(updated, result); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range u.delete {
+		current, ok := a.path.get(updated)
+		if !ok {
+			continue
+		}
+		result, empty, err := deleteValue(current, a.value)
+		if err != nil {
+			return nil, err
+		}
+		if empty {
+			a.path.remove(updated)
+			continue
+		}
+		if err = a.path.set(updated, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return updated, nil
+}
+
+func addValue(current value, exists bool, v value) (value, error) {
+	switch v.kind {
+	case typeN:
+		if !exists {
+			return v, nil
+		}
+		if current.kind != typeN {
+			return value{}, validationError("An operand in the update expression has an incorrect data type")
+		}
+		return numberValue(new(big.Rat).Add(current.number(), v.number())), nil
+	case typeSS, typeNS:
+		if !exists {
+			return v, nil
+		}
+		if current.kind != v.kind {
+			return value{}, validationError("An operand in the update expression has an incorrect data type")
+		}
+		result := current.clone()
+		for _, s := range v.strs {
+			if !containsMember(result.strs, s, v.kind == typeNS) {
+				result.strs = append(result.strs, s)
+			}
+		}
+		return result, nil
+	case typeBS:
+		if !exists {
+			return v, nil
+		}
+		if current.kind != typeBS {
+			return value{}, validationError("An operand in the update expression has an incorrect data type")
+		}
+		result := current.clone()
+		for _, b := range v.bins {
+			if !containsBinary(result.bins, b) {
+				result.bins = append(result.bins, b)
+			}
+		}
+		return result, nil
+	default:
+		return value{}, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD")
+	}
+}
+
+func deleteValue(current, v value) (value, bool, error) {
+	if current.kind != v.kind || (v.kind != typeSS && v.kind != typeNS && v.kind != typeBS) {
+		return value{}, false, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: DELETE")
+	}
+
+	result := value{kind: current.kind}
+	if v.kind == typeBS {
+		for _, b := range current.bins {
+			if !containsBinary(v.bins, b) {
+				result.bins = append(result.bins, b)
+			}
+		}
+		return result, len(result.bins) == 0, nil
+	}
+
+	for _, s := range current.strs {
+		if !containsMember(v.strs, s, v.kind == typeNS) {
+			result.strs = append(result.strs, s)
+		}
+	}
+	return result, len(result.strs) == 0, nil
+}
+
+func parseUpdate(expression string, names map[string]string, values map[string]value) (*update, *parser, error) {
+	p, err := newParser(expression, names, values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u := &update{}
+	seen := map[string]bool{}
+	for p.peek().kind != tokenEOF {
+		clause := strings.ToUpper(p.next().text)
+		if seen[clause] {
+			return nil, nil, validationError("Invalid UpdateExpression: The \"" + clause + "\" section can only be used once in an update expression")
+		}
+		seen[clause] = true
+
+		for {
+			if err = p.updateAction(clause, u); err != nil {
+				return nil, nil, err
+			}
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if len(u.paths()) == 0 {
+		return nil, nil, validationError("Invalid UpdateExpression: The expression can not be empty")
+	}
+
+	return u, p, nil
+}
+
+func (p *parser) updateAction(clause string, u *update) error {
+	target, err := p.path()
+	if err != nil {
+		return err
+	}
+
+	switch clause {
+	case "SET":
+		if err = p.expect("="); err != nil {
+			return err
+		}
+		operand, err := p.setValue()
+		if err != nil {
+			return err
+		}
+		u.set = append(u.set, setAction{path: target, operand: operand})
+	case "REMOVE":
+		u.remove = append(u.remove, target)
+	case "ADD", "DELETE":
+		t := p.next()
+		if t.kind != tokenValue {
+			return p.syntaxError()
+		}
+		v, err := p.value(t.text)
+		if err != nil {
+			return err
+		}
+		if clause == "ADD" {
+			u.add = append(u.add, pathValue{path: target, value: v})
+		} else {
+			u.delete = append(u.delete, pathValue{path: target, value: v})
+		}
+	default:
+		return validationError("Invalid UpdateExpression: Syntax error; token: \"" + clause + "\"")
+	}
+	return nil
+}
+
+func (p *parser) setValue() (setOperand, error) {
+	left, err := p.setOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isSymbol("+") || p.isSymbol("-") {
+		op := p.next().text
+		right, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) setOperand() (setOperand, error) {
+	t := p.peek()
+	if t.kind == tokenIdent && p.tokens[p.pos+1].text == "(" {
+		switch strings.ToLower(t.text) {
+		case "if_not_exists":
+			p.next()
+			p.next()
+			target, err := p.path()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(","); err != nil {
+				return nil, err
+			}
+			fallback, err := p.setOperand()
+			if err != nil {
+				return nil, err
+			}
+			return ifNotExists{path: target, fallback: fallback}, p.expect(")")
+		case "list_append":
+			p.next()
+			p.next()
+			left, err := p.setOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(","); err != nil {
+				return nil, err
+			}
+			right, err := p.setOperand()
+			if err != nil {
+				return nil, err
+			}
+			return listAppend{left: left, right: right}, p.expect(")")
+		}
+	}
+
+	o, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return plainOperand{operand: o}, nil
+}
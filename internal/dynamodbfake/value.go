@@ -0,0 +1,353 @@
+package dynamodbfake
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Attribute types, as found in the wire format.
+const (
+	typeS    = "S"
+	typeN    = "N"
+	typeB    = "B"
+	typeBOOL = "BOOL"
+	typeNULL = "NULL"
+	typeM    = "M"
+	typeL    = "L"
+	typeSS   = "SS"
+	typeNS   = "NS"
+	typeBS   = "BS"
+)
+
+// value is an attribute value in the JSON wire format of DynamoDB.
+type value struct {
+	kind    string
+	str     string // S and N
+	bin     []byte
+	boolean bool
+	m       map[string]value
+	l       []value
+	strs    []string // SS and NS
+	bins    [][]byte
+}
+
+type item map[string]value
+
+func stringValue(s string) value {
+	return value{kind: typeS, str: s}
+}
+
+func numberValue(r *big.Rat) value {
+	return value{kind: typeN, str: formatNumber(r)}
+}
+
+func (v value) MarshalJSON() ([]byte, error) {
+	var content any
+	switch v.kind {
+	case typeS, typeN:
+		content = v.str
+	case typeB:
+		content = base64.StdEncoding.EncodeToString(v.bin)
+	case typeBOOL:
+		content = v.boolean
+	case typeNULL:
+		content = true
+	case typeM:
+		m := v.m
+		if m == nil {
+			m = map[string]value{}
+		}
+		content = m
+	case typeL:
+		l := v.l
+		if l == nil {
+			l = []value{}
+		}
+		content = l
+	case typeSS, typeNS:
+		content = v.strs
+	case typeBS:
+		encoded := make([]string, len(v.bins))
+		for i, b := range v.bins {
+			encoded[i] = base64.StdEncoding.EncodeToString(b)
+		}
+		content = encoded
+	default:
+		return nil, fmt.Errorf("unknown attribute type %q", v.kind)
+	}
+
+	return json.Marshal(map[string]any{v.kind: content})
+}
+
+func (v *value) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if len(raw) != 1 {
+		return validationError("Supplied AttributeValue has more than one datatypes set, must contain exactly one of the supported datatypes")
+	}
+
+	for kind, content := range raw {
+		v.kind = kind
+		var err error
+		switch kind {
+		case typeS:
+			err = json.Unmarshal(content, &v.str)
+		case typeN:
+			err = json.Unmarshal(content, &v.str)
+			if err == nil {
+				var r *big.Rat
+				if r, err = parseNumber(v.str); err == nil {
+					v.str = formatNumber(r)
+				}
+			}
+		case typeB:
+			err = json.Unmarshal(content, &v.bin)
+		case typeBOOL:
+			err = json.Unmarshal(content, &v.boolean)
+		case typeNULL:
+			err = json.Unmarshal(content, &v.boolean)
+		case typeM:
+			err = json.Unmarshal(content, &v.m)
+		case typeL:
+			err = json.Unmarshal(content, &v.l)
+		case typeSS, typeNS:
+			err = json.Unmarshal(content, &v.strs)
+			if err == nil && kind == typeNS {
+				for i, s := range v.strs {
+					var r *big.Rat
+					if r, err = parseNumber(s); err != nil {
+						break
+					}
+					v.strs[i] = formatNumber(r)
+				}
+			}
+			if err == nil && len(v.strs) == 0 {
+				err = validationError("One or more parameter values were invalid: An string set may not be empty")
+			}
+		case typeBS:
+			err = json.Unmarshal(content, &v.bins)
+			if err == nil && len(v.bins) == 0 {
+				err = validationError("One or more parameter values were invalid: Binary sets should not be empty")
+			}
+		default:
+			return validationError(fmt.Sprintf("Supplied AttributeValue has an unknown datatype %q", kind))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseNumber(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return nil, validationError(fmt.Sprintf("A value provided cannot be converted into a number: %q", s))
+	}
+	return r, nil
+}
+
+// formatNumber keeps integers as they are and writes decimals with the 38
+// digits of precision DynamoDB supports, trailing zeros removed.
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	s := r.FloatString(38)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func (v value) number() *big.Rat {
+	r, _ := new(big.Rat).SetString(v.str)
+	return r
+}
+
+// compare orders two scalar values of the same type, ok is false when the
+// values cannot be compared.
+func compare(a, b value) (int, bool) {
+	if a.kind != b.kind {
+		return 0, false
+	}
+
+	switch a.kind {
+	case typeS:
+		return strings.Compare(a.str, b.str), true
+	case typeN:
+		return a.number().Cmp(b.number()), true
+	case typeB:
+		return bytes.Compare(a.bin, b.bin), true
+	default:
+		return 0, false
+	}
+}
+
+func equal(a, b value) bool {
+	if a.kind != b.kind {
+		return false
+	}
+
+	switch a.kind {
+	case typeS, typeN, typeB:
+		c, _ := compare(a, b)
+		return c == 0
+	case typeBOOL:
+		return a.boolean == b.boolean
+	case typeNULL:
+		return true
+	case typeM:
+		if len(a.m) != len(b.m) {
+			return false
+		}
+		for k, av := range a.m {
+			bv, ok := b.m[k]
+			if !ok || !equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	case typeL:
+		if len(a.l) != len(b.l) {
+			return false
+		}
+		for i := range a.l {
+			if !equal(a.l[i], b.l[i]) {
+				return false
+			}
+		}
+		return true
+	case typeSS, typeNS:
+		return sameSet(a.strs, b.strs, a.kind == typeNS)
+	case typeBS:
+		if len(a.bins) != len(b.bins) {
+			return false
+		}
+		for _, x := range a.bins {
+			if !containsBinary(b.bins, x) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func sameSet(a, b []string, numeric bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		if !containsMember(b, x, numeric) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsMember(set []string, member string, numeric bool) bool {
+	for _, s := range set {
+		if s == member {
+			return true
+		}
+		if numeric {
+			x, _ := new(big.Rat).SetString(s)
+			y, _ := new(big.Rat).SetString(member)
+			if x != nil && y != nil && x.Cmp(y) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsBinary(set [][]byte, member []byte) bool {
+	for _, b := range set {
+		if bytes.Equal(b, member) {
+			return true
+		}
+	}
+	return false
+}
+
+// size follows the size() function: length of strings, binaries, sets,
+// lists and maps.
+func size(v value) (int, bool) {
+	switch v.kind {
+	case typeS:
+		return len(v.str), true
+	case typeB:
+		return len(v.bin), true
+	case typeSS, typeNS:
+		return len(v.strs), true
+	case typeBS:
+		return len(v.bins), true
+	case typeL:
+		return len(v.l), true
+	case typeM:
+		return len(v.m), true
+	default:
+		return 0, false
+	}
+}
+
+// keyString identifies a scalar key value, numbers are normalized so 1 and
+// 1.0 are the same key.
+func keyString(v value) string {
+	switch v.kind {
+	case typeB:
+		return typeB + ":" + base64.StdEncoding.EncodeToString(v.bin)
+	default:
+		return v.kind + ":" + v.str
+	}
+}
+
+func (v value) clone() value {
+	c := v
+	if v.bin != nil {
+		c.bin = append([]byte{}, v.bin...)
+	}
+	if v.m != nil {
+		c.m = make(map[string]value, len(v.m))
+		for k, mv := range v.m {
+			c.m[k] = mv.clone()
+		}
+	}
+	if v.l != nil {
+		c.l = make([]value, len(v.l))
+		for i, lv := range v.l {
+			c.l[i] = lv.clone()
+		}
+	}
+	if v.strs != nil {
+		c.strs = append([]string{}, v.strs...)
+	}
+	if v.bins != nil {
+		c.bins = make([][]byte, len(v.bins))
+		for i, b := range v.bins {
+			c.bins[i] = append([]byte{}, b...)
+		}
+	}
+	return c
+}
+
+func (it item) clone() item {
+	if it == nil {
+		return nil
+	}
+	c := make(item, len(it))
+	for k, v := range it {
+		c[k] = v.clone()
+	}
+	return c
+}
@@ -0,0 +1,69 @@
+// Package events publishes what happened to the users for the services
+// that react to it, such as the mailers or the search index.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"time"
+)
+
+// Type names an event, as <entity>.<past tense verb>.
+type Type string
+
+const (
+	TypeUserSuspended   Type = "user.suspended"
+	TypeUserReactivated Type = "user.reactivated"
+	TypeUserClosed      Type = "user.closed"
+	TypeUserVerified    Type = "user.verified"
+)
+
+// Event is a status transition of a user.
+type Event struct {
+	Type   Type   `json:"type"`
+	UserID string `json:"user_id"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+	// Actor is the subject that caused the event.
+	Actor      string    `json:"actor"`
+	RequestID  string    `json:"request_id,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Publisher delivers the events at least once, the consumers deduplicate
+// them on the user id and the occurrence time.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+type logImpl struct {
+	log logging.Logger
+}
+
+// NewLog writes every event to log, for the local runs and the deployments
+// that collect the events from the logs.
+func NewLog(log logging.Logger) Publisher {
+	return &logImpl{log: log}
+}
+
+func (p *logImpl) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.log.WithContext(ctx).With("event", string(event.Type)).Infof("event published: %s", body)
+	return nil
+}
+
+type noopImpl struct{}
+
+// NewNoop drops every event.
+func NewNoop() Publisher {
+	return noopImpl{}
+}
+
+func (noopImpl) Publish(context.Context, Event) error {
+	return nil
+}
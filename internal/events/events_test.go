@@ -0,0 +1,96 @@
+package events_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"strings"
+	"testing"
+	"time"
+)
+
+var suspended = events.Event{
+	Type:       events.TypeUserSuspended,
+	UserID:     "usr-1",
+	From:       "active",
+	To:         "suspended",
+	Reason:     "spam",
+	Actor:      "admin-1",
+	RequestID:  "req-1",
+	OccurredAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+}
+
+type fakeSQS struct {
+	sent []*sqs.SendMessageInput
+	err  error
+}
+
+func (f *fakeSQS) SendMessage(_ context.Context, in *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.sent = append(f.sent, in)
+	return &sqs.SendMessageOutput{MessageId: aws.String("msg-1")}, nil
+}
+
+func TestSQS(t *testing.T) {
+	client := &fakeSQS{}
+	publisher := events.NewSQS(client, "https://sqs.local/users")
+	if err := publisher.Publish(context.Background(), suspended); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(client.sent) != 1 {
+		t.Fatalf("expected a single message, got %d", len(client.sent))
+	}
+	message := client.sent[0]
+	if aws.ToString(message.QueueUrl) != "https://sqs.local/users" {
+		t.Errorf("unexpected queue %q", aws.ToString(message.QueueUrl))
+	}
+	if aws.ToString(message.MessageAttributes["type"].StringValue) != "user.suspended" {
+		t.Errorf("unexpected attributes %+v", message.MessageAttributes)
+	}
+	var event events.Event
+	if err := json.Unmarshal([]byte(aws.ToString(message.MessageBody)), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event != suspended {
+		t.Errorf("expected %+v, got %+v", suspended, event)
+	}
+
+	client.err = errors.New("unavailable")
+	if err := publisher.Publish(context.Background(), suspended); !errors.Is(err, client.err) {
+		t.Errorf("expected the error of the client, got %v", err)
+	}
+}
+
+func TestLog(t *testing.T) {
+	var out bytes.Buffer
+	publisher := events.NewLog(logging.New(logging.Opts{AppName: "test", Output: &out}))
+	if err := publisher.Publish(context.Background(), suspended); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), `"event":"user.suspended"`) || !strings.Contains(out.String(), `\"reason\":\"spam\"`) {
+		t.Errorf("expected the event in the log, got %s", out.String())
+	}
+}
+
+func TestOpen(t *testing.T) {
+	log := logging.New(logging.Opts{AppName: "test", Output: &bytes.Buffer{}})
+	if _, err := events.Open(context.Background(), config.Events{Backend: config.EventsSQS}, log); err == nil {
+		t.Error("expected an error without queue url")
+	}
+	publisher, err := events.Open(context.Background(), config.Events{Backend: config.EventsOff}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = publisher.Publish(context.Background(), suspended); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
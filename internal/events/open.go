@@ -0,0 +1,31 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+)
+
+// Open returns the publisher of the EVENTS_BACKEND of cfg, a noop one when
+// off.
+func Open(ctx context.Context, cfg config.Events, log logging.Logger) (Publisher, error) {
+	switch cfg.Backend {
+	case config.EventsLog:
+		return NewLog(log), nil
+	case config.EventsSQS:
+		if cfg.QueueURL == "" {
+			return nil, errors.New("EVENTS_QUEUE_URL is required by the sqs backend")
+		}
+		awsCfg, err := awsConfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error loading aws config: %w", err)
+		}
+		return NewSQS(sqs.NewFromConfig(awsCfg), cfg.QueueURL), nil
+	default:
+		return NewNoop(), nil
+	}
+}
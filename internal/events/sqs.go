@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SQSAPI is the part of the sqs client used by NewSQS.
+type SQSAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+type sqsImpl struct {
+	client   SQSAPI
+	queueURL string
+}
+
+// NewSQS sends every event as a json message to the queue of queueURL, the
+// type is also a message attribute so the subscriptions filter on it.
+func NewSQS(client SQSAPI, queueURL string) Publisher {
+	return &sqsImpl{client: client, queueURL: queueURL}
+}
+
+func (p *sqsImpl) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(p.queueURL),
+		MessageBody: aws.String(string(body)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"type": {DataType: aws.String("String"), StringValue: aws.String(string(event.Type))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error publishing %s of user %s: %w", event.Type, event.UserID, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package fieldcrypt
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
+)
+
+// Encrypted returns an encrypted copy of the struct v points to, v is left
+// untouched.
+func Encrypted[T any](ctx context.Context, enc Encryptor, v *T) (*T, error) {
+	c := *v
+	if err := enc.Encrypt(ctx, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// MarshalMap is attributevalue.MarshalMap of an encrypted copy of in, a
+// pointer to a struct or a struct.
+func MarshalMap(ctx context.Context, enc Encryptor, in any) (map[string]types.AttributeValue, error) {
+	rv := reflect.ValueOf(in)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return attributevalue.MarshalMap(in)
+	}
+
+	c := reflect.New(rv.Type())
+	c.Elem().Set(rv)
+	if err := enc.Encrypt(ctx, c.Interface()); err != nil {
+		return nil, err
+	}
+	return attributevalue.MarshalMap(c.Interface())
+}
+
+// UnmarshalMap is attributevalue.UnmarshalMap followed by the decryption of
+// out, a pointer to a struct.
+func UnmarshalMap(ctx context.Context, enc Encryptor, item map[string]types.AttributeValue, out any) error {
+	if err := attributevalue.UnmarshalMap(item, out); err != nil {
+		return err
+	}
+	return enc.Decrypt(ctx, out)
+}
+
+// UnmarshalListOfMaps is attributevalue.UnmarshalListOfMaps followed by the
+// decryption of every struct, out is a pointer to a slice of structs or of
+// pointers to structs.
+func UnmarshalListOfMaps(ctx context.Context, enc Encryptor, items []map[string]types.AttributeValue, out any) error {
+	if err := attributevalue.UnmarshalListOfMaps(items, out); err != nil {
+		return err
+	}
+
+	list := reflect.ValueOf(out).Elem()
+	for i := 0; i < list.Len(); i++ {
+		element := list.Index(i)
+		if element.Kind() != reflect.Pointer {
+			element = element.Addr()
+		}
+		if element.IsNil() {
+			continue
+		}
+		if err := enc.Decrypt(ctx, element.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
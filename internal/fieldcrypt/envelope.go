@@ -0,0 +1,394 @@
+package fieldcrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	prefixRandomized    = "enc:v1:"
+	prefixDeterministic = "det:v1:"
+
+	nonceSize = 12
+	keySize   = 32
+
+	// unwrappedKeys bounds the data keys kept unwrapped in memory.
+	unwrappedKeys = 1000
+)
+
+// Options of New.
+type Options struct {
+	// DataKeyTTL is how long a data key encrypts new values, and how long
+	// an unwrapped data key is kept in memory.
+	DataKeyTTL time.Duration
+	// DataKeyUses is the number of values encrypted by a data key before a
+	// new one is generated.
+	DataKeyUses int
+}
+
+// DefaultOptions generate a data key every 5 minutes or 10000 values.
+func DefaultOptions() Options {
+	return Options{DataKeyTTL: 5 * time.Minute, DataKeyUses: 10000}
+}
+
+type currentKey struct {
+	key     DataKey
+	aead    cipher.AEAD
+	created time.Time
+	uses    int
+}
+
+// deterministicKey seals with aead under a nonce derived with mac from the
+// plaintext, a synthetic IV.
+type deterministicKey struct {
+	aead cipher.AEAD
+	mac  []byte
+}
+
+type encryptorImpl struct {
+	provider KeyProvider
+	opts     Options
+
+	mu            sync.Mutex
+	current       *currentKey
+	deterministic map[string]deterministicKey
+	unwrapped     cache.Cache
+}
+
+// New encrypts with the keys of provider. Data keys are reused for
+// opts.DataKeyTTL and opts.DataKeyUses values, so the provider is not called
+// for every write, and unwrapped data keys are cached for reads.
+func New(provider KeyProvider, opts Options) Encryptor {
+	return &encryptorImpl{
+		provider:      provider,
+		opts:          opts,
+		deterministic: map[string]deterministicKey{},
+		unwrapped:     cache.NewLRU(unwrappedKeys),
+	}
+}
+
+// field is a tagged string field of a struct.
+type field struct {
+	name  string
+	mode  string
+	value reflect.Value
+}
+
+// fields returns the tagged fields of the struct v points to, embedded
+// structs included.
+func fields(v any) ([]field, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("fieldcrypt: expected a pointer to a struct, got %T", v)
+	}
+
+	var out []field
+	var walk func(s reflect.Value)
+	walk = func(s reflect.Value) {
+		t := s.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				walk(s.Field(i))
+				continue
+			}
+
+			mode, ok := f.Tag.Lookup(TagEncrypt)
+			if !ok || !f.IsExported() || f.Type.Kind() != reflect.String {
+				continue
+			}
+			if mode == ModeRandomized || mode == ModeDeterministic {
+				out = append(out, field{name: f.Name, mode: mode, value: s.Field(i)})
+			}
+		}
+	}
+	walk(rv.Elem())
+	return out, nil
+}
+
+func (e *encryptorImpl) Encrypt(ctx context.Context, v any) error {
+	fs, err := fields(v)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fs {
+		plaintext := f.value.String()
+		if plaintext == "" {
+			continue
+		}
+
+		var ciphertext string
+		if f.mode == ModeDeterministic {
+			ciphertext, err = e.sealDeterministic(ctx, e.provider.KeyIDs()[0], f.name, plaintext)
+		} else {
+			ciphertext, err = e.sealRandomized(ctx, f.name, plaintext)
+		}
+		if err != nil {
+			return fmt.Errorf("error encrypting %s: %w", f.name, err)
+		}
+		f.value.SetString(ciphertext)
+	}
+	return nil
+}
+
+func (e *encryptorImpl) Decrypt(ctx context.Context, v any) error {
+	fs, err := fields(v)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fs {
+		value := f.value.String()
+
+		var plaintext string
+		switch {
+		case strings.HasPrefix(value, prefixRandomized):
+			plaintext, err = e.openRandomized(ctx, f.name, strings.TrimPrefix(value, prefixRandomized))
+		case strings.HasPrefix(value, prefixDeterministic):
+			plaintext, err = e.openDeterministic(ctx, f.name, strings.TrimPrefix(value, prefixDeterministic))
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error decrypting %s: %w", f.name, err)
+		}
+		f.value.SetString(plaintext)
+	}
+	return nil
+}
+
+func (e *encryptorImpl) Lookup(ctx context.Context, model any, field, value string) ([]string, error) {
+	switch Mode(model, field) {
+	case ModeDeterministic:
+	case ModeRandomized:
+		return nil, fmt.Errorf("%w: %s", ErrNotSearchable, field)
+	default:
+		return []string{value}, nil
+	}
+	if value == "" {
+		return []string{value}, nil
+	}
+
+	values := []string{value}
+	for _, keyID := range e.provider.KeyIDs() {
+		ciphertext, err := e.sealDeterministic(ctx, keyID, field, value)
+		if err != nil {
+			return nil, fmt.Errorf("error encrypting %s: %w", field, err)
+		}
+		values = append(values, ciphertext)
+	}
+	return values, nil
+}
+
+// additionalData binds a ciphertext to its field, so values cannot be
+// swapped between fields.
+func additionalData(mode, field string) []byte {
+	return []byte("fieldcrypt:v1:" + mode + ":" + field)
+}
+
+// sealRandomized encrypts plaintext under the current data key, the
+// ciphertext carries the wrapped data key:
+// len(keyID) | keyID | len(wrapped) | wrapped | nonce | sealed.
+func (e *encryptorImpl) sealRandomized(ctx context.Context, field, plaintext string) (string, error) {
+	key, aead, err := e.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	out := appendHeader(nil, key.KeyID)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(key.Encrypted)))
+	out = append(out, key.Encrypted...)
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, []byte(plaintext), additionalData(ModeRandomized, field))
+	return prefixRandomized + base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+func (e *encryptorImpl) openRandomized(ctx context.Context, field, encoded string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	keyID, rest, ok := readHeader(raw)
+	if !ok || len(rest) < 2 {
+		return "", ErrMalformed
+	}
+	size := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+size+nonceSize {
+		return "", ErrMalformed
+	}
+	wrapped, rest := rest[2:2+size], rest[2+size:]
+
+	aead, err := e.unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aead.Open(nil, rest[:nonceSize], rest[nonceSize:], additionalData(ModeRandomized, field))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// sealDeterministic encrypts plaintext under the deterministic key of
+// keyID with a nonce derived from the field and the plaintext:
+// len(keyID) | keyID | nonce | sealed.
+func (e *encryptorImpl) sealDeterministic(ctx context.Context, keyID, field, plaintext string) (string, error) {
+	key, err := e.deterministicKey(ctx, keyID)
+	if err != nil {
+		return "", err
+	}
+
+	aad := additionalData(ModeDeterministic, field)
+	mac := hmac.New(sha256.New, key.mac)
+	mac.Write(aad)
+	mac.Write([]byte{0})
+	mac.Write([]byte(plaintext))
+	nonce := mac.Sum(nil)[:nonceSize]
+
+	out := appendHeader(nil, keyID)
+	out = append(out, nonce...)
+	out = key.aead.Seal(out, nonce, []byte(plaintext), aad)
+	return prefixDeterministic + base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+func (e *encryptorImpl) openDeterministic(ctx context.Context, field, encoded string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	keyID, rest, ok := readHeader(raw)
+	if !ok || len(rest) < nonceSize {
+		return "", ErrMalformed
+	}
+
+	key, err := e.deterministicKey(ctx, keyID)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := key.aead.Open(nil, rest[:nonceSize], rest[nonceSize:], additionalData(ModeDeterministic, field))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func appendHeader(out []byte, keyID string) []byte {
+	out = append(out, byte(len(keyID)))
+	return append(out, keyID...)
+}
+
+func readHeader(raw []byte) (string, []byte, bool) {
+	if len(raw) < 1 || len(raw) < 1+int(raw[0]) {
+		return "", nil, false
+	}
+	size := int(raw[0])
+	return string(raw[1 : 1+size]), raw[1+size:], true
+}
+
+// dataKey returns the data key encrypting new values, a new one once the
+// current one is too old, used too often or the master key rotated.
+func (e *encryptorImpl) dataKey(ctx context.Context) (DataKey, cipher.AEAD, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c := e.current
+	if c == nil || c.key.KeyID != e.provider.KeyIDs()[0] ||
+		time.Since(c.created) >= e.opts.DataKeyTTL || c.uses >= e.opts.DataKeyUses {
+		key, err := e.provider.GenerateDataKey(ctx)
+		if err != nil {
+			return DataKey{}, nil, err
+		}
+		if len(key.KeyID) > 255 || len(key.Encrypted) > 65535 {
+			return DataKey{}, nil, fmt.Errorf("data key of %s too large", key.KeyID)
+		}
+
+		aead, err := newAEAD(key.Plaintext)
+		if err != nil {
+			return DataKey{}, nil, err
+		}
+		c = &currentKey{key: key, aead: aead, created: time.Now()}
+		e.current = c
+	}
+
+	c.uses++
+	return c.key, c.aead, nil
+}
+
+// unwrap returns the cipher of a wrapped data key, unwrapped by the
+// provider once per DataKeyTTL.
+func (e *encryptorImpl) unwrap(ctx context.Context, keyID string, wrapped []byte) (cipher.AEAD, error) {
+	sum := sha256.Sum256(append([]byte(keyID+"|"), wrapped...))
+	cacheKey := hex.EncodeToString(sum[:])
+
+	plaintext, ok, _ := e.unwrapped.Get(ctx, cacheKey)
+	if !ok {
+		var err error
+		if plaintext, err = e.provider.DecryptDataKey(ctx, keyID, wrapped); err != nil {
+			return nil, err
+		}
+		_ = e.unwrapped.Set(ctx, cacheKey, plaintext, e.opts.DataKeyTTL)
+	}
+	return newAEAD(plaintext)
+}
+
+func (e *encryptorImpl) deterministicKey(ctx context.Context, keyID string) (deterministicKey, error) {
+	e.mu.Lock()
+	key, ok := e.deterministic[keyID]
+	e.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	secret, err := e.provider.DeterministicKey(ctx, keyID)
+	if err != nil {
+		return deterministicKey{}, err
+	}
+	if key.aead, err = newAEAD(derive(secret, "fieldcrypt enc")); err != nil {
+		return deterministicKey{}, err
+	}
+	key.mac = derive(secret, "fieldcrypt mac")
+
+	e.mu.Lock()
+	e.deterministic[keyID] = key
+	e.mu.Unlock()
+	return key, nil
+}
+
+// derive returns a subkey of secret for purpose.
+func derive(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("expected a %d bytes key, got %d", keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,111 @@
+// Package fieldcrypt encrypts the personal data attributes of the models at
+// rest. String fields tagged with encrypt are replaced by their ciphertext
+// before they are written and decrypted after they are read:
+//
+//	Name  string `dynamodbav:"Name" encrypt:"true"`
+//	Email string `dynamodbav:"Email" encrypt:"deterministic"`
+//
+// Values are sealed with AES-GCM under data keys wrapped by the master keys
+// of a KeyProvider (envelope encryption). Deterministic fields always encrypt
+// a value to the same ciphertext, so they can still be looked up and kept
+// unique, at the cost of revealing which records share a value.
+package fieldcrypt
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// TagEncrypt marks the string fields to encrypt, with ModeRandomized or
+// ModeDeterministic.
+const TagEncrypt = "encrypt"
+
+const (
+	ModeRandomized    = "true"
+	ModeDeterministic = "deterministic"
+)
+
+var (
+	// ErrUnknownKey is returned for a value encrypted under a master key the
+	// provider does not hold.
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrMalformed is returned for a value that looks encrypted but cannot
+	// be parsed.
+	ErrMalformed = errors.New("malformed encrypted value")
+	// ErrNotSearchable is returned by Lookup for a field encrypted with
+	// ModeRandomized, its ciphertexts cannot be compared.
+	ErrNotSearchable = errors.New("field is not searchable")
+)
+
+// DataKey is a data key in plaintext and wrapped by the master key KeyID.
+type DataKey struct {
+	KeyID     string
+	Plaintext []byte
+	Encrypted []byte
+}
+
+// KeyProvider holds the master keys, such as KMS or NewLocal.
+type KeyProvider interface {
+	// KeyIDs are the master keys in use, the first one encrypts new values
+	// and the others are kept to read the values written before a rotation.
+	KeyIDs() []string
+	// GenerateDataKey returns a new 256 bits data key wrapped by the first
+	// master key.
+	GenerateDataKey(ctx context.Context) (DataKey, error)
+	// DecryptDataKey unwraps a data key returned by GenerateDataKey.
+	DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error)
+	// DeterministicKey returns the stable 256 bits key of the deterministic
+	// fields under the master key keyID.
+	DeterministicKey(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// Encryptor applies the encrypt tags of the structs.
+type Encryptor interface {
+	// Encrypt replaces the tagged fields of the struct v points to with
+	// their ciphertexts. Empty values are kept empty, the others are
+	// plaintexts however they look, so a value shaped like a ciphertext is
+	// encrypted too.
+	Encrypt(ctx context.Context, v any) error
+	// Decrypt replaces the ciphertexts of the struct v points to with their
+	// plaintexts. Values written before the encryption was enabled are kept
+	// as they are.
+	Decrypt(ctx context.Context, v any) error
+	// Lookup returns the stored forms of value in the field of the struct
+	// model: the plaintext and its ciphertext under every master key, to be
+	// matched with an IN condition while keys are rotated.
+	Lookup(ctx context.Context, model any, field, value string) ([]string, error)
+}
+
+// Mode returns the encrypt tag of the field of the struct model, empty when
+// the field is not encrypted.
+func Mode(model any, field string) string {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ""
+	}
+
+	f, ok := t.FieldByName(field)
+	if !ok || f.Type.Kind() != reflect.String {
+		return ""
+	}
+	return f.Tag.Get(TagEncrypt)
+}
+
+type noopImpl struct{}
+
+// NewNoop stores every field in plaintext.
+func NewNoop() Encryptor {
+	return noopImpl{}
+}
+
+func (noopImpl) Encrypt(context.Context, any) error { return nil }
+
+func (noopImpl) Decrypt(context.Context, any) error { return nil }
+
+func (noopImpl) Lookup(_ context.Context, _ any, _, value string) ([]string, error) {
+	return []string{value}, nil
+}
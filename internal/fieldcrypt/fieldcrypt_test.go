@@ -0,0 +1,373 @@
+package fieldcrypt_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type person struct {
+	ID       string `dynamodbav:"Id"`
+	Name     string `dynamodbav:"Name" encrypt:"true"`
+	Lastname string `dynamodbav:"Lastname" encrypt:"true"`
+	Email    string `dynamodbav:"Email" encrypt:"deterministic"`
+	Age      int    `dynamodbav:"Age" encrypt:"true"`
+}
+
+var (
+	key1 = fieldcrypt.LocalKey{ID: "k1", Key: bytes.Repeat([]byte{1}, 32)}
+	key2 = fieldcrypt.LocalKey{ID: "k2", Key: bytes.Repeat([]byte{2}, 32)}
+)
+
+// countingProvider counts the calls to the provider.
+type countingProvider struct {
+	fieldcrypt.KeyProvider
+	mu        sync.Mutex
+	generated int
+	decrypted int
+}
+
+func (p *countingProvider) GenerateDataKey(ctx context.Context) (fieldcrypt.DataKey, error) {
+	p.mu.Lock()
+	p.generated++
+	p.mu.Unlock()
+	return p.KeyProvider.GenerateDataKey(ctx)
+}
+
+func (p *countingProvider) DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error) {
+	p.mu.Lock()
+	p.decrypted++
+	p.mu.Unlock()
+	return p.KeyProvider.DecryptDataKey(ctx, keyID, encrypted)
+}
+
+func newProvider(t *testing.T, keys ...fieldcrypt.LocalKey) *countingProvider {
+	t.Helper()
+	provider, err := fieldcrypt.NewLocal(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &countingProvider{KeyProvider: provider}
+}
+
+func newPerson() *person {
+	return &person{ID: "usr-1", Name: "john", Lastname: "doe", Email: "john@example.com", Age: 30}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	enc := fieldcrypt.New(newProvider(t, key1), fieldcrypt.DefaultOptions())
+
+	first, second := newPerson(), newPerson()
+	for _, p := range []*person{first, second} {
+		if err := enc.Encrypt(ctx, p); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if first.ID != "usr-1" || first.Age != 30 {
+		t.Errorf("untagged fields changed: %+v", first)
+	}
+	if !strings.HasPrefix(first.Name, "enc:v1:") || strings.Contains(first.Name, "john") || first.Name == second.Name {
+		t.Errorf("expected randomized ciphertexts, got %q and %q", first.Name, second.Name)
+	}
+	if !strings.HasPrefix(first.Email, "det:v1:") || first.Email != second.Email {
+		t.Errorf("expected equal deterministic ciphertexts, got %q and %q", first.Email, second.Email)
+	}
+
+	// values shaped like ciphertexts are plaintexts, they are encrypted and
+	// read back as they were written
+	forged := &person{Name: "enc:v1:forged", Email: first.Email}
+	if err := enc.Encrypt(ctx, forged); err != nil || forged.Name == "enc:v1:forged" || forged.Email == first.Email {
+		t.Errorf("expected the forged values to be encrypted, got %+v, %v", forged, err)
+	}
+	if err := enc.Decrypt(ctx, forged); err != nil || forged.Name != "enc:v1:forged" || forged.Email != first.Email {
+		t.Errorf("expected the forged values back, got %+v, %v", forged, err)
+	}
+
+	if err := enc.Decrypt(ctx, first); err != nil || *first != *newPerson() {
+		t.Errorf("expected the original person, got %+v, %v", first, err)
+	}
+
+	// values stored before the encryption and empty values are kept
+	plain := &person{Name: "jane"}
+	if err := enc.Encrypt(ctx, &person{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.Decrypt(ctx, plain); err != nil || plain.Name != "jane" || plain.Email != "" {
+		t.Errorf("unexpected plaintext person %+v, %v", plain, err)
+	}
+}
+
+func TestCiphertextsAreBoundToTheirField(t *testing.T) {
+	ctx := context.Background()
+	enc := fieldcrypt.New(newProvider(t, key1), fieldcrypt.DefaultOptions())
+
+	p := newPerson()
+	if err := enc.Encrypt(ctx, p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.Name, p.Lastname = p.Lastname, p.Name
+	if err := enc.Decrypt(ctx, p); err == nil {
+		t.Error("expected swapped ciphertexts to fail")
+	}
+
+	for _, value := range []string{"enc:v1:!!", "enc:v1:AA", "det:v1:", "det:v1:AmsxAAAA"} {
+		if err := enc.Decrypt(ctx, &person{Name: value, Email: value}); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+
+	if err := enc.Encrypt(ctx, person{}); err == nil {
+		t.Error("expected an error for a struct that is not a pointer")
+	}
+}
+
+func TestDataKeys(t *testing.T) {
+	ctx := context.Background()
+	provider := newProvider(t, key1)
+	enc := fieldcrypt.New(provider, fieldcrypt.Options{DataKeyTTL: time.Hour, DataKeyUses: 4})
+
+	var people []*person
+	for i := 0; i < 3; i++ {
+		p := newPerson()
+		if err := enc.Encrypt(ctx, p); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		people = append(people, p)
+	}
+	// two randomized values per person, four per data key
+	if provider.generated != 2 {
+		t.Errorf("expected 2 data keys, got %d", provider.generated)
+	}
+
+	reader := fieldcrypt.New(provider, fieldcrypt.DefaultOptions())
+	for _, p := range people {
+		if err := reader.Decrypt(ctx, p); err != nil || p.Name != "john" {
+			t.Fatalf("unexpected person %+v, %v", p, err)
+		}
+	}
+	if provider.decrypted != 2 {
+		t.Errorf("expected each data key to be unwrapped once, got %d", provider.decrypted)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	ctx := context.Background()
+	old := fieldcrypt.New(newProvider(t, key1), fieldcrypt.DefaultOptions())
+	rotated := fieldcrypt.New(newProvider(t, key2, key1), fieldcrypt.DefaultOptions())
+
+	p := newPerson()
+	if err := old.Encrypt(ctx, p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	written := *p
+
+	if err := rotated.Decrypt(ctx, p); err != nil || *p != *newPerson() {
+		t.Fatalf("expected the rotated keys to read old values, got %+v, %v", p, err)
+	}
+
+	lookup, err := rotated.Lookup(ctx, person{}, "Email", "john@example.com")
+	if err != nil || len(lookup) != 3 || lookup[0] != "john@example.com" || lookup[2] != written.Email {
+		t.Fatalf("expected the plaintext and a value per key, got %v, %v", lookup, err)
+	}
+
+	if err = rotated.Encrypt(ctx, p); err != nil || p.Email != lookup[1] {
+		t.Errorf("expected new values under the current key, got %q, %v", p.Email, err)
+	}
+
+	retired := fieldcrypt.New(newProvider(t, key2), fieldcrypt.DefaultOptions())
+	if err = retired.Decrypt(ctx, &written); !errors.Is(err, fieldcrypt.ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	ctx := context.Background()
+	enc := fieldcrypt.New(newProvider(t, key1), fieldcrypt.DefaultOptions())
+
+	if _, err := enc.Lookup(ctx, &person{}, "Name", "john"); !errors.Is(err, fieldcrypt.ErrNotSearchable) {
+		t.Errorf("expected ErrNotSearchable, got %v", err)
+	}
+	if values, err := enc.Lookup(ctx, person{}, "ID", "usr-1"); err != nil || len(values) != 1 || values[0] != "usr-1" {
+		t.Errorf("expected the plaintext of an untagged field, got %v, %v", values, err)
+	}
+	if values, err := fieldcrypt.NewNoop().Lookup(ctx, person{}, "Email", "john@example.com"); err != nil || len(values) != 1 {
+		t.Errorf("expected the plaintext only, got %v, %v", values, err)
+	}
+
+	if fieldcrypt.Mode(&person{}, "Email") != fieldcrypt.ModeDeterministic || fieldcrypt.Mode(person{}, "Age") != "" || fieldcrypt.Mode(nil, "Email") != "" {
+		t.Error("unexpected modes")
+	}
+}
+
+func TestAttributeValues(t *testing.T) {
+	ctx := context.Background()
+	enc := fieldcrypt.New(newProvider(t, key1), fieldcrypt.DefaultOptions())
+
+	p := newPerson()
+	item, err := fieldcrypt.MarshalMap(ctx, enc, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Email != "john@example.com" {
+		t.Errorf("MarshalMap encrypted the value of the caller: %+v", p)
+	}
+	if email := item["Email"].(*types.AttributeValueMemberS).Value; !strings.HasPrefix(email, "det:v1:") {
+		t.Errorf("expected an encrypted email, got %s", email)
+	}
+
+	var one person
+	if err = fieldcrypt.UnmarshalMap(ctx, enc, item, &one); err != nil || one != *newPerson() {
+		t.Errorf("unexpected person %+v, %v", one, err)
+	}
+
+	var pointers []*person
+	var values []person
+	items := []map[string]types.AttributeValue{item, item}
+	if err = fieldcrypt.UnmarshalListOfMaps(ctx, enc, items, &pointers); err != nil || len(pointers) != 2 || *pointers[1] != *newPerson() {
+		t.Errorf("unexpected people %+v, %v", pointers, err)
+	}
+	if err = fieldcrypt.UnmarshalListOfMaps(ctx, enc, items, &values); err != nil || len(values) != 2 || values[0] != *newPerson() {
+		t.Errorf("unexpected people %+v, %v", values, err)
+	}
+
+	copied, err := fieldcrypt.Encrypted(ctx, enc, p)
+	if err != nil || copied.Email == p.Email || p.Email != "john@example.com" {
+		t.Errorf("unexpected copy %+v, %v", copied, err)
+	}
+}
+
+// fakeKMS wraps the data keys with a local provider and records the key
+// ids of the requests.
+type fakeKMS struct {
+	local fieldcrypt.KeyProvider
+	keyID []string
+}
+
+func (f *fakeKMS) GenerateDataKey(ctx context.Context, in *kms.GenerateDataKeyInput, _ ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	f.keyID = append(f.keyID, aws.ToString(in.KeyId))
+	if in.KeySpec != "AES_256" {
+		return nil, errors.New("unexpected key spec")
+	}
+	key, err := f.local.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GenerateDataKeyOutput{KeyId: in.KeyId, Plaintext: key.Plaintext, CiphertextBlob: key.Encrypted}, nil
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, in *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	f.keyID = append(f.keyID, aws.ToString(in.KeyId))
+	plaintext, err := f.local.DecryptDataKey(ctx, "k1", in.CiphertextBlob)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.DecryptOutput{KeyId: in.KeyId, Plaintext: plaintext}, nil
+}
+
+func TestKMS(t *testing.T) {
+	ctx := context.Background()
+	local, _ := fieldcrypt.NewLocal(key1)
+	client := &fakeKMS{local: local}
+
+	wrapped, err := local.GenerateDataKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deterministic, err := fieldcrypt.ParseWrappedKeys("alias/users=" + base64.StdEncoding.EncodeToString(wrapped.Encrypted))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	provider, err := fieldcrypt.NewKMS(client, []string{"alias/users"}, deterministic)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	enc := fieldcrypt.New(provider, fieldcrypt.DefaultOptions())
+
+	p := newPerson()
+	if err = enc.Encrypt(ctx, p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = fieldcrypt.New(provider, fieldcrypt.DefaultOptions()).Decrypt(ctx, p); err != nil || *p != *newPerson() {
+		t.Fatalf("unexpected person %+v, %v", p, err)
+	}
+	for _, keyID := range client.keyID {
+		if keyID != "alias/users" {
+			t.Errorf("unexpected key id %s", keyID)
+		}
+	}
+
+	if _, err = provider.DeterministicKey(ctx, "alias/other"); !errors.Is(err, fieldcrypt.ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	if _, err = fieldcrypt.NewKMS(client, nil, nil); err == nil {
+		t.Error("expected an error without keys")
+	}
+	if _, err = fieldcrypt.ParseWrappedKeys("alias/users"); err == nil {
+		t.Error("expected an error for a key without a value")
+	}
+}
+
+func TestParseLocalKeys(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(key1.Key)
+	keys, err := fieldcrypt.ParseLocalKeys("k2=" + base64.StdEncoding.EncodeToString(key2.Key) + ", k1=" + encoded)
+	if err != nil || len(keys) != 2 || keys[0].ID != "k2" || !bytes.Equal(keys[1].Key, key1.Key) {
+		t.Fatalf("unexpected keys %v, %v", keys, err)
+	}
+
+	for _, invalid := range []string{"k1", "k1=not base64!"} {
+		if _, err = fieldcrypt.ParseLocalKeys(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+	if _, err = fieldcrypt.ParseLocalKeys("secret-without-id"); err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected an error without the secret, got %v", err)
+	}
+
+	for _, keys := range [][]fieldcrypt.LocalKey{nil, {{ID: "short", Key: []byte("short")}}, {key1, key1}, {{Key: key1.Key}}} {
+		if _, err = fieldcrypt.NewLocal(keys...); err == nil {
+			t.Errorf("%v: expected an error", keys)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+
+	enc, err := fieldcrypt.Open(ctx, config.Encryption{Provider: config.EncryptionOff})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := newPerson()
+	if err = enc.Encrypt(ctx, p); err != nil || *p != *newPerson() {
+		t.Errorf("expected no encryption, got %+v, %v", p, err)
+	}
+
+	cfg := config.Encryption{
+		Provider:  config.EncryptionLocal,
+		LocalKeys: config.NewSecret("k1=" + base64.StdEncoding.EncodeToString(key1.Key)),
+	}
+	if enc, err = fieldcrypt.Open(ctx, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = enc.Encrypt(ctx, p); err != nil || !strings.HasPrefix(p.Email, "det:v1:") {
+		t.Errorf("expected an encrypted person, got %+v, %v", p, err)
+	}
+
+	cfg.LocalKeys = config.NewSecret("")
+	if _, err = fieldcrypt.Open(ctx, cfg); err == nil {
+		t.Error("expected an error without keys")
+	}
+}
@@ -0,0 +1,97 @@
+package fieldcrypt
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"strings"
+)
+
+// KMSAPI is the part of the kms client used by NewKMS.
+type KMSAPI interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+type kmsImpl struct {
+	client        KMSAPI
+	keyIDs        []string
+	deterministic map[string][]byte
+}
+
+// NewKMS wraps the data keys with the KMS keys of keyIDs, the first one is
+// current. KMS cannot derive a stable key, so deterministic holds a data key
+// per KMS key wrapped by it, generated once with
+// `aws kms generate-data-key-without-plaintext --key-spec AES_256`.
+func NewKMS(client KMSAPI, keyIDs []string, deterministic map[string][]byte) (KeyProvider, error) {
+	if len(keyIDs) == 0 {
+		return nil, errors.New("at least one KMS key is required")
+	}
+	for _, keyID := range keyIDs {
+		if keyID == "" || len(keyID) > 255 {
+			return nil, fmt.Errorf("invalid KMS key id %q", keyID)
+		}
+	}
+	return &kmsImpl{client: client, keyIDs: keyIDs, deterministic: deterministic}, nil
+}
+
+// ParseWrappedKeys parses a comma separated list of keyID=base64, the
+// wrapped deterministic keys of NewKMS.
+func ParseWrappedKeys(s string) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		keyID, encoded, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid wrapped key %q, expected keyID=base64", entry)
+		}
+		blob, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("wrapped key of %s is not base64", keyID)
+		}
+		keys[keyID] = blob
+	}
+	return keys, nil
+}
+
+func (p *kmsImpl) KeyIDs() []string {
+	return p.keyIDs
+}
+
+func (p *kmsImpl) GenerateDataKey(ctx context.Context) (DataKey, error) {
+	keyID := p.keyIDs[0]
+	out, err := p.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(keyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return DataKey{}, fmt.Errorf("error generating data key with %s: %w", keyID, err)
+	}
+	return DataKey{KeyID: keyID, Plaintext: out.Plaintext, Encrypted: out.CiphertextBlob}, nil
+}
+
+func (p *kmsImpl) DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error) {
+	out, err := p.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(keyID),
+		CiphertextBlob: encrypted,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key with %s: %w", keyID, err)
+	}
+	return out.Plaintext, nil
+}
+
+func (p *kmsImpl) DeterministicKey(ctx context.Context, keyID string) ([]byte, error) {
+	wrapped, ok := p.deterministic[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: no deterministic key for %s", ErrUnknownKey, keyID)
+	}
+	return p.DecryptDataKey(ctx, keyID, wrapped)
+}
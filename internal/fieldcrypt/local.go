@@ -0,0 +1,116 @@
+package fieldcrypt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// LocalKey is an AES-256 master key of NewLocal.
+type LocalKey struct {
+	ID  string
+	Key []byte
+}
+
+type localImpl struct {
+	ids  []string
+	keys map[string][]byte
+}
+
+// NewLocal wraps the data keys with master keys held in memory, for tests
+// and local runs. The first key is current, rotating means prepending a new
+// key and keeping the old ones until every value is encrypted again.
+func NewLocal(keys ...LocalKey) (KeyProvider, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+
+	p := &localImpl{keys: map[string][]byte{}}
+	for _, key := range keys {
+		if key.ID == "" || len(key.ID) > 255 {
+			return nil, fmt.Errorf("invalid key id %q", key.ID)
+		}
+		if len(key.Key) != keySize {
+			return nil, fmt.Errorf("key %s: expected %d bytes, got %d", key.ID, keySize, len(key.Key))
+		}
+		if _, ok := p.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key %s", key.ID)
+		}
+		p.ids = append(p.ids, key.ID)
+		p.keys[key.ID] = key.Key
+	}
+	return p, nil
+}
+
+// ParseLocalKeys parses a comma separated list of id=base64 keys, such as
+// "2024-06=...,2024-01=...".
+func ParseLocalKeys(s string) ([]LocalKey, error) {
+	var keys []LocalKey
+	for i, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		// the entries are secrets, errors only mention their position
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid key at position %d, expected id=base64", i+1)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s is not base64", id)
+		}
+		keys = append(keys, LocalKey{ID: id, Key: key})
+	}
+	return keys, nil
+}
+
+func (p *localImpl) KeyIDs() []string {
+	return p.ids
+}
+
+func (p *localImpl) GenerateDataKey(_ context.Context) (DataKey, error) {
+	plaintext := make([]byte, keySize)
+	if _, err := rand.Read(plaintext); err != nil {
+		return DataKey{}, err
+	}
+
+	keyID := p.ids[0]
+	aead, err := newAEAD(p.keys[keyID])
+	if err != nil {
+		return DataKey{}, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err = rand.Read(nonce); err != nil {
+		return DataKey{}, err
+	}
+	encrypted := aead.Seal(nonce, nonce, plaintext, []byte(keyID))
+	return DataKey{KeyID: keyID, Plaintext: plaintext, Encrypted: encrypted}, nil
+}
+
+func (p *localImpl) DecryptDataKey(_ context.Context, keyID string, encrypted []byte) ([]byte, error) {
+	master, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	if len(encrypted) < nonceSize {
+		return nil, ErrMalformed
+	}
+
+	aead, err := newAEAD(master)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], []byte(keyID))
+}
+
+func (p *localImpl) DeterministicKey(_ context.Context, keyID string) ([]byte, error) {
+	master, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	return derive(master, "fieldcrypt deterministic"), nil
+}
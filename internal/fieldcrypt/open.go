@@ -0,0 +1,59 @@
+package fieldcrypt
+
+import (
+	"context"
+	"fmt"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"strings"
+)
+
+// Open returns the encryptor of the FIELD_ENCRYPTION provider of cfg, a
+// noop one when off.
+func Open(ctx context.Context, cfg config.Encryption) (Encryptor, error) {
+	opts := DefaultOptions()
+	if cfg.DataKeyTTL > 0 {
+		opts.DataKeyTTL = cfg.DataKeyTTL
+	}
+
+	switch cfg.Provider {
+	case config.EncryptionLocal:
+		secret, err := cfg.LocalKeys.Value(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving FIELD_ENCRYPTION_LOCAL_KEYS: %w", err)
+		}
+		keys, err := ParseLocalKeys(secret)
+		if err != nil {
+			return nil, fmt.Errorf("FIELD_ENCRYPTION_LOCAL_KEYS: %w", err)
+		}
+		provider, err := NewLocal(keys...)
+		if err != nil {
+			return nil, fmt.Errorf("FIELD_ENCRYPTION_LOCAL_KEYS: %w", err)
+		}
+		return New(provider, opts), nil
+	case config.EncryptionKMS:
+		var keyIDs []string
+		for _, keyID := range strings.Split(cfg.KMSKeys, ",") {
+			if keyID = strings.TrimSpace(keyID); keyID != "" {
+				keyIDs = append(keyIDs, keyID)
+			}
+		}
+		deterministic, err := ParseWrappedKeys(cfg.DeterministicKeys)
+		if err != nil {
+			return nil, fmt.Errorf("FIELD_ENCRYPTION_DETERMINISTIC_KEYS: %w", err)
+		}
+
+		awsCfg, err := awsConfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error loading aws config: %w", err)
+		}
+		provider, err := NewKMS(kms.NewFromConfig(awsCfg), keyIDs, deterministic)
+		if err != nil {
+			return nil, fmt.Errorf("FIELD_ENCRYPTION_KMS_KEYS: %w", err)
+		}
+		return New(provider, opts), nil
+	default:
+		return NewNoop(), nil
+	}
+}
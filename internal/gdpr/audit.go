@@ -0,0 +1,46 @@
+package gdpr
+
+import (
+	"context"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+)
+
+// SourceAudit is the name of the audit entries.
+const SourceAudit = "audit"
+
+type auditSource struct {
+	store audit.Store
+}
+
+// NewAuditSource exports the audit trail of the user and redacts it on
+// erasure, the entries themselves are kept as the record of the mutations.
+func NewAuditSource(store audit.Store) Source {
+	return &auditSource{store: store}
+}
+
+func (s *auditSource) Name() string {
+	return SourceAudit
+}
+
+func (s *auditSource) Export(ctx context.Context, user *models.UserDB) ([]any, error) {
+	records := []any{}
+	query := audit.Query{Limit: audit.MaxPageSize}
+	for {
+		page, err := s.store.History(ctx, user.ID, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range page.Entries {
+			records = append(records, entry)
+		}
+		if page.NextCursor == "" {
+			return records, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func (s *auditSource) Erase(ctx context.Context, user *models.UserDB) (int, error) {
+	return s.store.Redact(ctx, user.ID)
+}
@@ -0,0 +1,82 @@
+// Package gdpr answers the access and erasure requests of data subjects.
+// Every store holding data about a user is a Source: Export gathers their
+// records into a Bundle and Erase removes them, leaving a Tombstone with a
+// signed Receipt as the proof of the erasure.
+package gdpr
+
+import (
+	"context"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when no user matches the subject.
+	ErrNotFound = errors.New("data subject not found")
+	// ErrInvalidSubject is returned for a subject without user id and email.
+	ErrInvalidSubject = errors.New("a user id or an email is required")
+)
+
+// Subject identifies the data subject of a request, by user id or by email.
+type Subject struct {
+	UserID string `json:"user_id,omitempty"`
+	Email  string `json:"email,omitempty" pii:"mask"`
+}
+
+func (s Subject) Validate() error {
+	if s.UserID == "" && s.Email == "" {
+		return ErrInvalidSubject
+	}
+	return nil
+}
+
+// Source is a store holding data about the users.
+type Source interface {
+	// Name is the key of the records of the source in the bundle and the
+	// receipt.
+	Name() string
+	// Export returns the records of the source about user.
+	Export(ctx context.Context, user *models.UserDB) ([]any, error)
+	// Erase deletes or anonymizes the records of the source about user and
+	// returns how many there were. Erasing twice is not an error.
+	Erase(ctx context.Context, user *models.UserDB) (int, error)
+}
+
+// Bundle is everything held about a subject, one entry per matching user.
+type Bundle struct {
+	Subject     Subject    `json:"subject"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	Users       []UserData `json:"users"`
+}
+
+// UserData holds the records of every source about a user.
+type UserData struct {
+	UserID  string           `json:"user_id"`
+	Records map[string][]any `json:"records"`
+}
+
+// Counts returns the number of records per source.
+func (d UserData) Counts() map[string]int {
+	counts := make(map[string]int, len(d.Records))
+	for name, records := range d.Records {
+		counts[name] = len(records)
+	}
+	return counts
+}
+
+// Collect exports the records of every source about user.
+func Collect(ctx context.Context, user *models.UserDB, sources []Source) (UserData, error) {
+	data := UserData{UserID: user.ID, Records: make(map[string][]any, len(sources))}
+	for _, source := range sources {
+		records, err := source.Export(ctx, user)
+		if err != nil {
+			return UserData{}, err
+		}
+		if records == nil {
+			records = []any{}
+		}
+		data.Records[source.Name()] = records
+	}
+	return data, nil
+}
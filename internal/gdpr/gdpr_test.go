@@ -0,0 +1,254 @@
+package gdpr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/gdpr"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"strings"
+	"testing"
+	"time"
+)
+
+var receiptKey = bytes.Repeat([]byte("k"), 32)
+
+func newLog() logging.Logger {
+	return logging.New(logging.Opts{AppName: "gdpr-test", Level: "error"})
+}
+
+func newReceipt() gdpr.Receipt {
+	return gdpr.Receipt{
+		ID:          "rcp-1",
+		UserID:      "usr-1",
+		RequestedBy: "admin-1",
+		ErasedAt:    time.Date(2024, 4, 1, 10, 30, 0, 0, time.UTC),
+		Records:     map[string]int{"users": 1, "audit": 3},
+		Retained:    gdpr.RetainedStores,
+	}
+}
+
+func TestSigner(t *testing.T) {
+	signer, err := gdpr.NewSigner(receiptKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	receipt := newReceipt()
+	if err = signer.Sign(&receipt); err != nil || receipt.Signature == "" || receipt.Algorithm != gdpr.AlgorithmHMACSHA256 {
+		t.Fatalf("unexpected receipt %+v, %v", receipt, err)
+	}
+	if err = signer.Verify(receipt); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+
+	// the zone of the erasure date does not change the signature
+	local := receipt
+	local.ErasedAt = receipt.ErasedAt.In(time.FixedZone("UTC-7", -7*60*60))
+	if err = signer.Verify(local); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+
+	tampered := map[string]func(r *gdpr.Receipt){
+		"user":      func(r *gdpr.Receipt) { r.UserID = "usr-2" },
+		"records":   func(r *gdpr.Receipt) { r.Records = map[string]int{"users": 1} },
+		"date":      func(r *gdpr.Receipt) { r.ErasedAt = r.ErasedAt.Add(time.Second) },
+		"retained":  func(r *gdpr.Receipt) { r.Retained = nil },
+		"algorithm": func(r *gdpr.Receipt) { r.Algorithm = "none" },
+		"signature": func(r *gdpr.Receipt) { r.Signature = "not base64!" },
+	}
+	for name, tamper := range tampered {
+		r := receipt
+		r.Records = map[string]int{"users": 1, "audit": 3}
+		tamper(&r)
+		if err = signer.Verify(r); !errors.Is(err, gdpr.ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+
+	other, _ := gdpr.NewSigner(bytes.Repeat([]byte("o"), 32))
+	if err = other.Verify(receipt); !errors.Is(err, gdpr.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature under another key, got %v", err)
+	}
+
+	if _, err = gdpr.NewSigner([]byte("short")); err == nil {
+		t.Error("expected an error for a short key")
+	}
+}
+
+func testTombstones(t *testing.T, tombstones gdpr.Tombstones) {
+	ctx := context.Background()
+	if _, err := tombstones.Get(ctx, "usr-1"); !errors.Is(err, gdpr.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	receipt := newReceipt()
+	if err := tombstones.Put(ctx, gdpr.Tombstone{UserID: "usr-1", ErasedAt: receipt.ErasedAt, Receipt: receipt}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual, err := tombstones.Get(ctx, "usr-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !actual.ErasedAt.Equal(receipt.ErasedAt) || actual.Receipt.ID != receipt.ID || actual.Receipt.Records["audit"] != 3 {
+		t.Errorf("unexpected tombstone %+v", actual)
+	}
+}
+
+func TestTombstones(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testTombstones(t, gdpr.NewMemoryTombstones())
+	})
+
+	t.Run("dynamodb", func(t *testing.T) {
+		client := dynamodbapi.NewInMemory()
+		for i := 0; i < 2; i++ {
+			if err := gdpr.CreateTable(context.Background(), client, "user-tombstones"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		testTombstones(t, gdpr.NewDynamoDBTombstones(client, "user-tombstones", newLog(), metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy())))
+	})
+}
+
+// failingSource is a source whose export fails.
+type failingSource struct{}
+
+func (failingSource) Name() string { return "failing" }
+
+func (failingSource) Export(context.Context, *models.UserDB) ([]any, error) {
+	return nil, errors.New("export failed")
+}
+
+func (failingSource) Erase(context.Context, *models.UserDB) (int, error) { return 0, nil }
+
+func TestUserSource(t *testing.T) {
+	ctx := context.Background()
+	client := dynamodbapi.NewInMemory()
+	opts := clientopts.New(clientopts.DefaultPolicy())
+	if err := db.New(client, newLog(), opts, db.NewNoopCache()).ConfigureTable(ctx, "users"); err != nil {
+		t.Fatalf("creating table: %v", err)
+	}
+	store := userstore.NewDynamoDB(client, "users", newLog(), metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "", clock.New())
+
+	user := &models.UserDB{ID: "usr-1", Name: "john", Email: "john@example.com"}
+	if err := store.Insert(ctx, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	source := gdpr.NewUserSource(store)
+	data, err := gdpr.Collect(ctx, user, []gdpr.Source{source})
+	if err != nil || data.UserID != "usr-1" || len(data.Records[gdpr.SourceUsers]) != 1 || data.Counts()[gdpr.SourceUsers] != 1 {
+		t.Fatalf("unexpected data %+v, %v", data, err)
+	}
+
+	if _, err = gdpr.Collect(ctx, user, []gdpr.Source{source, failingSource{}}); err == nil {
+		t.Error("expected the error of the failing source")
+	}
+
+	for expected := 1; expected >= 0; expected-- {
+		if erased, err := source.Erase(ctx, user); err != nil || erased != expected {
+			t.Errorf("expected %d erased, got %d, %v", expected, erased, err)
+		}
+	}
+	if _, err = store.Get(ctx, user.ID); !errors.Is(err, userstore.ErrNotFound) {
+		t.Errorf("expected the user deleted, got %v", err)
+	}
+}
+
+func TestAuditSource(t *testing.T) {
+	ctx := context.Background()
+	client := dynamodbapi.NewInMemory()
+	opts := clientopts.New(clientopts.DefaultPolicy())
+	if err := db.New(client, newLog(), opts, db.NewNoopCache()).ConfigureTable(ctx, "users"); err != nil {
+		t.Fatalf("creating table: %v", err)
+	}
+	if err := audit.CreateTable(ctx, client, "audit"); err != nil {
+		t.Fatalf("creating audit table: %v", err)
+	}
+	store := userstore.NewDynamoDB(client, "users", newLog(), metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "audit", clock.New())
+	trail := audit.NewDynamoDB(client, "audit", newLog(), metrics.NewNoop(), opts, fieldcrypt.NewNoop())
+
+	user := &models.UserDB{ID: "usr-1", Name: "john", Email: "john@example.com"}
+	if err := store.Insert(ctx, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	source := gdpr.NewAuditSource(trail)
+	data, err := gdpr.Collect(ctx, user, []gdpr.Source{source})
+	if err != nil || data.Counts()[gdpr.SourceAudit] != 1 {
+		t.Fatalf("expected the entry of the insert, got %+v, %v", data, err)
+	}
+
+	// the user is erased last, its deletion is recorded without values
+	if erased, err := source.Erase(ctx, user); err != nil || erased != 1 {
+		t.Fatalf("expected 1 redacted entry, got %d, %v", erased, err)
+	}
+	if _, err = gdpr.NewUserSource(store).Erase(ctx, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	page, err := trail.History(ctx, user.ID, audit.Query{})
+	if err != nil || len(page.Entries) != 2 {
+		t.Fatalf("expected the insert and the deletion, got %+v, %v", page, err)
+	}
+	for _, entry := range page.Entries {
+		if change := entry.Changes["email"]; change != (audit.Change{}) {
+			t.Errorf("expected no value left in %s, got %+v", entry.Action, change)
+		}
+	}
+}
+
+func TestVerificationSource(t *testing.T) {
+	ctx := context.Background()
+	issued := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := verification.NewMemoryStore()
+	if err := store.Put(ctx, verification.Record{UserID: "usr-1", Hash: "secret", IssuedAt: issued, ExpiresAt: issued.Add(time.Hour).Unix()}, issued); err != nil {
+		t.Fatal(err)
+	}
+	source := gdpr.NewVerificationSource(store)
+
+	user := &models.UserDB{ID: "usr-1"}
+	records, err := source.Export(ctx, user)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected the token, got %+v, %v", records, err)
+	}
+	if exported := fmt.Sprintf("%+v", records[0]); strings.Contains(exported, "secret") || !strings.Contains(exported, "2024-05-01 11:00:00") {
+		t.Errorf("expected the dates of the token without its hash, got %s", exported)
+	}
+
+	for _, expected := range []int{1, 0} {
+		if erased, err := source.Erase(ctx, user); err != nil || erased != expected {
+			t.Errorf("expected %d erased tokens, got %d, %v", expected, erased, err)
+		}
+	}
+	if _, err = store.Get(ctx, user.ID); !errors.Is(err, verification.ErrNotFound) {
+		t.Errorf("expected the token deleted, got %v", err)
+	}
+	if records, err = source.Export(ctx, user); err != nil || len(records) != 0 {
+		t.Errorf("expected no token, got %+v, %v", records, err)
+	}
+}
+
+func TestSubject(t *testing.T) {
+	if err := (gdpr.Subject{}).Validate(); !errors.Is(err, gdpr.ErrInvalidSubject) {
+		t.Errorf("expected ErrInvalidSubject, got %v", err)
+	}
+	for _, subject := range []gdpr.Subject{{UserID: "usr-1"}, {Email: "john@example.com"}} {
+		if err := subject.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", subject, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package gdpr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AlgorithmHMACSHA256 is the only signature algorithm of the receipts.
+const AlgorithmHMACSHA256 = "HMAC-SHA256"
+
+// RetainedStores are the stores holding data about the users that are not
+// sources, with the reason they are left. Every receipt states them, the
+// stores the erasure is expected to cover but that do not exist included.
+var RetainedStores = map[string]string{
+	"ratelimit":   "the request counters keyed by the subject are deleted by their TTL once their window ends",
+	"idempotency": "not applicable, no idempotency record of the requests is kept",
+	"outbox":      "not applicable, the status events are published to EVENTS_BACKEND without an outbox and the published ones are left to their consumers",
+}
+
+// ErrInvalidSignature is returned by Verify for a receipt that was not
+// signed with the key or was changed since.
+var ErrInvalidSignature = errors.New("invalid receipt signature")
+
+// Receipt is the proof that the data of a user was erased, it holds no
+// personal data besides the user id.
+type Receipt struct {
+	ID          string         `json:"id" dynamodbav:"Id"`
+	UserID      string         `json:"user_id" dynamodbav:"UserId"`
+	RequestedBy string         `json:"requested_by" dynamodbav:"RequestedBy"`
+	ErasedAt    time.Time      `json:"erased_at" dynamodbav:"ErasedAt"`
+	Records     map[string]int `json:"records" dynamodbav:"Records"`
+	// Retained names the stores the erasure left and why, the
+	// RetainedStores of its time.
+	Retained  map[string]string `json:"retained,omitempty" dynamodbav:"Retained,omitempty"`
+	Algorithm string            `json:"algorithm" dynamodbav:"Algorithm"`
+	Signature string            `json:"signature" dynamodbav:"Signature"`
+}
+
+type Signer interface {
+	// Sign sets the algorithm and the signature of r.
+	Sign(r *Receipt) error
+	// Verify returns ErrInvalidSignature unless r was signed by Sign.
+	Verify(r Receipt) error
+}
+
+type hmacSigner struct {
+	key []byte
+}
+
+// NewSigner signs the receipts with HMAC-SHA256 under key, which has to be
+// at least 32 bytes.
+func NewSigner(key []byte) (Signer, error) {
+	if len(key) < sha256.Size {
+		return nil, errors.New("the receipt key has to be at least 32 bytes")
+	}
+	return &hmacSigner{key: key}, nil
+}
+
+func (s *hmacSigner) Sign(r *Receipt) error {
+	r.Algorithm = AlgorithmHMACSHA256
+	mac, err := s.mac(*r)
+	if err != nil {
+		return err
+	}
+	r.Signature = base64.RawURLEncoding.EncodeToString(mac)
+	return nil
+}
+
+func (s *hmacSigner) Verify(r Receipt) error {
+	signature, err := base64.RawURLEncoding.DecodeString(r.Signature)
+	if err != nil || r.Algorithm != AlgorithmHMACSHA256 {
+		return ErrInvalidSignature
+	}
+	mac, err := s.mac(r)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// mac authenticates the JSON of r without its signature, encoding/json
+// sorts the keys of Records so the encoding is stable.
+func (s *hmacSigner) mac(r Receipt) ([]byte, error) {
+	r.Signature = ""
+	r.ErasedAt = r.ErasedAt.UTC()
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, s.key)
+	h.Write(payload)
+	return h.Sum(nil), nil
+}
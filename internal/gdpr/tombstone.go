@@ -0,0 +1,186 @@
+package gdpr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"sync"
+	"time"
+)
+
+const (
+	operationGetTombstone = "GetTombstone"
+	operationPutTombstone = "PutTombstone"
+
+	attributeUserID = "UserId"
+)
+
+// Tombstone records that a user was erased, so a repeated request is
+// answered with the same receipt.
+type Tombstone struct {
+	UserID   string    `dynamodbav:"UserId"`
+	ErasedAt time.Time `dynamodbav:"ErasedAt"`
+	Receipt  Receipt   `dynamodbav:"Receipt"`
+}
+
+type Tombstones interface {
+	// Put writes the tombstone, replacing the one of the same user.
+	Put(ctx context.Context, t Tombstone) error
+	// Get returns the tombstone of the user, ErrNotFound when there is none.
+	Get(ctx context.Context, userID string) (*Tombstone, error)
+}
+
+type memoryTombstones struct {
+	mu    sync.Mutex
+	items map[string]Tombstone
+}
+
+// NewMemoryTombstones keeps the tombstones in memory, for tests and the
+// command line.
+func NewMemoryTombstones() Tombstones {
+	return &memoryTombstones{items: map[string]Tombstone{}}
+}
+
+func (m *memoryTombstones) Put(_ context.Context, t Tombstone) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[t.UserID] = t
+	return nil
+}
+
+func (m *memoryTombstones) Get(_ context.Context, userID string) (*Tombstone, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.items[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+// DynamoDBAPI is the part of the client used by NewDynamoDBTombstones.
+type DynamoDBAPI interface {
+	dynamodbapi.ItemGetter
+	dynamodbapi.ItemPutter
+}
+
+type dynamoTombstones struct {
+	conn      DynamoDBAPI
+	tableName string
+	log       logging.Logger
+	metrics   metrics.Metrics
+	opts      clientopts.Options
+}
+
+// NewDynamoDBTombstones keeps the tombstones in the table created by
+// CreateTable, keyed by user id.
+func NewDynamoDBTombstones(conn DynamoDBAPI, tableName string, log logging.Logger, m metrics.Metrics, opts clientopts.Options) Tombstones {
+	return &dynamoTombstones{conn: conn, tableName: tableName, log: log, metrics: m, opts: opts}
+}
+
+// call runs fn with the per-call context of operation and records its
+// latency.
+func (d *dynamoTombstones) call(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	callCtx, cancel, err := d.opts.Context(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	start := time.Now()
+	err = fn(callCtx)
+	d.metrics.RepositoryLatency(operation, start)
+	return d.opts.Err(operation, err)
+}
+
+func (d *dynamoTombstones) Put(ctx context.Context, t Tombstone) error {
+	item, err := attributevalue.MarshalMap(t)
+	if err != nil {
+		return err
+	}
+
+	return d.call(ctx, operationPutTombstone, func(ctx context.Context) error {
+		out, err := d.conn.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:              aws.String(d.tableName),
+			Item:                   item,
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		}, tracing.DynamoDB, d.opts.DynamoDB)
+		if err == nil {
+			d.metrics.ConsumedCapacity(operationPutTombstone, out.ConsumedCapacity)
+		}
+		return err
+	})
+}
+
+func (d *dynamoTombstones) Get(ctx context.Context, userID string) (*Tombstone, error) {
+	var out *dynamodb.GetItemOutput
+	err := d.call(ctx, operationGetTombstone, func(ctx context.Context) (err error) {
+		out, err = d.conn.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:              aws.String(d.tableName),
+			Key:                    map[string]types.AttributeValue{attributeUserID: &types.AttributeValueMemberS{Value: userID}},
+			ConsistentRead:         aws.Bool(true),
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		}, tracing.DynamoDB, d.opts.DynamoDB)
+		return err
+	})
+	if err != nil {
+		d.log.WithContext(ctx).Errorf("error reading tombstone of %s: %v", userID, err)
+		return nil, err
+	}
+	d.metrics.ConsumedCapacity(operationGetTombstone, out.ConsumedCapacity)
+
+	if len(out.Item) == 0 {
+		return nil, ErrNotFound
+	}
+
+	var t Tombstone
+	if err = attributevalue.UnmarshalMap(out.Item, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// TableAPI is the part of the client used by CreateTable.
+type TableAPI interface {
+	dynamodbapi.TableCreator
+	dynamodbapi.TableDescriber
+}
+
+// CreateTable creates the table of NewDynamoDBTombstones when missing and
+// waits until it is active.
+func CreateTable(ctx context.Context, conn TableAPI, tableName string) error {
+	_, err := conn.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String(attributeUserID), AttributeType: types.ScalarAttributeTypeS}},
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String(attributeUserID), KeyType: types.KeyTypeHash}},
+		BillingMode:          types.BillingModePayPerRequest,
+	}, tracing.DynamoDB)
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("error creating table %s: %w", tableName, err)
+	}
+
+	maxWait := clientopts.InitTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(conn, func(o *dynamodb.TableExistsWaiterOptions) {
+		o.MinDelay = 500 * time.Millisecond
+		o.MaxDelay = 2 * time.Second
+		o.ClientOptions = append(o.ClientOptions, tracing.DynamoDB)
+	})
+	if err = waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, maxWait); err != nil {
+		return fmt.Errorf("error waiting for table %s: %w", tableName, err)
+	}
+	return nil
+}
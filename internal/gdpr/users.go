@@ -0,0 +1,42 @@
+package gdpr
+
+import (
+	"context"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+)
+
+// SourceUsers is the name of the user records.
+const SourceUsers = "users"
+
+type userSource struct {
+	store userstore.UserRepository
+}
+
+// NewUserSource exports the user record and deletes it on erasure. It has
+// to be the last source erased, the others are found through the user.
+func NewUserSource(store userstore.UserRepository) Source {
+	return &userSource{store: store}
+}
+
+func (s *userSource) Name() string {
+	return SourceUsers
+}
+
+func (s *userSource) Export(_ context.Context, user *models.UserDB) ([]any, error) {
+	return []any{user}, nil
+}
+
+func (s *userSource) Erase(ctx context.Context, user *models.UserDB) (int, error) {
+	// the audit entry of the deletion must not keep the erased values
+	err := s.store.Delete(audit.WithErasure(ctx), user.ID)
+	if errors.Is(err, userstore.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,57 @@
+package gdpr
+
+import (
+	"context"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"time"
+)
+
+// SourceVerification is the name of the verification tokens.
+const SourceVerification = "verification"
+
+type verificationSource struct {
+	store verification.Store
+}
+
+// verificationRecord is the exported token, its hash is a credential.
+type verificationRecord struct {
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewVerificationSource exports the live verification token of the user
+// and deletes it on erasure.
+func NewVerificationSource(store verification.Store) Source {
+	return &verificationSource{store: store}
+}
+
+func (s *verificationSource) Name() string {
+	return SourceVerification
+}
+
+func (s *verificationSource) Export(ctx context.Context, user *models.UserDB) ([]any, error) {
+	r, err := s.store.Get(ctx, user.ID)
+	if errors.Is(err, verification.ErrNotFound) {
+		return []any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []any{verificationRecord{IssuedAt: r.IssuedAt.UTC(), ExpiresAt: time.Unix(r.ExpiresAt, 0).UTC()}}, nil
+}
+
+func (s *verificationSource) Erase(ctx context.Context, user *models.UserDB) (int, error) {
+	r, err := s.store.Get(ctx, user.ID)
+	if errors.Is(err, verification.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err = s.store.Delete(ctx, user.ID, r.Hash); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
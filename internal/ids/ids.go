@@ -0,0 +1,196 @@
+// Package ids generates the ids of the users. They sort by creation time,
+// UUIDv7 or ULID, and carry an optional type prefix such as usr_. Validate
+// checks an id on the read paths, before it reaches the database.
+package ids
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatUUIDv7 = "uuidv7"
+	FormatULID   = "ulid"
+
+	// MaxPrefixLength caps the prefix, separator included.
+	MaxPrefixLength = 16
+)
+
+var (
+	// ErrInvalidID is returned by Validate for an id no generator issues.
+	ErrInvalidID = errors.New("invalid id")
+	// ErrInvalidPrefix is returned for a prefix other than lowercase
+	// letters and digits followed by an underscore.
+	ErrInvalidPrefix = errors.New("invalid id prefix")
+)
+
+type IDGenerator interface {
+	// NewID returns a new id, later ids sort after the earlier ones up to
+	// the precision of the clock.
+	NewID() string
+}
+
+var (
+	prefixPattern = regexp.MustCompile(`^[a-z][a-z0-9]*_$`)
+	uuidPattern   = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	ulidPattern   = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+func validatePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	if len(prefix) > MaxPrefixLength || !prefixPattern.MatchString(prefix) {
+		return fmt.Errorf("%w %q", ErrInvalidPrefix, prefix)
+	}
+	return nil
+}
+
+// Validate reports whether id is a lowercase UUID or a ULID, after an
+// optional prefix. Any UUID version is accepted, so the ids issued before
+// the time-sortable ones stay readable.
+func Validate(id string) error {
+	body := id
+	if i := strings.IndexByte(id, '_'); i >= 0 {
+		if validatePrefix(id[:i+1]) != nil {
+			return ErrInvalidID
+		}
+		body = id[i+1:]
+	}
+
+	if uuidPattern.MatchString(body) || ulidPattern.MatchString(body) {
+		return nil
+	}
+	return ErrInvalidID
+}
+
+// New returns the generator of format, FormatUUIDv7 or FormatULID, whose
+// ids start with prefix.
+func New(format, prefix string) (IDGenerator, error) {
+	switch format {
+	case FormatUUIDv7:
+		return NewUUIDv7(prefix)
+	case FormatULID:
+		return NewULID(prefix)
+	default:
+		return nil, fmt.Errorf("unknown id format %q", format)
+	}
+}
+
+type uuidv7Impl struct {
+	prefix string
+}
+
+// NewUUIDv7 issues RFC 9562 version 7 UUIDs, in their lowercase text form.
+func NewUUIDv7(prefix string) (IDGenerator, error) {
+	if err := validatePrefix(prefix); err != nil {
+		return nil, err
+	}
+	return &uuidv7Impl{prefix: prefix}, nil
+}
+
+func (g *uuidv7Impl) NewID() string {
+	// NewV7 only fails when the system random source does
+	return g.prefix + uuid.Must(uuid.NewV7()).String()
+}
+
+// crockford is the base32 alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type ulidImpl struct {
+	prefix string
+
+	mu sync.Mutex
+	// lastMillis and lastRandom make the previous id, its random part is
+	// incremented within the same millisecond so ids stay ordered.
+	lastMillis uint64
+	lastRandom [10]byte
+}
+
+// NewULID issues ULIDs, 48 bits of milliseconds and 80 random bits in
+// Crockford base32. Ids of the same millisecond are monotonic.
+func NewULID(prefix string) (IDGenerator, error) {
+	if err := validatePrefix(prefix); err != nil {
+		return nil, err
+	}
+	return &ulidImpl{prefix: prefix}, nil
+}
+
+func (g *ulidImpl) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	millis := uint64(time.Now().UnixMilli())
+	if millis != g.lastMillis || !increment(&g.lastRandom) {
+		if _, err := rand.Read(g.lastRandom[:]); err != nil {
+			panic(fmt.Sprintf("reading random source: %v", err))
+		}
+		g.lastMillis = millis
+	}
+
+	var raw [16]byte
+	for i := 0; i < 6; i++ {
+		raw[i] = byte(g.lastMillis >> (40 - 8*i))
+	}
+	copy(raw[6:], g.lastRandom[:])
+	return g.prefix + encodeULID(raw)
+}
+
+// increment adds one to the big endian b, false when it overflows.
+func increment(b *[10]byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID writes the 128 bits of raw as 26 base32 characters, the first
+// one holds the 3 high bits.
+func encodeULID(raw [16]byte) string {
+	out := make([]byte, 26)
+	// 26 characters are 130 bits, the accumulator starts with the 2
+	// leading zero bits
+	var acc uint32
+	bits := uint(2)
+	j := 0
+	for _, b := range raw {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[j] = crockford[(acc>>bits)&31]
+			j++
+		}
+	}
+	return string(out)
+}
+
+type sequentialImpl struct {
+	prefix string
+
+	mu   sync.Mutex
+	next uint64
+}
+
+// NewSequential issues the UUIDv7-shaped ids 00000000-0000-7000-8000-000000000001,
+// ...02 and so on after prefix, so tests can predict them. Not for
+// production, the ids restart with every generator.
+func NewSequential(prefix string) IDGenerator {
+	return &sequentialImpl{prefix: prefix}
+}
+
+func (g *sequentialImpl) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.next++
+	return fmt.Sprintf("%s00000000-0000-7000-8000-%012x", g.prefix, g.next)
+}
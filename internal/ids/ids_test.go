@@ -0,0 +1,143 @@
+package ids_test
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ids"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerators(t *testing.T) {
+	for _, format := range []string{ids.FormatUUIDv7, ids.FormatULID} {
+		t.Run(format, func(t *testing.T) {
+			gen, err := ids.New(format, "usr_")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			seen := map[string]bool{}
+			previous := ""
+			for i := 0; i < 1000; i++ {
+				id := gen.NewID()
+				if !strings.HasPrefix(id, "usr_") {
+					t.Fatalf("expected the prefix, got %s", id)
+				}
+				if err = ids.Validate(id); err != nil {
+					t.Fatalf("%s: unexpected error %v", id, err)
+				}
+				if seen[id] {
+					t.Fatalf("duplicate id %s", id)
+				}
+				if id <= previous {
+					t.Fatalf("expected %s after %s", id, previous)
+				}
+				seen[id] = true
+				previous = id
+			}
+		})
+	}
+}
+
+func TestUUIDv7(t *testing.T) {
+	gen, err := ids.NewUUIDv7("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	before := time.Now().Add(-time.Millisecond)
+	parsed, err := uuid.Parse(gen.NewID())
+	if err != nil || parsed.Version() != 7 {
+		t.Fatalf("expected a version 7 uuid, got %v, %v", parsed, err)
+	}
+	sec, nsec := parsed.Time().UnixTime()
+	if created := time.Unix(sec, nsec); created.Before(before) || created.After(time.Now().Add(time.Millisecond)) {
+		t.Errorf("expected the time of the id close to now, got %v", created)
+	}
+}
+
+func TestULID(t *testing.T) {
+	gen, err := ids.NewULID("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the first 10 characters hold the milliseconds
+	before := strings.ToUpper(ulidTime(time.Now().UnixMilli()))
+	id := gen.NewID()
+	after := strings.ToUpper(ulidTime(time.Now().UnixMilli()))
+	if len(id) != 26 || id[:10] < before || id[:10] > after {
+		t.Errorf("expected the time of the id between %s and %s, got %s", before, after, id)
+	}
+}
+
+// ulidTime encodes the milliseconds as the time part of a ULID.
+func ulidTime(millis int64) string {
+	const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	out := make([]byte, 10)
+	for i := 9; i >= 0; i-- {
+		out[i] = crockford[millis&31]
+		millis >>= 5
+	}
+	return string(out)
+}
+
+func TestSequential(t *testing.T) {
+	gen := ids.NewSequential("usr_")
+	for _, expected := range []string{
+		"usr_00000000-0000-7000-8000-000000000001",
+		"usr_00000000-0000-7000-8000-000000000002",
+	} {
+		if id := gen.NewID(); id != expected {
+			t.Errorf("expected %s, got %s", expected, id)
+		}
+	}
+	if err := ids.Validate(ids.NewSequential("").NewID()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, id := range []string{
+		"0190f0b2-5c4e-7a7b-9c3d-5e6f7a8b9c0d",
+		"usr_0190f0b2-5c4e-7a7b-9c3d-5e6f7a8b9c0d",
+		// ids issued before the time-sortable ones
+		"6f1c2a3b-4d5e-4f60-8a7b-9c0d1e2f3a4b",
+		"01HZX3Q5N8J9K2M4P6R7S8T9V0",
+		"usr_01HZX3Q5N8J9K2M4P6R7S8T9V0",
+	} {
+		if err := ids.Validate(id); err != nil {
+			t.Errorf("%s: unexpected error %v", id, err)
+		}
+	}
+
+	for _, id := range []string{
+		"",
+		"1234",
+		"usr_",
+		"USR_0190f0b2-5c4e-7a7b-9c3d-5e6f7a8b9c0d",
+		"0190F0B2-5C4E-7A7B-9C3D-5E6F7A8B9C0D",
+		"0190f0b2-5c4e-7a7b-9c3d-5e6f7a8b9c0d ",
+		"usr_usr_0190f0b2-5c4e-7a7b-9c3d-5e6f7a8b9c0d",
+		// the first character of a ULID holds 3 bits only
+		"81HZX3Q5N8J9K2M4P6R7S8T9V0",
+		"01HZX3Q5N8J9K2M4P6R7S8T9VU",
+		"' OR 1=1 --",
+	} {
+		if err := ids.Validate(id); !errors.Is(err, ids.ErrInvalidID) {
+			t.Errorf("%q: expected ErrInvalidID, got %v", id, err)
+		}
+	}
+}
+
+func TestInvalidConfiguration(t *testing.T) {
+	for _, prefix := range []string{"usr", "Usr_", "_", "a_very_long_prefix_", "usr-"} {
+		if _, err := ids.NewUUIDv7(prefix); !errors.Is(err, ids.ErrInvalidPrefix) {
+			t.Errorf("%q: expected ErrInvalidPrefix, got %v", prefix, err)
+		}
+	}
+	if _, err := ids.New("uuidv4", ""); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
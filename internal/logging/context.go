@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	FieldApp          = "app"
+	FieldRequestID    = "request_id"
+	FieldAPIRequestID = "apigw_request_id"
+	FieldTraceID      = "trace_id"
+
+	// HeaderLogLevel lowers (or raises) the log level for a single request.
+	HeaderLogLevel = "X-Log-Level"
+
+	traceIDContextKey = "x-amzn-trace-id"
+	traceIDEnv        = "_X_AMZN_TRACE_ID"
+)
+
+type fieldsKey struct{}
+
+// Fields are the correlation data attached to every line logged for a request.
+type Fields struct {
+	RequestID    string
+	APIRequestID string
+	TraceID      string
+	Level        *slog.Level
+}
+
+// WithAPIGatewayRequest stores the API Gateway request id and the level
+// requested through the X-Log-Level header in ctx.
+func WithAPIGatewayRequest(ctx context.Context, req events.APIGatewayProxyRequest) context.Context {
+	fields := fieldsFrom(ctx)
+	fields.APIRequestID = req.RequestContext.RequestID
+
+	for key, value := range req.Headers {
+		if strings.EqualFold(key, HeaderLogLevel) && len(value) > 0 {
+			level := ParseLevel(value)
+			fields.Level = &level
+			break
+		}
+	}
+
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// WithLevel overrides the log level for every logger derived from ctx.
+func WithLevel(ctx context.Context, level string) context.Context {
+	fields := fieldsFrom(ctx)
+	lvl := ParseLevel(level)
+	fields.Level = &lvl
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// FieldsFromContext collects the lambda request id, the api gateway request id
+// and the X-Ray trace id available in ctx.
+func FieldsFromContext(ctx context.Context) Fields {
+	fields := fieldsFrom(ctx)
+
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		fields.RequestID = lc.AwsRequestID
+	}
+
+	fields.TraceID = traceID(ctx)
+	return fields
+}
+
+func fieldsFrom(ctx context.Context) Fields {
+	if fields, ok := ctx.Value(fieldsKey{}).(Fields); ok {
+		return fields
+	}
+	return Fields{}
+}
+
+// traceID extracts the Root segment of the X-Ray trace header, for example
+// "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1".
+func traceID(ctx context.Context) string {
+	header, _ := ctx.Value(traceIDContextKey).(string)
+	if len(header) == 0 {
+		header = os.Getenv(traceIDEnv)
+	}
+
+	for _, part := range strings.Split(header, ";") {
+		if root, ok := strings.CutPrefix(strings.TrimSpace(part), "Root="); ok {
+			return root
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,180 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/encoding"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Logger is the request-aware logger shared by handlers, services and
+// repositories. WithContext must be called with the invocation ctx so every
+// line carries the correlation fields of the request being processed.
+type Logger interface {
+	Debug(args ...any)
+	Debugf(format string, args ...any)
+	Info(args ...any)
+	Infof(format string, args ...any)
+	Warn(args ...any)
+	Warnf(format string, args ...any)
+	Error(args ...any)
+	Errorf(format string, args ...any)
+	Fatal(args ...any)
+	Fatalf(format string, args ...any)
+	With(key string, value any) Logger
+	WithContext(ctx context.Context) Logger
+}
+
+type Opts struct {
+	AppName string
+	Level   string
+	// Output defaults to os.Stdout, which is shipped to CloudWatch by the runtime.
+	Output io.Writer
+}
+
+type loggerImpl struct {
+	base  *slog.Logger
+	level slog.Level
+}
+
+func New(opts Opts) Logger {
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	// the handler accepts everything, filtering happens in the logger so the
+	// level can be lowered for a single request
+	handler := slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug})
+
+	return &loggerImpl{
+		base:  slog.New(handler).With(slog.String(FieldApp, opts.AppName)),
+		level: ParseLevel(opts.Level),
+	}
+}
+
+// ParseLevel converts the LOG_LEVEL values (debug, info, warn, error) into a
+// slog.Level, unknown values fall back to info.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug", "trace":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error", "fatal", "panic":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func (l *loggerImpl) With(key string, value any) Logger {
+	return &loggerImpl{base: l.base.With(key, value), level: l.level}
+}
+
+func (l *loggerImpl) WithContext(ctx context.Context) Logger {
+	fields := FieldsFromContext(ctx)
+
+	child := &loggerImpl{base: l.base, level: l.level}
+	if fields.Level != nil {
+		child.level = *fields.Level
+	}
+
+	var attrs []any
+	if len(fields.RequestID) > 0 {
+		attrs = append(attrs, slog.String(FieldRequestID, fields.RequestID))
+	}
+	if len(fields.APIRequestID) > 0 {
+		attrs = append(attrs, slog.String(FieldAPIRequestID, fields.APIRequestID))
+	}
+	if len(fields.TraceID) > 0 {
+		attrs = append(attrs, slog.String(FieldTraceID, fields.TraceID))
+	}
+
+	if len(attrs) > 0 {
+		child.base = child.base.With(attrs...)
+	}
+
+	return child
+}
+
+func (l *loggerImpl) log(level slog.Level, msg string) {
+	if level < l.level {
+		return
+	}
+
+	l.base.Log(context.Background(), level, msg)
+}
+
+func (l *loggerImpl) Debug(args ...any) {
+	l.log(slog.LevelDebug, fmt.Sprint(redact(args)...))
+}
+
+func (l *loggerImpl) Debugf(format string, args ...any) {
+	l.log(slog.LevelDebug, fmt.Sprintf(format, redact(args)...))
+}
+
+func (l *loggerImpl) Info(args ...any) {
+	l.log(slog.LevelInfo, fmt.Sprint(redact(args)...))
+}
+
+func (l *loggerImpl) Infof(format string, args ...any) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, redact(args)...))
+}
+
+func (l *loggerImpl) Warn(args ...any) {
+	l.log(slog.LevelWarn, fmt.Sprint(redact(args)...))
+}
+
+func (l *loggerImpl) Warnf(format string, args ...any) {
+	l.log(slog.LevelWarn, fmt.Sprintf(format, redact(args)...))
+}
+
+func (l *loggerImpl) Error(args ...any) {
+	l.log(slog.LevelError, fmt.Sprint(redact(args)...))
+}
+
+func (l *loggerImpl) Errorf(format string, args ...any) {
+	l.log(slog.LevelError, fmt.Sprintf(format, redact(args)...))
+}
+
+func (l *loggerImpl) Fatal(args ...any) {
+	l.base.Log(context.Background(), slog.LevelError, fmt.Sprint(redact(args)...))
+	os.Exit(1)
+}
+
+func (l *loggerImpl) Fatalf(format string, args ...any) {
+	l.base.Log(context.Background(), slog.LevelError, fmt.Sprintf(format, redact(args)...))
+	os.Exit(1)
+}
+
+// redact replaces structs, slices and maps passed as arguments with their json
+// representation after applying the pii tags, so a model logged with %v never
+// leaks personal data. Errors, Stringers and scalars are left untouched.
+func redact(args []any) []any {
+	out := make([]any, len(args))
+	for i, arg := range args {
+		out[i] = arg
+
+		switch arg.(type) {
+		case nil, error, fmt.Stringer, time.Time, []byte:
+			continue
+		}
+
+		v := reflect.ValueOf(arg)
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+			out[i] = encoding.ToLogString(arg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"strings"
+	"testing"
+)
+
+func readLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if len(raw) == 0 {
+			continue
+		}
+
+		var line map[string]any
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatalf("line is not json: %s", raw)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("json output filtered by level", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := logging.New(logging.Opts{AppName: "test-app", Level: "info", Output: &buf})
+
+		log.Debug("hidden")
+		log.Infof("hello %s", "world")
+
+		lines := readLines(t, &buf)
+		if len(lines) != 1 {
+			t.Fatalf("expected 1 line, got %d", len(lines))
+		}
+
+		if lines[0]["msg"] != "hello world" || lines[0]["level"] != "INFO" || lines[0]["app"] != "test-app" {
+			t.Errorf("unexpected line: %v", lines[0])
+		}
+	})
+
+	t.Run("request fields from context", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := logging.New(logging.Opts{AppName: "test-app", Level: "info", Output: &buf})
+
+		ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "awsRequestId1234"})
+		ctx = context.WithValue(ctx, "x-amzn-trace-id", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+		ctx = logging.WithAPIGatewayRequest(ctx, events.APIGatewayProxyRequest{
+			RequestContext: events.APIGatewayProxyRequestContext{RequestID: "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"},
+		})
+
+		log.WithContext(ctx).Error("failed")
+
+		lines := readLines(t, &buf)
+		if len(lines) != 1 {
+			t.Fatalf("expected 1 line, got %d", len(lines))
+		}
+
+		expected := map[string]string{
+			logging.FieldRequestID:    "awsRequestId1234",
+			logging.FieldAPIRequestID: "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+			logging.FieldTraceID:      "1-5759e988-bd862e3fe1be46a994272793",
+		}
+		for key, value := range expected {
+			if lines[0][key] != value {
+				t.Errorf("%s: expected %s, got %v", key, value, lines[0][key])
+			}
+		}
+	})
+
+	t.Run("debug header lowers the level for one request", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := logging.New(logging.Opts{AppName: "test-app", Level: "error", Output: &buf})
+
+		ctx := logging.WithAPIGatewayRequest(context.Background(), events.APIGatewayProxyRequest{
+			Headers: map[string]string{"x-log-level": "debug"},
+		})
+
+		log.WithContext(ctx).Debug("visible")
+		log.WithContext(context.Background()).Debug("hidden")
+		log.Info("hidden")
+
+		lines := readLines(t, &buf)
+		if len(lines) != 1 || lines[0]["msg"] != "visible" {
+			t.Fatalf("unexpected lines: %v", lines)
+		}
+	})
+
+	t.Run("custom fields", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := logging.New(logging.Opts{AppName: "test-app", Level: "debug", Output: &buf})
+
+		log.With("table", "users").Warn("slow")
+
+		lines := readLines(t, &buf)
+		if len(lines) != 1 || lines[0]["table"] != "users" || lines[0]["level"] != "WARN" {
+			t.Fatalf("unexpected lines: %v", lines)
+		}
+	})
+	t.Run("pii is redacted from arguments", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := logging.New(logging.Opts{AppName: "test-app", Level: "debug", Output: &buf})
+
+		user := &models.UserDB{ID: "1", Name: "john", Email: "john.smith@test.com"}
+		log.Debug(user)
+		log.Debugf("user found: %v", *user)
+
+		if strings.Contains(buf.String(), "john.smith@test.com") {
+			t.Fatalf("email leaked: %s", buf.String())
+		}
+
+		lines := readLines(t, &buf)
+		if len(lines) != 2 || !strings.Contains(lines[1]["msg"].(string), "j********h@test.com") {
+			t.Fatalf("unexpected lines: %v", lines)
+		}
+	})
+}
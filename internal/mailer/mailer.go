@@ -0,0 +1,71 @@
+// Package mailer sends the emails of the application, such as the
+// verification of the addresses of the users.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"net/mail"
+	"strings"
+)
+
+// ErrInvalidMessage is returned for a message without a valid recipient or
+// with a line break in a header.
+var ErrInvalidMessage = errors.New("invalid message")
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Validate rejects the messages that could inject headers.
+func (m Message) Validate() error {
+	if _, err := mail.ParseAddress(m.To); err != nil || strings.ContainsAny(m.To, "\r\n") {
+		return fmt.Errorf("%w: recipient is not a valid address", ErrInvalidMessage)
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return fmt.Errorf("%w: subject holds a line break", ErrInvalidMessage)
+	}
+	return nil
+}
+
+type Mailer interface {
+	// Send delivers msg from the sender of the mailer.
+	Send(ctx context.Context, msg Message) error
+}
+
+type logImpl struct {
+	log logging.Logger
+}
+
+// NewLog writes the messages to log instead of sending them, for local
+// runs. The text, which holds credentials such as the verification links,
+// is only written at debug level.
+func NewLog(log logging.Logger) Mailer {
+	return &logImpl{log: log}
+}
+
+func (m *logImpl) Send(ctx context.Context, msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	log := m.log.WithContext(ctx)
+	log.Infof("mail %q to %s not sent, the log mailer is configured", msg.Subject, msg.To)
+	log.Debugf("mail text: %s", msg.Text)
+	return nil
+}
+
+type noopImpl struct{}
+
+// NewNoop drops every message.
+func NewNoop() Mailer {
+	return noopImpl{}
+}
+
+func (noopImpl) Send(context.Context, Message) error {
+	return nil
+}
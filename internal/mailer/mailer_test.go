@@ -0,0 +1,130 @@
+package mailer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/mailer"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/smtpfake"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+var welcome = mailer.Message{
+	To:      "john@example.com",
+	Subject: "Verify your email, John ✓",
+	Text:    "Open https://app.example.com/verify?token=abc.def to verify.\n",
+}
+
+func startServer(t *testing.T, opts smtpfake.Options) smtpfake.Server {
+	t.Helper()
+	server, err := smtpfake.Start(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	return server
+}
+
+func TestSMTP(t *testing.T) {
+	server := startServer(t, smtpfake.Options{Username: "relay", Password: "secret"})
+	m, err := mailer.NewSMTP(mailer.SMTPOptions{Addr: server.Addr(), From: "no-reply@example.com", Username: "relay", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = m.Send(ctx, welcome); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected a single message, got %d", len(messages))
+	}
+	if messages[0].From != "no-reply@example.com" || len(messages[0].To) != 1 || messages[0].To[0] != "john@example.com" {
+		t.Errorf("unexpected envelope %+v", messages[0])
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(messages[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != welcome.Subject {
+		t.Errorf("expected subject %q, got %q (%v)", welcome.Subject, subject, err)
+	}
+	if parsed.Header.Get("Message-ID") == "" || parsed.Header.Get("Date") == "" {
+		t.Errorf("missing headers %v", parsed.Header)
+	}
+	body, _ := io.ReadAll(parsed.Body)
+	// the quoted-printable encoding escapes = and may soft break the lines
+	if text := strings.ReplaceAll(string(body), "=\r\n", ""); !strings.Contains(text, "token=3Dabc.def") {
+		t.Errorf("expected the link in the body, got %q", body)
+	}
+}
+
+func TestSMTPErrors(t *testing.T) {
+	server := startServer(t, smtpfake.Options{Username: "relay", Password: "secret"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wrongPassword, _ := mailer.NewSMTP(mailer.SMTPOptions{Addr: server.Addr(), From: "no-reply@example.com", Username: "relay", Password: "wrong"})
+	if err := wrongPassword.Send(ctx, welcome); err == nil {
+		t.Error("expected an error with a wrong password")
+	}
+
+	tls, _ := mailer.NewSMTP(mailer.SMTPOptions{Addr: server.Addr(), From: "no-reply@example.com", TLS: true})
+	if err := tls.Send(ctx, welcome); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("expected STARTTLS to be required, got %v", err)
+	}
+
+	noAuth, _ := mailer.NewSMTP(mailer.SMTPOptions{Addr: server.Addr(), From: "no-reply@example.com"})
+	injected := welcome
+	injected.Subject = "hello\r\nBcc: everyone@example.com"
+	if err := noAuth.Send(ctx, injected); !errors.Is(err, mailer.ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage, got %v", err)
+	}
+	if len(server.Messages()) != 0 {
+		t.Errorf("expected no message, got %+v", server.Messages())
+	}
+
+	if _, err := mailer.NewSMTP(mailer.SMTPOptions{Addr: "no-port", From: "no-reply@example.com"}); err == nil {
+		t.Error("expected an error for an address without port")
+	}
+}
+
+func TestLog(t *testing.T) {
+	var out bytes.Buffer
+	m := mailer.NewLog(logging.New(logging.Opts{AppName: "test", Level: "info", Output: &out}))
+	if err := m.Send(context.Background(), welcome); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "john@example.com") || strings.Contains(out.String(), "token=") {
+		t.Errorf("expected the recipient without the text at info level, got %s", out.String())
+	}
+	if err := m.Send(context.Background(), mailer.Message{To: "not an address"}); !errors.Is(err, mailer.ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage, got %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	log := logging.New(logging.Opts{AppName: "test", Output: &bytes.Buffer{}})
+	if _, err := mailer.Open(context.Background(), config.Mailer{Backend: config.MailerSMTP, SMTPAddr: "localhost", From: "no-reply@example.com"}, log); err == nil {
+		t.Error("expected an error for an address without port")
+	}
+	m, err := mailer.Open(context.Background(), config.Mailer{Backend: config.MailerOff}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Send(context.Background(), welcome); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
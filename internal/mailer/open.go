@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+)
+
+// Open returns the mailer of the MAILER_BACKEND of cfg, a noop one when off.
+func Open(ctx context.Context, cfg config.Mailer, log logging.Logger) (Mailer, error) {
+	switch cfg.Backend {
+	case config.MailerLog:
+		return NewLog(log), nil
+	case config.MailerSMTP:
+		password, err := cfg.SMTPPassword.Value(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving MAILER_SMTP_PASSWORD: %w", err)
+		}
+		m, err := NewSMTP(SMTPOptions{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.From,
+			Username: cfg.SMTPUsername,
+			Password: password,
+			TLS:      cfg.SMTPTLS,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("MAILER_SMTP_ADDR or MAILER_FROM: %w", err)
+		}
+		return m, nil
+	default:
+		return NewNoop(), nil
+	}
+}
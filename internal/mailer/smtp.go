@@ -0,0 +1,140 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPOptions configure NewSMTP.
+type SMTPOptions struct {
+	// Addr is the host:port of the relay.
+	Addr string
+	// From is the sender of every message.
+	From     string
+	Username string
+	Password string
+	// TLS requires STARTTLS before authenticating, the relays on the
+	// loopback interface may do without.
+	TLS bool
+}
+
+type smtpImpl struct {
+	opts SMTPOptions
+	host string
+}
+
+// NewSMTP sends every message through the relay of opts, one connection per
+// message. The deadline of the context bounds the whole exchange.
+func NewSMTP(opts SMTPOptions) (Mailer, error) {
+	host, _, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid relay address %q: %w", opts.Addr, err)
+	}
+	if _, err = mail.ParseAddress(opts.From); err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", opts.From, err)
+	}
+	return &smtpImpl{opts: opts, host: host}, nil
+}
+
+func (m *smtpImpl) Send(ctx context.Context, msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	body, err := m.format(msg)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %w", m.opts.Addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("error greeting %s: %w", m.opts.Addr, err)
+	}
+	defer client.Close()
+
+	if m.opts.TLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("the relay does not offer STARTTLS")
+		}
+		if err = client.StartTLS(&tls.Config{ServerName: m.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("error starting tls: %w", err)
+		}
+	}
+	if m.opts.Username != "" {
+		// net/smtp refuses PLAIN without TLS unless the relay is local
+		if err = client.Auth(smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.host)); err != nil {
+			return fmt.Errorf("error authenticating: %w", err)
+		}
+	}
+
+	if err = client.Mail(m.opts.From); err != nil {
+		return err
+	}
+	if err = client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(body); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// format renders msg as a quoted-printable UTF-8 text message.
+func (m *smtpImpl) format(msg Message) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	headers := [][2]string{
+		{"From", m.opts.From},
+		{"To", msg.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", time.Now().UTC().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + hex.EncodeToString(id) + "@" + m.host + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `text/plain; charset="utf-8"`},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", header[0], header[1])
+	}
+	buf.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(msg.Text)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
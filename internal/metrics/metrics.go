@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DimensionFunction  = "function"
+	DimensionOperation = "operation"
+
+	MetricHandlerLatency    = "HandlerLatency"
+	MetricRepositoryLatency = "RepositoryLatency"
+	MetricConsumedCapacity  = "ConsumedCapacity"
+	MetricValidationFailure = "ValidationFailures"
+	MetricConflict          = "Conflicts"
+	MetricNotFound          = "NotFound"
+	MetricColdStart         = "ColdStart"
+	MetricCacheHit          = "CacheHit"
+	MetricUnauthenticated   = "Unauthenticated"
+	MetricForbidden         = "Forbidden"
+	MetricThrottled         = "Throttled"
+	MetricRateLimitFallback = "RateLimitFallbacks"
+)
+
+type Unit string
+
+const (
+	UnitMilliseconds Unit = "Milliseconds"
+	UnitCount        Unit = "Count"
+	UnitNone         Unit = "None"
+)
+
+// Metrics records the operational metrics of a lambda, every datum is
+// dimensioned by function and operation (handler or repository method).
+type Metrics interface {
+	// HandlerLatency records the time elapsed since start, meant to be deferred.
+	HandlerLatency(operation string, start time.Time)
+	// RepositoryLatency records the time elapsed since start, meant to be deferred.
+	RepositoryLatency(operation string, start time.Time)
+	// ConsumedCapacity records the capacity units returned by dynamodb, nil is ignored.
+	ConsumedCapacity(operation string, cc *types.ConsumedCapacity)
+	// Increment adds one to a counter such as MetricConflict or MetricNotFound.
+	Increment(operation, name string)
+	// ColdStart counts the first invocation handled by the container.
+	ColdStart(operation string)
+	// CacheLookup records 1 for a hit and 0 for a miss, the average of
+	// MetricCacheHit is the hit ratio.
+	CacheLookup(operation string, hit bool)
+}
+
+type metricsImpl struct {
+	function string
+	sink     Sink
+	warm     atomic.Bool
+}
+
+func New(function string, sink Sink) Metrics {
+	return &metricsImpl{function: function, sink: sink}
+}
+
+// NewNoop discards every metric.
+func NewNoop() Metrics {
+	return New("", NewNoopSink())
+}
+
+func (m *metricsImpl) HandlerLatency(operation string, start time.Time) {
+	m.emit(operation, MetricHandlerLatency, UnitMilliseconds, milliseconds(time.Since(start)))
+}
+
+func (m *metricsImpl) RepositoryLatency(operation string, start time.Time) {
+	m.emit(operation, MetricRepositoryLatency, UnitMilliseconds, milliseconds(time.Since(start)))
+}
+
+func (m *metricsImpl) ConsumedCapacity(operation string, cc *types.ConsumedCapacity) {
+	if cc == nil || cc.CapacityUnits == nil {
+		return
+	}
+
+	m.emit(operation, MetricConsumedCapacity, UnitNone, *cc.CapacityUnits)
+}
+
+func (m *metricsImpl) Increment(operation, name string) {
+	m.emit(operation, name, UnitCount, 1)
+}
+
+func (m *metricsImpl) ColdStart(operation string) {
+	if m.warm.Swap(true) {
+		return
+	}
+
+	m.emit(operation, MetricColdStart, UnitCount, 1)
+}
+
+func (m *metricsImpl) CacheLookup(operation string, hit bool) {
+	var value float64
+	if hit {
+		value = 1
+	}
+
+	m.emit(operation, MetricCacheHit, UnitNone, value)
+}
+
+func (m *metricsImpl) emit(operation, name string, unit Unit, value float64) {
+	m.sink.Write(Entry{
+		Timestamp: time.Now(),
+		Dimensions: map[string]string{
+			DimensionFunction:  m.function,
+			DimensionOperation: operation,
+		},
+		Name:  name,
+		Unit:  unit,
+		Value: value,
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
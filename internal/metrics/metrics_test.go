@@ -0,0 +1,89 @@
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("memory sink", func(t *testing.T) {
+		sink := metrics.NewMemorySink()
+		m := metrics.New("create-user-lambda", sink)
+
+		m.ColdStart("HandleCreateUser")
+		m.ColdStart("HandleCreateUser")
+		m.HandlerLatency("HandleCreateUser", time.Now().Add(-15*time.Millisecond))
+		m.Increment("HandleCreateUser", metrics.MetricConflict)
+		m.ConsumedCapacity("InsertUser", &types.ConsumedCapacity{CapacityUnits: aws.Float64(1)})
+		m.ConsumedCapacity("InsertUser", nil)
+		m.CacheLookup("FindDocumentById", true)
+		m.CacheLookup("FindDocumentById", false)
+		m.CacheLookup("FindDocumentById", true)
+
+		if sink.Sum("HandleCreateUser", metrics.MetricColdStart) != 1 {
+			t.Errorf("cold start must be emitted once")
+		}
+
+		latency := sink.Values("HandleCreateUser", metrics.MetricHandlerLatency)
+		if len(latency) != 1 || latency[0] < 15 {
+			t.Errorf("unexpected latency: %v", latency)
+		}
+
+		if sink.Sum("HandleCreateUser", metrics.MetricConflict) != 1 {
+			t.Errorf("conflict not counted")
+		}
+
+		if capacity := sink.Values("InsertUser", metrics.MetricConsumedCapacity); len(capacity) != 1 || capacity[0] != 1 {
+			t.Errorf("unexpected capacity: %v", capacity)
+		}
+
+		if hits := sink.Values("FindDocumentById", metrics.MetricCacheHit); len(hits) != 3 || sink.Sum("FindDocumentById", metrics.MetricCacheHit) != 2 {
+			t.Errorf("unexpected cache lookups: %v", hits)
+		}
+
+		entry := sink.Entries()[0]
+		if entry.Dimensions[metrics.DimensionFunction] != "create-user-lambda" {
+			t.Errorf("unexpected dimensions: %v", entry.Dimensions)
+		}
+	})
+
+	t.Run("emf sink", func(t *testing.T) {
+		var buf bytes.Buffer
+		m := metrics.New("get-document-lambda", metrics.NewEMFSink(&buf, "users-api"))
+
+		m.Increment("HandleGetUser", metrics.MetricNotFound)
+
+		var line map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatalf("line is not json: %s", buf.String())
+		}
+
+		if line["function"] != "get-document-lambda" || line["operation"] != "HandleGetUser" || line[metrics.MetricNotFound] != float64(1) {
+			t.Errorf("unexpected line: %s", buf.String())
+		}
+
+		directive := line["_aws"].(map[string]any)["CloudWatchMetrics"].([]any)[0].(map[string]any)
+		if directive["Namespace"] != "users-api" {
+			t.Errorf("unexpected namespace: %v", directive)
+		}
+
+		dimensions := directive["Dimensions"].([]any)[0].([]any)
+		if len(dimensions) != 2 || dimensions[0] != "function" || dimensions[1] != "operation" {
+			t.Errorf("unexpected dimensions: %v", dimensions)
+		}
+
+		metric := directive["Metrics"].([]any)[0].(map[string]any)
+		if metric["Name"] != metrics.MetricNotFound || metric["Unit"] != "Count" {
+			t.Errorf("unexpected metric: %v", metric)
+		}
+	})
+
+	t.Run("noop", func(t *testing.T) {
+		metrics.NewNoop().Increment("HandleRequest", metrics.MetricConflict)
+	})
+}
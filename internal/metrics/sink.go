@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is a single metric observation.
+type Entry struct {
+	Timestamp  time.Time
+	Dimensions map[string]string
+	Name       string
+	Unit       Unit
+	Value      float64
+}
+
+type Sink interface {
+	Write(entry Entry)
+}
+
+type emfSink struct {
+	mu        sync.Mutex
+	enc       *json.Encoder
+	namespace string
+}
+
+// NewEMFSink writes every entry as a CloudWatch Embedded Metric Format line,
+// lambda forwards stdout to CloudWatch Logs which extracts the metrics.
+func NewEMFSink(w io.Writer, namespace string) Sink {
+	return &emfSink{enc: json.NewEncoder(w), namespace: namespace}
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+func (s *emfSink) Write(entry Entry) {
+	dimensions := make([]string, 0, len(entry.Dimensions))
+	line := make(map[string]any, len(entry.Dimensions)+2)
+	for key, value := range entry.Dimensions {
+		dimensions = append(dimensions, key)
+		line[key] = value
+	}
+	sort.Strings(dimensions)
+
+	line[entry.Name] = entry.Value
+	line["_aws"] = emfMetadata{
+		Timestamp: entry.Timestamp.UnixMilli(),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  s.namespace,
+			Dimensions: [][]string{dimensions},
+			Metrics:    []emfMetric{{Name: entry.Name, Unit: entry.Unit}},
+		}},
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// metrics must never break a request, encoding errors are dropped
+	_ = s.enc.Encode(line)
+}
+
+type noopSink struct{}
+
+func NewNoopSink() Sink {
+	return noopSink{}
+}
+
+func (noopSink) Write(Entry) {}
+
+// MemorySink keeps every entry in memory so tests can assert on them.
+type MemorySink struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Write(entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+}
+
+func (s *MemorySink) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry(nil), s.entries...)
+}
+
+// Values returns the values recorded for name and operation, in order.
+func (s *MemorySink) Values(operation, name string) []float64 {
+	var values []float64
+	for _, entry := range s.Entries() {
+		if entry.Name == name && entry.Dimensions[DimensionOperation] == operation {
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
+
+// Sum adds every value recorded for name and operation.
+func (s *MemorySink) Sum(operation, name string) float64 {
+	var sum float64
+	for _, value := range s.Values(operation, name) {
+		sum += value
+	}
+	return sum
+}
+
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = nil
+}
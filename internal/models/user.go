@@ -1,13 +1,65 @@
 package models
 
-import "time"
+import (
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"time"
+)
 
+// DefaultStatus is the status of the users stored before the lifecycle,
+// which have none.
+const DefaultStatus = string(domain.StatusActive)
+
+// UserDB is the stored user, the fields tagged with encrypt are encrypted
+// at rest when FIELD_ENCRYPTION is on, Email deterministically so it can
+// still be looked up.
 type UserDB struct {
-	ID        string    `dynamodbav:"Id" json:"id"`
-	Name      string    `dynamodbav:"Name" json:"name"`
-	Lastname  string    `dynamodbav:"Lastname" json:"lastname"`
-	Age       int32     `dynamodbav:"Age" json:"age"`
-	Email     string    `dynamodbav:"Email" json:"email"`
-	CreatedAt time.Time `dynamodbav:"CreatedAt" json:"created_at"`
-	UpdatedAt time.Time `dynamodbav:"UpdatedAt" json:"updated_at"`
+	ID       string `dynamodbav:"Id" json:"id"`
+	Name     string `dynamodbav:"Name" json:"name" pii:"mask" encrypt:"true"`
+	Lastname string `dynamodbav:"Lastname" json:"lastname" pii:"mask" encrypt:"true"`
+	Age      int32  `dynamodbav:"Age" json:"age"`
+	Email    string `dynamodbav:"Email" json:"email" pii:"mask" encrypt:"deterministic"`
+	// Status is empty for the users of DefaultStatus stored before the
+	// lifecycle.
+	Status       string    `dynamodbav:"Status,omitempty" json:"status,omitempty"`
+	StatusReason string    `dynamodbav:"StatusReason,omitempty" json:"status_reason,omitempty"`
+	OwnerSubject string    `dynamodbav:"OwnerSubject,omitempty" json:"owner_subject,omitempty"`
+	CreatedAt    time.Time `dynamodbav:"CreatedAt" json:"created_at"`
+	UpdatedAt    time.Time `dynamodbav:"UpdatedAt" json:"updated_at"`
+}
+
+// UserFromDomain returns the stored form of user, its times in UTC.
+func UserFromDomain(user domain.User) *UserDB {
+	return &UserDB{
+		ID:           user.ID,
+		Name:         user.Name,
+		Lastname:     user.Lastname,
+		Age:          user.Age,
+		Email:        user.Email,
+		Status:       string(user.Status),
+		StatusReason: user.StatusReason,
+		OwnerSubject: user.OwnerSubject,
+		CreatedAt:    user.CreatedAt.UTC(),
+		UpdatedAt:    user.UpdatedAt.UTC(),
+	}
+}
+
+// ToDomain returns the user the services work with, its times in UTC
+// whatever the zone they were stored in.
+func (u *UserDB) ToDomain() domain.User {
+	status := u.Status
+	if status == "" {
+		status = DefaultStatus
+	}
+	return domain.User{
+		ID:           u.ID,
+		Name:         u.Name,
+		Lastname:     u.Lastname,
+		Age:          u.Age,
+		Email:        u.Email,
+		Status:       domain.Status(status),
+		StatusReason: u.StatusReason,
+		OwnerSubject: u.OwnerSubject,
+		CreatedAt:    u.CreatedAt.UTC(),
+		UpdatedAt:    u.UpdatedAt.UTC(),
+	}
 }
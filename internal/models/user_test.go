@@ -0,0 +1,59 @@
+package models_test
+
+import (
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/testutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUserMapsEveryField(t *testing.T) {
+	var user domain.User
+	testutil.Fill(&user)
+
+	stored := models.UserFromDomain(user)
+	if zero := testutil.ZeroFields(stored); len(zero) != 0 {
+		t.Errorf("fields of UserDB left unmapped: %v", zero)
+	}
+	if unmapped := testutil.UnmappedFields(user, stored); len(unmapped) != 0 {
+		t.Errorf("fields of domain.User missing from UserDB: %v", unmapped)
+	}
+	if back := stored.ToDomain(); !reflect.DeepEqual(back, user) {
+		t.Errorf("expected the same user after the round trip\nwant %+v\ngot  %+v", user, back)
+	}
+
+	var fromDB models.UserDB
+	testutil.Fill(&fromDB)
+	if zero := testutil.ZeroFields(fromDB.ToDomain()); len(zero) != 0 {
+		t.Errorf("fields of domain.User left unmapped: %v", zero)
+	}
+}
+
+func TestUserTimesInUTC(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, paris)
+
+	stored := models.UserFromDomain(domain.User{ID: "usr-1", CreatedAt: created, UpdatedAt: created})
+	if stored.CreatedAt.Location() != time.UTC || !stored.CreatedAt.Equal(created) {
+		t.Errorf("expected %v in UTC, got %v", created, stored.CreatedAt)
+	}
+
+	// users written before the timestamps were normalized
+	legacy := &models.UserDB{ID: "usr-1", CreatedAt: created, UpdatedAt: created}
+	if user := legacy.ToDomain(); user.UpdatedAt.Location() != time.UTC || !user.UpdatedAt.Equal(created) {
+		t.Errorf("expected %v in UTC, got %v", created, user.UpdatedAt)
+	}
+}
+
+func TestUserWithoutStatus(t *testing.T) {
+	// users stored before the lifecycle
+	legacy := &models.UserDB{ID: "usr-1"}
+	if status := legacy.ToDomain().Status; status != domain.StatusActive {
+		t.Errorf("expected %s, got %s", domain.StatusActive, status)
+	}
+}
@@ -0,0 +1,46 @@
+// Package problem writes error responses in the application/problem+json
+// format of RFC 9457.
+package problem
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"net/http"
+)
+
+const ContentType = "application/problem+json"
+
+type Problem struct {
+	// Type identifies the problem, about:blank when the status says it all.
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request.
+	Instance string `json:"instance,omitempty"`
+}
+
+// New returns a problem of type about:blank titled after status.
+func New(status int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Response renders p, headers are added to the response.
+func (p Problem) Response(headers map[string]string) events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(p)
+
+	res := events.APIGatewayProxyResponse{
+		StatusCode: p.Status,
+		Headers:    map[string]string{"Content-Type": ContentType},
+		Body:       string(body),
+	}
+	for key, value := range headers {
+		res.Headers[key] = value
+	}
+	return res
+}
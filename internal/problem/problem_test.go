@@ -0,0 +1,27 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"net/http"
+	"testing"
+)
+
+func TestResponse(t *testing.T) {
+	p := problem.New(http.StatusForbidden, "missing scope users:write")
+	p.Instance = "/users"
+	res := p.Response(map[string]string{"WWW-Authenticate": "Bearer"})
+
+	if res.StatusCode != http.StatusForbidden || res.Headers["Content-Type"] != problem.ContentType || res.Headers["WWW-Authenticate"] != "Bearer" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatalf("body is not json: %s", res.Body)
+	}
+	if body["type"] != "about:blank" || body["title"] != "Forbidden" || body["status"] != float64(403) ||
+		body["detail"] != "missing scope users:write" || body["instance"] != "/users" {
+		t.Errorf("unexpected body: %s", res.Body)
+	}
+}
@@ -0,0 +1,296 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	operationGetBucket = "GetRateLimitBucket"
+	operationPutBucket = "PutRateLimitBucket"
+
+	attributeKey       = "Key"
+	attributeTokens    = "Tokens"
+	attributeUpdatedAt = "UpdatedAt"
+	attributeVersion   = "Version"
+	attributeExpiresAt = "ExpiresAt"
+
+	// maxAttempts bounds the retries of a bucket written concurrently by
+	// another container.
+	maxAttempts = 3
+)
+
+// ErrContention is returned when the bucket kept changing under the limiter.
+var ErrContention = errors.New("rate limit bucket updated concurrently")
+
+// DynamoDBAPI is the part of the client used by NewDynamoDB.
+type DynamoDBAPI interface {
+	dynamodbapi.ItemGetter
+	dynamodbapi.ItemPutter
+}
+
+type dynamoImpl struct {
+	conn      DynamoDBAPI
+	tableName string
+	log       logging.Logger
+	metrics   metrics.Metrics
+	opts      clientopts.Options
+	now       func() time.Time
+
+	// local mirrors the buckets in the container, a request it refuses
+	// would be refused by the table as well since the table sees at least
+	// the requests of this container.
+	local Limiter
+
+	mu sync.Mutex
+	// blocked remembers until when the table refused a key.
+	blocked map[string]time.Time
+}
+
+// NewDynamoDB keeps the buckets in the table created by CreateTable, shared
+// by every container. Buckets are written with optimistic concurrency on
+// their version, and an in-memory fast path refuses the callers already
+// throttled without reading the table. now is the clock, time.Now when nil.
+func NewDynamoDB(conn DynamoDBAPI, tableName string, log logging.Logger, m metrics.Metrics, opts clientopts.Options, now func() time.Time) Limiter {
+	if now == nil {
+		now = time.Now
+	}
+	return &dynamoImpl{
+		conn:      conn,
+		tableName: tableName,
+		log:       log,
+		metrics:   m,
+		opts:      opts,
+		now:       now,
+		local:     NewMemory(now),
+		blocked:   map[string]time.Time{},
+	}
+}
+
+// call runs fn with the per-call context of operation and records its
+// latency.
+func (l *dynamoImpl) call(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	callCtx, cancel, err := l.opts.Context(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	start := time.Now()
+	err = fn(callCtx)
+	l.metrics.RepositoryLatency(operation, start)
+	return l.opts.Err(operation, err)
+}
+
+func (l *dynamoImpl) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	if d, ok := l.fastPath(ctx, key, limit); ok {
+		return d, nil
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		b, version, err := l.get(ctx, key, limit)
+		if err != nil {
+			return Decision{}, err
+		}
+
+		now := l.now()
+		d := b.take(limit, now)
+		if !d.Allowed {
+			l.block(key, now.Add(d.RetryAfter))
+			return d, nil
+		}
+
+		err = l.put(ctx, key, b, version, now.Add(limit.after(float64(limit.Burst))))
+		if err == nil {
+			return d, nil
+		}
+		if !isConditionalCheckFailed(err) {
+			return Decision{}, err
+		}
+		l.log.WithContext(ctx).Debugf("bucket %s updated concurrently, attempt %d", key, attempt+1)
+	}
+	return Decision{}, ErrContention
+}
+
+// fastPath refuses key without reading the table, while the table refused
+// it before or the local bucket is empty.
+func (l *dynamoImpl) fastPath(ctx context.Context, key string, limit Limit) (Decision, bool) {
+	now := l.now()
+
+	l.mu.Lock()
+	until, blocked := l.blocked[key]
+	if blocked && !now.Before(until) {
+		delete(l.blocked, key)
+		blocked = false
+	}
+	l.mu.Unlock()
+
+	if blocked {
+		return Decision{Limit: limit, RetryAfter: until.Sub(now), Reset: limit.after(float64(limit.Burst))}, true
+	}
+
+	d, _ := l.local.Allow(ctx, key, limit)
+	return d, !d.Allowed
+}
+
+func (l *dynamoImpl) block(key string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.blocked) >= sweepSize {
+		now := l.now()
+		for k, u := range l.blocked {
+			if !now.Before(u) {
+				delete(l.blocked, k)
+			}
+		}
+	}
+	l.blocked[key] = until
+}
+
+// get returns the bucket of key and its version, a full bucket of version
+// zero when there is none.
+func (l *dynamoImpl) get(ctx context.Context, key string, limit Limit) (bucket, int64, error) {
+	var out *dynamodb.GetItemOutput
+	err := l.call(ctx, operationGetBucket, func(ctx context.Context) (err error) {
+		out, err = l.conn.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:              aws.String(l.tableName),
+			Key:                    map[string]types.AttributeValue{attributeKey: &types.AttributeValueMemberS{Value: key}},
+			ConsistentRead:         aws.Bool(true),
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		}, tracing.DynamoDB, l.opts.DynamoDB)
+		return err
+	})
+	if err != nil {
+		return bucket{}, 0, err
+	}
+	l.metrics.ConsumedCapacity(operationGetBucket, out.ConsumedCapacity)
+
+	if len(out.Item) == 0 {
+		return fullBucket(limit, l.now()), 0, nil
+	}
+
+	tokens, err := number(out.Item, attributeTokens)
+	if err != nil {
+		return bucket{}, 0, err
+	}
+	updated, err := number(out.Item, attributeUpdatedAt)
+	if err != nil {
+		return bucket{}, 0, err
+	}
+	version, err := number(out.Item, attributeVersion)
+	if err != nil {
+		return bucket{}, 0, err
+	}
+	return bucket{tokens: tokens, updated: time.Unix(0, int64(updated))}, int64(version), nil
+}
+
+// put writes b when the stored bucket still has version. The item expires
+// once the bucket is full again, the table has a TTL on ExpiresAt.
+func (l *dynamoImpl) put(ctx context.Context, key string, b bucket, version int64, expires time.Time) error {
+	return l.call(ctx, operationPutBucket, func(ctx context.Context) error {
+		out, err := l.conn.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(l.tableName),
+			Item: map[string]types.AttributeValue{
+				attributeKey:       &types.AttributeValueMemberS{Value: key},
+				attributeTokens:    &types.AttributeValueMemberN{Value: strconv.FormatFloat(b.tokens, 'f', -1, 64)},
+				attributeUpdatedAt: &types.AttributeValueMemberN{Value: strconv.FormatInt(b.updated.UnixNano(), 10)},
+				attributeVersion:   &types.AttributeValueMemberN{Value: strconv.FormatInt(version+1, 10)},
+				attributeExpiresAt: &types.AttributeValueMemberN{Value: strconv.FormatInt(expires.Add(time.Second).Unix(), 10)},
+			},
+			ConditionExpression:      aws.String("attribute_not_exists(#key) OR #version = :version"),
+			ExpressionAttributeNames: map[string]string{"#key": attributeKey, "#version": attributeVersion},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+			},
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		}, tracing.DynamoDB, l.opts.DynamoDB)
+		if err == nil {
+			l.metrics.ConsumedCapacity(operationPutBucket, out.ConsumedCapacity)
+		}
+		return err
+	})
+}
+
+func number(item map[string]types.AttributeValue, name string) (float64, error) {
+	n, ok := item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("rate limit bucket without %s", name)
+	}
+	return strconv.ParseFloat(n.Value, 64)
+}
+
+func isConditionalCheckFailed(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	return errors.As(err, &ccf)
+}
+
+// TableAPI is the part of the client used by CreateTable.
+type TableAPI interface {
+	dynamodbapi.TableCreator
+	dynamodbapi.TableDescriber
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+}
+
+// CreateTable creates the table of NewDynamoDB when missing, waits until it
+// is active and enables its TTL on ExpiresAt, so expired buckets are removed.
+func CreateTable(ctx context.Context, conn TableAPI, tableName string) error {
+	_, err := conn.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String(attributeKey), AttributeType: types.ScalarAttributeTypeS}},
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String(attributeKey), KeyType: types.KeyTypeHash}},
+		BillingMode:          types.BillingModePayPerRequest,
+	}, tracing.DynamoDB)
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("error creating table %s: %w", tableName, err)
+	}
+
+	maxWait := clientopts.InitTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(conn, func(o *dynamodb.TableExistsWaiterOptions) {
+		o.MinDelay = 500 * time.Millisecond
+		o.MaxDelay = 2 * time.Second
+		o.ClientOptions = append(o.ClientOptions, tracing.DynamoDB)
+	})
+	if err = waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, maxWait); err != nil {
+		return fmt.Errorf("error waiting for table %s: %w", tableName, err)
+	}
+
+	// enabling an enabled TTL is refused, so it is checked first
+	ttl, err := conn.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)}, tracing.DynamoDB)
+	if err != nil {
+		return fmt.Errorf("error describing ttl of %s: %w", tableName, err)
+	}
+	if status := ttl.TimeToLiveDescription; status != nil &&
+		(status.TimeToLiveStatus == types.TimeToLiveStatusEnabled || status.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		return nil
+	}
+	_, err = conn.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attributeExpiresAt),
+			Enabled:       aws.Bool(true),
+		},
+	}, tracing.DynamoDB)
+	if err != nil {
+		return fmt.Errorf("error enabling ttl of %s: %w", tableName, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+// Package ratelimit throttles the callers of the API with token buckets,
+// kept in memory or in a DynamoDB table shared by every container.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket of Burst tokens, refilled with Requests tokens
+// every Period. The zero Limit does not throttle.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Unlimited reports whether l lets every request through.
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s:%d", l.Requests, l.Period, l.Burst)
+}
+
+// rate is the refill rate in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// ParseLimit parses "requests/period[:burst]" such as "10/1s" or
+// "100/1m:20", the burst defaults to requests. "off" is the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return Limit{}, nil
+	}
+
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	requests, period, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected requests/period[:burst]", s)
+	}
+
+	var l Limit
+	var err error
+	if l.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil || l.Requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid requests in limit %q", s)
+	}
+	if l.Period, err = time.ParseDuration(strings.TrimSpace(period)); err != nil || l.Period <= 0 {
+		return Limit{}, fmt.Errorf("invalid period in limit %q", s)
+	}
+
+	l.Burst = l.Requests
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil || l.Burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid burst in limit %q", s)
+		}
+	}
+	return l, nil
+}
+
+// Limits are the limits of every route, keyed by method and resource such
+// as "GET /users/{id}".
+type Limits struct {
+	Default Limit
+	Routes  map[string]Limit
+}
+
+// For returns the limit of route, the default one when not configured.
+func (l Limits) For(route string) Limit {
+	if limit, ok := l.Routes[route]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// ParseRoutes parses a comma separated list of route=limit, such as
+// "GET /users=5/1s:10, GET /users/{id}=off".
+func ParseRoutes(s string) (map[string]Limit, error) {
+	routes := map[string]Limit{}
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		route, spec, ok := strings.Cut(entry, "=")
+		method, resource, hasResource := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasResource || method == "" || strings.TrimSpace(resource) == "" {
+			return nil, fmt.Errorf("invalid route limit %q, expected METHOD /resource=limit", strings.TrimSpace(entry))
+		}
+
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(resource)] = limit
+	}
+	return routes, nil
+}
+
+// Decision is the outcome of a request against its bucket.
+type Decision struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining is the number of whole tokens left.
+	Remaining int
+	// RetryAfter is how long until the next token, zero when allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Limiter takes the tokens of the buckets.
+type Limiter interface {
+	// Allow takes a token of the bucket of key, limit must not be unlimited.
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// bucket is the state of a token bucket at updated.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// fullBucket is the state of a bucket never used.
+func fullBucket(limit Limit, now time.Time) bucket {
+	return bucket{tokens: float64(limit.Burst), updated: now}
+}
+
+// take refills b up to now and takes a token when there is one, b is only
+// changed when the request is allowed.
+func (b *bucket) take(limit Limit, now time.Time) Decision {
+	tokens := b.tokens
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		tokens = math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.rate())
+	}
+
+	d := Decision{Limit: limit}
+	if tokens >= 1 {
+		tokens--
+		d.Allowed = true
+		b.tokens, b.updated = tokens, now
+	} else {
+		d.RetryAfter = limit.after(1 - tokens)
+	}
+
+	d.Remaining = int(math.Floor(tokens))
+	d.Reset = limit.after(float64(limit.Burst) - tokens)
+	return d
+}
+
+// full reports whether b is full at now, a full bucket is the same as no
+// bucket at all.
+func (b bucket) full(limit Limit, now time.Time) bool {
+	return b.tokens+now.Sub(b.updated).Seconds()*limit.rate() >= float64(limit.Burst)
+}
+
+// after is the time it takes to refill tokens.
+func (l Limit) after(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / l.rate() * float64(time.Second)))
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepSize is the number of buckets above which the full ones are dropped.
+const sweepSize = 10000
+
+type memoryEntry struct {
+	bucket bucket
+	limit  Limit
+}
+
+type memoryImpl struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryEntry
+	now     func() time.Time
+}
+
+// NewMemory keeps the buckets in the container, every container throttles
+// on its own. now is the clock, time.Now when nil.
+func NewMemory(now func() time.Time) Limiter {
+	if now == nil {
+		now = time.Now
+	}
+	return &memoryImpl{buckets: map[string]*memoryEntry{}, now: now}
+}
+
+func (l *memoryImpl) Allow(_ context.Context, key string, limit Limit) (Decision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	e, ok := l.buckets[key]
+	if !ok || e.limit != limit {
+		if len(l.buckets) >= sweepSize {
+			l.sweep(now)
+		}
+		e = &memoryEntry{bucket: fullBucket(limit, now), limit: limit}
+		l.buckets[key] = e
+	}
+	return e.bucket.take(limit, now), nil
+}
+
+// sweep drops the buckets that refilled, the callers that stopped.
+func (l *memoryImpl) sweep(now time.Time) {
+	for key, e := range l.buckets {
+		if e.bucket.full(e.limit, now) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	operationRateLimit = "RateLimit"
+
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderPolicy     = "RateLimit-Policy"
+	HeaderRetryAfter = "Retry-After"
+)
+
+type Middleware interface {
+	// Wrap throttles the callers of next with the limit of the route of the
+	// request. It runs after the authentication, to key the callers by
+	// subject.
+	Wrap(next auth.HandlerFunc) auth.HandlerFunc
+}
+
+type middlewareImpl struct {
+	limiter  Limiter
+	fallback Limiter
+	limits   Limits
+	log      logging.Logger
+	metrics  metrics.Metrics
+}
+
+// NewMiddleware answers 429 to the callers over the limit of the route,
+// with Retry-After and the RateLimit headers of the IETF draft. A nil
+// limiter turns rate limiting off. When the limiter fails, ErrContention
+// included, the request is decided by a limiter in memory, so each
+// container still holds the callers to the limit.
+func NewMiddleware(limiter Limiter, limits Limits, log logging.Logger, m metrics.Metrics) Middleware {
+	return &middlewareImpl{limiter: limiter, fallback: NewMemory(nil), limits: limits, log: log, metrics: m}
+}
+
+// Open returns the middleware of the RATE_LIMIT_BACKEND of cfg, conn is
+// only used by the dynamodb backend.
+func Open(cfg config.RateLimit, conn DynamoDBAPI, log logging.Logger, m metrics.Metrics, opts clientopts.Options) (Middleware, error) {
+	if cfg.Backend == config.RateLimitOff {
+		return NewMiddleware(nil, Limits{}, log, m), nil
+	}
+
+	var limits Limits
+	var err error
+	if limits.Default, err = ParseLimit(cfg.Default); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_DEFAULT: %w", err)
+	}
+	if limits.Routes, err = ParseRoutes(cfg.Routes); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_ROUTES: %w", err)
+	}
+
+	if cfg.Backend == config.RateLimitDynamoDB {
+		if conn == nil {
+			return nil, fmt.Errorf("RATE_LIMIT_BACKEND %s requires a DynamoDB client", cfg.Backend)
+		}
+		return NewMiddleware(NewDynamoDB(conn, cfg.Table, log, m, opts, nil), limits, log, m), nil
+	}
+	return NewMiddleware(NewMemory(nil), limits, log, m), nil
+}
+
+func (mw *middlewareImpl) Wrap(next auth.HandlerFunc) auth.HandlerFunc {
+	if mw.limiter == nil {
+		return next
+	}
+
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		log := mw.log.WithContext(logging.WithAPIGatewayRequest(ctx, req))
+
+		route := Route(req)
+		limit := mw.limits.For(route)
+		if limit.Unlimited() {
+			return next(ctx, req)
+		}
+
+		caller := Caller(ctx, req)
+		key := route + "|" + caller
+		d, err := mw.limiter.Allow(ctx, key, limit)
+		if err != nil {
+			log.Warnf("error rate limiting %s on %s, deciding in memory: %v", caller, route, err)
+			mw.metrics.Increment(operationRateLimit, metrics.MetricRateLimitFallback)
+			// the memory limiter does not fail
+			d, _ = mw.fallback.Allow(ctx, key, limit)
+		}
+
+		if !d.Allowed {
+			log.Warnf("caller %s throttled on %s for %s", caller, route, d.RetryAfter)
+			mw.metrics.Increment(operationRateLimit, metrics.MetricThrottled)
+			return TooManyRequests(req, d), nil
+		}
+
+		res, err := next(ctx, req)
+		if err == nil {
+			if res.Headers == nil {
+				res.Headers = map[string]string{}
+			}
+			for key, value := range Headers(d) {
+				res.Headers[key] = value
+			}
+		}
+		return res, err
+	}
+}
+
+// Route is the method and resource of req, such as "GET /users/{id}", the
+// path when the resource is missing.
+func Route(req events.APIGatewayProxyRequest) string {
+	resource := req.Resource
+	if resource == "" {
+		resource = req.Path
+	}
+	return req.HTTPMethod + " " + resource
+}
+
+// Caller identifies who sent req, by API key, authenticated subject or
+// source IP in that order. Only the API key validated by API Gateway is
+// used, a client could rotate the one of its headers to get a new bucket
+// per request. API keys are hashed, they are credentials.
+func Caller(ctx context.Context, req events.APIGatewayProxyRequest) string {
+	if apiKey := req.RequestContext.Identity.APIKey; apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+
+	if identity, ok := auth.FromContext(ctx); ok && identity.Subject != "" {
+		return "sub:" + identity.Subject
+	}
+	if ip := req.RequestContext.Identity.SourceIP; ip != "" {
+		return "ip:" + ip
+	}
+	return "anonymous"
+}
+
+// Headers are the RateLimit headers of d.
+func Headers(d Decision) map[string]string {
+	return map[string]string{
+		HeaderLimit:     strconv.Itoa(d.Limit.Burst),
+		HeaderRemaining: strconv.Itoa(d.Remaining),
+		HeaderReset:     seconds(d.Reset),
+		HeaderPolicy:    fmt.Sprintf("%d;w=%s;burst=%d", d.Limit.Requests, seconds(d.Limit.Period), d.Limit.Burst),
+	}
+}
+
+// TooManyRequests is the 429 problem response of a refused decision.
+func TooManyRequests(req events.APIGatewayProxyRequest, d Decision) events.APIGatewayProxyResponse {
+	retryAfter := seconds(d.RetryAfter)
+	p := problem.New(http.StatusTooManyRequests, fmt.Sprintf("rate limit of %d requests per %s exceeded, retry in %s seconds", d.Limit.Requests, d.Limit.Period, retryAfter))
+	p.Instance = req.Path
+
+	headers := Headers(d)
+	headers[HeaderRetryAfter] = retryAfter
+	return p.Response(headers)
+}
+
+// seconds rounds d up to whole seconds, the unit of the headers.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,440 @@
+package ratelimit_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbfake"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ratelimit"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newLog() logging.Logger {
+	return logging.New(logging.Opts{AppName: "ratelimit-test", Level: "error"})
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := map[string]ratelimit.Limit{
+		"10/1s":       {Requests: 10, Period: time.Second, Burst: 10},
+		"100/1m:20":   {Requests: 100, Period: time.Minute, Burst: 20},
+		" 5 / 2s : 1": {Requests: 5, Period: 2 * time.Second, Burst: 1},
+		"off":         {},
+	}
+	for spec, expected := range tests {
+		limit, err := ratelimit.ParseLimit(spec)
+		if err != nil || limit != expected {
+			t.Errorf("%q: expected %+v, got %+v, %v", spec, expected, limit, err)
+		}
+	}
+
+	for _, spec := range []string{"", "10", "0/1s", "10/0s", "10/second", "x/1s", "10/1s:0", "10/1s:x"} {
+		if _, err := ratelimit.ParseLimit(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+
+	if !(ratelimit.Limit{}).Unlimited() {
+		t.Error("expected the zero limit to be unlimited")
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ratelimit.ParseRoutes("get /users=5/1s:10, GET /users/{id}=off,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]ratelimit.Limit{
+		"GET /users":      {Requests: 5, Period: time.Second, Burst: 10},
+		"GET /users/{id}": {},
+	}
+	if len(routes) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, routes)
+	}
+	for route, limit := range expected {
+		if routes[route] != limit {
+			t.Errorf("%s: expected %v, got %v", route, limit, routes[route])
+		}
+	}
+
+	limits := ratelimit.Limits{Default: ratelimit.Limit{Requests: 1, Period: time.Second, Burst: 1}, Routes: routes}
+	if limits.For("POST /users") != limits.Default || !limits.For("GET /users/{id}").Unlimited() {
+		t.Errorf("unexpected route limits: %+v", limits)
+	}
+
+	for _, spec := range []string{"/users=5/1s", "GET=5/1s", "GET /users", "GET /users=5"} {
+		if _, err = ratelimit.ParseRoutes(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	c := newClock()
+	limiter := ratelimit.NewMemory(c.Now)
+	limit := ratelimit.Limit{Requests: 1, Period: time.Second, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		d, err := limiter.Allow(ctx, "caller", limit)
+		if err != nil || !d.Allowed || d.Remaining != 1-i {
+			t.Fatalf("request %d: unexpected decision %+v, %v", i, d, err)
+		}
+	}
+
+	d, _ := limiter.Allow(ctx, "caller", limit)
+	if d.Allowed || d.Remaining != 0 || d.RetryAfter != time.Second || d.Reset != 2*time.Second {
+		t.Fatalf("expected a refusal, got %+v", d)
+	}
+
+	// other callers have their own bucket
+	if d, _ = limiter.Allow(ctx, "other", limit); !d.Allowed {
+		t.Fatalf("expected another caller to be allowed, got %+v", d)
+	}
+
+	// refused requests do not take tokens
+	c.Advance(500 * time.Millisecond)
+	if d, _ = limiter.Allow(ctx, "caller", limit); d.Allowed || d.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected a refusal for 500ms, got %+v", d)
+	}
+
+	c.Advance(500 * time.Millisecond)
+	if d, _ = limiter.Allow(ctx, "caller", limit); !d.Allowed || d.Remaining != 0 {
+		t.Fatalf("expected a refilled token, got %+v", d)
+	}
+
+	// the bucket never holds more than the burst
+	c.Advance(time.Hour)
+	if d, _ = limiter.Allow(ctx, "caller", limit); !d.Allowed || d.Remaining != 1 {
+		t.Fatalf("expected a full bucket, got %+v", d)
+	}
+}
+
+// countingConn counts the calls to the table and runs beforePut once.
+type countingConn struct {
+	ratelimit.DynamoDBAPI
+	mu        sync.Mutex
+	calls     int
+	beforePut func()
+}
+
+func (c *countingConn) GetItem(ctx context.Context, in *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	return c.DynamoDBAPI.GetItem(ctx, in, optFns...)
+}
+
+func (c *countingConn) PutItem(ctx context.Context, in *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.mu.Lock()
+	c.calls++
+	hook := c.beforePut
+	c.beforePut = nil
+	c.mu.Unlock()
+
+	if hook != nil {
+		hook()
+	}
+	return c.DynamoDBAPI.PutItem(ctx, in, optFns...)
+}
+
+func (c *countingConn) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func newTable(t *testing.T) dynamodbapi.Client {
+	t.Helper()
+	client := dynamodbfake.New().Client()
+	if err := ratelimit.CreateTable(context.Background(), client, "rate-limits"); err != nil {
+		t.Fatalf("creating table: %v", err)
+	}
+	// creating an existing table is not an error
+	if err := ratelimit.CreateTable(context.Background(), client, "rate-limits"); err != nil {
+		t.Fatalf("creating table again: %v", err)
+	}
+
+	ttl, err := client.DescribeTimeToLive(context.Background(), &dynamodb.DescribeTimeToLiveInput{TableName: aws.String("rate-limits")})
+	if err != nil {
+		t.Fatalf("describing ttl: %v", err)
+	}
+	if d := ttl.TimeToLiveDescription; d.TimeToLiveStatus != types.TimeToLiveStatusEnabled || aws.ToString(d.AttributeName) != "ExpiresAt" {
+		t.Fatalf("unexpected ttl %+v", d)
+	}
+	return client
+}
+
+func TestDynamoDB(t *testing.T) {
+	ctx := context.Background()
+	log := newLog()
+	opts := clientopts.New(clientopts.DefaultPolicy())
+	limit := ratelimit.Limit{Requests: 2, Period: time.Minute, Burst: 2}
+
+	t.Run("shared by containers", func(t *testing.T) {
+		client := newTable(t)
+		c := newClock()
+		conn := &countingConn{DynamoDBAPI: client}
+		first := ratelimit.NewDynamoDB(conn, "rate-limits", log, metrics.NewNoop(), opts, c.Now)
+		second := ratelimit.NewDynamoDB(client, "rate-limits", log, metrics.NewNoop(), opts, c.Now)
+
+		for i, limiter := range []ratelimit.Limiter{first, second} {
+			if d, err := limiter.Allow(ctx, "caller", limit); err != nil || !d.Allowed {
+				t.Fatalf("container %d: unexpected decision %+v, %v", i, d, err)
+			}
+		}
+
+		// the local bucket of first has a token left, the table has none
+		d, err := first.Allow(ctx, "caller", limit)
+		if err != nil || d.Allowed || d.RetryAfter != 30*time.Second {
+			t.Fatalf("expected a refusal from the table, got %+v, %v", d, err)
+		}
+
+		// throttled callers are refused without reading the table
+		calls := conn.Calls()
+		c.Advance(10 * time.Second)
+		if d, _ = first.Allow(ctx, "caller", limit); d.Allowed || d.RetryAfter != 20*time.Second {
+			t.Fatalf("expected a refusal from the fast path, got %+v", d)
+		}
+		if conn.Calls() != calls {
+			t.Errorf("expected no call to the table, got %d", conn.Calls()-calls)
+		}
+
+		c.Advance(20 * time.Second)
+		if d, err = first.Allow(ctx, "caller", limit); err != nil || !d.Allowed || d.Remaining != 0 {
+			t.Fatalf("expected a refilled token, got %+v, %v", d, err)
+		}
+	})
+
+	t.Run("concurrent update", func(t *testing.T) {
+		client := newTable(t)
+		c := newClock()
+		other := ratelimit.NewDynamoDB(client, "rate-limits", log, metrics.NewNoop(), opts, c.Now)
+		conn := &countingConn{DynamoDBAPI: client}
+		conn.beforePut = func() {
+			if d, err := other.Allow(ctx, "caller", limit); err != nil || !d.Allowed {
+				t.Errorf("unexpected decision of the other container %+v, %v", d, err)
+			}
+		}
+		limiter := ratelimit.NewDynamoDB(conn, "rate-limits", log, metrics.NewNoop(), opts, c.Now)
+
+		// the first write conflicts, the second one reads both tokens taken
+		d, err := limiter.Allow(ctx, "caller", limit)
+		if err != nil || !d.Allowed || d.Remaining != 0 {
+			t.Fatalf("unexpected decision %+v, %v", d, err)
+		}
+		if d, _ = other.Allow(ctx, "caller", limit); d.Allowed {
+			t.Fatalf("expected the bucket to be empty, got %+v", d)
+		}
+	})
+
+	t.Run("missing table", func(t *testing.T) {
+		limiter := ratelimit.NewDynamoDB(dynamodbapi.NewInMemory(), "rate-limits", log, metrics.NewNoop(), opts, nil)
+		if _, err := limiter.Allow(ctx, "caller", limit); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+// failingLimiter fails every request, like a bucket updated by many
+// containers at once.
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, ratelimit.ErrContention
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	log := newLog()
+	limits := ratelimit.Limits{
+		Default: ratelimit.Limit{Requests: 1, Period: time.Second, Burst: 1},
+		Routes:  map[string]ratelimit.Limit{"GET /health": {}},
+	}
+	ok := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+	request := func(resource, ip string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodGet,
+			Resource:   resource,
+			Path:       resource,
+			RequestContext: events.APIGatewayProxyRequestContext{
+				Identity: events.APIGatewayRequestIdentity{SourceIP: ip},
+			},
+		}
+	}
+
+	t.Run("throttled", func(t *testing.T) {
+		sink := metrics.NewMemorySink()
+		next := ratelimit.NewMiddleware(ratelimit.NewMemory(newClock().Now), limits, log, metrics.New("ratelimit-test", sink)).Wrap(ok)
+
+		res, err := next(ctx, request("/users", "10.0.0.1"))
+		if err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected response: %+v, %v", res, err)
+		}
+		if res.Headers[ratelimit.HeaderLimit] != "1" || res.Headers[ratelimit.HeaderRemaining] != "0" ||
+			res.Headers[ratelimit.HeaderReset] != "1" || res.Headers[ratelimit.HeaderPolicy] != "1;w=1;burst=1" {
+			t.Errorf("unexpected headers: %v", res.Headers)
+		}
+
+		res, err = next(ctx, request("/users", "10.0.0.1"))
+		if err != nil || res.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %+v, %v", res, err)
+		}
+		if res.Headers[ratelimit.HeaderRetryAfter] != "1" || res.Headers[ratelimit.HeaderRemaining] != "0" ||
+			res.Headers["Content-Type"] != problem.ContentType {
+			t.Errorf("unexpected headers: %v", res.Headers)
+		}
+		var p problem.Problem
+		if err = json.Unmarshal([]byte(res.Body), &p); err != nil || p.Status != http.StatusTooManyRequests || p.Instance != "/users" {
+			t.Errorf("unexpected problem %s: %v", res.Body, err)
+		}
+		if sink.Sum("RateLimit", metrics.MetricThrottled) != 1 {
+			t.Errorf("expected the throttled metric")
+		}
+
+		// routes and callers have their own buckets, off routes are not limited
+		for _, req := range []events.APIGatewayProxyRequest{request("/users/{id}", "10.0.0.1"), request("/users", "10.0.0.2"), request("/health", "10.0.0.1"), request("/health", "10.0.0.1")} {
+			if res, _ = next(ctx, req); res.StatusCode != http.StatusOK {
+				t.Errorf("%s from %s: expected 200, got %d", req.Resource, req.RequestContext.Identity.SourceIP, res.StatusCode)
+			}
+		}
+		if _, limited := res.Headers[ratelimit.HeaderLimit]; limited {
+			t.Errorf("expected no rate limit header on an off route: %v", res.Headers)
+		}
+	})
+
+	t.Run("ignores the api key header", func(t *testing.T) {
+		next := ratelimit.NewMiddleware(ratelimit.NewMemory(newClock().Now), limits, log, metrics.NewNoop()).Wrap(ok)
+		statuses := []int{}
+		for _, key := range []string{"key-1", "key-2"} {
+			req := request("/users", "10.0.0.1")
+			req.Headers = map[string]string{"X-Api-Key": key}
+			res, err := next(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			statuses = append(statuses, res.StatusCode)
+		}
+		if statuses[0] != http.StatusOK || statuses[1] != http.StatusTooManyRequests {
+			t.Errorf("expected a rotating key to be throttled, got %v", statuses)
+		}
+	})
+
+	t.Run("decides in memory when the limiter fails", func(t *testing.T) {
+		sink := metrics.NewMemorySink()
+		next := ratelimit.NewMiddleware(failingLimiter{}, limits, log, metrics.New("ratelimit-test", sink)).Wrap(ok)
+		if res, err := next(ctx, request("/users", "10.0.0.1")); err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected response: %+v, %v", res, err)
+		}
+		if res, err := next(ctx, request("/users", "10.0.0.1")); err != nil || res.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected the fallback to throttle, got %+v, %v", res, err)
+		}
+		if fallbacks := sink.Sum("RateLimit", metrics.MetricRateLimitFallback); fallbacks != 2 {
+			t.Errorf("expected 2 fallbacks, got %v", fallbacks)
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		next := ratelimit.NewMiddleware(nil, limits, log, metrics.NewNoop()).Wrap(ok)
+		for i := 0; i < 3; i++ {
+			if res, _ := next(ctx, request("/users", "10.0.0.1")); res.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200, got %d", res.StatusCode)
+			}
+		}
+	})
+}
+
+func TestCaller(t *testing.T) {
+	ctx := context.Background()
+	req := events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{SourceIP: "10.0.0.1", APIKey: "secret"}},
+	}
+	withSubject := auth.WithIdentity(ctx, &auth.Identity{Subject: "user-1"})
+
+	apiKey := ratelimit.Caller(withSubject, req)
+	if apiKey != "key:2bb80d537b1da3e38bd30361aa855686" {
+		t.Errorf("expected the hashed API key, got %s", apiKey)
+	}
+
+	// the API key header is not validated by API Gateway, it is ignored
+	req.RequestContext.Identity.APIKey = ""
+	req.Headers = map[string]string{"x-api-key": "secret"}
+	if caller := ratelimit.Caller(withSubject, req); caller != "sub:user-1" {
+		t.Errorf("expected the subject, got %s", caller)
+	}
+	if caller := ratelimit.Caller(ctx, req); caller != "ip:10.0.0.1" {
+		t.Errorf("expected the source IP, got %s", caller)
+	}
+	if caller := ratelimit.Caller(ctx, events.APIGatewayProxyRequest{}); caller != "anonymous" {
+		t.Errorf("expected anonymous, got %s", caller)
+	}
+
+	req.Resource, req.HTTPMethod = "/users/{id}", http.MethodGet
+	if route := ratelimit.Route(req); route != "GET /users/{id}" {
+		t.Errorf("unexpected route %s", route)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	log := newLog()
+	opts := clientopts.New(clientopts.DefaultPolicy())
+	cfg := config.RateLimit{Backend: config.RateLimitMemory, Table: "rate-limits", Default: "10/1s", Routes: "GET /users=1/1s"}
+
+	for _, backend := range []string{config.RateLimitOff, config.RateLimitMemory, config.RateLimitDynamoDB} {
+		cfg.Backend = backend
+		if _, err := ratelimit.Open(cfg, newTable(t), log, metrics.NewNoop(), opts); err != nil {
+			t.Errorf("%s: unexpected error: %v", backend, err)
+		}
+	}
+
+	cfg.Backend = config.RateLimitDynamoDB
+	if _, err := ratelimit.Open(cfg, nil, log, metrics.NewNoop(), opts); err == nil {
+		t.Error("expected an error without a client")
+	}
+
+	cfg.Backend = config.RateLimitMemory
+	for _, invalid := range []config.RateLimit{
+		{Backend: config.RateLimitMemory, Default: "10", Routes: ""},
+		{Backend: config.RateLimitMemory, Default: "10/1s", Routes: "/users=1/1s"},
+	} {
+		if _, err := ratelimit.Open(invalid, nil, log, metrics.NewNoop(), opts); err == nil {
+			t.Errorf("%+v: expected an error", invalid)
+		}
+	}
+}
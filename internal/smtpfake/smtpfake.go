@@ -0,0 +1,257 @@
+// Package smtpfake is a local SMTP server keeping the messages in memory,
+// for the tests of the mailers and local runs without a relay.
+//
+// It speaks enough of RFC 5321 for net/smtp: EHLO, AUTH PLAIN, MAIL, RCPT,
+// DATA, RSET, NOOP and QUIT. STARTTLS is not offered.
+package smtpfake
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is a mail transaction accepted by the server.
+type Message struct {
+	From string
+	To   []string
+	// Data is the content sent after DATA, headers included, with the dot
+	// stuffing removed.
+	Data string
+}
+
+// Options are the credentials the clients must authenticate with, any
+// client is accepted when Username is empty.
+type Options struct {
+	Username string
+	Password string
+}
+
+type Server interface {
+	// Addr is the host:port the server listens on.
+	Addr() string
+	// Messages returns the messages accepted so far, in order.
+	Messages() []Message
+	// Close stops the server, the connections in flight are dropped.
+	Close() error
+}
+
+type serverImpl struct {
+	listener net.Listener
+	opts     Options
+
+	mu       sync.Mutex
+	messages []Message
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// Start listens on a free port of the loopback interface.
+func Start(opts Options) (Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &serverImpl{listener: listener, opts: opts, conns: map[net.Conn]struct{}{}}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+func (s *serverImpl) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *serverImpl) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *serverImpl) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *serverImpl) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			_ = conn.Close()
+		}()
+	}
+}
+
+// session is the state of a connection.
+type session struct {
+	hello         bool
+	authenticated bool
+	message       *Message
+}
+
+func (s *serverImpl) serve(conn net.Conn) {
+	text := textproto.NewConn(conn)
+	if text.PrintfLine("220 smtpfake ESMTP ready") != nil {
+		return
+	}
+
+	var sess session
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			sess = session{hello: true}
+			err = text.PrintfLine("250-smtpfake\r\n250-8BITMIME\r\n250 AUTH PLAIN")
+		case "AUTH":
+			err = s.auth(text, &sess, arg)
+		case "MAIL":
+			err = s.mail(text, &sess, arg)
+		case "RCPT":
+			err = rcpt(text, &sess, arg)
+		case "DATA":
+			err = s.data(text, &sess)
+		case "RSET":
+			sess.message = nil
+			err = text.PrintfLine("250 OK")
+		case "NOOP":
+			err = text.PrintfLine("250 OK")
+		case "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			err = text.PrintfLine("502 command not implemented")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *serverImpl) auth(text *textproto.Conn, sess *session, arg string) error {
+	mechanism, response, _ := strings.Cut(arg, " ")
+	if !strings.EqualFold(mechanism, "PLAIN") {
+		return text.PrintfLine("504 unrecognized authentication type")
+	}
+	if response == "" {
+		if err := text.PrintfLine("334 "); err != nil {
+			return err
+		}
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+		response = line
+	}
+
+	// the response is authorization identity, username and password
+	// separated by NUL
+	decoded, err := base64.StdEncoding.DecodeString(response)
+	parts := strings.Split(string(decoded), "\x00")
+	if err != nil || len(parts) != 3 {
+		return text.PrintfLine("501 malformed authentication")
+	}
+	if s.opts.Username != "" && (parts[1] != s.opts.Username || parts[2] != s.opts.Password) {
+		return text.PrintfLine("535 authentication credentials invalid")
+	}
+	sess.authenticated = true
+	return text.PrintfLine("235 authentication successful")
+}
+
+func (s *serverImpl) mail(text *textproto.Conn, sess *session, arg string) error {
+	switch {
+	case !sess.hello:
+		return text.PrintfLine("503 send EHLO first")
+	case s.opts.Username != "" && !sess.authenticated:
+		return text.PrintfLine("530 authentication required")
+	}
+
+	from, err := path(arg, "FROM:")
+	if err != nil {
+		return text.PrintfLine("501 %s", err)
+	}
+	sess.message = &Message{From: from}
+	return text.PrintfLine("250 OK")
+}
+
+func rcpt(text *textproto.Conn, sess *session, arg string) error {
+	if sess.message == nil {
+		return text.PrintfLine("503 send MAIL first")
+	}
+	to, err := path(arg, "TO:")
+	if err != nil || to == "" {
+		return text.PrintfLine("501 invalid recipient")
+	}
+	sess.message.To = append(sess.message.To, to)
+	return text.PrintfLine("250 OK")
+}
+
+func (s *serverImpl) data(text *textproto.Conn, sess *session) error {
+	if sess.message == nil || len(sess.message.To) == 0 {
+		return text.PrintfLine("503 send RCPT first")
+	}
+	if err := text.PrintfLine("354 end data with <CR><LF>.<CR><LF>"); err != nil {
+		return err
+	}
+
+	var data strings.Builder
+	scanner := bufio.NewScanner(text.DotReader())
+	for scanner.Scan() {
+		data.WriteString(scanner.Text())
+		data.WriteString("\r\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	sess.message.Data = data.String()
+	s.mu.Lock()
+	s.messages = append(s.messages, *sess.message)
+	s.mu.Unlock()
+	sess.message = nil
+	return text.PrintfLine("250 OK queued")
+}
+
+// path returns the address of a MAIL or RCPT argument such as
+// FROM:<john@example.com> SIZE=100.
+func path(arg, prefix string) (string, error) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", errors.New("syntax error in parameters")
+	}
+	address, _, _ := strings.Cut(strings.TrimSpace(arg[len(prefix):]), " ")
+	if !strings.HasPrefix(address, "<") || !strings.HasSuffix(address, ">") {
+		return "", errors.New("syntax error in address")
+	}
+	return address[1 : len(address)-1], nil
+}
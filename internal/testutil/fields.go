@@ -0,0 +1,80 @@
+// Package testutil holds the reflection helpers of the mapping tests, it
+// imports the standard library only so any package can use it.
+package testutil
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Fill sets every exported field of the struct v points to a non-zero value
+// derived from the field index, so a mapping can be checked with ZeroFields.
+func Fill(v any) {
+	s := reflect.ValueOf(v).Elem()
+	for i := 0; i < s.NumField(); i++ {
+		f := s.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(fmt.Sprintf("%s-%d", s.Type().Field(i).Name, i+1))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.SetInt(int64(i + 1))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f.SetUint(uint64(i + 1))
+		case reflect.Float32, reflect.Float64:
+			f.SetFloat(float64(i) + 1.5)
+		case reflect.Bool:
+			f.SetBool(true)
+		default:
+			if f.Type() == reflect.TypeOf(time.Time{}) {
+				f.Set(reflect.ValueOf(time.Date(2024, 5, 1, 10, i, 0, 0, time.UTC)))
+				continue
+			}
+			panic(fmt.Sprintf("tests.Fill: unsupported field %s of type %s", s.Type().Field(i).Name, f.Type()))
+		}
+	}
+}
+
+// ZeroFields returns the names of the exported fields of the struct v, or v
+// points to, left to their zero value.
+func ZeroFields(v any) []string {
+	s := reflect.Indirect(reflect.ValueOf(v))
+	var zero []string
+	for i := 0; i < s.NumField(); i++ {
+		if s.Type().Field(i).IsExported() && s.Field(i).IsZero() {
+			zero = append(zero, s.Type().Field(i).Name)
+		}
+	}
+	return zero
+}
+
+// UnmappedFields returns the names of the exported fields of the struct src
+// missing from dst or holding another value there, src and dst may be
+// pointers. Values of named types such as a Status string are compared
+// after conversion to the type of dst.
+func UnmappedFields(src, dst any) []string {
+	s, d := reflect.Indirect(reflect.ValueOf(src)), reflect.Indirect(reflect.ValueOf(dst))
+	var unmapped []string
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		target := d.FieldByName(field.Name)
+		if !target.IsValid() {
+			unmapped = append(unmapped, field.Name)
+			continue
+		}
+		value := s.Field(i)
+		if value.Type() != target.Type() && value.Kind() == target.Kind() && value.CanConvert(target.Type()) {
+			value = value.Convert(target.Type())
+		}
+		if !reflect.DeepEqual(value.Interface(), target.Interface()) {
+			unmapped = append(unmapped, field.Name)
+		}
+	}
+	return unmapped
+}
@@ -0,0 +1,53 @@
+// Package timezone picks the timezone the responses render their times in.
+// Times are stored in UTC, a client asks for another zone with the
+// Accept-Timezone header or the timezone query parameter.
+package timezone
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/conditional"
+	"strings"
+	"time"
+	// the zones of the requests do not depend on the zoneinfo of the host
+	_ "time/tzdata"
+)
+
+const (
+	HeaderAcceptTimezone = "Accept-Timezone"
+	// QueryParameter wins over the header when both are sent.
+	QueryParameter = "timezone"
+)
+
+// ErrInvalidTimezone is returned for a name missing from the IANA database.
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
+// FromRequest returns the zone asked by the request, fallback when it asks
+// none.
+func FromRequest(headers, query map[string]string, fallback *time.Location) (*time.Location, error) {
+	name, ok := query[QueryParameter]
+	if !ok {
+		name, ok = conditional.Header(headers, HeaderAcceptTimezone)
+	}
+	if !ok {
+		if fallback == nil {
+			return time.UTC, nil
+		}
+		return fallback, nil
+	}
+	return Load(name)
+}
+
+// Load returns the zone of an IANA name such as Europe/Paris, or UTC.
+// Local is refused, it is the zone of the host and not of the client.
+func Load(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("%w %q", ErrInvalidTimezone, name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", ErrInvalidTimezone, name)
+	}
+	return loc, nil
+}
@@ -0,0 +1,44 @@
+package timezone_test
+
+import (
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/timezone"
+	"testing"
+	"time"
+)
+
+func TestFromRequest(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	for name, tc := range map[string]struct {
+		headers, query map[string]string
+		fallback       *time.Location
+		expected       string
+	}{
+		"none":              {expected: "UTC"},
+		"fallback":          {fallback: tokyo, expected: "Asia/Tokyo"},
+		"header":            {headers: map[string]string{"Accept-Timezone": "America/Mexico_City"}, expected: "America/Mexico_City"},
+		"header case":       {headers: map[string]string{"accept-timezone": "Europe/Paris"}, expected: "Europe/Paris"},
+		"query":             {query: map[string]string{"timezone": "Europe/Paris"}, expected: "Europe/Paris"},
+		"query over header": {headers: map[string]string{"Accept-Timezone": "Asia/Tokyo"}, query: map[string]string{"timezone": "UTC"}, expected: "UTC"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			loc, err := timezone.FromRequest(tc.headers, tc.query, tc.fallback)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if loc.String() != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, loc)
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, name := range []string{"", " ", "Local", "Mars/Olympus", "../../etc/passwd", "+05:00"} {
+		_, err := timezone.FromRequest(map[string]string{"Accept-Timezone": name}, nil, nil)
+		if !errors.Is(err, timezone.ErrInvalidTimezone) {
+			t.Errorf("%q: expected ErrInvalidTimezone, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Start opens an internal span, used by the service layer.
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+}
+
+// StartServer opens the root span of an invocation, used by the handlers.
+func StartServer(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// Error records err in span and flags the span as failed.
+func Error(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// WithAPIGatewayRequest continues the trace sent by the caller in the W3C
+// traceparent/tracestate headers. API Gateway keeps the header case used by
+// the client, so headers are canonicalized before extraction.
+func WithAPIGatewayRequest(ctx context.Context, req events.APIGatewayProxyRequest) context.Context {
+	header := http.Header{}
+	for key, values := range req.MultiValueHeaders {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	for key, value := range req.Headers {
+		header.Set(key, value)
+	}
+
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// DynamoDB is passed as option to every dynamodb call so the operation is
+// recorded as a client span of the current trace:
+//
+//	repo.conn.GetItem(ctx, input, tracing.DynamoDB)
+func DynamoDB(o *dynamodb.Options) {
+	otelaws.AppendMiddlewares(&o.APIOptions,
+		otelaws.WithTracerProvider(otel.GetTracerProvider()),
+		otelaws.WithAttributeSetter(otelaws.DynamoDBAttributeSetter),
+	)
+}
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"strings"
+)
+
+const (
+	// EnvExporter selects the span exporter, the OTLP endpoint and headers are
+	// read by the exporter from the standard OTEL_EXPORTER_OTLP_* variables.
+	EnvExporter = "OTEL_TRACES_EXPORTER"
+
+	ExporterOTLP = "otlp"
+	ExporterNone = "none"
+
+	instrumentationName = "github.com/ricardojonathanromero/lambda-golang-example"
+)
+
+type Opts struct {
+	ServiceName string
+	// Exporter is ExporterOTLP or ExporterNone, spans are still created and
+	// propagated when nothing is exported.
+	Exporter string
+}
+
+// Provider flushes the spans of an invocation and releases the exporter.
+type Provider interface {
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// Setup installs the global tracer provider used by Start and DynamoDB.
+func Setup(ctx context.Context, opts Opts) (Provider, error) {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(opts.ServiceName))
+	providerOpts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	switch strings.ToLower(strings.TrimSpace(opts.Exporter)) {
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exporter))
+	case ExporterNone, "":
+	default:
+		return nil, fmt.Errorf("unsupported traces exporter: %s", opts.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(providerOpts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return provider, nil
+}
+
+// WithFlush wraps a lambda handler so the spans of every invocation are
+// exported before the runtime freezes the container.
+func WithFlush[Req, Res any](provider Provider, fn func(context.Context, Req) (Res, error)) func(context.Context, Req) (Res, error) {
+	return func(ctx context.Context, req Req) (Res, error) {
+		defer func() {
+			// a failed export must never fail the invocation
+			_ = provider.ForceFlush(context.WithoutCancel(ctx))
+		}()
+
+		return fn(ctx, req)
+	}
+}
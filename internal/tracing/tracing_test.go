@@ -0,0 +1,133 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestWithAPIGatewayRequest(t *testing.T) {
+	recorder := tracing.NewRecorder()
+
+	req := events.APIGatewayProxyRequest{Headers: map[string]string{"traceparent": traceparent}}
+	ctx := tracing.WithAPIGatewayRequest(context.Background(), req)
+
+	ctx, span := tracing.StartServer(ctx, "HandleGetUser")
+	_, child := tracing.Start(ctx, "LookingUpUser")
+	child.End()
+	span.End()
+
+	ended := recorder.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("unexpected spans: %v", tracing.SpanNames(recorder))
+	}
+
+	server := ended[1]
+	if server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace not continued: %s", server.SpanContext().TraceID())
+	}
+
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" || server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("unexpected server span: parent %s kind %s", server.Parent().SpanID(), server.SpanKind())
+	}
+
+	if ended[0].Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("service span is not a child of the handler span")
+	}
+}
+
+func TestError(t *testing.T) {
+	recorder := tracing.NewRecorder()
+
+	_, span := tracing.Start(context.Background(), "CreateUser")
+	tracing.Error(span, errors.New("conditional check failed"))
+	span.End()
+
+	ended := recorder.Ended()[0]
+	if ended.Status().Code != codes.Error || len(ended.Events()) != 1 {
+		t.Errorf("error not recorded: %v", ended.Status())
+	}
+}
+
+func TestDynamoDB(t *testing.T) {
+	recorder := tracing.NewRecorder()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+
+	ctx, span := tracing.Start(context.Background(), "FindDocumentById")
+	_, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("users"),
+		Key:       map[string]types.AttributeValue{"Id": &types.AttributeValueMemberS{Value: "1"}},
+	}, tracing.DynamoDB)
+	span.End()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 2 || ended[0].Name() != "DynamoDB.GetItem" {
+		t.Fatalf("unexpected spans: %v", tracing.SpanNames(recorder))
+	}
+
+	if ended[0].SpanKind() != trace.SpanKindClient || ended[0].Parent().SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("dynamodb span is not a client child span")
+	}
+}
+
+type countingProvider struct {
+	flushes int
+}
+
+func (p *countingProvider) ForceFlush(context.Context) error {
+	p.flushes++
+	return errors.New("collector unavailable")
+}
+
+func (p *countingProvider) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestWithFlush(t *testing.T) {
+	provider := &countingProvider{}
+	fn := tracing.WithFlush(provider, func(ctx context.Context, req string) (string, error) {
+		return "hello " + req, nil
+	})
+
+	res, err := fn(context.Background(), "john")
+	if err != nil || res != "hello john" || provider.flushes != 1 {
+		t.Errorf("unexpected result: %q %v %d", res, err, provider.flushes)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	provider, err := tracing.Setup(context.Background(), tracing.Opts{ServiceName: "get-document-lambda", Exporter: tracing.ExporterNone})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	if _, err = tracing.Setup(context.Background(), tracing.Opts{Exporter: "zipkin"}); err == nil {
+		t.Errorf("unsupported exporter accepted")
+	}
+}
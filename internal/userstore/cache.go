@@ -0,0 +1,62 @@
+package userstore
+
+import (
+	"context"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+)
+
+// CachePrefix namespaces the users in the read-through caches.
+const CachePrefix = "user:"
+
+type invalidatingImpl struct {
+	UserRepository
+	cache cache.ReadThrough[models.UserDB]
+	log   logging.Logger
+}
+
+// NewInvalidating drops the cached user from c after every write of next,
+// so the readers sharing c see the write on their next read instead of
+// after the TTL of cfg. A failed invalidation is logged and the write kept.
+func NewInvalidating(next UserRepository, c cache.Cache, cfg config.Cache, log logging.Logger, m metrics.Metrics) UserRepository {
+	return &invalidatingImpl{
+		UserRepository: next,
+		cache: cache.NewReadThrough[models.UserDB](c, cache.Options{
+			Prefix:  CachePrefix,
+			Version: cfg.Version,
+		}, log, m),
+		log: log,
+	}
+}
+
+func (repo *invalidatingImpl) Insert(ctx context.Context, user *models.UserDB) error {
+	// a lookup of the id before its creation may be cached as missing
+	return repo.invalidate(ctx, user.ID, repo.UserRepository.Insert(ctx, user))
+}
+
+func (repo *invalidatingImpl) Update(ctx context.Context, user *models.UserDB) error {
+	return repo.invalidate(ctx, user.ID, repo.UserRepository.Update(ctx, user))
+}
+
+func (repo *invalidatingImpl) UpdateStatus(ctx context.Context, id string, change StatusChange) error {
+	return repo.invalidate(ctx, id, repo.UserRepository.UpdateStatus(ctx, id, change))
+}
+
+func (repo *invalidatingImpl) Delete(ctx context.Context, id string) error {
+	return repo.invalidate(ctx, id, repo.UserRepository.Delete(ctx, id))
+}
+
+// invalidate drops the user with the id when the write succeeded and
+// returns its error.
+func (repo *invalidatingImpl) invalidate(ctx context.Context, id string, err error) error {
+	if err != nil {
+		return err
+	}
+	if err = repo.cache.Invalidate(ctx, id); err != nil {
+		repo.log.WithContext(ctx).Warnf("error invalidating cached user %s: %v", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package userstore_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"testing"
+	"time"
+)
+
+func TestInvalidating(t *testing.T) {
+	ctx := context.Background()
+	log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+	cfg := config.Cache{Version: "1", TTL: time.Minute, NegativeTTL: time.Minute}
+	c := cache.NewLRU(10)
+
+	next := userstore.NewDynamoDB(newDynamoDBTable(t), "users", log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop(), "", clock.New())
+	store := userstore.NewInvalidating(next, c, cfg, log, metrics.NewNoop())
+
+	// reader caches the users like get-document
+	reader := cache.NewReadThrough[models.UserDB](c, cache.Options{
+		Prefix:      userstore.CachePrefix,
+		Version:     cfg.Version,
+		TTL:         cfg.TTL,
+		NegativeTTL: cfg.NegativeTTL,
+		NotFound:    userstore.ErrNotFound,
+	}, log, metrics.NewNoop())
+	read := func(id string) (*models.UserDB, error) {
+		return reader.Get(ctx, id, func(ctx context.Context) (*models.UserDB, error) {
+			return next.Get(ctx, id)
+		})
+	}
+
+	if _, err := read("usr-1"); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("expected a cached miss, got %v", err)
+	}
+
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	if err := store.Insert(ctx, &models.UserDB{ID: "usr-1", Name: "john", CreatedAt: created, UpdatedAt: created}); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := read("usr-1"); err != nil || user.Name != "john" {
+		t.Fatalf("expected the inserted user, got %+v, %v", user, err)
+	}
+
+	change := userstore.StatusChange{From: models.DefaultStatus, To: "suspended", Reason: "spam", UpdatedAt: created.Add(time.Hour)}
+	if err := store.UpdateStatus(ctx, "usr-1", change); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := read("usr-1"); err != nil || user.Status != "suspended" {
+		t.Fatalf("expected the suspended user, got %+v, %v", user, err)
+	}
+
+	if err := store.Delete(ctx, "usr-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read("usr-1"); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("expected the deleted user to be missing, got %v", err)
+	}
+
+	// failed writes return their error
+	if err := store.Update(ctx, &models.UserDB{ID: "usr-2", Name: "jane"}); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
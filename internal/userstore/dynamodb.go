@@ -0,0 +1,650 @@
+package userstore
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScans caps the scans of a List call, each reads at most the limit of
+// the page.
+const maxScans = 5
+
+// DynamoDBAPI is the part of the client used by NewDynamoDB.
+type DynamoDBAPI interface {
+	dynamodbapi.ItemPutter
+	dynamodbapi.ItemUpdater
+	dynamodbapi.ItemDeleter
+	dynamodbapi.Querier
+	dynamodbapi.Scanner
+	dynamodbapi.TransactWriter
+}
+
+type dynamoImpl struct {
+	conn      DynamoDBAPI
+	log       logging.Logger
+	metrics   metrics.Metrics
+	opts      clientopts.Options
+	enc       fieldcrypt.Encryptor
+	tableName string
+	// auditTable receives the entries of the writes, empty when the audit
+	// is off.
+	auditTable string
+	// clock timestamps the audit entries.
+	clock clock.Clock
+}
+
+// NewDynamoDB stores the users in the table provisioned by the db package.
+// The table is keyed by Id and CreatedAt, so users are looked up by a query
+// on Id rather than GetItem. The attributes tagged with encrypt are
+// encrypted by enc. Every write appends its audit.Entry, timestamped by
+// clk, to auditTable in the same transaction, unless auditTable is empty.
+func NewDynamoDB(conn DynamoDBAPI, tableName string, log logging.Logger, m metrics.Metrics, opts clientopts.Options, enc fieldcrypt.Encryptor, auditTable string, clk clock.Clock) UserRepository {
+	return &dynamoImpl{
+		conn:       conn,
+		log:        log,
+		metrics:    m,
+		opts:       opts,
+		enc:        enc,
+		tableName:  tableName,
+		auditTable: auditTable,
+		clock:      clk,
+	}
+}
+
+// call runs fn with the per-call context of operation and records its
+// latency.
+func (repo *dynamoImpl) call(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	callCtx, cancel, err := repo.opts.Context(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	start := time.Now()
+	err = fn(callCtx)
+	repo.metrics.RepositoryLatency(operation, start)
+	return repo.opts.Err(operation, err)
+}
+
+// isConditionalCheckFailed reports whether the condition of the user item
+// failed, alone or as the first item of a transaction.
+func isConditionalCheckFailed(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return true
+	}
+
+	var canceled *types.TransactionCanceledException
+	return errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+		aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed"
+}
+
+// transact writes the user item along the audit entry of the mutation from
+// before to after, in a single transaction.
+func (repo *dynamoImpl) transact(ctx context.Context, operation string, write types.TransactWriteItem, action audit.Action, before, after *models.UserDB) error {
+	entry, err := audit.NewEntry(ctx, repo.enc, repo.clock, action, before, after)
+	if err != nil {
+		return err
+	}
+	put, err := audit.TransactPut(repo.auditTable, entry)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems:          []types.TransactWriteItem{write, put},
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+
+	var out *dynamodb.TransactWriteItemsOutput
+	err = repo.call(ctx, operation, func(ctx context.Context) (err error) {
+		out, err = repo.conn.TransactWriteItems(ctx, input, tracing.DynamoDB, repo.opts.DynamoDB)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range out.ConsumedCapacity {
+		repo.metrics.ConsumedCapacity(operation, &out.ConsumedCapacity[i])
+	}
+	return nil
+}
+
+// primaryKey returns the key attributes of item.
+func primaryKey(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"Id": item["Id"], "CreatedAt": item["CreatedAt"]}
+}
+
+// find returns the item of the user with the id, nil when there is none.
+func (repo *dynamoImpl) find(ctx context.Context, operation, id string, projection *string) (map[string]types.AttributeValue, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(repo.tableName),
+		KeyConditionExpression:    aws.String("#id = :id"),
+		ExpressionAttributeNames:  map[string]string{"#id": "Id"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":id": &types.AttributeValueMemberS{Value: id}},
+		Limit:                     aws.Int32(1),
+		ConsistentRead:            aws.Bool(true),
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}
+	if projection != nil {
+		input.ProjectionExpression = projection
+		input.ExpressionAttributeNames["#createdAt"] = "CreatedAt"
+	}
+
+	var out *dynamodb.QueryOutput
+	err := repo.call(ctx, operation, func(ctx context.Context) (err error) {
+		out, err = repo.conn.Query(ctx, input, tracing.DynamoDB, repo.opts.DynamoDB)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repo.metrics.ConsumedCapacity(operation, out.ConsumedCapacity)
+	if len(out.Items) == 0 {
+		return nil, nil
+	}
+	return out.Items[0], nil
+}
+
+// key returns the primary key of the user with the id, nil when there is
+// none.
+func (repo *dynamoImpl) key(ctx context.Context, operation, id string) (map[string]types.AttributeValue, error) {
+	return repo.find(ctx, operation, id, aws.String("#id, #createdAt"))
+}
+
+func (repo *dynamoImpl) Insert(ctx context.Context, user *models.UserDB) error {
+	log := repo.log.WithContext(ctx)
+
+	// the condition only sees an item with the same Id and CreatedAt
+	existing, err := repo.key(ctx, operationInsert, user.ID)
+	if err != nil {
+		log.Errorf("error looking up user %s: %v", user.ID, err)
+		return err
+	}
+	if existing != nil {
+		return ErrAlreadyExists
+	}
+
+	av, err := fieldcrypt.MarshalMap(ctx, repo.enc, user)
+	if err != nil {
+		log.Errorf("error marshalling user: %v", err)
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:              aws.String(repo.tableName),
+		Item:                   av,
+		ConditionExpression:    aws.String("attribute_not_exists(Id)"),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+
+	if repo.auditTable != "" {
+		err = repo.transact(ctx, operationInsert, types.TransactWriteItem{Put: &types.Put{
+			TableName:           input.TableName,
+			Item:                input.Item,
+			ConditionExpression: input.ConditionExpression,
+		}}, audit.ActionCreate, nil, user)
+		if isConditionalCheckFailed(err) {
+			return ErrAlreadyExists
+		}
+		if err != nil {
+			log.Errorf("error put item with audit entry: %v", err)
+		}
+		return err
+	}
+
+	var out *dynamodb.PutItemOutput
+	err = repo.call(ctx, operationInsert, func(ctx context.Context) (err error) {
+		out, err = repo.conn.PutItem(ctx, input, tracing.DynamoDB, repo.opts.DynamoDB)
+		return err
+	})
+	if isConditionalCheckFailed(err) {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		log.Errorf("error put item: %v", err)
+		return err
+	}
+
+	repo.metrics.ConsumedCapacity(operationInsert, out.ConsumedCapacity)
+	return nil
+}
+
+func (repo *dynamoImpl) Get(ctx context.Context, id string) (*models.UserDB, error) {
+	log := repo.log.WithContext(ctx)
+
+	item, err := repo.find(ctx, operationGet, id, nil)
+	if err != nil {
+		log.Errorf("error querying user %s: %v", id, err)
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrNotFound
+	}
+
+	var user models.UserDB
+	if err = fieldcrypt.UnmarshalMap(ctx, repo.enc, item, &user); err != nil {
+		log.Errorf("error unmarshal user: %v", err)
+		return nil, err
+	}
+	return &user, nil
+}
+
+// filterExpression builds the scan filter of f, nil when f is empty.
+func filterExpression(f storedFilter) (*string, map[string]string, map[string]types.AttributeValue) {
+	var conditions []string
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+
+	for _, equal := range f.equal {
+		names["#"+equal.field] = equal.field
+		placeholders := make([]string, len(equal.values))
+		for i, value := range equal.values {
+			placeholders[i] = ":" + equal.field + strconv.Itoa(i)
+			values[placeholders[i]] = &types.AttributeValueMemberS{Value: value}
+		}
+
+		if len(placeholders) == 1 {
+			conditions = append(conditions, "#"+equal.field+" = "+placeholders[0])
+		} else {
+			conditions = append(conditions, "#"+equal.field+" IN ("+strings.Join(placeholders, ", ")+")")
+		}
+	}
+
+	age := func(placeholder, operator string, value *int32) {
+		if value == nil {
+			return
+		}
+		names["#age"] = "Age"
+		values[placeholder], _ = attributevalue.Marshal(*value)
+		conditions = append(conditions, "#age "+operator+" "+placeholder)
+	}
+	age(":minAge", ">=", f.minAge)
+	age(":maxAge", "<=", f.maxAge)
+
+	if f.status != "" {
+		names["#status"] = "Status"
+		values[":status"] = &types.AttributeValueMemberS{Value: f.status}
+		if f.status == models.DefaultStatus {
+			conditions = append(conditions, "(#status = :status OR attribute_not_exists(#status))")
+		} else {
+			conditions = append(conditions, "#status = :status")
+		}
+	}
+
+	if len(conditions) == 0 {
+		return nil, nil, nil
+	}
+	return aws.String(strings.Join(conditions, " AND ")), names, values
+}
+
+func (repo *dynamoImpl) List(ctx context.Context, query ListQuery) (*Page, error) {
+	log := repo.log.WithContext(ctx)
+
+	var start map[string]types.AttributeValue
+	if query.Cursor != "" {
+		var position map[string]string
+		if err := decodeCursor(query.Cursor, &position); err != nil {
+			return nil, err
+		}
+		if len(position) != 2 || position["Id"] == "" || position["CreatedAt"] == "" {
+			return nil, ErrInvalidCursor
+		}
+		start = map[string]types.AttributeValue{
+			"Id":        &types.AttributeValueMemberS{Value: position["Id"]},
+			"CreatedAt": &types.AttributeValueMemberS{Value: position["CreatedAt"]},
+		}
+	}
+
+	stored, err := query.Filter.stored(ctx, repo.enc)
+	if err != nil {
+		log.Errorf("error encrypting filter: %v", err)
+		return nil, err
+	}
+
+	filter, names, values := filterExpression(stored)
+	limit := query.limit()
+	page := &Page{Users: []*models.UserDB{}}
+
+	// the limit of a scan applies before the filter, asking for the missing
+	// users only keeps the last evaluated key on the last returned user. A
+	// selective filter gets a short page after maxScans rather than the
+	// whole table read in one call
+	for scans := 0; len(page.Users) < limit && scans < maxScans; scans++ {
+		input := &dynamodb.ScanInput{
+			TableName:                 aws.String(repo.tableName),
+			Limit:                     aws.Int32(int32(limit - len(page.Users))),
+			ExclusiveStartKey:         start,
+			FilterExpression:          filter,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+		}
+
+		var out *dynamodb.ScanOutput
+		err := repo.call(ctx, operationList, func(ctx context.Context) (err error) {
+			out, err = repo.conn.Scan(ctx, input, tracing.DynamoDB, repo.opts.DynamoDB)
+			return err
+		})
+		if err != nil {
+			log.Errorf("error scanning users: %v", err)
+			return nil, err
+		}
+		repo.metrics.ConsumedCapacity(operationList, out.ConsumedCapacity)
+
+		var users []*models.UserDB
+		if err = fieldcrypt.UnmarshalListOfMaps(ctx, repo.enc, out.Items, &users); err != nil {
+			log.Errorf("error unmarshal users: %v", err)
+			return nil, err
+		}
+		// the scanned users not matching the decrypted filter are skipped,
+		// the next scan starts after them anyway
+		for _, user := range users {
+			if stored.decrypted.matches(user) {
+				page.Users = append(page.Users, user)
+			}
+		}
+
+		start = out.LastEvaluatedKey
+		if start == nil {
+			break
+		}
+	}
+
+	if start != nil {
+		position := map[string]string{}
+		for _, name := range []string{"Id", "CreatedAt"} {
+			if s, ok := start[name].(*types.AttributeValueMemberS); ok {
+				position[name] = s.Value
+			}
+		}
+
+		cursor, err := encodeCursor(position)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = cursor
+	}
+	return page, nil
+}
+
+// current returns the item of the user with the id, only its key when the
+// audit is off since the entry needs the previous values. Nil when there
+// is no user.
+func (repo *dynamoImpl) current(ctx context.Context, operation, id string) (map[string]types.AttributeValue, error) {
+	if repo.auditTable == "" {
+		return repo.key(ctx, operation, id)
+	}
+	return repo.find(ctx, operation, id, nil)
+}
+
+func (repo *dynamoImpl) Update(ctx context.Context, user *models.UserDB) error {
+	log := repo.log.WithContext(ctx)
+
+	item, err := repo.current(ctx, operationUpdate, user.ID)
+	if err != nil {
+		log.Errorf("error looking up user %s: %v", user.ID, err)
+		return err
+	}
+	if item == nil {
+		return ErrNotFound
+	}
+	key := primaryKey(item)
+
+	stored, err := fieldcrypt.Encrypted(ctx, repo.enc, user)
+	if err != nil {
+		log.Errorf("error encrypting user: %v", err)
+		return err
+	}
+
+	values, err := attributevalue.MarshalMap(map[string]any{
+		":name":      stored.Name,
+		":lastname":  stored.Lastname,
+		":age":       stored.Age,
+		":email":     stored.Email,
+		":updatedAt": stored.UpdatedAt,
+	})
+	if err != nil {
+		log.Errorf("error marshalling user: %v", err)
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:        aws.String(repo.tableName),
+		Key:              key,
+		UpdateExpression: aws.String("SET #name = :name, #lastname = :lastname, #age = :age, #email = :email, #updatedAt = :updatedAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#id":        "Id",
+			"#name":      "Name",
+			"#lastname":  "Lastname",
+			"#age":       "Age",
+			"#email":     "Email",
+			"#updatedAt": "UpdatedAt",
+		},
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String("attribute_exists(#id)"),
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}
+
+	if repo.auditTable != "" {
+		var before models.UserDB
+		if err = fieldcrypt.UnmarshalMap(ctx, repo.enc, item, &before); err != nil {
+			log.Errorf("error unmarshal user: %v", err)
+			return err
+		}
+		// the creation date and the status are kept
+		after := *user
+		after.CreatedAt = before.CreatedAt
+		after.Status, after.StatusReason = before.Status, before.StatusReason
+		after.OwnerSubject = before.OwnerSubject
+
+		err = repo.transact(ctx, operationUpdate, types.TransactWriteItem{Update: &types.Update{
+			TableName:                 input.TableName,
+			Key:                       input.Key,
+			UpdateExpression:          input.UpdateExpression,
+			ExpressionAttributeNames:  input.ExpressionAttributeNames,
+			ExpressionAttributeValues: input.ExpressionAttributeValues,
+			ConditionExpression:       input.ConditionExpression,
+		}}, audit.ActionUpdate, &before, &after)
+		if isConditionalCheckFailed(err) {
+			return ErrNotFound
+		}
+		if err != nil {
+			log.Errorf("error update item with audit entry: %v", err)
+		}
+		return err
+	}
+
+	var out *dynamodb.UpdateItemOutput
+	err = repo.call(ctx, operationUpdate, func(ctx context.Context) (err error) {
+		out, err = repo.conn.UpdateItem(ctx, input, tracing.DynamoDB, repo.opts.DynamoDB)
+		return err
+	})
+	if isConditionalCheckFailed(err) {
+		// deleted since the lookup
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Errorf("error update item: %v", err)
+		return err
+	}
+
+	repo.metrics.ConsumedCapacity(operationUpdate, out.ConsumedCapacity)
+	return nil
+}
+
+func (repo *dynamoImpl) UpdateStatus(ctx context.Context, id string, change StatusChange) error {
+	log := repo.log.WithContext(ctx)
+
+	item, err := repo.current(ctx, operationUpdateStatus, id)
+	if err != nil {
+		log.Errorf("error looking up user %s: %v", id, err)
+		return err
+	}
+	if item == nil {
+		return ErrNotFound
+	}
+
+	names := map[string]string{
+		"#id":        "Id",
+		"#status":    "Status",
+		"#reason":    "StatusReason",
+		"#updatedAt": "UpdatedAt",
+	}
+	values, err := attributevalue.MarshalMap(map[string]any{
+		":from":      change.From,
+		":to":        change.To,
+		":updatedAt": change.UpdatedAt.UTC(),
+	})
+	if err != nil {
+		log.Errorf("error marshalling status: %v", err)
+		return err
+	}
+
+	update := "SET #status = :to, #updatedAt = :updatedAt REMOVE #reason"
+	if change.Reason != "" {
+		update = "SET #status = :to, #updatedAt = :updatedAt, #reason = :reason"
+		values[":reason"] = &types.AttributeValueMemberS{Value: change.Reason}
+	}
+	// the users stored before the lifecycle have no status
+	condition := "attribute_exists(#id) AND #status = :from"
+	if change.From == models.DefaultStatus {
+		condition = "attribute_exists(#id) AND (#status = :from OR attribute_not_exists(#status))"
+	}
+	if !change.Seen.IsZero() {
+		if values[":seen"], err = attributevalue.Marshal(change.Seen.UTC()); err != nil {
+			log.Errorf("error marshalling seen: %v", err)
+			return err
+		}
+		condition += " AND #updatedAt = :seen"
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(repo.tableName),
+		Key:                       primaryKey(item),
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String(condition),
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}
+
+	if repo.auditTable != "" {
+		var before models.UserDB
+		if err = fieldcrypt.UnmarshalMap(ctx, repo.enc, item, &before); err != nil {
+			log.Errorf("error unmarshal user: %v", err)
+			return err
+		}
+		after := before
+		after.Status, after.StatusReason, after.UpdatedAt = change.To, change.Reason, change.UpdatedAt.UTC()
+
+		err = repo.transact(ctx, operationUpdateStatus, types.TransactWriteItem{Update: &types.Update{
+			TableName:                 input.TableName,
+			Key:                       input.Key,
+			UpdateExpression:          input.UpdateExpression,
+			ExpressionAttributeNames:  input.ExpressionAttributeNames,
+			ExpressionAttributeValues: input.ExpressionAttributeValues,
+			ConditionExpression:       input.ConditionExpression,
+		}}, audit.ActionUpdate, &before, &after)
+		if isConditionalCheckFailed(err) {
+			return ErrStatusConflict
+		}
+		if err != nil {
+			log.Errorf("error update status with audit entry: %v", err)
+		}
+		return err
+	}
+
+	var out *dynamodb.UpdateItemOutput
+	err = repo.call(ctx, operationUpdateStatus, func(ctx context.Context) (err error) {
+		out, err = repo.conn.UpdateItem(ctx, input, tracing.DynamoDB, repo.opts.DynamoDB)
+		return err
+	})
+	if isConditionalCheckFailed(err) {
+		return ErrStatusConflict
+	}
+	if err != nil {
+		log.Errorf("error update status: %v", err)
+		return err
+	}
+
+	repo.metrics.ConsumedCapacity(operationUpdateStatus, out.ConsumedCapacity)
+	return nil
+}
+
+func (repo *dynamoImpl) Delete(ctx context.Context, id string) error {
+	log := repo.log.WithContext(ctx)
+
+	item, err := repo.current(ctx, operationDelete, id)
+	if err != nil {
+		log.Errorf("error looking up user %s: %v", id, err)
+		return err
+	}
+	if item == nil {
+		return ErrNotFound
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName:                aws.String(repo.tableName),
+		Key:                      primaryKey(item),
+		ConditionExpression:      aws.String("attribute_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{"#id": "Id"},
+		ReturnConsumedCapacity:   types.ReturnConsumedCapacityTotal,
+	}
+
+	if repo.auditTable != "" {
+		var before models.UserDB
+		if err = fieldcrypt.UnmarshalMap(ctx, repo.enc, item, &before); err != nil {
+			log.Errorf("error unmarshal user: %v", err)
+			return err
+		}
+
+		err = repo.transact(ctx, operationDelete, types.TransactWriteItem{Delete: &types.Delete{
+			TableName:                input.TableName,
+			Key:                      input.Key,
+			ConditionExpression:      input.ConditionExpression,
+			ExpressionAttributeNames: input.ExpressionAttributeNames,
+		}}, audit.ActionDelete, &before, nil)
+		if isConditionalCheckFailed(err) {
+			return ErrNotFound
+		}
+		if err != nil {
+			log.Errorf("error delete item with audit entry: %v", err)
+		}
+		return err
+	}
+
+	var out *dynamodb.DeleteItemOutput
+	err = repo.call(ctx, operationDelete, func(ctx context.Context) (err error) {
+		out, err = repo.conn.DeleteItem(ctx, input, tracing.DynamoDB, repo.opts.DynamoDB)
+		return err
+	})
+	if isConditionalCheckFailed(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		log.Errorf("error delete item: %v", err)
+		return err
+	}
+
+	repo.metrics.ConsumedCapacity(operationDelete, out.ConsumedCapacity)
+	return nil
+}
@@ -0,0 +1,109 @@
+package userstore_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore/userstoretest"
+	"testing"
+	"time"
+)
+
+func newDynamoDBTable(t *testing.T) dynamodbapi.Client {
+	t.Helper()
+	log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+	client := dynamodbapi.NewInMemory()
+	if err := db.New(client, log, clientopts.New(clientopts.DefaultPolicy()), db.NewNoopCache()).ConfigureTable(context.Background(), "users"); err != nil {
+		t.Fatalf("creating table: %v", err)
+	}
+	return client
+}
+
+func TestDynamoDB(t *testing.T) {
+	for name, newEncryptor := range encryptors() {
+		t.Run(name, func(t *testing.T) {
+			userstoretest.Run(t, func(t *testing.T) userstore.UserRepository {
+				log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+				opts := clientopts.New(clientopts.DefaultPolicy())
+				return userstore.NewDynamoDB(newDynamoDBTable(t), "users", log, metrics.NewNoop(), opts, newEncryptor(t), "", clock.New())
+			})
+		})
+	}
+}
+
+func TestDynamoDBAudited(t *testing.T) {
+	userstoretest.Run(t, func(t *testing.T) userstore.UserRepository {
+		log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+		opts := clientopts.New(clientopts.DefaultPolicy())
+		client := newDynamoDBTable(t)
+		if err := audit.CreateTable(context.Background(), client, "audit"); err != nil {
+			t.Fatalf("creating audit table: %v", err)
+		}
+		return userstore.NewDynamoDB(client, "users", log, metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "audit", clock.New())
+	})
+}
+
+// scanCounter counts the scans sent to the client.
+type scanCounter struct {
+	dynamodbapi.Client
+	scans int
+}
+
+func (c *scanCounter) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	c.scans++
+	return c.Client.Scan(ctx, params, optFns...)
+}
+
+func TestDynamoDBListCapsTheScans(t *testing.T) {
+	ctx := context.Background()
+	log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+	client := &scanCounter{Client: newDynamoDBTable(t)}
+	repo := userstore.NewDynamoDB(client, "users", log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop(), "", clock.New())
+
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		user := &models.UserDB{ID: fmt.Sprintf("usr-%02d", i), Email: "other@example.com", CreatedAt: created, UpdatedAt: created}
+		if i == 29 {
+			user.Email = "john@example.com"
+		}
+		if err := repo.Insert(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := userstore.ListQuery{Limit: 2, Filter: userstore.Filter{Email: "john@example.com"}}
+	var found []string
+	for calls := 1; ; calls++ {
+		client.scans = 0
+		page, err := repo.List(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.scans > 5 {
+			t.Fatalf("expected at most 5 scans per page, got %d", client.scans)
+		}
+		for _, user := range page.Users {
+			found = append(found, user.ID)
+		}
+		if page.NextCursor == "" {
+			if calls == 1 {
+				t.Error("expected the filter to take several pages")
+			}
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if len(found) != 1 || found[0] != "usr-29" {
+		t.Errorf("expected the matching user only, got %v", found)
+	}
+}
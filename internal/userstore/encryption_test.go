@@ -0,0 +1,149 @@
+package userstore_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldKey = fieldcrypt.LocalKey{ID: "2024-01", Key: bytes.Repeat([]byte{1}, 32)}
+	newKey = fieldcrypt.LocalKey{ID: "2024-06", Key: bytes.Repeat([]byte{2}, 32)}
+)
+
+func newEncryptor(t *testing.T, keys ...fieldcrypt.LocalKey) fieldcrypt.Encryptor {
+	t.Helper()
+	provider, err := fieldcrypt.NewLocal(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fieldcrypt.New(provider, fieldcrypt.DefaultOptions())
+}
+
+// encryptors are the encryptions every backend runs the suite with.
+func encryptors() map[string]func(t *testing.T) fieldcrypt.Encryptor {
+	return map[string]func(t *testing.T) fieldcrypt.Encryptor{
+		"plaintext": func(*testing.T) fieldcrypt.Encryptor { return fieldcrypt.NewNoop() },
+		"encrypted": func(t *testing.T) fieldcrypt.Encryptor { return newEncryptor(t, oldKey) },
+	}
+}
+
+// storedUser is a user as written in the store.
+type storedUser struct {
+	Name, Lastname, Email string
+}
+
+func assertEncrypted(t *testing.T, stored storedUser) {
+	t.Helper()
+	if !strings.HasPrefix(stored.Name, "enc:v1:") || !strings.HasPrefix(stored.Lastname, "enc:v1:") {
+		t.Errorf("expected randomized ciphertexts, got %+v", stored)
+	}
+	if !strings.HasPrefix(stored.Email, "det:v1:") {
+		t.Errorf("expected a deterministic ciphertext, got %+v", stored)
+	}
+}
+
+// testRotation writes a user under the old key, reads and finds it under
+// the rotated keys, then updates it so it no longer needs the old key.
+func testRotation(t *testing.T, newRepo func(enc fieldcrypt.Encryptor) userstore.UserRepository, raw func(id string) storedUser) {
+	ctx := context.Background()
+	created := time.Date(2024, 4, 1, 10, 30, 0, 0, time.UTC)
+	user := &models.UserDB{ID: "usr-1", Name: "john", Lastname: "doe", Age: 30, Email: "john@example.com", CreatedAt: created, UpdatedAt: created}
+
+	if err := newRepo(newEncryptor(t, oldKey)).Insert(ctx, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored := raw(user.ID)
+	assertEncrypted(t, stored)
+	if user.Email != "john@example.com" {
+		t.Errorf("insert encrypted the user of the caller: %+v", user)
+	}
+
+	// the same email is stored the same way, other fields are not
+	other := *user
+	other.ID = "usr-2"
+	if err := newRepo(newEncryptor(t, oldKey)).Insert(ctx, &other); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if otherStored := raw(other.ID); otherStored.Email != stored.Email || otherStored.Name == stored.Name {
+		t.Errorf("expected a shared email ciphertext only, got %+v and %+v", stored, otherStored)
+	}
+
+	rotated := newRepo(newEncryptor(t, newKey, oldKey))
+	actual, err := rotated.Get(ctx, user.ID)
+	if err != nil || actual.Name != "john" || actual.Email != "john@example.com" {
+		t.Fatalf("unexpected user %+v, %v", actual, err)
+	}
+
+	page, err := rotated.List(ctx, userstore.ListQuery{Filter: userstore.Filter{Email: "john@example.com", Name: "john"}})
+	if err != nil || len(page.Users) != 2 {
+		t.Fatalf("expected both users by email and name, got %+v, %v", page, err)
+	}
+
+	// an update encrypts under the current key
+	actual.Lastname = "roe"
+	if err = rotated.Update(ctx, actual); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated := raw(user.ID); updated.Email == stored.Email {
+		t.Errorf("expected the email under the new key, got %+v", updated)
+	}
+	if actual, err = newRepo(newEncryptor(t, newKey)).Get(ctx, user.ID); err != nil || actual.Lastname != "roe" {
+		t.Fatalf("expected the user readable without the old key, got %+v, %v", actual, err)
+	}
+	if _, err = newRepo(newEncryptor(t, newKey)).Get(ctx, other.ID); err == nil {
+		t.Error("expected an error reading a user of a retired key")
+	}
+}
+
+func TestDynamoDBEncryption(t *testing.T) {
+	log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+	opts := clientopts.New(clientopts.DefaultPolicy())
+	client := newDynamoDBTable(t)
+
+	testRotation(t, func(enc fieldcrypt.Encryptor) userstore.UserRepository {
+		return userstore.NewDynamoDB(client, "users", log, metrics.NewNoop(), opts, enc, "", clock.New())
+	}, func(id string) storedUser {
+		out, err := client.Query(context.Background(), &dynamodb.QueryInput{
+			TableName:                 aws.String("users"),
+			KeyConditionExpression:    aws.String("Id = :id"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":id": &types.AttributeValueMemberS{Value: id}},
+		})
+		if err != nil || len(out.Items) != 1 {
+			t.Fatalf("reading %s: %v", id, err)
+		}
+		s := func(name string) string { return out.Items[0][name].(*types.AttributeValueMemberS).Value }
+		return storedUser{Name: s("Name"), Lastname: s("Lastname"), Email: s("Email")}
+	})
+}
+
+func TestSQLEncryption(t *testing.T) {
+	log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+	conn := openSQLite(t)
+	if err := userstore.Migrate(context.Background(), conn, "users"); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	testRotation(t, func(enc fieldcrypt.Encryptor) userstore.UserRepository {
+		return userstore.NewSQL(conn, "users", log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), enc, "", clock.New())
+	}, func(id string) storedUser {
+		var stored storedUser
+		err := conn.QueryRow(`SELECT name, lastname, email FROM users WHERE id = $1`, id).Scan(&stored.Name, &stored.Lastname, &stored.Email)
+		if err != nil {
+			t.Fatalf("reading %s: %v", id, err)
+		}
+		return stored
+	})
+}
@@ -0,0 +1,52 @@
+package userstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+)
+
+// OpenSQL connects to the database of cfg and returns its repository, the
+// tables are created first when cfg.Migrate is set. The returned db is
+// shared with the other stores of the database, such as the audit trail of
+// auditTable, and closing it releases the connections. The pgx driver is
+// registered by this package, others have to be imported by the caller.
+func OpenSQL(ctx context.Context, cfg config.SQL, log logging.Logger, m metrics.Metrics, opts clientopts.Options, enc fieldcrypt.Encryptor, auditTable string, clk clock.Clock) (UserRepository, *sql.DB, error) {
+	dsn, err := cfg.DSN.Value(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error resolving SQL_DSN: %w", err)
+	}
+
+	db, err := sql.Open(cfg.Driver, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("error connecting to %s: %w", cfg.Driver, err)
+	}
+
+	if cfg.Migrate {
+		if err = Migrate(ctx, db, cfg.Table); err != nil {
+			_ = db.Close()
+			return nil, nil, err
+		}
+		if auditTable != "" {
+			if err = audit.Migrate(ctx, db, auditTable); err != nil {
+				_ = db.Close()
+				return nil, nil, err
+			}
+		}
+	}
+
+	return NewSQL(db, cfg.Table, log, m, opts, enc, auditTable, clk), db, nil
+}
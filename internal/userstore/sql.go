@@ -0,0 +1,479 @@
+package userstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const userColumns = "id, name, lastname, age, email, status, status_reason, owner_subject, created_at, updated_at"
+
+type sqlImpl struct {
+	db      *sql.DB
+	table   string
+	log     logging.Logger
+	metrics metrics.Metrics
+	opts    clientopts.Options
+	enc     fieldcrypt.Encryptor
+	// auditTable receives the entries of the writes, empty when the audit
+	// is off.
+	auditTable string
+	// clock timestamps the audit entries.
+	clock clock.Clock
+}
+
+// NewSQL stores the users in a table of db, created by Migrate. Statements
+// use $n placeholders, understood by PostgreSQL and SQLite. The columns of
+// the fields tagged with encrypt are encrypted by enc. Every write appends
+// its audit.Entry, timestamped by clk, to auditTable in the same
+// transaction, unless auditTable is empty.
+func NewSQL(db *sql.DB, tableName string, log logging.Logger, m metrics.Metrics, opts clientopts.Options, enc fieldcrypt.Encryptor, auditTable string, clk clock.Clock) UserRepository {
+	return &sqlImpl{
+		db:         db,
+		table:      quoteIdentifier(tableName),
+		log:        log,
+		metrics:    m,
+		opts:       opts,
+		enc:        enc,
+		auditTable: auditTable,
+		clock:      clk,
+	}
+}
+
+// Migrate creates the table of NewSQL and its indexes when missing.
+func Migrate(ctx context.Context, db *sql.DB, tableName string) error {
+	table := quoteIdentifier(tableName)
+	create := `CREATE TABLE IF NOT EXISTS ` + table + ` (
+			id            TEXT PRIMARY KEY,
+			name          TEXT NOT NULL,
+			lastname      TEXT NOT NULL,
+			age           INTEGER NOT NULL,
+			email         TEXT NOT NULL,
+			status        TEXT NOT NULL DEFAULT '',
+			status_reason TEXT NOT NULL DEFAULT '',
+			owner_subject TEXT NOT NULL DEFAULT '',
+			created_at    TIMESTAMPTZ NOT NULL,
+			updated_at    TIMESTAMPTZ NOT NULL
+		)`
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("error migrating %s: %w", tableName, err)
+	}
+
+	var statements []string
+	// the tables created before the lifecycle get the status columns, their
+	// users have no status, nor owner
+	for _, column := range []string{"status", "status_reason", "owner_subject"} {
+		if _, err := db.ExecContext(ctx, `SELECT `+column+` FROM `+table+` LIMIT 0`); err != nil {
+			statements = append(statements, `ALTER TABLE `+table+` ADD COLUMN `+column+` TEXT NOT NULL DEFAULT ''`)
+		}
+	}
+	statements = append(statements,
+		`CREATE INDEX IF NOT EXISTS `+quoteIdentifier(tableName+"_email_idx")+` ON `+table+` (email)`,
+		`CREATE INDEX IF NOT EXISTS `+quoteIdentifier(tableName+"_status_idx")+` ON `+table+` (status)`,
+	)
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("error migrating %s: %w", tableName, err)
+		}
+	}
+	return nil
+}
+
+// quoteIdentifier quotes a table name the same way for PostgreSQL and
+// SQLite.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// call runs fn with the per-call context of operation and records its
+// latency.
+func (repo *sqlImpl) call(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	callCtx, cancel, err := repo.opts.Context(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	start := time.Now()
+	err = fn(callCtx)
+	repo.metrics.RepositoryLatency(operation, start)
+	return repo.opts.Err(operation, err)
+}
+
+// mutation is a write of a user, described by its audit entry.
+type mutation struct {
+	action audit.Action
+	id     string
+	// after is the user once written, nil when deleted.
+	after *models.UserDB
+	// apply derives after from the user before the write, when set.
+	apply func(before models.UserDB) *models.UserDB
+}
+
+// exec runs the statement of m and returns the number of affected rows.
+// With the audit on, the statement and the entry are written in one
+// transaction, rolled back when no row is affected.
+func (repo *sqlImpl) exec(ctx context.Context, operation string, m mutation, statement string, args ...any) (int64, error) {
+	var affected int64
+	err := repo.call(ctx, operation, func(ctx context.Context) error {
+		if repo.auditTable == "" {
+			result, err := repo.db.ExecContext(ctx, statement, args...)
+			if err != nil {
+				return err
+			}
+			affected, err = result.RowsAffected()
+			return err
+		}
+
+		tx, err := repo.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		var before *models.UserDB
+		if m.action != audit.ActionCreate {
+			before, err = scanUser(tx.QueryRowContext(ctx, `SELECT `+userColumns+` FROM `+repo.table+` WHERE id = $1`, m.id))
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err = repo.enc.Decrypt(ctx, before); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.ExecContext(ctx, statement, args...)
+		if err != nil {
+			return err
+		}
+		if affected, err = result.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+
+		after := m.after
+		switch {
+		case m.apply != nil && before != nil:
+			after = m.apply(*before)
+		case after != nil && before != nil:
+			// the creation date, the status and the owner are kept
+			updated := *after
+			updated.CreatedAt = before.CreatedAt
+			updated.Status, updated.StatusReason = before.Status, before.StatusReason
+			updated.OwnerSubject = before.OwnerSubject
+			after = &updated
+		}
+		entry, err := audit.NewEntry(ctx, repo.enc, repo.clock, m.action, before, after)
+		if err != nil {
+			return err
+		}
+		if err = audit.Insert(ctx, tx, repo.auditTable, entry); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	return affected, err
+}
+
+func (repo *sqlImpl) Insert(ctx context.Context, user *models.UserDB) error {
+	log := repo.log.WithContext(ctx)
+	query := `INSERT INTO ` + repo.table + ` (` + userColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT (id) DO NOTHING`
+
+	stored, err := fieldcrypt.Encrypted(ctx, repo.enc, user)
+	if err != nil {
+		log.Errorf("error encrypting user: %v", err)
+		return err
+	}
+
+	inserted, err := repo.exec(ctx, operationInsert, mutation{action: audit.ActionCreate, id: user.ID, after: user}, query,
+		stored.ID, stored.Name, stored.Lastname, stored.Age, stored.Email, stored.Status, stored.StatusReason, stored.OwnerSubject, stored.CreatedAt.UTC(), stored.UpdatedAt.UTC())
+	if err != nil {
+		log.Errorf("error inserting user: %v", err)
+		return err
+	}
+	if inserted == 0 {
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+func (repo *sqlImpl) Get(ctx context.Context, id string) (*models.UserDB, error) {
+	log := repo.log.WithContext(ctx)
+	query := `SELECT ` + userColumns + ` FROM ` + repo.table + ` WHERE id = $1`
+
+	var user *models.UserDB
+	err := repo.call(ctx, operationGet, func(ctx context.Context) (err error) {
+		user, err = scanUser(repo.db.QueryRowContext(ctx, query, id))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Errorf("error selecting user %s: %v", id, err)
+		return nil, err
+	}
+	if err = repo.enc.Decrypt(ctx, user); err != nil {
+		log.Errorf("error decrypting user %s: %v", id, err)
+		return nil, err
+	}
+	return user, nil
+}
+
+type sqlCursor struct {
+	ID string `json:"id"`
+}
+
+func (repo *sqlImpl) List(ctx context.Context, query ListQuery) (*Page, error) {
+	log := repo.log.WithContext(ctx)
+
+	var position sqlCursor
+	if query.Cursor != "" {
+		if err := decodeCursor(query.Cursor, &position); err != nil {
+			return nil, err
+		}
+		if position.ID == "" {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	stored, err := query.Filter.stored(ctx, repo.enc)
+	if err != nil {
+		log.Errorf("error encrypting filter: %v", err)
+		return nil, err
+	}
+
+	// one more user than the limit tells whether there is a next page, the
+	// rows not matching the decrypted filter take more batches
+	limit := query.limit()
+	page := &Page{Users: []*models.UserDB{}}
+	for len(page.Users) <= limit {
+		statement, args := repo.listStatement(stored, position, limit+1)
+
+		var rows int
+		err = repo.call(ctx, operationList, func(ctx context.Context) error {
+			result, err := repo.db.QueryContext(ctx, statement, args...)
+			if err != nil {
+				return err
+			}
+			defer result.Close()
+
+			for result.Next() {
+				user, err := scanUser(result)
+				if err != nil {
+					return err
+				}
+				rows++
+				position.ID = user.ID
+
+				if err = repo.enc.Decrypt(ctx, user); err != nil {
+					return err
+				}
+				if stored.decrypted.matches(user) {
+					page.Users = append(page.Users, user)
+				}
+			}
+			return result.Err()
+		})
+		if err != nil {
+			log.Errorf("error listing users: %v", err)
+			return nil, err
+		}
+		if rows <= limit {
+			break
+		}
+	}
+
+	if len(page.Users) > limit {
+		page.Users = page.Users[:limit]
+		cursor, err := encodeCursor(sqlCursor{ID: page.Users[limit-1].ID})
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = cursor
+	}
+	return page, nil
+}
+
+// listStatement selects up to size users after position matching f.
+func (repo *sqlImpl) listStatement(f storedFilter, position sqlCursor, size int) (string, []any) {
+	var conditions []string
+	var args []any
+	placeholder := func(arg any) string {
+		args = append(args, arg)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if position.ID != "" {
+		conditions = append(conditions, "id > "+placeholder(position.ID))
+	}
+
+	for _, equal := range f.equal {
+		column := strings.ToLower(equal.field)
+		if len(equal.values) == 1 {
+			conditions = append(conditions, column+" = "+placeholder(equal.values[0]))
+			continue
+		}
+
+		placeholders := make([]string, len(equal.values))
+		for i, value := range equal.values {
+			placeholders[i] = placeholder(value)
+		}
+		conditions = append(conditions, column+" IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if f.minAge != nil {
+		conditions = append(conditions, "age >= "+placeholder(*f.minAge))
+	}
+	if f.maxAge != nil {
+		conditions = append(conditions, "age <= "+placeholder(*f.maxAge))
+	}
+	if f.status == models.DefaultStatus {
+		conditions = append(conditions, "status IN ("+placeholder(f.status)+", '')")
+	} else if f.status != "" {
+		conditions = append(conditions, "status = "+placeholder(f.status))
+	}
+
+	statement := `SELECT ` + userColumns + ` FROM ` + repo.table
+	if len(conditions) > 0 {
+		statement += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	return statement + ` ORDER BY id LIMIT ` + strconv.Itoa(size), args
+}
+
+func (repo *sqlImpl) Update(ctx context.Context, user *models.UserDB) error {
+	log := repo.log.WithContext(ctx)
+	query := `UPDATE ` + repo.table + ` SET name = $1, lastname = $2, age = $3, email = $4, updated_at = $5 WHERE id = $6`
+
+	stored, err := fieldcrypt.Encrypted(ctx, repo.enc, user)
+	if err != nil {
+		log.Errorf("error encrypting user: %v", err)
+		return err
+	}
+
+	updated, err := repo.exec(ctx, operationUpdate, mutation{action: audit.ActionUpdate, id: user.ID, after: user}, query,
+		stored.Name, stored.Lastname, stored.Age, stored.Email, stored.UpdatedAt.UTC(), stored.ID)
+	if err != nil {
+		log.Errorf("error updating user %s: %v", user.ID, err)
+		return err
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (repo *sqlImpl) UpdateStatus(ctx context.Context, id string, change StatusChange) error {
+	log := repo.log.WithContext(ctx)
+	query := `UPDATE ` + repo.table + ` SET status = $1, status_reason = $2, updated_at = $3 WHERE id = $4 AND status = $5`
+	if change.From == models.DefaultStatus {
+		// the users stored before the lifecycle have no status
+		query = `UPDATE ` + repo.table + ` SET status = $1, status_reason = $2, updated_at = $3 WHERE id = $4 AND status IN ($5, '')`
+	}
+	args := []any{change.To, change.Reason, change.UpdatedAt.UTC(), id, change.From}
+	if !change.Seen.IsZero() {
+		query += ` AND updated_at = $6`
+		args = append(args, change.Seen.UTC())
+	}
+
+	apply := func(before models.UserDB) *models.UserDB {
+		before.Status, before.StatusReason, before.UpdatedAt = change.To, change.Reason, change.UpdatedAt.UTC()
+		return &before
+	}
+	updated, err := repo.exec(ctx, operationUpdateStatus, mutation{action: audit.ActionUpdate, id: id, apply: apply}, query, args...)
+	if err != nil {
+		log.Errorf("error updating status of user %s: %v", id, err)
+		return err
+	}
+	if updated > 0 {
+		return nil
+	}
+
+	// nothing matched, either the user is gone, in another status or
+	// updated since it was seen
+	if _, err = repo.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrStatusConflict
+}
+
+func (repo *sqlImpl) Delete(ctx context.Context, id string) error {
+	log := repo.log.WithContext(ctx)
+	query := `DELETE FROM ` + repo.table + ` WHERE id = $1`
+
+	deleted, err := repo.exec(ctx, operationDelete, mutation{action: audit.ActionDelete, id: id}, query, id)
+	if err != nil {
+		log.Errorf("error deleting user %s: %v", id, err)
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (*models.UserDB, error) {
+	var user models.UserDB
+	err := row.Scan(&user.ID, &user.Name, &user.Lastname, &user.Age, &user.Email, &user.Status, &user.StatusReason, &user.OwnerSubject,
+		timestamp{&user.CreatedAt}, timestamp{&user.UpdatedAt})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// timestampLayouts are the text forms of a timestamp column, drivers
+// without a native type (SQLite) hand them back as strings.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+}
+
+// timestamp scans a timestamp column in UTC, whatever the driver returns.
+type timestamp struct {
+	t *time.Time
+}
+
+func (ts timestamp) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*ts.t = v.UTC()
+		return nil
+	case string:
+		return ts.parse(v)
+	case []byte:
+		return ts.parse(string(v))
+	default:
+		return fmt.Errorf("unsupported timestamp %T", src)
+	}
+}
+
+func (ts timestamp) parse(s string) error {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			*ts.t = t.UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported timestamp %q", s)
+}
@@ -0,0 +1,93 @@
+package userstore_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore/userstoretest"
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"testing"
+)
+
+// SQLite stands in for PostgreSQL, the statements of NewSQL are portable.
+func openSQLite(t *testing.T) *sql.DB {
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestSQL(t *testing.T) {
+	for name, newEncryptor := range encryptors() {
+		t.Run(name, func(t *testing.T) {
+			userstoretest.Run(t, func(t *testing.T) userstore.UserRepository {
+				log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+				conn := openSQLite(t)
+				if err := userstore.Migrate(context.Background(), conn, "users"); err != nil {
+					t.Fatalf("migrating: %v", err)
+				}
+				return userstore.NewSQL(conn, "users", log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), newEncryptor(t), "", clock.New())
+			})
+		})
+	}
+}
+
+func TestSQLAudited(t *testing.T) {
+	userstoretest.Run(t, func(t *testing.T) userstore.UserRepository {
+		log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+		conn := openSQLite(t)
+		if err := userstore.Migrate(context.Background(), conn, "users"); err != nil {
+			t.Fatalf("migrating: %v", err)
+		}
+		if err := audit.Migrate(context.Background(), conn, "audit"); err != nil {
+			t.Fatalf("migrating audit: %v", err)
+		}
+		return userstore.NewSQL(conn, "users", log, metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()), fieldcrypt.NewNoop(), "audit", clock.New())
+	})
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	conn := openSQLite(t)
+	for i := 0; i < 2; i++ {
+		if err := userstore.Migrate(context.Background(), conn, "users"); err != nil {
+			t.Fatalf("migration %d: %v", i, err)
+		}
+	}
+}
+
+func TestOpenSQL(t *testing.T) {
+	log := logging.New(logging.Opts{AppName: "userstore-test", Level: "error"})
+	opts := clientopts.New(clientopts.DefaultPolicy())
+	cfg := config.SQL{
+		Driver:  "sqlite",
+		DSN:     config.NewSecret(filepath.Join(t.TempDir(), "users.db")),
+		Table:   "users",
+		Migrate: true,
+	}
+
+	repo, closer, err := userstore.OpenSQL(context.Background(), cfg, log, metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "audit", clock.New())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer closer.Close()
+
+	page, err := repo.List(context.Background(), userstore.ListQuery{})
+	if err != nil || len(page.Users) != 0 {
+		t.Fatalf("expected an empty migrated table, got %v, %v", page, err)
+	}
+
+	cfg.Driver = "unknown"
+	if _, _, err = userstore.OpenSQL(context.Background(), cfg, log, metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "audit", clock.New()); err == nil {
+		t.Fatal("expected an error for an unregistered driver")
+	}
+}
@@ -0,0 +1,190 @@
+// Package userstore declares the storage of the users independently of the
+// database. NewDynamoDB keeps them in the table of the lambdas, NewSQL in a
+// database/sql database such as PostgreSQL, and Open picks one from the
+// configuration. Every implementation passes the userstoretest suite.
+package userstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when no user has the requested id.
+	ErrNotFound = errors.New("user not found")
+	// ErrAlreadyExists is returned by Insert when the id is taken.
+	ErrAlreadyExists = errors.New("user already exists")
+	// ErrInvalidCursor is returned by List for a cursor it did not issue.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrStatusConflict is returned by UpdateStatus when the user is no
+	// longer in the expected status.
+	ErrStatusConflict = errors.New("user status changed")
+)
+
+const (
+	// DefaultPageSize is used by List when the query has no limit.
+	DefaultPageSize = 50
+	// MaxPageSize caps the limit of List.
+	MaxPageSize = 1000
+)
+
+const (
+	operationInsert = "Insert"
+	operationGet    = "Get"
+	operationList   = "List"
+	operationUpdate = "Update"
+	operationDelete = "Delete"
+
+	operationUpdateStatus = "UpdateStatus"
+)
+
+type UserRepository interface {
+	// Insert stores a new user, ErrAlreadyExists when the id is taken.
+	Insert(ctx context.Context, user *models.UserDB) error
+	// Get returns the user with the id, ErrNotFound when there is none.
+	Get(ctx context.Context, id string) (*models.UserDB, error)
+	// List returns a page of the users matching the filter of the query.
+	List(ctx context.Context, query ListQuery) (*Page, error)
+	// Update replaces the attributes of an existing user, the creation date
+	// and the status are kept. ErrNotFound when there is no user with the id.
+	Update(ctx context.Context, user *models.UserDB) error
+	// UpdateStatus moves the user with the id from change.From to change.To,
+	// ErrNotFound when there is none and ErrStatusConflict when it is in
+	// another status.
+	UpdateStatus(ctx context.Context, id string, change StatusChange) error
+	// Delete removes the user with the id, ErrNotFound when there is none.
+	Delete(ctx context.Context, id string) error
+}
+
+type ListQuery struct {
+	// Limit is the maximum number of users of the page, DefaultPageSize
+	// when zero.
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first one.
+	Cursor string
+	Filter Filter
+}
+
+// StatusChange is a transition of the status of a user, the state machine
+// is enforced by the services.
+type StatusChange struct {
+	// From is the status the user is expected in, models.DefaultStatus
+	// also matches the users without status.
+	From      string
+	To        string
+	Reason    string
+	UpdatedAt time.Time
+	// Seen is the UpdatedAt the user was read with, when set the change
+	// conflicts once the user was updated since even if it is back in From.
+	Seen time.Time
+}
+
+// Filter keeps the users matching every non-zero field.
+type Filter struct {
+	Email    string
+	Name     string
+	Lastname string
+	MinAge   *int32
+	MaxAge   *int32
+	// Status keeps the users in the status, the ones without status have
+	// models.DefaultStatus.
+	Status string
+}
+
+// storedFilter is a Filter against the stored attributes. The values of
+// the searchable fields are looked up under every encryption key, the ones
+// of the fields encrypted at random are matched after decryption.
+type storedFilter struct {
+	equal  []storedValues
+	minAge *int32
+	maxAge *int32
+	status string
+	// decrypted is matched by the decrypted users, see Filter.matches.
+	decrypted Filter
+}
+
+// storedValues are the stored forms of a value of field, any of them
+// matches.
+type storedValues struct {
+	field  string
+	values []string
+}
+
+func (f Filter) stored(ctx context.Context, enc fieldcrypt.Encryptor) (storedFilter, error) {
+	s := storedFilter{minAge: f.MinAge, maxAge: f.MaxAge, status: f.Status}
+	for _, text := range []struct {
+		field     string
+		value     string
+		decrypted *string
+	}{
+		{"Email", f.Email, &s.decrypted.Email},
+		{"Name", f.Name, &s.decrypted.Name},
+		{"Lastname", f.Lastname, &s.decrypted.Lastname},
+	} {
+		if text.value == "" {
+			continue
+		}
+
+		values, err := enc.Lookup(ctx, models.UserDB{}, text.field, text.value)
+		if errors.Is(err, fieldcrypt.ErrNotSearchable) {
+			*text.decrypted = text.value
+			continue
+		}
+		if err != nil {
+			return storedFilter{}, err
+		}
+		s.equal = append(s.equal, storedValues{field: text.field, values: values})
+	}
+	return s, nil
+}
+
+// matches reports whether user has the email and names of f, the ages are
+// always compared by the backend.
+func (f Filter) matches(user *models.UserDB) bool {
+	return (f.Email == "" || user.Email == f.Email) &&
+		(f.Name == "" || user.Name == f.Name) &&
+		(f.Lastname == "" || user.Lastname == f.Lastname)
+}
+
+type Page struct {
+	Users []*models.UserDB
+	// NextCursor is empty on the last page. A page can be shorter than the
+	// limit, even empty, and still have a next one.
+	NextCursor string
+}
+
+func (q ListQuery) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultPageSize
+	case q.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return q.Limit
+	}
+}
+
+// encodeCursor hides the position of a backend behind an opaque string.
+func encodeCursor(position any) (string, error) {
+	raw, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string, position any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err = json.Unmarshal(raw, position); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
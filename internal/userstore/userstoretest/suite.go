@@ -0,0 +1,433 @@
+// Package userstoretest is the conformance suite of the userstore
+// implementations, every backend runs it from its own test.
+package userstoretest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"testing"
+	"time"
+)
+
+// Run checks the behaviour shared by every UserRepository. newRepo returns
+// a repository over an empty store, it is called once per subtest.
+func Run(t *testing.T, newRepo func(t *testing.T) userstore.UserRepository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, ctx context.Context, repo userstore.UserRepository)
+	}{
+		{"InsertAndGet", testInsertAndGet},
+		{"InsertDuplicate", testInsertDuplicate},
+		{"GetMissing", testGetMissing},
+		{"Update", testUpdate},
+		{"UpdateMissing", testUpdateMissing},
+		{"Delete", testDelete},
+		{"ListPages", testListPages},
+		{"ListFilter", testListFilter},
+		{"ListFilterPages", testListFilterPages},
+		{"ListInvalidCursor", testListInvalidCursor},
+		{"UpdateStatus", testUpdateStatus},
+		{"UpdateStatusConflict", testUpdateStatusConflict},
+		{"UpdateStatusSeen", testUpdateStatusSeen},
+		{"UpdateStatusMissing", testUpdateStatusMissing},
+		{"UpdateKeepsStatus", testUpdateKeepsStatus},
+		{"ListStatus", testListStatus},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			tc.fn(t, ctx, newRepo(t))
+		})
+	}
+}
+
+// newUser builds a user with times that survive every backend, PostgreSQL
+// keeps microseconds.
+func newUser(id string, age int32) *models.UserDB {
+	created := time.Date(2024, 4, 1, 10, 30, 0, 123456000, time.UTC)
+	return &models.UserDB{
+		ID:       id,
+		Name:     "john",
+		Lastname: "doe",
+		Age:      age,
+		Email:    id + "@example.com",
+		// the subject of the creator is kept with the user
+		OwnerSubject: "sub-" + id,
+		CreatedAt:    created,
+		UpdatedAt:    created,
+	}
+}
+
+func insert(t *testing.T, ctx context.Context, repo userstore.UserRepository, users ...*models.UserDB) {
+	t.Helper()
+	for _, user := range users {
+		if err := repo.Insert(ctx, user); err != nil {
+			t.Fatalf("inserting %s: %v", user.ID, err)
+		}
+	}
+}
+
+func assertUser(t *testing.T, expected, actual *models.UserDB) {
+	t.Helper()
+	if actual == nil {
+		t.Fatalf("expected user %s, got nil", expected.ID)
+	}
+	if actual.ID != expected.ID || actual.Name != expected.Name || actual.Lastname != expected.Lastname ||
+		actual.Age != expected.Age || actual.Email != expected.Email || actual.OwnerSubject != expected.OwnerSubject {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if !actual.CreatedAt.Equal(expected.CreatedAt) || !actual.UpdatedAt.Equal(expected.UpdatedAt) {
+		t.Errorf("expected times %s/%s, got %s/%s", expected.CreatedAt, expected.UpdatedAt, actual.CreatedAt, actual.UpdatedAt)
+	}
+}
+
+// listAll follows the cursors until the last page and returns the ids.
+func listAll(t *testing.T, ctx context.Context, repo userstore.UserRepository, query userstore.ListQuery) []string {
+	t.Helper()
+	var ids []string
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("too many pages")
+		}
+
+		page, err := repo.List(ctx, query)
+		if err != nil {
+			t.Fatalf("listing: %v", err)
+		}
+		if query.Limit > 0 && len(page.Users) > query.Limit {
+			t.Errorf("page of %d users over the limit of %d", len(page.Users), query.Limit)
+		}
+		for _, user := range page.Users {
+			ids = append(ids, user.ID)
+		}
+
+		if page.NextCursor == "" {
+			return ids
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func assertIDs(t *testing.T, expected, actual []string) {
+	t.Helper()
+	seen := map[string]int{}
+	for _, id := range actual {
+		seen[id]++
+	}
+	for _, id := range expected {
+		if seen[id] != 1 {
+			t.Errorf("expected %s once, got it %d times in %v", id, seen[id], actual)
+		}
+	}
+	if len(actual) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func testInsertAndGet(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	user := newUser("usr-1", 30)
+	insert(t, ctx, repo, user)
+
+	actual, err := repo.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertUser(t, user, actual)
+}
+
+func testInsertDuplicate(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	insert(t, ctx, repo, newUser("usr-1", 30))
+
+	// another creation date must not hide the conflict
+	duplicate := newUser("usr-1", 40)
+	duplicate.CreatedAt = duplicate.CreatedAt.Add(time.Hour)
+	if err := repo.Insert(ctx, duplicate); !errors.Is(err, userstore.ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	actual, err := repo.Get(ctx, "usr-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertUser(t, newUser("usr-1", 30), actual)
+}
+
+func testGetMissing(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testUpdate(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	user := newUser("usr-1", 30)
+	insert(t, ctx, repo, user)
+
+	updated := *user
+	updated.Name = "jane"
+	updated.Lastname = "roe"
+	updated.Age = 31
+	updated.Email = "jane@example.com"
+	updated.UpdatedAt = user.UpdatedAt.Add(time.Minute)
+	// the creation date is not updatable
+	updated.CreatedAt = user.CreatedAt.Add(time.Hour)
+	if err := repo.Update(ctx, &updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual, err := repo.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updated.CreatedAt = user.CreatedAt
+	assertUser(t, &updated, actual)
+}
+
+func testUpdateMissing(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	if err := repo.Update(ctx, newUser("missing", 30)); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("update created the user: %v", err)
+	}
+}
+
+func testDelete(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	insert(t, ctx, repo, newUser("usr-1", 30), newUser("usr-2", 30))
+
+	if err := repo.Delete(ctx, "usr-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := repo.Get(ctx, "usr-1"); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := repo.Delete(ctx, "usr-1"); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound on second delete, got %v", err)
+	}
+	if _, err := repo.Get(ctx, "usr-2"); err != nil {
+		t.Fatalf("delete removed another user: %v", err)
+	}
+}
+
+func testListPages(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	if ids := listAll(t, ctx, repo, userstore.ListQuery{}); len(ids) != 0 {
+		t.Fatalf("expected no user, got %v", ids)
+	}
+
+	var expected []string
+	for i := 0; i < 7; i++ {
+		user := newUser(fmt.Sprintf("usr-%d", i), 30)
+		insert(t, ctx, repo, user)
+		expected = append(expected, user.ID)
+	}
+
+	assertIDs(t, expected, listAll(t, ctx, repo, userstore.ListQuery{Limit: 3}))
+	assertIDs(t, expected, listAll(t, ctx, repo, userstore.ListQuery{Limit: 7}))
+
+	page, err := repo.List(ctx, userstore.ListQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Users) != 7 || page.NextCursor != "" {
+		t.Errorf("expected a single page of 7 users, got %d users and cursor %q", len(page.Users), page.NextCursor)
+	}
+}
+
+func testListFilter(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	young, old := newUser("usr-young", 20), newUser("usr-old", 60)
+	other := newUser("usr-other", 40)
+	other.Name = "jane"
+	other.Lastname = "roe"
+	insert(t, ctx, repo, young, old, other)
+
+	minAge, maxAge := int32(30), int32(50)
+	tests := []struct {
+		name     string
+		filter   userstore.Filter
+		expected []string
+	}{
+		{"email", userstore.Filter{Email: old.Email}, []string{old.ID}},
+		{"name", userstore.Filter{Name: "john"}, []string{young.ID, old.ID}},
+		{"lastname", userstore.Filter{Lastname: "roe"}, []string{other.ID}},
+		{"min age", userstore.Filter{MinAge: &minAge}, []string{other.ID, old.ID}},
+		{"max age", userstore.Filter{MaxAge: &maxAge}, []string{young.ID, other.ID}},
+		{"age range", userstore.Filter{MinAge: &minAge, MaxAge: &maxAge}, []string{other.ID}},
+		{"combined", userstore.Filter{Name: "john", MinAge: &minAge}, []string{old.ID}},
+		{"no match", userstore.Filter{Email: "nobody@example.com"}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assertIDs(t, tc.expected, listAll(t, ctx, repo, userstore.ListQuery{Filter: tc.filter}))
+		})
+	}
+}
+
+func testListFilterPages(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	var expected []string
+	for i := 0; i < 10; i++ {
+		user := newUser(fmt.Sprintf("usr-%d", i), int32(20+i))
+		insert(t, ctx, repo, user)
+		if i%2 == 0 {
+			expected = append(expected, user.ID)
+		}
+	}
+
+	// the even users are the only ones with this name
+	for i := 0; i < 10; i += 2 {
+		user := newUser(fmt.Sprintf("usr-%d", i), int32(20+i))
+		user.Name = "even"
+		if err := repo.Update(ctx, user); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	assertIDs(t, expected, listAll(t, ctx, repo, userstore.ListQuery{Limit: 2, Filter: userstore.Filter{Name: "even"}}))
+}
+
+func testListInvalidCursor(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		if _, err := repo.List(ctx, userstore.ListQuery{Cursor: cursor}); !errors.Is(err, userstore.ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor for %q, got %v", cursor, err)
+		}
+	}
+}
+
+func testUpdateStatus(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	// a user stored without status is active
+	user := newUser("usr-1", 30)
+	insert(t, ctx, repo, user)
+
+	suspended := user.UpdatedAt.Add(time.Minute)
+	change := userstore.StatusChange{From: models.DefaultStatus, To: "suspended", Reason: "spam", UpdatedAt: suspended}
+	if err := repo.UpdateStatus(ctx, user.ID, change); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual, err := repo.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual.Status != "suspended" || actual.StatusReason != "spam" || !actual.UpdatedAt.Equal(suspended) {
+		t.Errorf("expected suspended for spam at %s, got %+v", suspended, actual)
+	}
+
+	change = userstore.StatusChange{From: "suspended", To: models.DefaultStatus, UpdatedAt: suspended.Add(time.Minute)}
+	if err = repo.UpdateStatus(ctx, user.ID, change); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual, err = repo.Get(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual.Status != models.DefaultStatus || actual.StatusReason != "" {
+		t.Errorf("expected active without reason, got %+v", actual)
+	}
+}
+
+func testUpdateStatusConflict(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	user := newUser("usr-1", 30)
+	user.Status = "closed"
+	insert(t, ctx, repo, user)
+
+	change := userstore.StatusChange{From: models.DefaultStatus, To: "suspended", Reason: "spam", UpdatedAt: user.UpdatedAt.Add(time.Minute)}
+	if err := repo.UpdateStatus(ctx, user.ID, change); !errors.Is(err, userstore.ErrStatusConflict) {
+		t.Fatalf("expected ErrStatusConflict, got %v", err)
+	}
+	actual, err := repo.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual.Status != "closed" || !actual.UpdatedAt.Equal(user.UpdatedAt) {
+		t.Errorf("the conflicting change was written: %+v", actual)
+	}
+}
+
+func testUpdateStatusSeen(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	user := newUser("usr-1", 30)
+	insert(t, ctx, repo, user)
+	seen := user.UpdatedAt
+
+	// suspended and reactivated since it was seen, the status is the same
+	suspended := seen.Add(time.Minute)
+	for _, change := range []userstore.StatusChange{
+		{From: models.DefaultStatus, To: "suspended", Reason: "spam", UpdatedAt: suspended, Seen: seen},
+		{From: "suspended", To: models.DefaultStatus, UpdatedAt: suspended.Add(time.Minute)},
+	} {
+		if err := repo.UpdateStatus(ctx, user.ID, change); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	change := userstore.StatusChange{From: models.DefaultStatus, To: "closed", Reason: "gone", UpdatedAt: suspended.Add(2 * time.Minute), Seen: seen}
+	if err := repo.UpdateStatus(ctx, user.ID, change); !errors.Is(err, userstore.ErrStatusConflict) {
+		t.Fatalf("expected ErrStatusConflict, got %v", err)
+	}
+	actual, err := repo.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual.Status != models.DefaultStatus {
+		t.Errorf("the stale change was written: %+v", actual)
+	}
+}
+
+func testUpdateStatusMissing(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	change := userstore.StatusChange{From: models.DefaultStatus, To: "closed", Reason: "gone", UpdatedAt: time.Now()}
+	if err := repo.UpdateStatus(ctx, "missing", change); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, userstore.ErrNotFound) {
+		t.Fatalf("the status change created the user: %v", err)
+	}
+}
+
+func testUpdateKeepsStatus(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	user := newUser("usr-1", 30)
+	user.Status = "suspended"
+	user.StatusReason = "spam"
+	insert(t, ctx, repo, user)
+
+	// the status only changes through UpdateStatus
+	updated := *user
+	updated.Name = "jane"
+	updated.Status = models.DefaultStatus
+	updated.StatusReason = ""
+	updated.OwnerSubject = "sub-other"
+	if err := repo.Update(ctx, &updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual, err := repo.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual.Name != "jane" || actual.Status != "suspended" || actual.StatusReason != "spam" || actual.OwnerSubject != user.OwnerSubject {
+		t.Errorf("expected jane still suspended for spam and owned by %s, got %+v", user.OwnerSubject, actual)
+	}
+}
+
+func testListStatus(t *testing.T, ctx context.Context, repo userstore.UserRepository) {
+	legacy, active, suspended := newUser("usr-legacy", 30), newUser("usr-active", 30), newUser("usr-suspended", 30)
+	active.Status = models.DefaultStatus
+	suspended.Status = "suspended"
+	insert(t, ctx, repo, legacy, active, suspended)
+
+	tests := []struct {
+		name     string
+		filter   userstore.Filter
+		expected []string
+	}{
+		{"active", userstore.Filter{Status: models.DefaultStatus}, []string{active.ID, legacy.ID}},
+		{"suspended", userstore.Filter{Status: "suspended"}, []string{suspended.ID}},
+		{"combined", userstore.Filter{Status: "suspended", Name: "john"}, []string{suspended.ID}},
+		{"no match", userstore.Filter{Status: "closed"}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assertIDs(t, tc.expected, listAll(t, ctx, repo, userstore.ListQuery{Filter: tc.filter}))
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package encoding
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// TagPII marks struct fields holding personal data, the tag value selects the
+// strategy applied before the value reaches a log line, e.g. `pii:"mask"`.
+const TagPII = "pii"
+
+const (
+	// StrategyMask keeps the first and last characters (and the domain of an email).
+	StrategyMask = "mask"
+	// StrategyHash replaces the value with a short sha256 so equal values can still be correlated.
+	StrategyHash = "hash"
+	// StrategyDrop removes the field from the output.
+	StrategyDrop = "drop"
+)
+
+const hashPrefix = "sha256:"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ToLogString serializes input as json applying the pii strategies declared
+// in its struct tags. Use it for anything written to the logs, ToString is
+// reserved for response bodies.
+func ToLogString(input any) string {
+	return ToString(Redact(input))
+}
+
+// Redact returns a copy of input made of maps and slices where every field
+// tagged with pii has been masked, hashed or dropped. Field names follow the
+// json tags so the output looks like the regular json representation.
+func Redact(input any) any {
+	return redactValue(reflect.ValueOf(input))
+}
+
+func redactValue(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface()
+		}
+		return redactStruct(v)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		out := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = redactValue(v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = redactValue(iter.Value())
+		}
+		return out
+	default:
+		if v.CanInterface() {
+			return v.Interface()
+		}
+		return nil
+	}
+}
+
+func redactStruct(v reflect.Value) map[string]any {
+	out := make(map[string]any, v.NumField())
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		value := v.Field(i)
+
+		// embedded structs without json name are flattened like encoding/json does
+		if field.Anonymous && len(name) == 0 {
+			if nested, ok := redactValue(value).(map[string]any); ok {
+				for key, val := range nested {
+					out[key] = val
+				}
+				continue
+			}
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		strategy, tagged := field.Tag.Lookup(TagPII)
+		if !tagged {
+			out[name] = redactValue(value)
+			continue
+		}
+
+		if strategy == StrategyDrop {
+			continue
+		}
+
+		if isZero(value) {
+			out[name] = redactValue(value)
+			continue
+		}
+
+		out[name] = redactScalar(strategy, fmt.Sprint(redactValue(value)))
+	}
+
+	return out
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+	return name, false
+}
+
+func isZero(v reflect.Value) bool {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	return v.IsZero()
+}
+
+func redactScalar(strategy, value string) string {
+	switch strategy {
+	case StrategyHash:
+		return Hash(value)
+	default:
+		// unknown strategies fall back to mask, never leak the raw value
+		return Mask(value)
+	}
+}
+
+// Mask keeps the first and last character of value, emails keep their domain:
+// "john.smith@test.com" becomes "j********h@test.com".
+func Mask(value string) string {
+	if local, domain, ok := strings.Cut(value, "@"); ok && len(domain) > 0 {
+		return maskString(local) + "@" + domain
+	}
+	return maskString(value)
+}
+
+func maskString(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 2 {
+		return strings.Repeat("*", len(runes))
+	}
+
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
+
+// Hash returns a short, stable digest of value to correlate log lines without
+// exposing it.
+func Hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hashPrefix + hex.EncodeToString(sum[:8])
+}
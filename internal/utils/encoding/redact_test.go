@@ -0,0 +1,113 @@
+package encoding_test
+
+import (
+	"encoding/json"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/encoding"
+	"strings"
+	"testing"
+	"time"
+)
+
+type account struct {
+	Username string            `json:"username"`
+	Email    string            `json:"email" pii:"mask"`
+	Phone    string            `json:"phone" pii:"hash"`
+	Password string            `json:"password" pii:"drop"`
+	Internal string            `json:"-"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Owner    *models.UserDB    `json:"owner,omitempty"`
+}
+
+func TestMask(t *testing.T) {
+	cases := map[string]string{
+		"john.smith@test.com": "j********h@test.com",
+		"john":                "j**n",
+		"jo":                  "**",
+		"":                    "",
+		"josé":                "j**é",
+	}
+
+	for input, expected := range cases {
+		if result := encoding.Mask(input); result != expected {
+			t.Errorf("mask %q: expected %q, got %q", input, expected, result)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	first := encoding.Hash("+52 555 555 5555")
+	if first != encoding.Hash("+52 555 555 5555") {
+		t.Errorf("hash is not stable")
+	}
+
+	if !strings.HasPrefix(first, "sha256:") || len(first) != len("sha256:")+16 {
+		t.Errorf("unexpected hash format: %s", first)
+	}
+}
+
+func TestToLogString(t *testing.T) {
+	t.Run("apply strategies", func(t *testing.T) {
+		input := &account{
+			Username: "jsmith",
+			Email:    "john.smith@test.com",
+			Phone:    "+52 555 555 5555",
+			Password: "secret",
+			Internal: "hidden",
+			Owner:    &models.UserDB{ID: "1", Name: "john", Email: "owner@test.com", CreatedAt: time.Now()},
+		}
+
+		result := encoding.ToLogString(input)
+		for _, leaked := range []string{"john.smith@test.com", "+52 555 555 5555", "secret", "hidden", "owner@test.com", `"john"`} {
+			if strings.Contains(result, leaked) {
+				t.Errorf("%q leaked in %s", leaked, result)
+			}
+		}
+
+		var decoded map[string]any
+		if err := json.Unmarshal([]byte(result), &decoded); err != nil {
+			t.Fatalf("result is not json: %v", err)
+		}
+
+		if decoded["username"] != "jsmith" || decoded["email"] != "j********h@test.com" {
+			t.Errorf("unexpected output: %s", result)
+		}
+
+		if _, ok := decoded["password"]; ok {
+			t.Errorf("dropped field present: %s", result)
+		}
+
+		owner := decoded["owner"].(map[string]any)
+		if owner["id"] != "1" || owner["email"] != "o***r@test.com" {
+			t.Errorf("nested struct not redacted: %s", result)
+		}
+	})
+
+	t.Run("slices of models", func(t *testing.T) {
+		users := []*models.UserDB{
+			{ID: "1", Name: "john", Lastname: "smith", Email: "john.smith@test.com"},
+			{ID: "2", Name: "jane", Lastname: "doe", Email: "jane.doe@test.com"},
+		}
+
+		result := encoding.ToLogString(users)
+		if strings.Contains(result, "jane.doe@test.com") || strings.Contains(result, "smith") {
+			t.Fatalf("email leaked: %s", result)
+		}
+
+		var decoded []map[string]any
+		if err := json.Unmarshal([]byte(result), &decoded); err != nil {
+			t.Fatalf("result is not json: %v", err)
+		}
+
+		if len(decoded) != 2 || decoded[1]["lastname"] != "d*e" || decoded[1]["age"] != float64(0) {
+			t.Errorf("unexpected output: %s", result)
+		}
+	})
+
+	t.Run("zero values are kept", func(t *testing.T) {
+		result := encoding.ToLogString(account{Username: "jsmith"})
+		if !strings.Contains(result, `"email":""`) {
+			t.Errorf("unexpected output: %s", result)
+		}
+	})
+}
@@ -103,11 +103,7 @@ func (db *dbSuiteImpl) CreateTable(input *dynamodb.CreateTableInput) error {
 		return err
 	}
 
-	_, err = conn.CreateTable(context.Background(), input)
-	if err != nil {
-		return err
-	}
-	return nil
+	return createTable(conn, input)
 }
 
 func (db *dbSuiteImpl) Shutdown() {
@@ -144,6 +140,32 @@ func (db *dbSuiteImpl) GetLocalClient() (*dynamodb.Client, error) {
 }
 
 func (db *dbSuiteImpl) PutItem(tableName string, item any) error {
+	conn, err := db.GetLocalClient()
+	if err != nil {
+		return err
+	}
+
+	return putItem(conn, tableName, item)
+}
+
+func (db *dbSuiteImpl) DeleteItem(tableName string, key string, value string) error {
+	conn, err := db.GetLocalClient()
+	if err != nil {
+		return err
+	}
+
+	return deleteItem(conn, tableName, key, value)
+}
+
+func createTable(conn *dynamodb.Client, input *dynamodb.CreateTableInput) error {
+	_, err := conn.CreateTable(context.Background(), input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func putItem(conn *dynamodb.Client, tableName string, item any) error {
 	av, err := attributevalue.MarshalMap(item)
 	if err != nil {
 		fmt.Printf("%v\n", err)
@@ -155,11 +177,6 @@ func (db *dbSuiteImpl) PutItem(tableName string, item any) error {
 		TableName: aws.String(tableName),
 	}
 
-	conn, err := db.GetLocalClient()
-	if err != nil {
-		return err
-	}
-
 	_, err = conn.PutItem(context.Background(), putInput)
 	if err != nil {
 		fmt.Printf("%v\n", err)
@@ -168,7 +185,7 @@ func (db *dbSuiteImpl) PutItem(tableName string, item any) error {
 	return nil
 }
 
-func (db *dbSuiteImpl) DeleteItem(tableName string, key string, value string) error {
+func deleteItem(conn *dynamodb.Client, tableName string, key string, value string) error {
 	input := &dynamodb.DeleteItemInput{
 		Key: map[string]types.AttributeValue{
 			key: &types.AttributeValueMemberS{
@@ -178,12 +195,7 @@ func (db *dbSuiteImpl) DeleteItem(tableName string, key string, value string) er
 		TableName: aws.String(tableName),
 	}
 
-	conn, err := db.GetLocalClient()
-	if err != nil {
-		return err
-	}
-
-	_, err = conn.DeleteItem(context.Background(), input)
+	_, err := conn.DeleteItem(context.Background(), input)
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return err
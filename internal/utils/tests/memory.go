@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbfake"
+	"os"
+)
+
+// BackendEnv selects the DynamoDB of the e2e suites, "docker" starts
+// amazon/dynamodb-local, anything else runs the in-memory fake.
+const BackendEnv = "E2E_DYNAMODB"
+
+type memorySuiteImpl struct {
+	fake dynamodbfake.Fake
+}
+
+// NewInMemory returns a DBSuite backed by dynamodbfake, it needs neither
+// Docker nor a free port.
+func NewInMemory() DBSuite {
+	return &memorySuiteImpl{fake: dynamodbfake.New()}
+}
+
+// NewFromEnv returns the Docker suite on exposedPort when BackendEnv is
+// "docker", the in-memory suite otherwise.
+func NewFromEnv(exposedPort string) DBSuite {
+	if os.Getenv(BackendEnv) == "docker" {
+		return New(exposedPort)
+	}
+	return NewInMemory()
+}
+
+func (db *memorySuiteImpl) StartDynamoDB() error {
+	return nil
+}
+
+func (db *memorySuiteImpl) CreateTable(input *dynamodb.CreateTableInput) error {
+	return createTable(db.fake.Client(), input)
+}
+
+func (db *memorySuiteImpl) Shutdown() {
+	db.fake.Reset()
+}
+
+func (db *memorySuiteImpl) GetLocalClient() (*dynamodb.Client, error) {
+	return db.fake.Client(), nil
+}
+
+func (db *memorySuiteImpl) PutItem(tableName string, item any) error {
+	return putItem(db.fake.Client(), tableName, item)
+}
+
+func (db *memorySuiteImpl) DeleteItem(tableName string, key string, value string) error {
+	return deleteItem(db.fake.Client(), tableName, key, value)
+}
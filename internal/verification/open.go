@@ -0,0 +1,28 @@
+package verification
+
+import (
+	"context"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/mailer"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+)
+
+// Open returns the verifier of cfg, its tokens are kept in the
+// VERIFICATION_TOKEN_TABLE of conn and mailed through MAILER_BACKEND.
+func Open(ctx context.Context, cfg config.Verification, conn DynamoDBAPI, log logging.Logger, m metrics.Metrics, opts clientopts.Options) (Verifier, error) {
+	mail, err := mailer.Open(ctx, cfg.Mailer, log)
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewDynamoDBStore(conn, cfg.TokenTable, log, m, opts)
+	v, err := New(store, mail, clock.New(), Options{TTL: cfg.TokenTTL, ResendInterval: cfg.ResendInterval, LinkURL: cfg.LinkURL})
+	if err != nil {
+		return nil, fmt.Errorf("VERIFICATION_TOKEN_TTL or VERIFICATION_LINK_URL: %w", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,327 @@
+package verification
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	operationGetToken     = "GetVerificationToken"
+	operationPutToken     = "PutVerificationToken"
+	operationConsumeToken = "ConsumeVerificationToken"
+	operationDeleteToken  = "DeleteVerificationToken"
+
+	attributeUserID    = "UserId"
+	attributeHash      = "Hash"
+	attributeIssuedAt  = "IssuedAt"
+	attributeExpiresAt = "ExpiresAt"
+)
+
+// Record is the live token of a user, a user has at most one.
+type Record struct {
+	UserID string `dynamodbav:"UserId"`
+	Hash   string `dynamodbav:"Hash"`
+	// IssuedAt is kept in unix seconds so that Put can compare it.
+	IssuedAt time.Time `dynamodbav:"IssuedAt,unixtime"`
+	// ExpiresAt is in unix seconds, the format of the TTL of DynamoDB.
+	ExpiresAt int64 `dynamodbav:"ExpiresAt"`
+}
+
+func (r Record) expired(now time.Time) bool {
+	return now.Unix() >= r.ExpiresAt
+}
+
+type Store interface {
+	// Put writes the record, replacing the token of the same user, unless
+	// that token was issued after cutoff, a TooSoonError then tells how
+	// long until it is not. The check and the write are one atomic step.
+	Put(ctx context.Context, r Record, cutoff time.Time) error
+	// Get returns the record of the user, ErrNotFound when there is none.
+	Get(ctx context.Context, userID string) (*Record, error)
+	// Consume deletes the record of the user when it has hash and has not
+	// expired at now, ErrInvalidToken otherwise. A token is consumed once
+	// even by concurrent calls.
+	Consume(ctx context.Context, userID, hash string, now time.Time) error
+	// Delete deletes the record of the user when it still has hash, a
+	// replaced or consumed record is left alone.
+	Delete(ctx context.Context, userID, hash string) error
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	items map[string]Record
+}
+
+// NewMemoryStore keeps the records in memory, for tests.
+func NewMemoryStore() Store {
+	return &memoryStore{items: map[string]Record{}}
+}
+
+func (m *memoryStore) Put(_ context.Context, r Record, cutoff time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if previous, ok := m.items[r.UserID]; ok && previous.IssuedAt.After(cutoff) {
+		return &TooSoonError{RetryAfter: previous.IssuedAt.Sub(cutoff)}
+	}
+	m.items[r.UserID] = r
+	return nil
+}
+
+func (m *memoryStore) Get(_ context.Context, userID string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.items[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &r, nil
+}
+
+func (m *memoryStore) Consume(_ context.Context, userID, hash string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.items[userID]
+	if !ok || subtle.ConstantTimeCompare([]byte(r.Hash), []byte(hash)) != 1 || r.expired(now) {
+		return ErrInvalidToken
+	}
+	delete(m.items, userID)
+	return nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, userID, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.items[userID]; ok && r.Hash == hash {
+		delete(m.items, userID)
+	}
+	return nil
+}
+
+// DynamoDBAPI is the part of the client used by NewDynamoDBStore.
+type DynamoDBAPI interface {
+	dynamodbapi.ItemGetter
+	dynamodbapi.ItemPutter
+	dynamodbapi.ItemDeleter
+}
+
+type dynamoStore struct {
+	conn      DynamoDBAPI
+	tableName string
+	log       logging.Logger
+	metrics   metrics.Metrics
+	opts      clientopts.Options
+}
+
+// NewDynamoDBStore keeps the records in the table created by CreateTable,
+// keyed by user id.
+func NewDynamoDBStore(conn DynamoDBAPI, tableName string, log logging.Logger, m metrics.Metrics, opts clientopts.Options) Store {
+	return &dynamoStore{conn: conn, tableName: tableName, log: log, metrics: m, opts: opts}
+}
+
+// call runs fn with the per-call context of operation and records its
+// latency.
+func (d *dynamoStore) call(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	callCtx, cancel, err := d.opts.Context(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	start := time.Now()
+	err = fn(callCtx)
+	d.metrics.RepositoryLatency(operation, start)
+	return d.opts.Err(operation, err)
+}
+
+// Put compares the issue times in seconds, the token of a user can be
+// replaced up to a second early.
+func (d *dynamoStore) Put(ctx context.Context, r Record, cutoff time.Time) error {
+	item, err := attributevalue.MarshalMap(r)
+	if err != nil {
+		return err
+	}
+
+	err = d.call(ctx, operationPutToken, func(ctx context.Context) error {
+		out, err := d.conn.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                aws.String(d.tableName),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#userID) OR #issuedAt <= :cutoff"),
+			ExpressionAttributeNames: map[string]string{"#userID": attributeUserID, "#issuedAt": attributeIssuedAt},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":cutoff": &types.AttributeValueMemberN{Value: strconv.FormatInt(cutoff.Unix(), 10)},
+			},
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			ReturnConsumedCapacity:              types.ReturnConsumedCapacityTotal,
+		}, tracing.DynamoDB, d.opts.DynamoDB)
+		if err == nil {
+			d.metrics.ConsumedCapacity(operationPutToken, out.ConsumedCapacity)
+		}
+		return err
+	})
+
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		var previous Record
+		if err = attributevalue.UnmarshalMap(ccf.Item, &previous); err != nil {
+			return err
+		}
+		return &TooSoonError{RetryAfter: previous.IssuedAt.Sub(cutoff)}
+	}
+	if err != nil {
+		d.log.WithContext(ctx).Errorf("error writing verification token of %s: %v", r.UserID, err)
+	}
+	return err
+}
+
+func (d *dynamoStore) Get(ctx context.Context, userID string) (*Record, error) {
+	var out *dynamodb.GetItemOutput
+	err := d.call(ctx, operationGetToken, func(ctx context.Context) (err error) {
+		out, err = d.conn.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:              aws.String(d.tableName),
+			Key:                    map[string]types.AttributeValue{attributeUserID: &types.AttributeValueMemberS{Value: userID}},
+			ConsistentRead:         aws.Bool(true),
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		}, tracing.DynamoDB, d.opts.DynamoDB)
+		return err
+	})
+	if err != nil {
+		d.log.WithContext(ctx).Errorf("error reading verification token of %s: %v", userID, err)
+		return nil, err
+	}
+	d.metrics.ConsumedCapacity(operationGetToken, out.ConsumedCapacity)
+	if len(out.Item) == 0 {
+		return nil, ErrNotFound
+	}
+
+	var r Record
+	if err = attributevalue.UnmarshalMap(out.Item, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Consume deletes the item on condition, the TTL removes the expired items
+// up to days later so the expiry is checked as well.
+func (d *dynamoStore) Consume(ctx context.Context, userID, hash string, now time.Time) error {
+	err := d.call(ctx, operationConsumeToken, func(ctx context.Context) error {
+		out, err := d.conn.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName:                aws.String(d.tableName),
+			Key:                      map[string]types.AttributeValue{attributeUserID: &types.AttributeValueMemberS{Value: userID}},
+			ConditionExpression:      aws.String("#hash = :hash AND #expiresAt > :now"),
+			ExpressionAttributeNames: map[string]string{"#hash": attributeHash, "#expiresAt": attributeExpiresAt},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":hash": &types.AttributeValueMemberS{Value: hash},
+				":now":  &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			},
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		}, tracing.DynamoDB, d.opts.DynamoDB)
+		if err == nil {
+			d.metrics.ConsumedCapacity(operationConsumeToken, out.ConsumedCapacity)
+		}
+		return err
+	})
+
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		d.log.WithContext(ctx).Errorf("error consuming verification token of %s: %v", userID, err)
+	}
+	return err
+}
+
+func (d *dynamoStore) Delete(ctx context.Context, userID, hash string) error {
+	err := d.call(ctx, operationDeleteToken, func(ctx context.Context) error {
+		out, err := d.conn.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName:                 aws.String(d.tableName),
+			Key:                       map[string]types.AttributeValue{attributeUserID: &types.AttributeValueMemberS{Value: userID}},
+			ConditionExpression:       aws.String("#hash = :hash"),
+			ExpressionAttributeNames:  map[string]string{"#hash": attributeHash},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":hash": &types.AttributeValueMemberS{Value: hash}},
+			ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+		}, tracing.DynamoDB, d.opts.DynamoDB)
+		if err == nil {
+			d.metrics.ConsumedCapacity(operationDeleteToken, out.ConsumedCapacity)
+		}
+		return err
+	})
+
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return nil
+	}
+	if err != nil {
+		d.log.WithContext(ctx).Errorf("error deleting verification token of %s: %v", userID, err)
+	}
+	return err
+}
+
+// TableAPI is the part of the client used by CreateTable.
+type TableAPI interface {
+	dynamodbapi.TableCreator
+	dynamodbapi.TableDescriber
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+}
+
+// CreateTable creates the table of NewDynamoDBStore when missing, waits
+// until it is active and enables its TTL on ExpiresAt.
+func CreateTable(ctx context.Context, conn TableAPI, tableName string) error {
+	_, err := conn.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String(attributeUserID), AttributeType: types.ScalarAttributeTypeS}},
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String(attributeUserID), KeyType: types.KeyTypeHash}},
+		BillingMode:          types.BillingModePayPerRequest,
+	}, tracing.DynamoDB)
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("error creating table %s: %w", tableName, err)
+	}
+
+	maxWait := clientopts.InitTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(conn, func(o *dynamodb.TableExistsWaiterOptions) {
+		o.MinDelay = 500 * time.Millisecond
+		o.MaxDelay = 2 * time.Second
+		o.ClientOptions = append(o.ClientOptions, tracing.DynamoDB)
+	})
+	if err = waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, maxWait); err != nil {
+		return fmt.Errorf("error waiting for table %s: %w", tableName, err)
+	}
+
+	// enabling an enabled TTL is refused, so it is checked first
+	ttl, err := conn.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)}, tracing.DynamoDB)
+	if err != nil {
+		return fmt.Errorf("error describing ttl of %s: %w", tableName, err)
+	}
+	if status := ttl.TimeToLiveDescription; status != nil &&
+		(status.TimeToLiveStatus == types.TimeToLiveStatusEnabled || status.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		return nil
+	}
+	_, err = conn.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName:               aws.String(tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{AttributeName: aws.String(attributeExpiresAt), Enabled: aws.Bool(true)},
+	}, tracing.DynamoDB)
+	if err != nil {
+		return fmt.Errorf("error enabling ttl of %s: %w", tableName, err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package verification
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// secretSize is the entropy of a token, in bytes.
+const secretSize = 32
+
+// NewToken returns a token of the user with the id and its hash, which is
+// the only part stored. The token is <user id>.<secret>, so it is looked up
+// without index.
+func NewToken(userID string) (token, hash string, err error) {
+	secret := make([]byte, secretSize)
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token = userID + "." + base64.RawURLEncoding.EncodeToString(secret)
+	return token, Hash(token), nil
+}
+
+// ParseToken returns the user id and the hash of token, ErrInvalidToken
+// when it is malformed.
+func ParseToken(token string) (userID, hash string, err error) {
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 {
+		return "", "", ErrInvalidToken
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || len(secret) != secretSize {
+		return "", "", ErrInvalidToken
+	}
+	return token[:i], Hash(token), nil
+}
+
+// Hash is the SHA-256 of token in hex. The secret is random, a salt would
+// add nothing.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,149 @@
+// Package verification proves that the users own their email, with a
+// single use token mailed to them when they sign up.
+package verification
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/mailer"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("verification token not found")
+	// ErrInvalidToken is returned for a token that is malformed, unknown,
+	// already used or expired, the caller is not told which.
+	ErrInvalidToken = errors.New("verification token is not valid")
+	// ErrTooSoon is matched by the TooSoonError of Issue.
+	ErrTooSoon = errors.New("verification email sent too recently")
+)
+
+// TooSoonError is returned by Issue when the last token of the user is
+// younger than the resend interval, and by Store.Put.
+type TooSoonError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooSoonError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrTooSoon, e.RetryAfter)
+}
+
+func (e *TooSoonError) Is(target error) bool {
+	return target == ErrTooSoon
+}
+
+// Options configure New.
+type Options struct {
+	// TTL is the lifetime of a token.
+	TTL time.Duration
+	// ResendInterval is the least time between two tokens of a user.
+	ResendInterval time.Duration
+	// LinkURL is the page the emails link to, with the token in the token
+	// query parameter.
+	LinkURL string
+}
+
+type Verifier interface {
+	// Issue replaces the token of user and mails the link to its email,
+	// the previous token stops working.
+	Issue(ctx context.Context, user domain.User) error
+	// Check returns the id of the user of token without using it up,
+	// ErrInvalidToken when Consume would refuse it.
+	Check(ctx context.Context, token string) (string, error)
+	// Consume uses token up and returns the id of its user.
+	Consume(ctx context.Context, token string) (string, error)
+}
+
+type verifierImpl struct {
+	store  Store
+	mailer mailer.Mailer
+	clock  clock.Clock
+	opts   Options
+}
+
+func New(store Store, m mailer.Mailer, clk clock.Clock, opts Options) (Verifier, error) {
+	if opts.TTL <= 0 {
+		return nil, errors.New("the token ttl must be positive")
+	}
+	link, err := url.Parse(opts.LinkURL)
+	if err != nil || !link.IsAbs() {
+		return nil, fmt.Errorf("invalid link url %q", opts.LinkURL)
+	}
+	return &verifierImpl{store: store, mailer: m, clock: clk, opts: opts}, nil
+}
+
+func (v *verifierImpl) Issue(ctx context.Context, user domain.User) error {
+	now := v.clock.Now()
+	token, hash, err := NewToken(user.ID)
+	if err != nil {
+		return err
+	}
+	expires := now.Add(v.opts.TTL)
+	// the store refuses the token while the last one is younger than the
+	// resend interval, concurrent resends included
+	err = v.store.Put(ctx, Record{UserID: user.ID, Hash: hash, IssuedAt: now, ExpiresAt: expires.Unix()}, now.Add(-v.opts.ResendInterval))
+	if err != nil {
+		return err
+	}
+
+	err = v.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Text:    v.text(user, token, expires),
+	})
+	if err != nil {
+		// the token that was never mailed is dropped, so the user can ask
+		// for another right away
+		if deleteErr := v.store.Delete(ctx, user.ID, hash); deleteErr != nil {
+			return errors.Join(err, deleteErr)
+		}
+	}
+	return err
+}
+
+func (v *verifierImpl) text(user domain.User, token string, expires time.Time) string {
+	link, _ := url.Parse(v.opts.LinkURL)
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return fmt.Sprintf("Hello %s,\n\n"+
+		"Confirm your email address by opening the link below before %s:\n\n"+
+		"%s\n\n"+
+		"If you did not create an account, ignore this email.\n",
+		user.Name, expires.UTC().Format("2006-01-02 15:04 MST"), link)
+}
+
+func (v *verifierImpl) Check(ctx context.Context, token string) (string, error) {
+	userID, hash, err := ParseToken(token)
+	if err != nil {
+		return "", err
+	}
+	r, err := v.store.Get(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", err
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Hash), []byte(hash)) != 1 || r.expired(v.clock.Now()) {
+		return "", ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func (v *verifierImpl) Consume(ctx context.Context, token string) (string, error) {
+	userID, hash, err := ParseToken(token)
+	if err != nil {
+		return "", err
+	}
+	if err = v.store.Consume(ctx, userID, hash, v.clock.Now()); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
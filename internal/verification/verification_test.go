@@ -0,0 +1,388 @@
+package verification_test
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbfake"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/mailer"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/smtpfake"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"io"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	john  = domain.User{ID: "usr_01HXAMPLE", Name: "John", Email: "john@example.com", Status: domain.StatusPendingVerification}
+)
+
+func newLog() logging.Logger {
+	return logging.New(logging.Opts{AppName: "verification-test", Level: "error"})
+}
+
+func TestToken(t *testing.T) {
+	token, hash, err := verification.NewToken(john.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, john.ID+".") || strings.Contains(hash, token) {
+		t.Errorf("unexpected token %q with hash %q", token, hash)
+	}
+
+	userID, parsed, err := verification.ParseToken(token)
+	if err != nil || userID != john.ID || parsed != hash {
+		t.Errorf("unexpected parse %q, %q, %v", userID, parsed, err)
+	}
+
+	other, _, _ := verification.NewToken(john.ID)
+	if other == token {
+		t.Error("expected distinct tokens")
+	}
+
+	for _, malformed := range []string{"", "abc", ".abc", john.ID + ".", john.ID + ".short", john.ID + ".!" + token[len(john.ID)+2:]} {
+		if _, _, err = verification.ParseToken(malformed); !errors.Is(err, verification.ErrInvalidToken) {
+			t.Errorf("expected ErrInvalidToken for %q, got %v", malformed, err)
+		}
+	}
+}
+
+func testStore(t *testing.T, store verification.Store) {
+	ctx := context.Background()
+	if _, err := store.Get(ctx, john.ID); !errors.Is(err, verification.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	record := verification.Record{UserID: john.ID, Hash: "old", IssuedAt: start, ExpiresAt: start.Add(time.Hour).Unix()}
+	if err := store.Put(ctx, record, start); err != nil {
+		t.Fatal(err)
+	}
+	record.Hash = "new"
+	if err := store.Put(ctx, record, start); err != nil {
+		t.Fatal(err)
+	}
+	early := record
+	early.Hash = "early"
+	err := store.Put(ctx, early, start.Add(-time.Minute))
+	var tooSoon *verification.TooSoonError
+	if !errors.As(err, &tooSoon) || tooSoon.RetryAfter != time.Minute {
+		t.Fatalf("expected ErrTooSoon in 1m, got %v", err)
+	}
+	actual, err := store.Get(ctx, john.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.Hash != "new" || !actual.IssuedAt.Equal(start) || actual.ExpiresAt != record.ExpiresAt {
+		t.Errorf("unexpected record %+v", actual)
+	}
+
+	if err = store.Delete(ctx, john.ID, "old"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Get(ctx, john.ID); err != nil {
+		t.Fatalf("expected the replaced hash to leave the record, got %v", err)
+	}
+
+	cases := []struct {
+		name string
+		hash string
+		now  time.Time
+	}{
+		{"replaced", "old", start},
+		{"expired", "new", start.Add(time.Hour)},
+		{"other", "other", start},
+	}
+	for _, c := range cases {
+		if err = store.Consume(ctx, john.ID, c.hash, c.now); !errors.Is(err, verification.ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", c.name, err)
+		}
+	}
+	if err = store.Consume(ctx, "usr_other", "new", start); !errors.Is(err, verification.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for unknown user, got %v", err)
+	}
+
+	if err = store.Consume(ctx, john.ID, "new", start.Add(time.Hour-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Consume(ctx, john.ID, "new", start); !errors.Is(err, verification.ErrInvalidToken) {
+		t.Errorf("expected a single use, got %v", err)
+	}
+	if _, err = store.Get(ctx, john.ID); !errors.Is(err, verification.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after consume, got %v", err)
+	}
+
+	if err = store.Put(ctx, record, start); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Delete(ctx, john.ID, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Get(ctx, john.ID); !errors.Is(err, verification.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err = store.Delete(ctx, john.ID, "new"); err != nil {
+		t.Errorf("expected a missing record to be ignored, got %v", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testStore(t, verification.NewMemoryStore())
+	})
+
+	t.Run("dynamodb", func(t *testing.T) {
+		client := dynamodbfake.New().Client()
+		for i := 0; i < 2; i++ {
+			if err := verification.CreateTable(context.Background(), client, "verification-tokens"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		ttl, err := client.DescribeTimeToLive(context.Background(), &dynamodb.DescribeTimeToLiveInput{TableName: aws.String("verification-tokens")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := ttl.TimeToLiveDescription; d.TimeToLiveStatus != types.TimeToLiveStatusEnabled || aws.ToString(d.AttributeName) != "ExpiresAt" {
+			t.Errorf("unexpected ttl %+v", d)
+		}
+
+		testStore(t, verification.NewDynamoDBStore(client, "verification-tokens", newLog(), metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy())))
+	})
+}
+
+// link returns the token linked to by the email.
+func link(t *testing.T, message smtpfake.Message) string {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(message.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := regexp.MustCompile(`https://app\.example\.com/verify\?\S+`).FindString(string(body))
+	u, err := url.Parse(match)
+	if err != nil || match == "" {
+		t.Fatalf("no link in %q", body)
+	}
+	if u.Query().Get("ref") != "email" {
+		t.Errorf("expected the query of the link url to be kept, got %s", u)
+	}
+	return u.Query().Get("token")
+}
+
+func TestVerifier(t *testing.T) {
+	ctx := context.Background()
+	server, err := smtpfake.Start(smtpfake.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	m, err := mailer.NewSMTP(mailer.SMTPOptions{Addr: server.Addr(), From: "no-reply@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := clock.NewFake(start)
+	store := verification.NewMemoryStore()
+	v, err := verification.New(store, m, c, verification.Options{TTL: time.Hour, ResendInterval: time.Minute, LinkURL: "https://app.example.com/verify?ref=email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.Issue(ctx, john); err != nil {
+		t.Fatal(err)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 || len(messages[0].To) != 1 || messages[0].To[0] != john.Email {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+	first := link(t, messages[0])
+
+	c.Advance(30 * time.Second)
+	err = v.Issue(ctx, john)
+	var tooSoon *verification.TooSoonError
+	if !errors.Is(err, verification.ErrTooSoon) || !errors.As(err, &tooSoon) || tooSoon.RetryAfter != 30*time.Second {
+		t.Fatalf("expected ErrTooSoon in 30s, got %v", err)
+	}
+
+	c.Advance(30 * time.Second)
+	if err = v.Issue(ctx, john); err != nil {
+		t.Fatal(err)
+	}
+	messages = server.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("expected a second email, got %d", len(messages))
+	}
+	second := link(t, messages[1])
+
+	if _, err = v.Check(ctx, first); !errors.Is(err, verification.ErrInvalidToken) {
+		t.Errorf("expected the resent token to replace the first, got %v", err)
+	}
+	if _, err = v.Consume(ctx, first); !errors.Is(err, verification.ErrInvalidToken) {
+		t.Errorf("expected the resent token to replace the first, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if userID, err := v.Check(ctx, second); err != nil || userID != john.ID {
+			t.Fatalf("expected the check to leave the token, got %q, %v", userID, err)
+		}
+	}
+	userID, err := v.Consume(ctx, second)
+	if err != nil || userID != john.ID {
+		t.Fatalf("unexpected consume %q, %v", userID, err)
+	}
+	if _, err = v.Consume(ctx, second); !errors.Is(err, verification.ErrInvalidToken) {
+		t.Errorf("expected a single use, got %v", err)
+	}
+	if _, err = v.Check(ctx, second); !errors.Is(err, verification.ErrInvalidToken) {
+		t.Errorf("expected a used token, got %v", err)
+	}
+
+	if err = v.Issue(ctx, john); err != nil {
+		t.Fatal(err)
+	}
+	third := link(t, server.Messages()[2])
+	c.Advance(time.Hour)
+	if _, err = v.Check(ctx, third); !errors.Is(err, verification.ErrInvalidToken) {
+		t.Errorf("expected an expired token, got %v", err)
+	}
+	if _, err = v.Consume(ctx, third); !errors.Is(err, verification.ErrInvalidToken) {
+		t.Errorf("expected an expired token, got %v", err)
+	}
+}
+
+// failingMailer fails while down is set.
+type failingMailer struct {
+	down bool
+	sent int
+}
+
+func (f *failingMailer) Send(context.Context, mailer.Message) error {
+	if f.down {
+		return errors.New("smtp server unavailable")
+	}
+	f.sent++
+	return nil
+}
+
+func TestVerifierSendFailure(t *testing.T) {
+	ctx := context.Background()
+	m := &failingMailer{down: true}
+	store := verification.NewMemoryStore()
+	v, err := verification.New(store, m, clock.NewFake(start), verification.Options{TTL: time.Hour, ResendInterval: time.Minute, LinkURL: "https://app.example.com/verify"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.Issue(ctx, john); err == nil || errors.Is(err, verification.ErrTooSoon) {
+		t.Fatalf("expected the send error, got %v", err)
+	}
+	if _, err = store.Get(ctx, john.ID); !errors.Is(err, verification.ErrNotFound) {
+		t.Errorf("expected the token not mailed to be dropped, got %v", err)
+	}
+
+	m.down = false
+	if err = v.Issue(ctx, john); err != nil || m.sent != 1 {
+		t.Errorf("expected a resend right away, got %v with %d sent", err, m.sent)
+	}
+}
+
+func TestVerifierConcurrent(t *testing.T) {
+	ctx := context.Background()
+	client := dynamodbfake.New().Client()
+	if err := verification.CreateTable(ctx, client, "verification-tokens"); err != nil {
+		t.Fatal(err)
+	}
+	store := verification.NewDynamoDBStore(client, "verification-tokens", newLog(), metrics.NewNoop(), clientopts.New(clientopts.DefaultPolicy()))
+	v, err := verification.New(store, mailer.NewNoop(), clock.NewFake(start), verification.Options{TTL: time.Hour, ResendInterval: time.Minute, LinkURL: "https://app.example.com/verify"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- v.Issue(ctx, john)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	issued := 0
+	for err = range errs {
+		switch {
+		case err == nil:
+			issued++
+		case !errors.Is(err, verification.ErrTooSoon):
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if issued != 1 {
+		t.Errorf("expected a single token, got %d", issued)
+	}
+}
+
+func TestNew(t *testing.T) {
+	store := verification.NewMemoryStore()
+	cases := []verification.Options{
+		{TTL: 0, LinkURL: "https://app.example.com/verify"},
+		{TTL: time.Hour, LinkURL: "/verify"},
+		{TTL: time.Hour, LinkURL: "://"},
+	}
+	for _, opts := range cases {
+		if _, err := verification.New(store, mailer.NewNoop(), clock.New(), opts); err == nil {
+			t.Errorf("expected an error for %+v", opts)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	client := dynamodbfake.New().Client()
+	if err := verification.CreateTable(ctx, client, "verification-tokens"); err != nil {
+		t.Fatal(err)
+	}
+	opts := clientopts.New(clientopts.DefaultPolicy())
+	cfg := config.Verification{
+		TokenTable:     "verification-tokens",
+		TokenTTL:       time.Hour,
+		ResendInterval: time.Minute,
+		LinkURL:        "https://app.example.com/verify",
+		Mailer:         config.Mailer{Backend: config.MailerOff},
+	}
+
+	v, err := verification.Open(ctx, cfg, client, newLog(), metrics.NewNoop(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Issue(ctx, john); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Issue(ctx, john); !errors.Is(err, verification.ErrTooSoon) {
+		t.Errorf("expected ErrTooSoon from the table, got %v", err)
+	}
+
+	cfg.LinkURL = ""
+	if _, err = verification.Open(ctx, cfg, client, newLog(), metrics.NewNoop(), opts); err == nil {
+		t.Error("expected an error for a missing link url")
+	}
+}
@@ -0,0 +1,90 @@
+// Package wiring builds the dependencies shared by the lambdas from their
+// configuration.
+package wiring
+
+import (
+	"context"
+	"fmt"
+	awsdynamodb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/ricardojonathanromero/go-utilities/db/dynamodb"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	dbInfra "github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ratelimit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"sync"
+)
+
+// Conn is the DynamoDB connection of a lambda, opened by the first call of
+// Client and shared by every store, so a lambda opens one at most and none
+// when its stores are elsewhere.
+type Conn struct {
+	db     dynamodb.DB
+	once   sync.Once
+	client *awsdynamodb.Client
+	err    error
+}
+
+func NewConn() *Conn {
+	return &Conn{db: dynamodb.New()}
+}
+
+// Client connects on the first call and returns the same client after.
+func (c *Conn) Client() (*awsdynamodb.Client, error) {
+	c.once.Do(func() {
+		c.client, c.err = c.db.Connect()
+	})
+	return c.client, c.err
+}
+
+// Close disconnects when Client connected.
+func (c *Conn) Close() error {
+	if c.client == nil {
+		return nil
+	}
+	return c.db.Disconnect()
+}
+
+// provisions tells whether the tables of the lambdas are created with the
+// users table, the other modes rely on the bootstrap command.
+func provisions(cfg config.Common) bool {
+	return cfg.DynamoDB.Provisioning == dbInfra.ModeCreate || cfg.DynamoDB.Provisioning == dbInfra.ModeReconcile
+}
+
+// NewRateLimiter returns the middleware of RATE_LIMIT_BACKEND, the dynamodb
+// backend keeps its buckets on conn.
+func NewRateLimiter(ctx context.Context, cfg config.Common, rl config.RateLimit, conn *Conn, log logging.Logger, m metrics.Metrics, opts clientopts.Options) (ratelimit.Middleware, error) {
+	if rl.Backend != config.RateLimitDynamoDB {
+		return ratelimit.Open(rl, nil, log, m, opts)
+	}
+
+	client, err := conn.Client()
+	if err != nil {
+		return nil, fmt.Errorf("error initializing db connection: %w", err)
+	}
+
+	if provisions(cfg) {
+		if err = ratelimit.CreateTable(ctx, client, rl.Table); err != nil {
+			return nil, fmt.Errorf("error provisioning rate limit table: %w", err)
+		}
+	}
+	return ratelimit.Open(rl, client, log, m, opts)
+}
+
+// NewVerifier returns the verifier of v, its tokens are kept on conn
+// whatever the backend of the users, so it always connects.
+func NewVerifier(ctx context.Context, cfg config.Common, v config.Verification, conn *Conn, log logging.Logger, m metrics.Metrics, opts clientopts.Options) (verification.Verifier, error) {
+	client, err := conn.Client()
+	if err != nil {
+		return nil, fmt.Errorf("error initializing db connection: %w", err)
+	}
+
+	if provisions(cfg) {
+		if err = verification.CreateTable(ctx, client, v.TokenTable); err != nil {
+			return nil, fmt.Errorf("error provisioning verification token table: %w", err)
+		}
+	}
+	return verification.Open(ctx, v, client, log, m, opts)
+}
@@ -0,0 +1,52 @@
+package wiring_test
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/wiring"
+	"net/http"
+	"testing"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	log := logging.New(logging.Opts{AppName: "wiring-test", Level: "error"})
+	opts := clientopts.New(clientopts.DefaultPolicy())
+	ok := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Resource:   "/gdpr/erase",
+		Path:       "/gdpr/erase",
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Identity: events.APIGatewayRequestIdentity{SourceIP: "10.0.0.1"},
+		},
+	}
+
+	// the memory backend never connects to DynamoDB
+	conn := wiring.NewConn()
+	cfg := config.RateLimit{Backend: config.RateLimitMemory, Default: "1/1m:1"}
+	limiter, err := wiring.NewRateLimiter(ctx, config.Common{}, cfg, conn, log, metrics.NewNoop(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := limiter.Wrap(ok)
+	for _, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if res, err := next(ctx, req); err != nil || res.StatusCode != expected {
+			t.Errorf("expected %d, got %+v, %v", expected, res, err)
+		}
+	}
+	if err = conn.Close(); err != nil {
+		t.Errorf("unexpected error closing an unused connection: %v", err)
+	}
+
+	cfg.Default = "1"
+	if _, err = wiring.NewRateLimiter(ctx, config.Common{}, cfg, conn, log, metrics.NewNoop(), opts); err == nil {
+		t.Error("expected an error for an invalid limit")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	lambdaEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/cache"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/config"
+	dbInfra "github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/wiring"
+	"github.com/ricardojonathanromero/lambda-golang-example/user-status-lambda/internal/handler"
+	"github.com/ricardojonathanromero/lambda-golang-example/user-status-lambda/pkg/service"
+	"os"
+)
+
+const appName = "user-status-lambda"
+
+func main() {
+	// configuration is loaded once, every problem is reported at once and
+	// ssm:// or secretsmanager:// references are resolved at cold start
+	var cfg config.UserStatus
+	configCtx, cancelConfig := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	secrets, err := config.NewProvider(configCtx)
+	if err == nil {
+		err = config.Load(&cfg, config.WithContext(configCtx), config.WithProvider(secrets))
+	}
+	cancelConfig()
+	if err != nil {
+		logging.New(logging.Opts{AppName: appName}).Fatal(err.Error())
+	}
+
+	customLog := logging.New(logging.Opts{
+		AppName: appName,
+		Level:   cfg.LogLevel,
+	})
+
+	// metrics are written to stdout in embedded metric format
+	customMetrics := metrics.New(appName, metrics.NewEMFSink(os.Stdout, cfg.MetricsNamespace))
+
+	// spans are exported to the OTLP endpoint when OTEL_TRACES_EXPORTER=otlp
+	tracerProvider, err := tracing.Setup(context.Background(), tracing.Opts{
+		ServiceName: appName,
+		Exporter:    cfg.TracesExporter,
+	})
+	if err != nil {
+		customLog.Fatalf("error configuring tracing: %v", err)
+	}
+
+	defer func() {
+		if err = tracerProvider.Shutdown(context.Background()); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	// per-call timeouts are derived from the invocation deadline
+	clientOpts := clientopts.New(cfg.DynamoDB.Policy())
+
+	// the stores share one DynamoDB connection, opened on first use
+	conn := wiring.NewConn()
+	defer func() {
+		if err = conn.Close(); err != nil {
+			customLog.Error(err.Error())
+		}
+	}()
+
+	// init dependency injection, every transition is written to the audit
+	// trail with the status when AUDIT_ENABLED
+	store, closeStore := newStore(cfg.Common, conn, customLog, customMetrics, clientOpts)
+	defer closeStore()
+
+	// the changed users are dropped from the cache of get-document when it
+	// is shared
+	if cfg.Cache.Backend == config.CacheRedis {
+		cacheCtx, cancelCache := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+		userCache, cacheCloser, err := cache.Open(cacheCtx, cfg.Cache)
+		cancelCache()
+		if err != nil {
+			customLog.Fatalf("error opening cache: %v", err)
+		}
+
+		defer func() {
+			if err = cacheCloser.Close(); err != nil {
+				customLog.Error(err.Error())
+			}
+		}()
+
+		store = userstore.NewInvalidating(store, userCache, cfg.Cache, customLog, customMetrics)
+	}
+
+	// transitions are published to EVENTS_BACKEND once stored
+	eventsCtx, cancelEvents := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	publisher, err := events.Open(eventsCtx, cfg.Events, customLog)
+	cancelEvents()
+	if err != nil {
+		customLog.Fatalf("error configuring events: %v", err)
+	}
+
+	// pending users are mailed new verification tokens on request
+	verifierCtx, cancelVerifier := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	verifier, err := wiring.NewVerifier(verifierCtx, cfg.Common, cfg.Verification, conn, customLog, customMetrics, clientOpts)
+	cancelVerifier()
+	if err != nil {
+		customLog.Fatalf("error configuring verification: %v", err)
+	}
+
+	srv := service.New(store, publisher, verifier, customLog, clock.New())
+
+	// callers need the write scope, the handler restricts the suspensions
+	// to the admins and the closures and resends to the owner. The
+	// verification is public, its token is the credential
+	authMiddleware, err := auth.Open(cfg.Auth, customLog, customMetrics)
+	if err != nil {
+		customLog.Fatalf("error configuring authentication: %v", err)
+	}
+
+	// callers are throttled per route after authentication, so they are
+	// keyed by subject
+	limiterCtx, cancelLimiter := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	limiter, err := wiring.NewRateLimiter(limiterCtx, cfg.Common, cfg.RateLimit, conn, customLog, customMetrics, clientOpts)
+	cancelLimiter()
+	if err != nil {
+		customLog.Fatalf("error configuring rate limiting: %v", err)
+	}
+
+	h := handler.New(srv, customLog, customMetrics, cfg.Timezone)
+	authenticated := authMiddleware.Require(auth.ScopeWrite, limiter.Wrap(h.HandleRequest))
+	public := limiter.Wrap(h.HandleRequest)
+	lambda.Start(tracing.WithFlush(tracerProvider, func(ctx context.Context, req lambdaEvents.APIGatewayProxyRequest) (lambdaEvents.APIGatewayProxyResponse, error) {
+		if req.Resource == handler.ResourceVerify {
+			return public(ctx, req)
+		}
+		return authenticated(ctx, req)
+	}))
+}
+
+// newStore connects to the backend selected by USER_STORE, the DynamoDB
+// table through dbConn. The returned func releases the other backends.
+func newStore(cfg config.Common, dbConn *wiring.Conn, log logging.Logger, m metrics.Metrics, opts clientopts.Options) (userstore.UserRepository, func()) {
+	initCtx, cancelInit := context.WithTimeout(context.Background(), clientopts.InitTimeout)
+	defer cancelInit()
+
+	// PII attributes are encrypted at rest unless FIELD_ENCRYPTION=off
+	enc, err := fieldcrypt.Open(initCtx, cfg.Encryption)
+	if err != nil {
+		log.Fatalf("error configuring field encryption: %v", err)
+	}
+
+	if cfg.Storage.Backend == config.BackendSQL {
+		store, sqlDB, err := userstore.OpenSQL(initCtx, cfg.Storage.SQL, log, m, opts, enc, cfg.Audit.TableName(), clock.New())
+		if err != nil {
+			log.Fatalf("error opening sql store: %v", err)
+		}
+		return store, func() {
+			if err = sqlDB.Close(); err != nil {
+				log.Error(err.Error())
+			}
+		}
+	}
+
+	// connect to db
+	conn, err := dbConn.Client()
+	if err != nil {
+		log.Fatalf("error initializing db connection: %s", err.Error())
+	}
+
+	// provision table, production runs with verify or off and relies on the
+	// bootstrap command of the internal module to create it
+	tableName := cfg.DynamoDB.TableName
+	verifyCache := dbInfra.NewFileCache(os.TempDir(), dbInfra.DefaultVerifyTTL)
+	if err = dbInfra.New(conn, log, opts, verifyCache).Provision(initCtx, tableName, cfg.DynamoDB.Provisioning); err != nil {
+		log.Fatalf("error provisioning table: %v", err)
+	}
+
+	// the audit table follows the provisioning of the users table, the user
+	// store writes the status and its audit entry in one transaction
+	if cfg.Audit.Enabled && (cfg.DynamoDB.Provisioning == dbInfra.ModeCreate || cfg.DynamoDB.Provisioning == dbInfra.ModeReconcile) {
+		if err = audit.CreateTable(initCtx, conn, cfg.Audit.Table); err != nil {
+			log.Fatalf("error provisioning audit table: %v", err)
+		}
+	}
+
+	return userstore.NewDynamoDB(conn, tableName, log, m, opts, enc, cfg.Audit.TableName(), clock.New()), func() {}
+}
@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/conditional"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dto"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ids"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/ratelimit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/timezone"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/tracing"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/encoding"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"github.com/ricardojonathanromero/lambda-golang-example/user-status-lambda/pkg/service"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// ResourceSuspend blocks an active user, admins only.
+	ResourceSuspend = "/users/{id}/suspend"
+	// ResourceReactivate unblocks a suspended user, admins only.
+	ResourceReactivate = "/users/{id}/reactivate"
+	// ResourceClose closes the account for good, the user or an admin.
+	ResourceClose = "/users/{id}/close"
+	// ResourceVerify activates the user of the token in the body, it is
+	// public as the token is the credential.
+	ResourceVerify = "/users/verify"
+	// ResourceResend mails a new verification token, the user or an admin.
+	ResourceResend = "/users/{id}/verify/resend"
+
+	operationTransition = "HandleTransition"
+	operationVerify     = "HandleVerify"
+	operationResend     = "HandleResend"
+)
+
+var actions = map[string]service.Action{
+	ResourceSuspend:    service.ActionSuspend,
+	ResourceReactivate: service.ActionReactivate,
+	ResourceClose:      service.ActionClose,
+}
+
+type Handler interface {
+	// HandleRequest applies the transition of the resource of the proxy
+	// integration event, the verification resources included.
+	HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}
+
+type handleImpl struct {
+	srv      service.Service
+	log      logging.Logger
+	metrics  metrics.Metrics
+	timezone *time.Location
+}
+
+// New answers with the times in UTC, loc is the zone of the representations
+// read by get-document when the request asks for none, their ETags are
+// compared with If-Match in it.
+func New(srv service.Service, log logging.Logger, m metrics.Metrics, loc *time.Location) Handler {
+	return &handleImpl{
+		srv:      srv,
+		log:      log,
+		metrics:  m,
+		timezone: loc,
+	}
+}
+
+// transitionRequest is the body of every transition.
+type transitionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// verifyRequest is the body of a verification.
+type verifyRequest struct {
+	Token string `json:"token"`
+}
+
+func (h *handleImpl) HandleRequest(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch req.Resource {
+	case ResourceVerify:
+		return h.handleVerify(ctx, req)
+	case ResourceResend:
+		return h.handleResend(ctx, req)
+	default:
+		return h.handleTransition(ctx, req)
+	}
+}
+
+func (h *handleImpl) handleTransition(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx = logging.WithAPIGatewayRequest(ctx, req)
+	ctx, span := tracing.StartServer(tracing.WithAPIGatewayRequest(ctx, req), operationTransition)
+	defer span.End()
+
+	defer h.metrics.HandlerLatency(operationTransition, time.Now())
+	h.metrics.ColdStart(operationTransition)
+
+	log := h.log.WithContext(ctx)
+	action, ok := actions[req.Resource]
+	if !ok {
+		return h.problem(req, http.StatusNotFound, "unknown resource "+req.Resource), nil
+	}
+
+	id := req.PathParameters["id"]
+	if err := ids.Validate(id); err != nil {
+		h.metrics.Increment(operationTransition, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "id is not valid"), nil
+	}
+
+	// suspensions are decided by the admins, an account is closed by its
+	// owner as well, which the service checks against the stored user
+	if identity, ok := auth.FromContext(ctx); ok && action != service.ActionClose && !identity.Admin {
+		log.Warnf("subject %s denied %s of user %s", identity.Subject, action, id)
+		h.metrics.Increment(operationTransition, metrics.MetricForbidden)
+		return auth.Forbidden(req, "callers cannot "+string(action)+" this user", nil), nil
+	}
+
+	var body transitionRequest
+	if err := decodeRequest(req, &body); err != nil {
+		h.metrics.Increment(operationTransition, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "body is not a valid transition request"), nil
+	}
+
+	// If-Match holds the ETag of the user read from get-document, it is
+	// computed again over the same representation, in the same timezone
+	loc, err := timezone.FromRequest(req.Headers, req.QueryStringParameters, h.timezone)
+	if err != nil {
+		h.metrics.Increment(operationTransition, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "timezone is not valid"), nil
+	}
+	precondition := func(user domain.User) bool {
+		etag := conditional.ETag([]byte(encoding.ToString(dto.NewUserResponse(user, loc))))
+		return !conditional.PreconditionFailed(req.Headers, etag, user.UpdatedAt, true)
+	}
+
+	user, err := h.srv.Transition(ctx, id, action, body.Reason, precondition)
+	if err != nil {
+		log.Errorf("error applying %s to user %s: %v", action, id, err)
+		tracing.Error(span, err)
+		return h.errorResponse(req, operationTransition, err), nil
+	}
+
+	log.Infof("user %s is %s", id, user.Status)
+	return h.userResponse(req, operationTransition, *user), nil
+}
+
+func (h *handleImpl) handleVerify(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx = logging.WithAPIGatewayRequest(ctx, req)
+	ctx, span := tracing.StartServer(tracing.WithAPIGatewayRequest(ctx, req), operationVerify)
+	defer span.End()
+
+	defer h.metrics.HandlerLatency(operationVerify, time.Now())
+	h.metrics.ColdStart(operationVerify)
+
+	log := h.log.WithContext(ctx)
+	var body verifyRequest
+	if err := decodeRequest(req, &body); err != nil || body.Token == "" {
+		h.metrics.Increment(operationVerify, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "body is not a valid verification request"), nil
+	}
+
+	// the token is a credential, it is never logged
+	user, err := h.srv.Verify(ctx, body.Token)
+	if err != nil {
+		log.Errorf("error verifying user: %v", err)
+		tracing.Error(span, err)
+		return h.errorResponse(req, operationVerify, err), nil
+	}
+
+	log.Infof("user %s is verified", user.ID)
+	return h.userResponse(req, operationVerify, *user), nil
+}
+
+func (h *handleImpl) handleResend(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx = logging.WithAPIGatewayRequest(ctx, req)
+	ctx, span := tracing.StartServer(tracing.WithAPIGatewayRequest(ctx, req), operationResend)
+	defer span.End()
+
+	defer h.metrics.HandlerLatency(operationResend, time.Now())
+	h.metrics.ColdStart(operationResend)
+
+	log := h.log.WithContext(ctx)
+	id := req.PathParameters["id"]
+	if err := ids.Validate(id); err != nil {
+		h.metrics.Increment(operationResend, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "id is not valid"), nil
+	}
+
+	if err := h.srv.ResendVerification(ctx, id); err != nil {
+		log.Errorf("error resending verification of user %s: %v", id, err)
+		tracing.Error(span, err)
+		return h.errorResponse(req, operationResend, err), nil
+	}
+
+	log.Infof("verification of user %s resent", id)
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusAccepted}, nil
+}
+
+func (h *handleImpl) userResponse(req events.APIGatewayProxyRequest, operation string, user domain.User) events.APIGatewayProxyResponse {
+	res, err := json.Marshal(dto.NewUserResponse(user, nil))
+	if err != nil {
+		return h.errorResponse(req, operation, err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: string(res),
+	}
+}
+
+// errDecoding is answered with 400.
+var errDecoding = errors.New("body is not valid json")
+
+func decodeRequest(req events.APIGatewayProxyRequest, v any) error {
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return errDecoding
+		}
+		body = decoded
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return errDecoding
+	}
+	return nil
+}
+
+func (h *handleImpl) problem(req events.APIGatewayProxyRequest, status int, detail string) events.APIGatewayProxyResponse {
+	p := problem.New(status, detail)
+	p.Instance = req.Path
+	return p.Response(nil)
+}
+
+func (h *handleImpl) errorResponse(req events.APIGatewayProxyRequest, operation string, err error) events.APIGatewayProxyResponse {
+	var tooSoon *verification.TooSoonError
+	switch {
+	case errors.Is(err, service.ErrInvalidReason):
+		h.metrics.Increment(operation, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrForbidden):
+		h.metrics.Increment(operation, metrics.MetricForbidden)
+		return auth.Forbidden(req, "callers can only change their own user", nil)
+	case errors.Is(err, service.ErrInvalidToken):
+		// unknown, used and expired tokens are not told apart
+		h.metrics.Increment(operation, metrics.MetricValidationFailure)
+		return h.problem(req, http.StatusBadRequest, "token is not valid or has expired")
+	case errors.Is(err, service.ErrNotFound):
+		h.metrics.Increment(operation, metrics.MetricNotFound)
+		return h.problem(req, http.StatusNotFound, "user not found")
+	case errors.Is(err, service.ErrIllegalTransition):
+		h.metrics.Increment(operation, metrics.MetricConflict)
+		return h.problem(req, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrPreconditionFailed):
+		h.metrics.Increment(operation, metrics.MetricConflict)
+		return h.problem(req, http.StatusPreconditionFailed, "the user changed since it was read, read it again")
+	case errors.As(err, &tooSoon):
+		h.metrics.Increment(operation, metrics.MetricThrottled)
+		p := problem.New(http.StatusTooManyRequests, "a verification email was sent recently, retry later")
+		p.Instance = req.Path
+		retryAfter := int(math.Ceil(tooSoon.RetryAfter.Seconds()))
+		return p.Response(map[string]string{ratelimit.HeaderRetryAfter: strconv.Itoa(retryAfter)})
+	case errors.Is(err, clientopts.ErrUnavailable):
+		return h.problem(req, http.StatusServiceUnavailable, "service unavailable, retry later")
+	default:
+		return h.problem(req, http.StatusInternalServerError, "the request could not be completed")
+	}
+}
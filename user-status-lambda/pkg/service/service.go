@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxReasonLength bounds the reason recorded with a transition, in runes.
+const MaxReasonLength = 500
+
+var (
+	ErrNotFound = userstore.ErrNotFound
+	// ErrIllegalTransition is returned when the user is in a status the
+	// action does not leave from.
+	ErrIllegalTransition = errors.New("illegal status transition")
+	ErrInvalidReason     = fmt.Errorf("reason is required and at most %d characters", MaxReasonLength)
+	// ErrForbidden is returned when the caller does not own the user.
+	ErrForbidden = auth.ErrForbidden
+	// ErrPreconditionFailed is returned when the precondition of a
+	// transition rejects the current user.
+	ErrPreconditionFailed = errors.New("user changed since it was read")
+	ErrInvalidToken       = verification.ErrInvalidToken
+	ErrTooSoon            = verification.ErrTooSoon
+)
+
+// reasonVerified is recorded with the verification of a user.
+const reasonVerified = "email verified"
+
+// Action is a transition requested by a caller.
+type Action string
+
+const (
+	ActionSuspend    Action = "suspend"
+	ActionReactivate Action = "reactivate"
+	ActionClose      Action = "close"
+	// ActionVerify is applied by Verify only, with the token of the user.
+	ActionVerify Action = "verify"
+)
+
+type transition struct {
+	from  []domain.Status
+	to    domain.Status
+	event events.Type
+}
+
+// transitions is the state machine of the accounts, closed is final. A
+// pending user only leaves its status through the verification or closure.
+var transitions = map[Action]transition{
+	ActionSuspend: {
+		from:  []domain.Status{domain.StatusActive},
+		to:    domain.StatusSuspended,
+		event: events.TypeUserSuspended,
+	},
+	ActionReactivate: {
+		from:  []domain.Status{domain.StatusSuspended},
+		to:    domain.StatusActive,
+		event: events.TypeUserReactivated,
+	},
+	ActionClose: {
+		from:  []domain.Status{domain.StatusPendingVerification, domain.StatusActive, domain.StatusSuspended},
+		to:    domain.StatusClosed,
+		event: events.TypeUserClosed,
+	},
+	ActionVerify: {
+		from:  []domain.Status{domain.StatusPendingVerification},
+		to:    domain.StatusActive,
+		event: events.TypeUserVerified,
+	},
+}
+
+// CanTransition tells whether action leaves from status.
+func CanTransition(action Action, status domain.Status) bool {
+	t, ok := transitions[action]
+	if !ok {
+		return false
+	}
+	for _, from := range t.from {
+		if from == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Precondition tells whether a transition may apply to the current user,
+// such as the If-Match of the request. A nil one always passes.
+type Precondition func(user domain.User) bool
+
+type Service interface {
+	// Transition applies action to the user with the id and records the
+	// reason, the event is published once the status is stored.
+	// ErrPreconditionFailed when precondition rejects the user.
+	Transition(ctx context.Context, id string, action Action, reason string, precondition Precondition) (*domain.User, error)
+	// Verify activates the user of the verification token and consumes it,
+	// the token stays usable when the activation fails.
+	Verify(ctx context.Context, token string) (*domain.User, error)
+	// ResendVerification mails a new token to the pending user with the id,
+	// ErrTooSoon within the resend interval of the last one.
+	ResendVerification(ctx context.Context, id string) error
+}
+
+type serviceImpl struct {
+	store     userstore.UserRepository
+	publisher events.Publisher
+	verifier  verification.Verifier
+	log       logging.Logger
+	clock     clock.Clock
+}
+
+func New(store userstore.UserRepository, publisher events.Publisher, verifier verification.Verifier, log logging.Logger, clk clock.Clock) Service {
+	return &serviceImpl{
+		store:     store,
+		publisher: publisher,
+		verifier:  verifier,
+		log:       log,
+		clock:     clk,
+	}
+}
+
+func (s *serviceImpl) Transition(ctx context.Context, id string, action Action, reason string, precondition Precondition) (*domain.User, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || utf8.RuneCountInString(reason) > MaxReasonLength {
+		return nil, ErrInvalidReason
+	}
+	if action == ActionVerify {
+		return nil, fmt.Errorf("%w: a user is verified with its token", ErrIllegalTransition)
+	}
+	return s.apply(ctx, id, action, reason, precondition)
+}
+
+func (s *serviceImpl) Verify(ctx context.Context, token string) (*domain.User, error) {
+	id, err := s.verifier.Check(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// the token proves the caller is the user, who has no identity yet. It
+	// is used up once the user is verified, a failed transition leaves it
+	// for a retry
+	user, err := s.apply(audit.WithActor(ctx, id), id, ActionVerify, reasonVerified, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = s.verifier.Consume(ctx, token); err != nil {
+		// the user is active, the token cannot verify it again
+		s.log.WithContext(ctx).Warnf("error consuming the verification token of user %s: %v", id, err)
+	}
+	return user, nil
+}
+
+func (s *serviceImpl) ResendVerification(ctx context.Context, id string) error {
+	stored, err := s.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	user := stored.ToDomain()
+	if !callerOwns(ctx, user) {
+		return fmt.Errorf("%w: cannot resend the verification of user %s", ErrForbidden, id)
+	}
+	if user.Status != domain.StatusPendingVerification {
+		return fmt.Errorf("%w: a %s user is not verified", ErrIllegalTransition, user.Status)
+	}
+	return s.verifier.Issue(ctx, user)
+}
+
+// callerOwns tells whether the caller of ctx created user or is an admin,
+// the calls without identity are trusted.
+func callerOwns(ctx context.Context, user domain.User) bool {
+	identity, ok := auth.FromContext(ctx)
+	return !ok || identity.CanAccessUser(user.OwnerSubject)
+}
+
+// apply moves the user with the id through the transition of action when
+// precondition passes.
+func (s *serviceImpl) apply(ctx context.Context, id string, action Action, reason string, precondition Precondition) (*domain.User, error) {
+	log := s.log.WithContext(ctx)
+	t, ok := transitions[action]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrIllegalTransition, action)
+	}
+
+	stored, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user := stored.ToDomain()
+	// suspensions are decided by the admins upfront, a closure by the owner
+	// as well
+	if action == ActionClose && !callerOwns(ctx, user) {
+		return nil, fmt.Errorf("%w: cannot close user %s", ErrForbidden, id)
+	}
+	if precondition != nil && !precondition(user) {
+		log.Warnf("precondition of %s failed for user %s", action, id)
+		return nil, ErrPreconditionFailed
+	}
+	if !CanTransition(action, user.Status) {
+		log.Warnf("user %s cannot %s from %s", id, action, user.Status)
+		return nil, fmt.Errorf("%w: cannot %s a %s user", ErrIllegalTransition, action, user.Status)
+	}
+
+	// the change is conditional on the status read above, a concurrent
+	// transition makes it fail instead of being overwritten. The
+	// precondition held on the user as read, so any update since fails it
+	// even when the status came back
+	now := s.clock.Now()
+	change := userstore.StatusChange{
+		From:      string(user.Status),
+		To:        string(t.to),
+		Reason:    reason,
+		UpdatedAt: now,
+	}
+	if precondition != nil {
+		change.Seen = user.UpdatedAt
+	}
+	err = s.store.UpdateStatus(ctx, id, change)
+	if precondition != nil && errors.Is(err, userstore.ErrStatusConflict) {
+		log.Warnf("user %s changed since its precondition passed", id)
+		return nil, ErrPreconditionFailed
+	}
+	if errors.Is(err, userstore.ErrStatusConflict) {
+		log.Warnf("status of user %s changed concurrently", id)
+		return nil, fmt.Errorf("%w: the status of the user changed, retry", ErrIllegalTransition)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	event := events.Event{
+		Type:       t.event,
+		UserID:     id,
+		From:       string(user.Status),
+		To:         string(t.to),
+		Reason:     reason,
+		Actor:      audit.Actor(ctx),
+		RequestID:  audit.RequestID(ctx),
+		OccurredAt: now,
+	}
+	// the transition is stored, a lost event is logged rather than failing
+	// a request that cannot be rolled back
+	if err = s.publisher.Publish(ctx, event); err != nil {
+		log.Errorf("error publishing %s of user %s: %v", event.Type, id, err)
+	}
+
+	user.Status, user.StatusReason, user.UpdatedAt = t.to, reason, now
+	return &user, nil
+}
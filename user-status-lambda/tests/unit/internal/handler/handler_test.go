@@ -0,0 +1,255 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/conditional"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dto"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/problem"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/utils/encoding"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"github.com/ricardojonathanromero/lambda-golang-example/user-status-lambda/internal/handler"
+	"github.com/ricardojonathanromero/lambda-golang-example/user-status-lambda/pkg/service"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) Transition(ctx context.Context, id string, action service.Action, reason string, precondition service.Precondition) (*domain.User, error) {
+	args := m.Called(ctx, id, action, reason, precondition)
+	user, _ := args.Get(0).(*domain.User)
+	return user, args.Error(1)
+}
+
+func (m *MockService) Verify(ctx context.Context, token string) (*domain.User, error) {
+	args := m.Called(ctx, token)
+	user, _ := args.Get(0).(*domain.User)
+	return user, args.Error(1)
+}
+
+func (m *MockService) ResendVerification(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+var _ = Describe("Handler", func() {
+	var mockService *MockService
+	var sink *metrics.MemorySink
+	var h handler.Handler
+
+	appName := "user-status-lambda-handler-test"
+	id := "usr_0190a5e4-5b1c-7000-8000-000000000001"
+
+	request := func(resource, body string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			Resource:       resource,
+			Path:           strings.Replace(resource, "{id}", id, 1),
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"id": id},
+			Body:           body,
+		}
+	}
+	admin := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "admin-1", Admin: true})
+	owner := auth.WithIdentity(context.Background(), &auth.Identity{Subject: id})
+
+	BeforeEach(func() {
+		log := logging.New(logging.Opts{AppName: appName, Level: "error"})
+		mockService = new(MockService)
+		sink = metrics.NewMemorySink()
+		h = handler.New(mockService, log, metrics.New(appName, sink), time.UTC)
+	})
+
+	It("applies the transition of the resource", func() {
+		now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		user := &domain.User{ID: id, Name: "john", Status: domain.StatusSuspended, StatusReason: "spam", CreatedAt: now, UpdatedAt: now}
+		mockService.On("Transition", mock.Anything, id, service.ActionSuspend, "spam", mock.Anything).Return(user, nil)
+
+		res, err := h.HandleRequest(admin, request(handler.ResourceSuspend, `{"reason": "spam"}`))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+		var actual dto.UserResponse
+		Expect(json.Unmarshal([]byte(res.Body), &actual)).To(Succeed())
+		Expect(actual.Status).To(Equal("suspended"))
+		Expect(actual.StatusReason).To(Equal("spam"))
+	})
+
+	It("decodes a base64 encoded body", func() {
+		mockService.On("Transition", mock.Anything, id, service.ActionReactivate, "appeal", mock.Anything).Return(&domain.User{ID: id}, nil)
+
+		req := request(handler.ResourceReactivate, base64.StdEncoding.EncodeToString([]byte(`{"reason": "appeal"}`)))
+		req.IsBase64Encoded = true
+		res, err := h.HandleRequest(admin, req)
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("lets the owner close the account", func() {
+		mockService.On("Transition", mock.Anything, id, service.ActionClose, "leaving", mock.Anything).Return(&domain.User{ID: id, Status: domain.StatusClosed}, nil)
+
+		res, err := h.HandleRequest(owner, request(handler.ResourceClose, `{"reason": "leaving"}`))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("forbids the suspensions to the non admins", func() {
+		for _, resource := range []string{handler.ResourceSuspend, handler.ResourceReactivate} {
+			res, err := h.HandleRequest(owner, request(resource, `{"reason": "spam"}`))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusForbidden))
+		}
+
+		Expect(sink.Sum("HandleTransition", metrics.MetricForbidden)).To(Equal(float64(2)))
+		mockService.AssertNotCalled(GinkgoT(), "Transition")
+	})
+
+	It("forbids the closure of the users of others", func() {
+		other := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "usr-other"})
+		mockService.On("Transition", mock.Anything, id, service.ActionClose, "spam", mock.Anything).Return(nil, fmt.Errorf("%w: cannot close user", service.ErrForbidden))
+
+		res, err := h.HandleRequest(other, request(handler.ResourceClose, `{"reason": "spam"}`))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(sink.Sum("HandleTransition", metrics.MetricForbidden)).To(Equal(float64(1)))
+	})
+
+	It("rejects the invalid requests", func() {
+		res, err := h.HandleRequest(admin, request(handler.ResourceSuspend, `not json`))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(res.Headers["Content-Type"]).To(Equal(problem.ContentType))
+
+		req := request(handler.ResourceSuspend, `{"reason": "spam"}`)
+		req.PathParameters["id"] = "not-an-id"
+		res, err = h.HandleRequest(admin, req)
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+
+		res, err = h.HandleRequest(admin, request("/users/{id}/delete", `{"reason": "spam"}`))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusNotFound))
+	})
+
+	It("verifies the user of the token without identity", func() {
+		mockService.On("Verify", mock.Anything, "usr-1.secret").Return(&domain.User{ID: id, Status: domain.StatusActive}, nil)
+
+		res, err := h.HandleRequest(context.Background(), request(handler.ResourceVerify, `{"token": "usr-1.secret"}`))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+		var actual dto.UserResponse
+		Expect(json.Unmarshal([]byte(res.Body), &actual)).To(Succeed())
+		Expect(actual.Status).To(Equal("active"))
+	})
+
+	It("rejects the invalid verifications", func() {
+		for _, body := range []string{`not json`, `{}`, `{"token": ""}`} {
+			res, err := h.HandleRequest(context.Background(), request(handler.ResourceVerify, body))
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+		}
+		mockService.AssertNotCalled(GinkgoT(), "Verify")
+
+		mockService.On("Verify", mock.Anything, "usr-1.used").Return(nil, service.ErrInvalidToken)
+		res, err := h.HandleRequest(context.Background(), request(handler.ResourceVerify, `{"token": "usr-1.used"}`))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(res.Body).NotTo(ContainSubstring("usr-1.used"))
+		Expect(sink.Sum("HandleVerify", metrics.MetricValidationFailure)).To(Equal(float64(4)))
+	})
+
+	It("resends the verification to the owner", func() {
+		mockService.On("ResendVerification", mock.Anything, id).Return(nil)
+
+		res, err := h.HandleRequest(owner, request(handler.ResourceResend, ``))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusAccepted))
+	})
+
+	It("forbids the resends to the users of others", func() {
+		mockService.On("ResendVerification", mock.Anything, id).Return(service.ErrForbidden)
+
+		other := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "usr-other"})
+		res, err := h.HandleRequest(other, request(handler.ResourceResend, ``))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(sink.Sum("HandleResend", metrics.MetricForbidden)).To(Equal(float64(1)))
+	})
+
+	It("throttles the resends within the interval", func() {
+		tooSoon := &verification.TooSoonError{RetryAfter: 1500 * time.Millisecond}
+		mockService.On("ResendVerification", mock.Anything, id).Return(tooSoon)
+
+		res, err := h.HandleRequest(admin, request(handler.ResourceResend, ``))
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(res.Headers["Retry-After"]).To(Equal("2"))
+		Expect(sink.Sum("HandleResend", metrics.MetricThrottled)).To(Equal(float64(1)))
+	})
+
+	It("compares If-Match with the ETag of the user read from get-document", func() {
+		now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		current := domain.User{ID: id, Name: "john", Status: domain.StatusActive, CreatedAt: now, UpdatedAt: now}
+		etag := conditional.ETag([]byte(encoding.ToString(dto.NewUserResponse(current, time.UTC))))
+
+		var precondition service.Precondition
+		mockService.On("Transition", mock.Anything, id, service.ActionSuspend, "spam", mock.Anything).
+			Run(func(args mock.Arguments) { precondition = args.Get(4).(service.Precondition) }).
+			Return(&domain.User{ID: id, Status: domain.StatusSuspended}, nil)
+
+		req := request(handler.ResourceSuspend, `{"reason": "spam"}`)
+		req.Headers = map[string]string{"if-match": etag}
+		res, err := h.HandleRequest(admin, req)
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+		Expect(precondition(current)).To(BeTrue())
+		changed := current
+		changed.UpdatedAt = now.Add(time.Minute)
+		Expect(precondition(changed)).To(BeFalse())
+	})
+
+	It("answers 412 when the user changed since it was read", func() {
+		mockService.On("Transition", mock.Anything, id, service.ActionSuspend, "spam", mock.Anything).Return(nil, service.ErrPreconditionFailed)
+
+		req := request(handler.ResourceSuspend, `{"reason": "spam"}`)
+		req.Headers = map[string]string{"If-Match": `"stale"`}
+		res, err := h.HandleRequest(admin, req)
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(http.StatusPreconditionFailed))
+		Expect(res.Headers["Content-Type"]).To(Equal(problem.ContentType))
+		Expect(sink.Sum("HandleTransition", metrics.MetricConflict)).To(Equal(float64(1)))
+	})
+
+	DescribeTable("maps the errors of the service",
+		func(err error, status int) {
+			mockService.On("Transition", mock.Anything, id, service.ActionClose, mock.Anything, mock.Anything).Return(nil, err)
+
+			res, handleErr := h.HandleRequest(admin, request(handler.ResourceClose, `{"reason": ""}`))
+			Expect(handleErr).To(BeNil())
+			Expect(res.StatusCode).To(Equal(status))
+			Expect(res.Headers["Content-Type"]).To(Equal(problem.ContentType))
+		},
+		Entry("invalid reason", service.ErrInvalidReason, http.StatusBadRequest),
+		Entry("not found", service.ErrNotFound, http.StatusNotFound),
+		Entry("illegal transition", fmt.Errorf("%w: cannot close a closed user", service.ErrIllegalTransition), http.StatusConflict),
+		Entry("unavailable", clientopts.ErrUnavailable, http.StatusServiceUnavailable),
+		Entry("unexpected", errors.New("boom"), http.StatusInternalServerError),
+	)
+})
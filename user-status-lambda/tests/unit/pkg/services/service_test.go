@@ -0,0 +1,322 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/audit"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/auth"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clientopts"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/clock"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/db"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/domain"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/dynamodbapi"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/events"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/fieldcrypt"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/logging"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/mailer"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/metrics"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/models"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/userstore"
+	"github.com/ricardojonathanromero/lambda-golang-example/internal/verification"
+	"github.com/ricardojonathanromero/lambda-golang-example/user-status-lambda/pkg/service"
+	"strings"
+	"time"
+)
+
+// recordingPublisher keeps the published events.
+type recordingPublisher struct {
+	published []events.Event
+	err       error
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event events.Event) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+// failingStore fails the status changes with err.
+type failingStore struct {
+	userstore.UserRepository
+	err error
+}
+
+func (s *failingStore) UpdateStatus(context.Context, string, userstore.StatusChange) error {
+	return s.err
+}
+
+// recordingMailer keeps the sent messages.
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+var _ = Describe("Service", func() {
+	var ctx context.Context
+	var store userstore.UserRepository
+	var publisher *recordingPublisher
+	var tokens verification.Store
+	var mail *recordingMailer
+	var clk *clock.Fake
+	var verifier verification.Verifier
+	var srv service.Service
+
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		ctx = audit.WithActor(context.Background(), "admin-1")
+		log := logging.New(logging.Opts{AppName: "user-status-lambda-service-test", Level: "error"})
+		opts := clientopts.New(clientopts.DefaultPolicy())
+
+		clk = clock.NewFake(created.Add(time.Hour))
+		client := dynamodbapi.NewInMemory()
+		Expect(db.New(client, log, opts, db.NewNoopCache()).ConfigureTable(ctx, "users")).To(Succeed())
+		store = userstore.NewDynamoDB(client, "users", log, metrics.NewNoop(), opts, fieldcrypt.NewNoop(), "", clk)
+
+		for _, user := range []*models.UserDB{
+			// stored before the lifecycle, so without status
+			{ID: "usr-1", Name: "john", CreatedAt: created, UpdatedAt: created},
+			{ID: "usr-2", Name: "jane", Status: string(domain.StatusSuspended), StatusReason: "spam", CreatedAt: created, UpdatedAt: created},
+			{ID: "usr-3", Name: "jim", Status: string(domain.StatusClosed), CreatedAt: created, UpdatedAt: created},
+			{ID: "usr-4", Name: "joe", Email: "joe@example.com", Status: string(domain.StatusPendingVerification), OwnerSubject: "sub-4", CreatedAt: created, UpdatedAt: created},
+		} {
+			Expect(store.Insert(ctx, user)).To(Succeed())
+		}
+
+		publisher = &recordingPublisher{}
+		tokens = verification.NewMemoryStore()
+		mail = &recordingMailer{}
+		var err error
+		verifier, err = verification.New(tokens, mail, clk, verification.Options{TTL: time.Hour, ResendInterval: time.Minute, LinkURL: "https://app.example.com/verify"})
+		Expect(err).To(BeNil())
+		srv = service.New(store, publisher, verifier, log, clk)
+	})
+
+	// issue stores a token of the user with the id, valid for an hour.
+	issue := func(id string) string {
+		token, hash, err := verification.NewToken(id)
+		Expect(err).To(BeNil())
+		Expect(tokens.Put(ctx, verification.Record{UserID: id, Hash: hash, IssuedAt: clk.Now(), ExpiresAt: clk.Now().Add(time.Hour).Unix()}, clk.Now())).To(Succeed())
+		return token
+	}
+
+	It("suspends an active user and publishes the event", func() {
+		user, err := srv.Transition(ctx, "usr-1", service.ActionSuspend, " spam ", nil)
+		Expect(err).To(BeNil())
+		Expect(user.Status).To(Equal(domain.StatusSuspended))
+		Expect(user.StatusReason).To(Equal("spam"))
+		Expect(user.UpdatedAt).To(Equal(clk.Now()))
+
+		stored, err := store.Get(ctx, "usr-1")
+		Expect(err).To(BeNil())
+		Expect(stored.Status).To(Equal(string(domain.StatusSuspended)))
+		Expect(stored.StatusReason).To(Equal("spam"))
+
+		Expect(publisher.published).To(Equal([]events.Event{{
+			Type:       events.TypeUserSuspended,
+			UserID:     "usr-1",
+			From:       string(domain.StatusActive),
+			To:         string(domain.StatusSuspended),
+			Reason:     "spam",
+			Actor:      "admin-1",
+			OccurredAt: clk.Now(),
+		}}))
+	})
+
+	It("reactivates a suspended user", func() {
+		user, err := srv.Transition(ctx, "usr-2", service.ActionReactivate, "appeal accepted", nil)
+		Expect(err).To(BeNil())
+		Expect(user.Status).To(Equal(domain.StatusActive))
+		Expect(publisher.published).To(HaveLen(1))
+		Expect(publisher.published[0].Type).To(Equal(events.TypeUserReactivated))
+	})
+
+	It("closes a user from every status but closed", func() {
+		for _, id := range []string{"usr-1", "usr-2", "usr-4"} {
+			user, err := srv.Transition(ctx, id, service.ActionClose, "requested by the user", nil)
+			Expect(err).To(BeNil())
+			Expect(user.Status).To(Equal(domain.StatusClosed))
+		}
+
+		_, err := srv.Transition(ctx, "usr-3", service.ActionClose, "again", nil)
+		Expect(err).To(MatchError(service.ErrIllegalTransition))
+	})
+
+	It("rejects the illegal transitions without writing", func() {
+		for _, tc := range []struct {
+			id     string
+			action service.Action
+		}{
+			{"usr-1", service.ActionReactivate},
+			{"usr-2", service.ActionSuspend},
+			{"usr-3", service.ActionReactivate},
+			{"usr-4", service.ActionSuspend},
+		} {
+			_, err := srv.Transition(ctx, tc.id, tc.action, "because", nil)
+			Expect(err).To(MatchError(service.ErrIllegalTransition), "%s of %s", tc.action, tc.id)
+		}
+
+		stored, err := store.Get(ctx, "usr-2")
+		Expect(err).To(BeNil())
+		Expect(stored.StatusReason).To(Equal("spam"))
+		Expect(publisher.published).To(BeEmpty())
+	})
+
+	It("requires a reason", func() {
+		for _, reason := range []string{"", "   ", strings.Repeat("r", service.MaxReasonLength+1)} {
+			_, err := srv.Transition(ctx, "usr-1", service.ActionSuspend, reason, nil)
+			Expect(err).To(MatchError(service.ErrInvalidReason))
+		}
+	})
+
+	It("returns not found for an unknown user", func() {
+		_, err := srv.Transition(ctx, "usr-9", service.ActionClose, "gone", nil)
+		Expect(err).To(MatchError(service.ErrNotFound))
+	})
+
+	It("keeps the transition when the event is lost", func() {
+		publisher.err = errors.New("queue unavailable")
+
+		user, err := srv.Transition(ctx, "usr-1", service.ActionSuspend, "spam", nil)
+		Expect(err).To(BeNil())
+		Expect(user.Status).To(Equal(domain.StatusSuspended))
+	})
+
+	It("verifies a pending user with its token once", func() {
+		token := issue("usr-4")
+
+		user, err := srv.Verify(context.Background(), token)
+		Expect(err).To(BeNil())
+		Expect(user.Status).To(Equal(domain.StatusActive))
+
+		stored, err := store.Get(ctx, "usr-4")
+		Expect(err).To(BeNil())
+		Expect(stored.Status).To(Equal(string(domain.StatusActive)))
+		Expect(publisher.published).To(Equal([]events.Event{{
+			Type:       events.TypeUserVerified,
+			UserID:     "usr-4",
+			From:       string(domain.StatusPendingVerification),
+			To:         string(domain.StatusActive),
+			Reason:     "email verified",
+			Actor:      "usr-4",
+			OccurredAt: clk.Now(),
+		}}))
+
+		_, err = srv.Verify(context.Background(), token)
+		Expect(err).To(MatchError(service.ErrInvalidToken))
+	})
+
+	It("keeps the token when the verification fails", func() {
+		token := issue("usr-4")
+		failing := service.New(&failingStore{UserRepository: store, err: errors.New("dynamodb unavailable")}, publisher, verifier, logging.New(logging.Opts{AppName: "user-status-lambda-service-test", Level: "error"}), clk)
+
+		_, err := failing.Verify(context.Background(), token)
+		Expect(err).To(MatchError("dynamodb unavailable"))
+
+		user, err := srv.Verify(context.Background(), token)
+		Expect(err).To(BeNil())
+		Expect(user.Status).To(Equal(domain.StatusActive))
+		_, err = srv.Verify(context.Background(), token)
+		Expect(err).To(MatchError(service.ErrInvalidToken))
+	})
+
+	It("rejects the expired and malformed tokens", func() {
+		token := issue("usr-4")
+		clk.Advance(time.Hour)
+
+		for _, t := range []string{token, "", "usr-4.abc"} {
+			_, err := srv.Verify(ctx, t)
+			Expect(err).To(MatchError(service.ErrInvalidToken))
+		}
+		Expect(publisher.published).To(BeEmpty())
+	})
+
+	It("verifies the pending users only", func() {
+		_, err := srv.Verify(ctx, issue("usr-2"))
+		Expect(err).To(MatchError(service.ErrIllegalTransition))
+
+		_, err = srv.Transition(ctx, "usr-4", service.ActionVerify, "no token", nil)
+		Expect(err).To(MatchError(service.ErrIllegalTransition))
+	})
+
+	It("resends the verification within its interval", func() {
+		Expect(srv.ResendVerification(ctx, "usr-4")).To(Succeed())
+		Expect(mail.sent).To(HaveLen(1))
+		Expect(mail.sent[0].To).To(Equal("joe@example.com"))
+
+		Expect(srv.ResendVerification(ctx, "usr-4")).To(MatchError(service.ErrTooSoon))
+		clk.Advance(time.Minute)
+		Expect(srv.ResendVerification(ctx, "usr-4")).To(Succeed())
+		Expect(mail.sent).To(HaveLen(2))
+
+		Expect(srv.ResendVerification(ctx, "usr-1")).To(MatchError(service.ErrIllegalTransition))
+		Expect(srv.ResendVerification(ctx, "usr-9")).To(MatchError(service.ErrNotFound))
+	})
+
+	It("lets the owners only close and resend to their own users", func() {
+		owner := auth.WithIdentity(ctx, &auth.Identity{Subject: "sub-4"})
+		other := auth.WithIdentity(ctx, &auth.Identity{Subject: "sub-1"})
+
+		Expect(srv.ResendVerification(other, "usr-4")).To(MatchError(service.ErrForbidden))
+		_, err := srv.Transition(other, "usr-4", service.ActionClose, "requested by the user", nil)
+		Expect(err).To(MatchError(service.ErrForbidden))
+		Expect(mail.sent).To(BeEmpty())
+		Expect(publisher.published).To(BeEmpty())
+
+		Expect(srv.ResendVerification(owner, "usr-4")).To(Succeed())
+		user, err := srv.Transition(owner, "usr-4", service.ActionClose, "requested by the user", nil)
+		Expect(err).To(BeNil())
+		Expect(user.Status).To(Equal(domain.StatusClosed))
+	})
+
+	It("rejects the transitions whose precondition fails", func() {
+		var checked domain.User
+		_, err := srv.Transition(ctx, "usr-1", service.ActionSuspend, "spam", func(user domain.User) bool {
+			checked = user
+			return false
+		})
+		Expect(err).To(MatchError(service.ErrPreconditionFailed))
+		Expect(checked.ID).To(Equal("usr-1"))
+		Expect(publisher.published).To(BeEmpty())
+
+		stored, err := store.Get(ctx, "usr-1")
+		Expect(err).To(BeNil())
+		Expect(stored.ToDomain().Status).To(Equal(domain.StatusActive))
+	})
+
+	It("rejects the transitions whose user changed since its precondition passed", func() {
+		// suspended and reactivated between the read and the write, so in
+		// the status the precondition was checked on
+		_, err := srv.Transition(ctx, "usr-1", service.ActionSuspend, "spam", func(domain.User) bool {
+			_, err := srv.Transition(ctx, "usr-1", service.ActionSuspend, "spam", nil)
+			Expect(err).To(BeNil())
+			clk.Advance(time.Minute)
+			_, err = srv.Transition(ctx, "usr-1", service.ActionReactivate, "appeal accepted", nil)
+			Expect(err).To(BeNil())
+			return true
+		})
+		Expect(err).To(MatchError(service.ErrPreconditionFailed))
+		Expect(publisher.published).To(HaveLen(2))
+
+		stored, err := store.Get(ctx, "usr-1")
+		Expect(err).To(BeNil())
+		Expect(stored.ToDomain().Status).To(Equal(domain.StatusActive))
+	})
+
+	It("describes the state machine", func() {
+		Expect(service.CanTransition(service.ActionSuspend, domain.StatusActive)).To(BeTrue())
+		Expect(service.CanTransition(service.ActionSuspend, domain.StatusPendingVerification)).To(BeFalse())
+		Expect(service.CanTransition(service.ActionClose, domain.StatusClosed)).To(BeFalse())
+		Expect(service.CanTransition(service.ActionVerify, domain.StatusPendingVerification)).To(BeTrue())
+		Expect(service.CanTransition(service.ActionVerify, domain.StatusActive)).To(BeFalse())
+		Expect(service.CanTransition(service.Action("delete"), domain.StatusActive)).To(BeFalse())
+	})
+})